@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"repo-pack/config"
+)
+
+// runAlias handles `repo-pack alias <add|remove|list>`, naming URLs that are pulled often so
+// they can be given as @name
+func runAlias(args []string) error {
+	usage := fmt.Errorf("usage: repo-pack alias add <name> <url> | remove <name> | list")
+	if len(args) < 1 {
+		return usage
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return fmt.Errorf("error locating config file: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	switch action, args := args[0], args[1:]; {
+	case action == "add" && len(args) == 2:
+		if err := cfg.SetAlias(args[0], args[1]); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "remove" && len(args) == 1:
+		if err := cfg.RemoveAlias(args[0]); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "list" && len(args) == 0:
+		names := make([]string, 0, len(cfg.Aliases))
+		for name := range cfg.Aliases {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Printf("@%s\t%s\n", name, cfg.Aliases[name])
+		}
+		return nil
+	}
+	return usage
+}
+
+// resolveAlias returns the URL an @name argument stands for, leaving other arguments as they are
+func resolveAlias(arg string) (string, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return arg, nil
+	}
+	path, err := config.Path()
+	if err != nil {
+		return "", fmt.Errorf("error locating config file: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return "", err
+	}
+	return cfg.Resolve(arg)
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// EnvTokens are the environment variables a github.com token is read from, in order of precedence
+var EnvTokens = []string{"GITHUB_TOKEN", "GH_TOKEN"}
+
+// EnterpriseEnvTokens are the environment variables a token for any other host, such as a
+// GitHub Enterprise Server, is read from, in order of precedence, as the gh CLI reads them
+var EnterpriseEnvTokens = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+
+// Resolve returns the token to use for host, such as github.com or a GitHub Enterprise Server
+// hostname. The first of these that is set wins:
+//
+//  1. explicit, the token given with --token or in the config
+//  2. the GITHUB_TOKEN and GH_TOKEN environment variables for github.com and GHE.com
+//     subdomains, and GH_ENTERPRISE_TOKEN and GITHUB_ENTERPRISE_TOKEN for other hosts
+//  3. the token the gh CLI stores for host in its hosts.yml
+//
+// A CI token for github.com is thus never sent to another host. It returns "" when none is
+// set, which is fine for public repositories. A hosts.yml that can't be read is an error; a
+// missing one isn't.
+func Resolve(explicit, host string) (string, error) {
+	if explicit != "" {
+		return explicit, nil
+	}
+	names := EnterpriseEnvTokens
+	if isGitHubCom(host) {
+		names = EnvTokens
+	}
+	for _, name := range names {
+		if token := strings.TrimSpace(os.Getenv(name)); token != "" {
+			return token, nil
+		}
+	}
+
+	path, err := HostsPath()
+	if err != nil {
+		return "", nil
+	}
+	token, err := HostsToken(path, host)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return token, err
+}
+
+// isGitHubCom reports whether host is github.com or a GHE.com subdomain, which the gh CLI
+// gives the github.com environment variables too
+func isGitHubCom(host string) bool {
+	host = strings.ToLower(host)
+	return host == "github.com" || strings.HasSuffix(host, ".ghe.com")
+}
+
+// HostsPath returns where the gh CLI keeps its hosts.yml: in $GH_CONFIG_DIR when set, and
+// otherwise in its directory under $XDG_CONFIG_HOME, %AppData% on Windows or ~/.config
+func HostsPath() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml"), nil
+	}
+	if dir := os.Getenv("AppData"); runtime.GOOS == "windows" && dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml"), nil
+}
+
+// HostsToken reads the oauth_token of host's active account from the gh CLI hosts.yml at path.
+// It returns "" when host isn't logged in or gh keeps the token in the system keyring instead.
+// Only the flat mapping gh writes is understood, not YAML in general.
+func HostsToken(path, host string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var inHost bool
+	var indent string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		key, value, _ := strings.Cut(trimmed, ":")
+		key, value = unquote(key), unquote(strings.TrimSpace(value))
+
+		lineIndent := line[:len(line)-len(trimmed)]
+		if lineIndent == "" {
+			inHost, indent = key == host, ""
+			continue
+		}
+		if !inHost {
+			continue
+		}
+		// Only the host's own keys count, not those of the accounts nested under users:
+		if indent == "" {
+			indent = lineIndent
+		}
+		if lineIndent == indent && key == "oauth_token" {
+			return value, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("error reading %s: %v", path, err)
+	}
+	return "", nil
+}
+
+// unquote strips the quotes YAML allows around a scalar
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
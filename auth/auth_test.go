@@ -0,0 +1,129 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"repo-pack/auth"
+	"testing"
+)
+
+const hostsYAML = `github.com:
+    users:
+        octocat:
+            oauth_token: gho_other
+    oauth_token: gho_public
+    git_protocol: https
+    user: octocat
+"ghe.example.com":
+    user: work
+    oauth_token: "ghp_work"
+keyring.example.com:
+    user: someone
+    git_protocol: ssh
+`
+
+// isolate clears every token source, pointing the gh CLI config at a directory holding hosts
+func isolate(t *testing.T, hosts string) {
+	t.Helper()
+	for _, name := range append(auth.EnvTokens, auth.EnterpriseEnvTokens...) {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	t.Setenv("GH_CONFIG_DIR", dir)
+	if hosts != "" {
+		if err := os.WriteFile(filepath.Join(dir, "hosts.yml"), []byte(hosts), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func resolve(t *testing.T, explicit, host string) string {
+	t.Helper()
+	token, err := auth.Resolve(explicit, host)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return token
+}
+
+func TestResolveExplicit(t *testing.T) {
+	isolate(t, hostsYAML)
+	t.Setenv("GITHUB_TOKEN", "env")
+	if token := resolve(t, "flag", "github.com"); token != "flag" {
+		t.Errorf("expected the explicit token to win, got %q", token)
+	}
+}
+
+func TestResolveGitHubToken(t *testing.T) {
+	isolate(t, hostsYAML)
+	t.Setenv("GITHUB_TOKEN", "github-env")
+	t.Setenv("GH_TOKEN", "gh-env")
+	if token := resolve(t, "", "github.com"); token != "github-env" {
+		t.Errorf("expected GITHUB_TOKEN to win over GH_TOKEN and hosts.yml, got %q", token)
+	}
+}
+
+func TestResolveGHToken(t *testing.T) {
+	isolate(t, hostsYAML)
+	t.Setenv("GH_TOKEN", " gh-env\n")
+	if token := resolve(t, "", "github.com"); token != "gh-env" {
+		t.Errorf("expected GH_TOKEN to win over hosts.yml, got %q", token)
+	}
+}
+
+func TestResolveEnterpriseToken(t *testing.T) {
+	isolate(t, hostsYAML)
+	t.Setenv("GITHUB_TOKEN", "github-env")
+	for host, expected := range map[string]string{
+		// A github.com token is never sent elsewhere, whether or not hosts.yml has one
+		"ghe.example.com":   "ghp_work",
+		"other.example.com": "",
+		"acme.ghe.com":      "github-env",
+	} {
+		if token := resolve(t, "", host); token != expected {
+			t.Errorf("expected token %q for %s, got %q", expected, host, token)
+		}
+	}
+
+	t.Setenv("GITHUB_ENTERPRISE_TOKEN", "github-enterprise-env")
+	t.Setenv("GH_ENTERPRISE_TOKEN", "gh-enterprise-env")
+	if token := resolve(t, "", "ghe.example.com"); token != "gh-enterprise-env" {
+		t.Errorf("expected GH_ENTERPRISE_TOKEN to win for an Enterprise host, got %q", token)
+	}
+	if token := resolve(t, "", "github.com"); token != "github-env" {
+		t.Errorf("expected Enterprise tokens not to be sent to github.com, got %q", token)
+	}
+}
+
+func TestResolveHostsFile(t *testing.T) {
+	isolate(t, hostsYAML)
+	for host, expected := range map[string]string{
+		"github.com":          "gho_public",
+		"ghe.example.com":     "ghp_work",
+		"keyring.example.com": "",
+		"other.example.com":   "",
+	} {
+		if token := resolve(t, "", host); token != expected {
+			t.Errorf("expected token %q for %s, got %q", expected, host, token)
+		}
+	}
+}
+
+func TestResolveNothing(t *testing.T) {
+	isolate(t, "")
+	if token := resolve(t, "", "github.com"); token != "" {
+		t.Errorf("expected no token without a hosts.yml, got %q", token)
+	}
+}
+
+func TestHostsPath(t *testing.T) {
+	t.Setenv("GH_CONFIG_DIR", "")
+	t.Setenv("XDG_CONFIG_HOME", "xdg")
+	path, err := auth.HostsPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join("xdg", "gh", "hosts.yml"); path != expected {
+		t.Errorf("expected %s, got %s", expected, path)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+)
+
+// openCache opens the cache at dir, or the default per-user cache when dir is empty
+func openCache(dir string) (*gh.FileCache, error) {
+	if dir == "" {
+		defaultDir, err := gh.DefaultCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("error locating cache directory: %v", err)
+		}
+		dir = defaultDir
+	}
+	return gh.NewFileCache(dir)
+}
+
+// runCache handles `repo-pack cache <export|import|clear|stats> [flags] [archive]`
+func runCache(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: repo-pack cache <export|import|clear|stats> [--cache-dir dir] [archive.tar.gz]")
+	}
+
+	action := args[0]
+	flags := flag.NewFlagSet("cache "+action, flag.ExitOnError)
+	cacheDir := flags.String("cache-dir", "", "Cache directory (defaults to the user cache directory)")
+	if err := parseFlags(flags, args[1:]); err != nil {
+		return err
+	}
+
+	archiveArgs := 0
+	if action == "export" || action == "import" {
+		archiveArgs = 1
+	}
+	if flags.NArg() != archiveArgs {
+		if archiveArgs == 0 {
+			return fmt.Errorf("usage: repo-pack cache %s [--cache-dir dir]", action)
+		}
+		return fmt.Errorf("usage: repo-pack cache %s [--cache-dir dir] <archive.tar.gz>", action)
+	}
+
+	cache, err := openCache(*cacheDir)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "export":
+		return exportCache(cache, flags.Arg(0))
+	case "import":
+		return importCache(cache, flags.Arg(0))
+	case "clear":
+		return clearCache(cache)
+	case "stats":
+		return printCacheStats(cache)
+	default:
+		return fmt.Errorf("unknown cache command: %s", action)
+	}
+}
+
+// clearCache empties the cache, reporting what was freed
+func clearCache(cache *gh.FileCache) error {
+	stats, err := cache.Stats()
+	if err != nil {
+		return fmt.Errorf("error reading cache: %v", err)
+	}
+	if err := cache.Clear(); err != nil {
+		return fmt.Errorf("error clearing cache: %v", err)
+	}
+	fmt.Printf("[-] Removed %d cached files (%s) from %s\n", stats.Objects, helpers.FormatByteSize(stats.Bytes), cache.Dir())
+	return nil
+}
+
+// printCacheStats reports where the cache is and how much it holds
+func printCacheStats(cache *gh.FileCache) error {
+	stats, err := cache.Stats()
+	if err != nil {
+		return fmt.Errorf("error reading cache: %v", err)
+	}
+	fmt.Printf("Directory: %s\nFiles:     %d\nSize:      %s\n", cache.Dir(), stats.Objects, helpers.FormatByteSize(stats.Bytes))
+	return nil
+}
+
+// exportCache writes the cache to an archive whose compression follows its extension
+func exportCache(cache *gh.FileCache, archive string) error {
+	file, err := os.Create(archive)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", archive, err)
+	}
+	defer file.Close()
+
+	w, err := helpers.CompressWriter(archive, file)
+	if err != nil {
+		os.Remove(archive)
+		return err
+	}
+
+	count, err := cache.Export(w)
+	if err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error finishing %s: %v", archive, err)
+	}
+
+	fmt.Printf("[-] Exported %d cached files to %s\n", count, archive)
+	return file.Close()
+}
+
+// importCache seeds the cache from an archive written by exportCache
+func importCache(cache *gh.FileCache, archive string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", archive, err)
+	}
+	defer file.Close()
+
+	r, err := helpers.DecompressReader(archive, file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	stats, err := cache.Import(r)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[-] Imported %d files into %s\n", stats.Added, cache.Dir())
+	if stats.LFS > 0 {
+		fmt.Printf("[-] %d of them are Git LFS files, checked against their pointers\n", stats.LFS)
+	}
+	if stats.Mismatched > 0 {
+		fmt.Printf("[-] Left out %d files whose content doesn't match the blob SHA they're named by\n", stats.Mismatched)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// compareContext is the number of unchanged lines shown around each change
+const compareContext = 3
+
+// fileChange is one file that differs between the two refs of a comparison
+type fileChange struct {
+	Kind byte // 'A'dded, 'D'eleted or 'M'odified
+	Path string
+}
+
+// runCompare handles `repo-pack compare <url> --base <ref> --head <ref>`, printing how a
+// directory changed between two refs without downloading either version to disk
+func runCompare(args []string) error {
+	flags := flag.NewFlagSet("compare", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	base := flags.String("base", "", "Ref to compare from, e.g. a tag or branch")
+	head := flags.String("head", "", "Ref to compare to")
+	stat := flags.Bool("stat", false, "Only list the changed files, without fetching their contents")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	// Flags may also follow the URL, as in `repo-pack compare <url> --base v1 --head v2`
+	if flags.NArg() > 1 {
+		repoArg := flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+		args = append([]string{repoArg}, flags.Args()...)
+	} else {
+		args = flags.Args()
+	}
+
+	if len(args) != 1 || *base == "" || *head == "" {
+		return fmt.Errorf("usage: repo-pack compare [--token token] [--stat] <url> --base <ref> --head <ref>")
+	}
+
+	repoURL, err := resolveAlias(args[0])
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		if components, err = helpers.ParseRepoRootURL(repoURL); err != nil {
+			return fmt.Errorf("failed to parse repository URL: %v", err)
+		}
+	}
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+	if err := detectPrivate(ctx, client, &components); err != nil {
+		return err
+	}
+
+	if err := client.ResolveURLRef(ctx, &components); err != nil {
+		return err
+	}
+
+	baseComponents, headComponents := components, components
+	baseComponents.Ref, headComponents.Ref = *base, *head
+	baseFiles, _, err := client.RepoListingSlashBranchSupport(ctx, &baseComponents)
+	if err != nil {
+		return fmt.Errorf("failed to list files at %s: %v", *base, err)
+	}
+	headFiles, _, err := client.RepoListingSlashBranchSupport(ctx, &headComponents)
+	if err != nil {
+		return fmt.Errorf("failed to list files at %s: %v", *head, err)
+	}
+
+	changes := compareListings(baseFiles, headFiles)
+	var added, removed, modified int
+	for _, change := range changes {
+		switch change.Kind {
+		case 'A':
+			added++
+		case 'D':
+			removed++
+		default:
+			modified++
+		}
+	}
+	dir := components.Dir
+	if dir == "" {
+		dir = components.Repository
+	}
+	fmt.Printf("[-] Comparing %s between %s and %s: %d added, %d removed, %d modified\n",
+		dir, *base, *head, added, removed, modified)
+	for _, change := range changes {
+		fmt.Printf("%c  %s\n", change.Kind, change.Path)
+	}
+	if *stat {
+		return nil
+	}
+
+	for _, change := range changes {
+		if err := printFileDiff(ctx, client, baseComponents, headComponents, change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareListings returns the files added, deleted or modified between two listings, by path,
+// telling modified files apart by their blob SHAs
+func compareListings(baseFiles, headFiles []model.FileInfo) []fileChange {
+	baseSHAs := make(map[string]string, len(baseFiles))
+	for _, file := range baseFiles {
+		baseSHAs[file.Path] = file.SHA
+	}
+
+	var changes []fileChange
+	for _, file := range headFiles {
+		sha, ok := baseSHAs[file.Path]
+		switch {
+		case !ok:
+			changes = append(changes, fileChange{Kind: 'A', Path: file.Path})
+		case sha != file.SHA:
+			changes = append(changes, fileChange{Kind: 'M', Path: file.Path})
+		}
+		delete(baseSHAs, file.Path)
+	}
+	for path := range baseSHAs {
+		changes = append(changes, fileChange{Kind: 'D', Path: path})
+	}
+
+	slices.SortFunc(changes, func(a, b fileChange) int { return strings.Compare(a.Path, b.Path) })
+	return changes
+}
+
+// printFileDiff fetches both versions of a changed file and prints a unified diff of them
+func printFileDiff(ctx context.Context, client *gh.Client, baseComponents, headComponents model.RepoURLComponents, change fileChange) error {
+	var oldContent, newContent []byte
+	var err error
+	oldName, newName := "a/"+change.Path, "b/"+change.Path
+	if change.Kind == 'A' {
+		oldName = "/dev/null"
+	} else if oldContent, err = client.RawFile(ctx, baseComponents, change.Path); err != nil {
+		return fmt.Errorf("failed to fetch %s at %s: %v", change.Path, baseComponents.Ref, err)
+	}
+	if change.Kind == 'D' {
+		newName = "/dev/null"
+	} else if newContent, err = client.RawFile(ctx, headComponents, change.Path); err != nil {
+		return fmt.Errorf("failed to fetch %s at %s: %v", change.Path, headComponents.Ref, err)
+	}
+
+	if helpers.IsBinaryContent(oldContent) || helpers.IsBinaryContent(newContent) {
+		fmt.Printf("Binary files %s and %s differ\n", oldName, newName)
+		return nil
+	}
+	os.Stdout.WriteString(helpers.UnifiedDiff(oldName, newName, oldContent, newContent, compareContext))
+	return nil
+}
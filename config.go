@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"repo-pack/config"
+)
+
+// runConfig handles `repo-pack config <set|get|unset|list|path|profile>`, editing the defaults
+// that config.json supplies for flags
+func runConfig(args []string) error {
+	usage := fmt.Errorf("usage: repo-pack config set <key> <value> | get <key> | unset <key> | list | path | profile ...")
+	if len(args) < 1 {
+		return usage
+	}
+
+	path, err := config.Path()
+	if err != nil {
+		return fmt.Errorf("error locating config file: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	switch action, args := args[0], args[1:]; {
+	case action == "set" && len(args) == 2:
+		if err := cfg.Set(args[0], args[1]); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "unset" && len(args) == 1:
+		if err := cfg.Set(args[0], ""); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "get" && len(args) == 1:
+		value, err := cfg.Get(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+	case action == "list" && len(args) == 0:
+		for _, key := range config.Keys() {
+			value, _ := cfg.Get(key)
+			if key == "token" && value != "" {
+				value = "(set)"
+			}
+			fmt.Printf("%s=%s\n", key, value)
+		}
+		return nil
+	case action == "path" && len(args) == 0:
+		fmt.Println(path)
+		return nil
+	case action == "profile":
+		return runConfigProfile(path, cfg, args)
+	}
+	return usage
+}
+
+// runConfigProfile handles `repo-pack config profile <set|unset|remove|list>`, editing the
+// named profiles selected with --profile
+func runConfigProfile(path string, cfg config.Config, args []string) error {
+	usage := fmt.Errorf("usage: repo-pack config profile set <name> <key> <value> | unset <name> <key> | remove <name> | list")
+	if len(args) < 1 {
+		return usage
+	}
+
+	switch action, args := args[0], args[1:]; {
+	case action == "set" && len(args) == 3:
+		if err := cfg.SetProfile(args[0], args[1], args[2]); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "unset" && len(args) == 2:
+		if err := cfg.SetProfile(args[0], args[1], ""); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "remove" && len(args) == 1:
+		if err := cfg.RemoveProfile(args[0]); err != nil {
+			return err
+		}
+		return config.Save(path, cfg)
+	case action == "list" && len(args) == 0:
+		for _, name := range cfg.ProfileNames() {
+			marker := ""
+			if name == cfg.Profile {
+				marker = " (default)"
+			}
+			fmt.Printf("%s%s\n", name, marker)
+			for _, key := range config.ProfileKeys() {
+				if value, _ := cfg.Profiles[name].Get(key); value != "" {
+					fmt.Printf("  %s=%s\n", key, value)
+				}
+			}
+		}
+		return nil
+	}
+	return usage
+}
@@ -0,0 +1,449 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPath names an environment variable overriding the config file's location
+const EnvPath = "REPO_PACK_CONFIG"
+
+// CurrentVersion is the config format written by this release. Files of an older version
+// are migrated when loaded.
+var CurrentVersion = len(migrations)
+
+// migrations upgrade a decoded config file from the version of their index to the next one,
+// renaming or converting keys as the format changes. Append one with every format change.
+var migrations = []func(raw map[string]json.RawMessage) error{
+	// Version 0 predates the version key and only needs it added
+	func(map[string]json.RawMessage) error { return nil },
+}
+
+// Config holds the defaults of repo-pack's flags, so they needn't be repeated on every run
+type Config struct {
+	// Version is the format the file was written in
+	Version     int    `json:"version"`
+	Token       string `json:"token,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+	CacheDir    string `json:"cache_dir,omitempty"`
+	APIBase     string `json:"api_base,omitempty"`
+	RawBase     string `json:"raw_base,omitempty"`
+	MediaBase   string `json:"media_base,omitempty"`
+	// Timeout is the --timeout duration, such as "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// HTTP2 is --http2, left unset to keep the flag's default
+	HTTP2 *bool `json:"http2,omitempty"`
+	// DefaultOutput is the --output template used when the flag isn't given
+	DefaultOutput string `json:"default_output,omitempty"`
+	// Aliases maps short names to the URLs they stand for as @name
+	Aliases map[string]string `json:"aliases,omitempty"`
+	// Profile names the profile used when --profile isn't given
+	Profile  string             `json:"profile,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+// field ties a config key to the flag it supplies the default of
+type field struct {
+	key, flag string
+	get       func(c *Config) string
+	set       func(c *Config, value string) error
+	// check validates a set value, given as get returns it
+	check func(value string) error
+}
+
+func stringField(key, flag string, ptr func(c *Config) *string, check func(value string) error) field {
+	return field{
+		key:  key,
+		flag: flag,
+		get:  func(c *Config) string { return *ptr(c) },
+		set: func(c *Config, value string) error {
+			*ptr(c) = value
+			return nil
+		},
+		check: check,
+	}
+}
+
+func anyString(string) error {
+	return nil
+}
+
+// duration checks a non-negative duration as time.ParseDuration reads them, such as "45s"
+func duration(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration such as 30s or 2m", value)
+	}
+	if d < 0 {
+		return fmt.Errorf("%q must not be negative", value)
+	}
+	return nil
+}
+
+func httpURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
+
+var fields = []field{
+	stringField("token", "token", func(c *Config) *string { return &c.Token }, anyString),
+	{
+		key:  "concurrency",
+		flag: "concurrency",
+		get: func(c *Config) string {
+			if c.Concurrency == 0 {
+				return ""
+			}
+			return strconv.Itoa(c.Concurrency)
+		},
+		set: func(c *Config, value string) error {
+			if value == "" {
+				c.Concurrency = 0
+				return nil
+			}
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("concurrency must be a number, got %q", value)
+			}
+			c.Concurrency = n
+			return nil
+		},
+		check: func(value string) error {
+			if n, _ := strconv.Atoi(value); n < 1 {
+				return fmt.Errorf("concurrency must be at least 1, got %s", value)
+			}
+			return nil
+		},
+	},
+	stringField("cache_dir", "cache-dir", func(c *Config) *string { return &c.CacheDir }, anyString),
+	stringField("api_base", "api-base", func(c *Config) *string { return &c.APIBase }, httpURL),
+	stringField("raw_base", "raw-base", func(c *Config) *string { return &c.RawBase }, httpURL),
+	stringField("media_base", "media-base", func(c *Config) *string { return &c.MediaBase }, httpURL),
+	stringField("timeout", "timeout", func(c *Config) *string { return &c.Timeout }, duration),
+	{
+		key:  "http2",
+		flag: "http2",
+		get: func(c *Config) string {
+			if c.HTTP2 == nil {
+				return ""
+			}
+			return strconv.FormatBool(*c.HTTP2)
+		},
+		set: func(c *Config, value string) error {
+			if value == "" {
+				c.HTTP2 = nil
+				return nil
+			}
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("http2 must be true or false, got %q", value)
+			}
+			c.HTTP2 = &enabled
+			return nil
+		},
+		check: anyString,
+	},
+	stringField("default_output", "output", func(c *Config) *string { return &c.DefaultOutput }, anyString),
+	stringField("profile", "profile", func(c *Config) *string { return &c.Profile }, checkProfileName),
+}
+
+func lookup(key string) (field, error) {
+	for _, f := range fields {
+		if f.key == key {
+			return f, nil
+		}
+	}
+	return field{}, fmt.Errorf("unknown config key %q, expected one of %s", key, strings.Join(Keys(), ", "))
+}
+
+// Keys returns the names of every config key, in the order they are listed
+func Keys() []string {
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// Get returns the value of key, or "" when it isn't set
+func (c *Config) Get(key string) (string, error) {
+	f, err := lookup(key)
+	if err != nil {
+		return "", err
+	}
+	return f.get(c), nil
+}
+
+// Set parses value into key; an empty value unsets it. An invalid value leaves c unchanged.
+func (c *Config) Set(key, value string) error {
+	f, err := lookup(key)
+	if err != nil {
+		return err
+	}
+	updated := *c
+	if err := f.set(&updated, value); err != nil {
+		return err
+	}
+	if value != "" {
+		if err := f.check(value); err != nil {
+			return err
+		}
+	}
+	if _, ok := c.Profiles[updated.Profile]; updated.Profile != "" && !ok {
+		return fmt.Errorf("no profile named %q", updated.Profile)
+	}
+	*c = updated
+	return nil
+}
+
+// SetAlias makes @name stand for rawURL, replacing any URL it stood for before
+func (c *Config) SetAlias(name, rawURL string) error {
+	if err := checkAlias(name, rawURL); err != nil {
+		return err
+	}
+	if c.Aliases == nil {
+		c.Aliases = map[string]string{}
+	}
+	c.Aliases[name] = rawURL
+	return nil
+}
+
+// RemoveAlias deletes the alias name
+func (c *Config) RemoveAlias(name string) error {
+	if _, ok := c.Aliases[name]; !ok {
+		return fmt.Errorf("no alias named %q", name)
+	}
+	delete(c.Aliases, name)
+	return nil
+}
+
+// Resolve returns the URL an @name argument stands for. Any other argument is returned as is.
+func (c *Config) Resolve(arg string) (string, error) {
+	name, ok := strings.CutPrefix(arg, "@")
+	if !ok {
+		return arg, nil
+	}
+	rawURL, ok := c.Aliases[name]
+	if !ok {
+		return "", fmt.Errorf("no alias named %q, add one with repo-pack alias add %s <url>", name, name)
+	}
+	return rawURL, nil
+}
+
+// checkAlias validates an alias name and the URL it stands for
+func checkAlias(name, rawURL string) error {
+	if name == "" || strings.ContainsAny(name, "@/ \t") {
+		return fmt.Errorf("alias name %q must be non-empty without @, / or spaces", name)
+	}
+	if err := httpURL(rawURL); err != nil {
+		return fmt.Errorf("alias %s: %v", name, err)
+	}
+	return nil
+}
+
+// FlagDefaults maps the name of every flag the config sets to its value
+func (c *Config) FlagDefaults() map[string]string {
+	defaults := map[string]string{}
+	for _, f := range fields {
+		if value := f.get(c); value != "" {
+			defaults[f.flag] = value
+		}
+	}
+	return defaults
+}
+
+// Path returns the config file's location: $REPO_PACK_CONFIG, or config.json under
+// repo-pack in the user config directory
+func Path() (string, error) {
+	if path := os.Getenv(EnvPath); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repo-pack", "config.json"), nil
+}
+
+// Load reads the config file at path. A missing file is an empty config. A file of an older
+// version is migrated and written back upgraded; failing to write it back only means it is
+// migrated again next time, so that error is ignored.
+func Load(path string) (Config, error) {
+	c := Config{Version: CurrentVersion}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("error reading config: %v", err)
+	}
+
+	upgraded, err := migrate(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("error migrating config %s: %v", path, err)
+	}
+	if c, err = Parse(upgraded); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if !bytes.Equal(upgraded, data) {
+		_ = Save(path, c)
+	}
+	return c, nil
+}
+
+// migrate upgrades config file data to CurrentVersion, returning it unchanged when it is
+// current. Data that doesn't decode is also returned as is, for Parse to report the error.
+func migrate(data []byte) ([]byte, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return data, nil
+	}
+	version := 0
+	if value, ok := raw["version"]; ok {
+		if err := json.Unmarshal(value, &version); err != nil {
+			return data, nil
+		}
+	}
+	if version < 0 || version >= CurrentVersion {
+		return data, nil
+	}
+
+	for ; version < CurrentVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, fmt.Errorf("version %d: %v", version, err)
+		}
+	}
+	raw["version"] = json.RawMessage(strconv.Itoa(CurrentVersion))
+	return json.MarshalIndent(raw, "", "  ")
+}
+
+// Parse decodes and validates a config file. Unknown keys, values of the wrong type and values
+// out of range are rejected with the line they appear on, rather than left to fail as flags.
+func Parse(data []byte) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.As(err, &syntaxErr):
+			return Config{}, fmt.Errorf("line %d: %v", lineAt(data, syntaxErr.Offset), err)
+		case errors.As(err, &typeErr):
+			return Config{}, fmt.Errorf("line %d: %s must be %s, got %s",
+				lineAt(data, typeErr.Offset), typeErr.Field, describeType(typeErr.Type), typeErr.Value)
+		}
+		// Unknown keys are reported by name only, so their line is found by searching for it
+		if key, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			name, _ := strconv.Unquote(key)
+			return Config{}, fmt.Errorf("line %d: unknown key %s, expected one of %s, aliases or profiles",
+				keyLine(data, name), key, strings.Join(Keys(), ", "))
+		}
+		return Config{}, err
+	}
+
+	if c.Version < 0 {
+		return Config{}, fmt.Errorf("line %d: version must not be negative, got %d", keyLine(data, "version"), c.Version)
+	}
+	if c.Version > CurrentVersion {
+		return Config{}, fmt.Errorf("line %d: version %d is newer than this repo-pack understands (%d), upgrade repo-pack",
+			keyLine(data, "version"), c.Version, CurrentVersion)
+	}
+	for _, f := range fields {
+		if value := f.get(&c); value != "" {
+			if err := f.check(value); err != nil {
+				return Config{}, fmt.Errorf("line %d: %v", keyLine(data, f.key), err)
+			}
+		}
+	}
+	for name, rawURL := range c.Aliases {
+		if err := checkAlias(name, rawURL); err != nil {
+			return Config{}, fmt.Errorf("line %d: %v", keyLine(data, name), err)
+		}
+	}
+	for name, profile := range c.Profiles {
+		if err := checkProfile(name, profile); err != nil {
+			return Config{}, fmt.Errorf("line %d: %v", keyLine(data, name), err)
+		}
+	}
+	if _, ok := c.Profiles[c.Profile]; c.Profile != "" && !ok {
+		return Config{}, fmt.Errorf("line %d: no profile named %q", keyLine(data, "profile"), c.Profile)
+	}
+	return c, nil
+}
+
+// describeType names a Go type the way the JSON holding it would be written
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+// lineAt returns the 1-based line holding the byte at offset
+func lineAt(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// keyLine returns the line on which key first appears as an object key
+func keyLine(data []byte, key string) int {
+	quoted := []byte(strconv.Quote(key))
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], quoted)
+		if i < 0 {
+			return 1
+		}
+		offset += i + len(quoted)
+		if rest := bytes.TrimLeft(data[offset:], " \t\r\n"); len(rest) > 0 && rest[0] == ':' {
+			return lineAt(data, int64(offset))
+		}
+	}
+}
+
+// Save writes c to path, readable only by the user since it may hold a token. The file is
+// replaced in one step, so an interrupted write never leaves a truncated config.
+func Save(path string, c Config) error {
+	c.Version = CurrentVersion
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error creating config directory: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-")
+	if err != nil {
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,248 @@
+package config_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/config"
+	"strings"
+	"testing"
+)
+
+func TestConfigSetGetAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repo-pack", "config.json")
+
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading a missing config: %v", err)
+	}
+	if err := c.Set("token", "ghp_x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("concurrency", "20"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("concurrency", "many"); err == nil {
+		t.Errorf("expected a non-numeric concurrency to be rejected")
+	}
+	if err := c.Set("concurrency", "-1"); err == nil {
+		t.Errorf("expected a negative concurrency to be rejected")
+	}
+	if err := c.Set("colour", "red"); err == nil {
+		t.Errorf("expected an unknown key to be rejected")
+	}
+	if err := config.Save(path, c); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := loaded.Get("concurrency"); value != "20" {
+		t.Errorf("expected concurrency 20, got %q", value)
+	}
+
+	expected := map[string]string{"token": "ghp_x", "concurrency": "20"}
+	if defaults := loaded.FlagDefaults(); !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("expected flag defaults %v, got %v", expected, defaults)
+	}
+
+	if err := loaded.Set("token", ""); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := loaded.Get("token"); value != "" {
+		t.Errorf("expected an empty value to unset the token, got %q", value)
+	}
+}
+
+func TestConfigHTTPSettings(t *testing.T) {
+	var c config.Config
+	if err := c.Set("timeout", "1m"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("http2", "false"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("http2", "sometimes"); err == nil {
+		t.Errorf("expected a non-boolean http2 to be rejected")
+	}
+	expected := map[string]string{"timeout": "1m", "http2": "false"}
+	if defaults := c.FlagDefaults(); !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("expected flag defaults %v, got %v", expected, defaults)
+	}
+
+	if err := c.Set("http2", ""); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := c.Get("http2"); value != "" {
+		t.Errorf("expected an empty value to unset http2, got %q", value)
+	}
+}
+
+func TestParseReportsInvalidFields(t *testing.T) {
+	tests := []struct {
+		name, data, expected string
+	}{
+		{"unknown key", "{\n  \"token\": \"x\",\n  \"colour\": \"red\"\n}", `line 3: unknown key "colour"`},
+		{"wrong type", "{\n  \"concurrency\": \"ten\"\n}", "line 2: concurrency must be a number, got string"},
+		{"out of range", "{\n  \"token\": \"x\",\n  \"concurrency\": -4\n}", "line 3: concurrency must be at least 1, got -4"},
+		{"bad url", "{\"api_base\": \"ghe.example.com\"}", `line 1: "ghe.example.com" is not an http(s) URL`},
+		{"bad duration", "{\"timeout\": \"30\"}", `line 1: "30" is not a duration`},
+		{"negative duration", "{\"timeout\": \"-1s\"}", `line 1: "-1s" must not be negative`},
+		{"bool as string", "{\n  \"http2\": \"no\"\n}", "line 2: http2 must be true or false, got string"},
+		{"syntax", "{\n  \"token\": \"x\"\n  \"concurrency\": 4\n}", "line 3:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Parse([]byte(tt.data))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expected) {
+				t.Errorf("expected an error starting with %q, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	if _, err := config.Parse([]byte(`{"token": "x", "concurrency": 4}`)); err != nil {
+		t.Errorf("unexpected error for a valid config: %v", err)
+	}
+}
+
+func TestLoadMigratesUnversionedConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"concurrency": 6}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Version != config.CurrentVersion || c.Concurrency != 6 {
+		t.Errorf("expected concurrency 6 at version %d, got %+v", config.CurrentVersion, c)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded, err := config.Parse(data)
+	if err != nil {
+		t.Fatalf("expected the upgraded file to parse: %v", err)
+	}
+	if upgraded.Version != config.CurrentVersion || upgraded.Concurrency != 6 {
+		t.Errorf("expected the file to be written back upgraded, got %s", data)
+	}
+}
+
+func TestParseRejectsNewerVersion(t *testing.T) {
+	data := fmt.Sprintf("{\n  \"version\": %d\n}", config.CurrentVersion+1)
+	_, err := config.Parse([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), "upgrade repo-pack") {
+		t.Errorf("expected a newer version to be rejected, got %v", err)
+	}
+}
+
+func TestConfigAliases(t *testing.T) {
+	var c config.Config
+	if err := c.SetAlias("protos", "https://github.com/org/mono/tree/main/proto"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetAlias("my/protos", "https://github.com/org/mono"); err == nil {
+		t.Errorf("expected a name with a slash to be rejected")
+	}
+	if err := c.SetAlias("docs", "github.com/org/mono"); err == nil {
+		t.Errorf("expected a URL without a scheme to be rejected")
+	}
+
+	for arg, expected := range map[string]string{
+		"@protos":                    "https://github.com/org/mono/tree/main/proto",
+		"https://github.com/o/r/x/y": "https://github.com/o/r/x/y",
+	} {
+		if resolved, err := c.Resolve(arg); err != nil || resolved != expected {
+			t.Errorf("expected %s to resolve to %s, got %q (%v)", arg, expected, resolved, err)
+		}
+	}
+	if _, err := c.Resolve("@docs"); err == nil {
+		t.Errorf("expected an unknown alias to be an error")
+	}
+
+	if err := c.RemoveAlias("protos"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Resolve("@protos"); err == nil {
+		t.Errorf("expected a removed alias to be gone")
+	}
+
+	if _, err := config.Parse([]byte("{\n  \"aliases\": {\n    \"protos\": \"proto\"\n  }\n}")); err == nil || !strings.HasPrefix(err.Error(), "line 3:") {
+		t.Errorf("expected an invalid alias URL to be reported on line 3, got %v", err)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, "work-token")
+	if err := os.WriteFile(tokenPath, []byte("ghp_work\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var c config.Config
+	c.Concurrency = 4
+	for _, setting := range [][3]string{
+		{"work", "token_path", tokenPath},
+		{"work", "api_base", "https://ghe.example.com/api/v3"},
+		{"work", "concurrency", "20"},
+	} {
+		if err := c.SetProfile(setting[0], setting[1], setting[2]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.SetProfile("work", "api_base", "ghe.example.com"); err == nil {
+		t.Errorf("expected a base that isn't a URL to be rejected")
+	}
+	if err := c.SetProfile("work", "colour", "red"); err == nil {
+		t.Errorf("expected an unknown profile key to be rejected")
+	}
+	if err := c.Set("profile", "home"); err == nil {
+		t.Errorf("expected a default profile that doesn't exist to be rejected")
+	}
+	if err := c.Set("profile", "work"); err != nil {
+		t.Fatal(err)
+	}
+
+	defaults, err := c.ProfileDefaults("work")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"token": "ghp_work", "api-base": "https://ghe.example.com/api/v3", "concurrency": "20"}
+	if !reflect.DeepEqual(defaults, expected) {
+		t.Errorf("expected profile defaults %v, got %v", expected, defaults)
+	}
+	if _, err := c.ProfileDefaults("home"); err == nil {
+		t.Errorf("expected an unknown profile to be an error")
+	}
+
+	// Profiles survive a round trip through the file, and removing one unsets it as the default
+	path := filepath.Join(dir, "config.json")
+	if err := config.Save(path, c); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error loading profiles: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Profiles, c.Profiles) || loaded.Profile != "work" {
+		t.Errorf("expected profiles %+v, got %+v", c.Profiles, loaded.Profiles)
+	}
+	if err := loaded.RemoveProfile("work"); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Profile != "" {
+		t.Errorf("expected removing the default profile to unset it, got %q", loaded.Profile)
+	}
+
+	if _, err := config.Parse([]byte("{\n  \"profile\": \"home\"\n}")); err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Errorf("expected a missing default profile to be reported on line 2, got %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Profile holds the settings for one GitHub host or account, such as a work GitHub Enterprise
+// Server instance next to personal github.com, chosen with --profile
+type Profile struct {
+	// TokenPath is a file holding the token, so the token itself needn't sit in the config
+	TokenPath   string `json:"token_path,omitempty"`
+	APIBase     string `json:"api_base,omitempty"`
+	RawBase     string `json:"raw_base,omitempty"`
+	MediaBase   string `json:"media_base,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+}
+
+// profileKeys are the settings of a profile, in the order they are listed. Those after
+// token_path share their flag and validation with the top-level key of the same name.
+var profileKeys = []string{"token_path", "api_base", "raw_base", "media_base", "concurrency"}
+
+// asConfig returns a config holding the profile's flag settings, so they are read, set and
+// checked like the top-level keys
+func (p Profile) asConfig() Config {
+	return Config{APIBase: p.APIBase, RawBase: p.RawBase, MediaBase: p.MediaBase, Concurrency: p.Concurrency}
+}
+
+// ProfileKeys returns the names of every profile setting
+func ProfileKeys() []string {
+	return append([]string(nil), profileKeys...)
+}
+
+// Get returns the value of a profile setting, or "" when it isn't set
+func (p Profile) Get(key string) (string, error) {
+	switch {
+	case key == "token_path":
+		return p.TokenPath, nil
+	case !slices.Contains(profileKeys, key):
+		return "", unknownProfileKey(key)
+	}
+	c := p.asConfig()
+	return c.Get(key)
+}
+
+// SetProfile parses value into key of the named profile, creating the profile if needed; an
+// empty value unsets the key. An invalid value leaves c unchanged.
+func (c *Config) SetProfile(name, key, value string) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	profile := c.Profiles[name]
+	switch {
+	case key == "token_path":
+		profile.TokenPath = value
+	case !slices.Contains(profileKeys, key):
+		return unknownProfileKey(key)
+	default:
+		settings := profile.asConfig()
+		if err := settings.Set(key, value); err != nil {
+			return err
+		}
+		profile.APIBase, profile.RawBase, profile.MediaBase = settings.APIBase, settings.RawBase, settings.MediaBase
+		profile.Concurrency = settings.Concurrency
+	}
+
+	if c.Profiles == nil {
+		c.Profiles = map[string]Profile{}
+	}
+	c.Profiles[name] = profile
+	return nil
+}
+
+// RemoveProfile deletes the named profile, and unsets it as the default profile
+func (c *Config) RemoveProfile(name string) error {
+	if _, ok := c.Profiles[name]; !ok {
+		return fmt.Errorf("no profile named %q", name)
+	}
+	delete(c.Profiles, name)
+	if c.Profile == name {
+		c.Profile = ""
+	}
+	return nil
+}
+
+// ProfileNames returns the names of every profile, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ProfileDefaults maps the name of every flag the named profile sets to its value, reading
+// the token from the profile's token file. They take precedence over the top-level keys.
+func (c *Config) ProfileDefaults(name string) (map[string]string, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile named %q, add one with repo-pack config profile set %s <key> <value>", name, name)
+	}
+	settings := profile.asConfig()
+	defaults := settings.FlagDefaults()
+	if profile.TokenPath != "" {
+		token, err := readTokenFile(profile.TokenPath)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %v", name, err)
+		}
+		defaults["token"] = token
+	}
+	return defaults, nil
+}
+
+// readTokenFile reads a token from path, where a leading ~ is the home directory
+func readTokenFile(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error expanding %s: %v", path, err)
+		}
+		path = filepath.Join(home, path[1:])
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading token file: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// checkProfile validates a profile's name and settings
+func checkProfile(name string, profile Profile) error {
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	settings := profile.asConfig()
+	for _, key := range profileKeys[1:] {
+		f, _ := lookup(key)
+		if value := f.get(&settings); value != "" {
+			if err := f.check(value); err != nil {
+				return fmt.Errorf("profile %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkProfileName validates a profile name
+func checkProfileName(name string) error {
+	if name == "" || strings.ContainsAny(name, "@/ \t") {
+		return fmt.Errorf("profile name %q must be non-empty without @, / or spaces", name)
+	}
+	return nil
+}
+
+func unknownProfileKey(key string) error {
+	return fmt.Errorf("unknown profile key %q, expected one of %s", key, strings.Join(profileKeys, ", "))
+}
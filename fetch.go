@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// runFetch handles `repo-pack fetch [flags] <path>...`, downloading the content of placeholders
+// written with --placeholders. Each path may be a placeholder or a directory holding some.
+func runFetch(args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	concurrency := flags.Int("concurrency", 10, "Maximum number of files to download at once")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 {
+		return fmt.Errorf("usage: repo-pack fetch [--token token] [--concurrency n] <path>...")
+	}
+	workers, err := helpers.FitConcurrency(*concurrency, 0)
+	if err != nil {
+		return err
+	}
+
+	client, err := global.newClient("")
+	if err != nil {
+		return err
+	}
+
+	for _, target := range flags.Args() {
+		if err := fetchPlaceholders(context.Background(), client, target, workers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchPlaceholders downloads the placeholders at target, dropping them from the manifest
+// unless they failed
+func fetchPlaceholders(ctx context.Context, client *gh.Client, target string, workers int) error {
+	dir, placeholders, err := helpers.FindPlaceholders(target)
+	if err != nil {
+		return err
+	}
+	selected, rest, err := placeholders.Select(dir, target)
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		return fmt.Errorf("no placeholders left to fetch at %s", target)
+	}
+
+	components := placeholders.Components
+	fmt.Printf("[-] Fetching %d files from %s/%s\n", len(selected), components.Owner, components.Repository)
+	failed, _ := downloadFiles(ctx, client, &components, selected, workers, gh.FetchOptions{
+		StreamThreshold: 1 << 20,
+		Budget:          helpers.NewMemoryBudget(64 << 20),
+		Warn: func(err error) {
+			log.Printf("warning: %v", err)
+		},
+		OutputDir: dir,
+	}, os.Stdout, nil, false, nil, nil)
+
+	placeholders.Files = rest
+	for _, failure := range failed {
+		placeholders.Files = append(placeholders.Files, failure.File)
+	}
+	if err := helpers.SavePlaceholders(dir, placeholders); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed and remain placeholders", len(failed))
+	}
+	return nil
+}
+
+// writePlaceholders records files as placeholders in outputDir instead of downloading them
+func writePlaceholders(outputDir string, components model.RepoURLComponents, files []model.FileInfo) error {
+	if err := helpers.WritePlaceholders(outputDir, helpers.Placeholders{Components: components, Files: files}); err != nil {
+		return err
+	}
+	fmt.Printf("[-] Wrote %d placeholders; run repo-pack fetch <path> to download their content\n", len(files))
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"repo-pack/auth"
+	"repo-pack/config"
+	"repo-pack/gh"
+	"repo-pack/helpers"
+)
+
+// listFlag collects the values of a flag that may be given more than once
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// hideFlags leaves the named flags out of the usage message, for flags meant for testing
+// rather than everyday use. They still parse as usual.
+func hideFlags(flags *flag.FlagSet, hidden ...string) {
+	flags.Usage = func() {
+		visible := flag.NewFlagSet(flags.Name(), flag.ContinueOnError)
+		visible.SetOutput(flags.Output())
+		flags.VisitAll(func(f *flag.Flag) {
+			if !slices.Contains(hidden, f.Name) {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		fmt.Fprintf(flags.Output(), "Usage of %s:\n", flags.Name())
+		visible.PrintDefaults()
+	}
+}
+
+// dropFlags removes the named flags and their values from args. The flags must take a value,
+// given either as the next argument or after "=".
+func dropFlags(args []string, names ...string) []string {
+	kept := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if strings.HasPrefix(args[i], "-") && slices.Contains(names, name) {
+			if !hasValue {
+				i++
+			}
+			continue
+		}
+		kept = append(kept, args[i])
+	}
+	return kept
+}
+
+// flagValue returns the value args give the valued flag name, in either the --name value or
+// the --name=value form, the last one winning as when parsed
+func flagValue(args []string, name string) (value string, ok bool) {
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--" {
+			break
+		}
+		flagName, flagArg, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || flagName != name {
+			continue
+		}
+		if !hasValue && i+1 < len(args) {
+			i++
+			flagArg = args[i]
+		}
+		value, ok = flagArg, true
+	}
+	return value, ok
+}
+
+// globalFlags are the flags every subcommand talking to GitHub shares
+type globalFlags struct {
+	token, userAgentSuffix  *string
+	maxRate                 *string
+	maxRetries, maxAPICalls *int
+	retryDelay, timeout     *time.Duration
+	http2                   *bool
+	endpoints               endpointFlags
+	// flags is the set they were added to, whose --concurrency sizes the connection pool
+	flags *flag.FlagSet
+}
+
+func addGlobalFlags(flags *flag.FlagSet) globalFlags {
+	// parseFlags applies the profile's values as defaults before the other flags are parsed
+	flags.String("profile", "", "Config profile supplying the token, endpoints and concurrency, e.g. for a GitHub Enterprise Server instance")
+	return globalFlags{
+		token:           flags.String("token", "", "GitHub personal access token"),
+		userAgentSuffix: flags.String("user-agent-suffix", "", "Text appended to the repo-pack/<version> User-Agent, for traffic attribution"),
+		maxRetries:      flags.Int("max-retries", gh.DefaultMaxAttempts-1, "How many times a request failing with a network error, rate limit or 5xx is retried"),
+		retryDelay:      flags.Duration("retry-delay", gh.DefaultRetryDelay, "Wait before the first retry, doubled for each one after, unless the server asks for longer"),
+		maxAPICalls:     flags.Int("max-api-calls", 0, "Fail once this many GitHub API requests have been made, to protect a shared token (0 for no limit)"),
+		timeout:         flags.Duration("timeout", gh.DefaultTimeout, "Time allowed to connect to a server and to receive its response headers (0 for no limit)"),
+		maxRate:         flags.String("max-rate", "", "Cap on the combined download speed of all workers, e.g. 2MB/s (default: no limit)"),
+		http2:           flags.Bool("http2", true, "Use HTTP/2 with servers offering it; --http2=false for HTTP/1.1 only, e.g. behind proxies mishandling it"),
+		endpoints:       addEndpointFlags(flags),
+		flags:           flags,
+	}
+}
+
+// rateLimitReserve is how many API requests are kept unused before pausing for the rate limit
+// to reset, enough for the requests of a default-sized worker pool already in flight
+const rateLimitReserve = 10
+
+// newClient creates a client with the token, User-Agent, retry policy, API call limit,
+// timeouts, bandwidth cap and endpoints the flags name, looking the token up as auth.Resolve
+// describes when none is given. Its connection pool keeps one idle connection per concurrent
+// download. API requests pause with a countdown on stderr when the rate limit nears exhaustion.
+func (g globalFlags) newClient(repoURL string) (*gh.Client, error) {
+	if *g.maxRetries < 0 {
+		return nil, fmt.Errorf("max-retries must not be negative, got %d", *g.maxRetries)
+	}
+	if *g.retryDelay < 0 {
+		return nil, fmt.Errorf("retry-delay must not be negative, got %s", *g.retryDelay)
+	}
+	if *g.maxAPICalls < 0 {
+		return nil, fmt.Errorf("max-api-calls must not be negative, got %d", *g.maxAPICalls)
+	}
+	if *g.timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative, got %s", *g.timeout)
+	}
+	var throttle *helpers.Throttle
+	if *g.maxRate != "" {
+		var err error
+		if throttle, err = helpers.ParseThrottle(*g.maxRate); err != nil {
+			return nil, fmt.Errorf("invalid --max-rate: %v", err)
+		}
+	}
+
+	token, err := auth.Resolve(*g.token, tokenHost(repoURL, *g.endpoints.api))
+	if err != nil {
+		log.Printf("warning: couldn't read the gh CLI's token: %v", err)
+	}
+	client := gh.NewClient(token)
+	client.UserAgent = gh.UserAgent(version, *g.userAgentSuffix)
+	client.MaxAttempts = *g.maxRetries + 1
+	client.RetryDelay = *g.retryDelay
+	client.HTTPClient = gh.NewHTTPClient(gh.HTTPOptions{Timeout: *g.timeout, PoolSize: g.poolSize(), HTTP2: *g.http2})
+	client.Throttle = throttle
+	client.RateLimiter = gh.NewRateLimiter(rateLimitReserve)
+	client.RateLimiter.Countdown = printRateLimitCountdown
+	if *g.maxAPICalls > 0 {
+		client.CallLimit = gh.NewCallLimit(*g.maxAPICalls)
+	}
+	if err := g.endpoints.apply(client, repoURL); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// poolSize returns how many connections per host the client keeps open: the subcommand's
+// --concurrency, or the default pool size for those downloading nothing in parallel
+func (g globalFlags) poolSize() int {
+	if f := g.flags.Lookup("concurrency"); f != nil {
+		if n, ok := f.Value.(flag.Getter).Get().(int); ok && n > 0 {
+			return n
+		}
+	}
+	return gh.DefaultPoolSize
+}
+
+func printRateLimitCountdown(left time.Duration) {
+	if left == 0 {
+		fmt.Fprintln(os.Stderr, "\r[-] Rate limit reset, resuming                      ")
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r[-] Rate limit nearly exhausted, resuming in %s ", left.Round(time.Second))
+}
+
+// parseFlags parses args once the config file's values are applied as defaults, so flags given
+// on the command line win over the config. The selected profile's values win over the
+// config's top-level ones. Config keys for flags the set lacks are ignored.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	// Without a home directory there is no config to read, which only costs the defaults
+	if path, err := config.Path(); err == nil {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return err
+		}
+		defaults := cfg.FlagDefaults()
+		// The profile has to be known before parsing, as its values are defaults for the rest
+		profile := cfg.Profile
+		if value, ok := flagValue(args, "profile"); ok {
+			profile = value
+		}
+		if profile != "" && flags.Lookup("profile") != nil {
+			profileDefaults, err := cfg.ProfileDefaults(profile)
+			if err != nil {
+				return err
+			}
+			maps.Copy(defaults, profileDefaults)
+		}
+		for name, value := range defaults {
+			if flags.Lookup(name) == nil {
+				continue
+			}
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("invalid %s in %s: %v", name, path, err)
+			}
+		}
+	}
+	return flags.Parse(args)
+}
+
+// endpointFlags point a client at a GitHub Enterprise Server instance instead of github.com
+type endpointFlags struct {
+	api, raw, media *string
+}
+
+func addEndpointFlags(flags *flag.FlagSet) endpointFlags {
+	return endpointFlags{
+		api:   flags.String("api-base", gh.DefaultBaseURL, "GitHub API root, e.g. https://ghe.example.com/api/v3 for GitHub Enterprise Server"),
+		raw:   flags.String("raw-base", gh.DefaultRawBaseURL, "Root raw file content is served from, e.g. https://ghe.example.com/raw"),
+		media: flags.String("media-base", gh.DefaultMediaBaseURL, "Root Git LFS file content is served from, e.g. https://ghe.example.com/media"),
+	}
+}
+
+// apply sets the client's endpoints. Git repositories are served from the host of repoURL,
+// so URLs of an Enterprise Server instance are fetched from that instance.
+func (e endpointFlags) apply(client *gh.Client, repoURL string) error {
+	for _, base := range []struct{ name, value string }{
+		{"--api-base", *e.api},
+		{"--raw-base", *e.raw},
+		{"--media-base", *e.media},
+	} {
+		if err := validateBaseURL(base.value); err != nil {
+			return fmt.Errorf("invalid %s: %v", base.name, err)
+		}
+	}
+	client.BaseURL = *e.api
+	client.RawBaseURL = *e.raw
+	client.MediaBaseURL = *e.media
+
+	if parsed, err := url.Parse(repoURL); err == nil && parsed.Host != "" && parsed.Host != "github.com" && parsed.Host != "www.github.com" {
+		client.GitBaseURL = parsed.Scheme + "://" + parsed.Host
+	}
+	return nil
+}
+
+// tokenHost returns the host whose token a client uses: that of a GitHub Enterprise Server API
+// root, where API requests carry the token, or without one, that of repoURL
+func tokenHost(repoURL, apiBase string) string {
+	if parsed, err := url.Parse(apiBase); err == nil && parsed.Host != "" && apiBase != gh.DefaultBaseURL {
+		return parsed.Host
+	}
+	if parsed, err := url.Parse(repoURL); err == nil && parsed.Host != "" && parsed.Host != "www.github.com" {
+		return parsed.Host
+	}
+	return "github.com"
+}
+
+func validateBaseURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http(s) URL", value)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package fuse serves a read-only directory tree to the Linux kernel over /dev/fuse. It speaks
+// the FUSE protocol itself, so mounting needs neither a library nor a C toolchain.
+package fuse
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FS supplies the content of the files of a Tree
+type FS interface {
+	// Open returns the content of the file at path, relative to the root, fetching it on first
+	// use. For a symbolic link, the content is the link's target.
+	Open(path string) (*os.File, error)
+}
+
+// Tree is the directory hierarchy a mount serves. It is built up front from a listing, so
+// browsing it never waits on the network; only reading files does.
+type Tree struct {
+	// nodes holds every node by inode number, less one; the root is inode 1
+	nodes []*node
+}
+
+type node struct {
+	ino  uint64
+	path string
+	mode os.FileMode
+	size int64
+	// children maps the names of a directory's entries to them, and names lists them in the
+	// order they are read in, sorted once the tree is served
+	children map[string]*node
+	names    []string
+}
+
+// NewTree returns a tree holding only its root directory
+func NewTree() *Tree {
+	t := &Tree{}
+	t.add("", os.ModeDir|0o555, 0)
+	return t
+}
+
+func (t *Tree) add(p string, mode os.FileMode, size int64) *node {
+	n := &node{ino: uint64(len(t.nodes) + 1), path: p, mode: mode, size: size}
+	if mode.IsDir() {
+		n.children = make(map[string]*node)
+	}
+	t.nodes = append(t.nodes, n)
+	return n
+}
+
+// Add adds the file at p, relative to the root, along with the directories leading to it.
+// mode holds its permission bits and, for a symbolic link, os.ModeSymlink.
+func (t *Tree) Add(p string, mode os.FileMode, size int64) error {
+	if p == "" || p == "." || p != path.Clean(p) || path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("invalid path %q", p)
+	}
+	dir := t.nodes[0]
+	segments := strings.Split(p, "/")
+	for i, name := range segments {
+		child, ok := dir.children[name]
+		last := i == len(segments)-1
+		switch {
+		case ok && (last || !child.mode.IsDir()):
+			return fmt.Errorf("%s conflicts with %s", p, child.path)
+		case !ok && last:
+			child = t.add(p, mode&(os.ModeSymlink|os.ModePerm), size)
+		case !ok:
+			child = t.add(path.Join(segments[:i+1]...), os.ModeDir|0o555, 0)
+		}
+		if !ok {
+			dir.children[name] = child
+			dir.names = append(dir.names, name)
+		}
+		dir = child
+	}
+	return nil
+}
+
+// sortNames puts the entries of every directory in order by name
+func (t *Tree) sortNames() {
+	for _, n := range t.nodes {
+		sort.Strings(n.names)
+	}
+}
+
+// Len returns how many files and directories the tree holds, its root included
+func (t *Tree) Len() int {
+	return len(t.nodes)
+}
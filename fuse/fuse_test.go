@@ -0,0 +1,29 @@
+package fuse_test
+
+import (
+	"os"
+	"repo-pack/fuse"
+	"testing"
+)
+
+func TestTreeAdd(t *testing.T) {
+	tree := fuse.NewTree()
+	for _, p := range []string{"README.md", "docs/a.md", "docs/guide/b.md"} {
+		if err := tree.Add(p, 0o444, 1); err != nil {
+			t.Fatalf("Add(%q): %v", p, err)
+		}
+	}
+	// The root, three files and the docs and docs/guide directories
+	if tree.Len() != 6 {
+		t.Errorf("expected 6 nodes, got %d", tree.Len())
+	}
+
+	for _, p := range []string{"", ".", "/etc/passwd", "../up", "docs//a.md", "docs/", "docs/../x", "docs", "docs/a.md", "docs/a.md/c"} {
+		if err := tree.Add(p, 0o444, 1); err == nil {
+			t.Errorf("Add(%q) should fail", p)
+		}
+	}
+	if err := tree.Add("link", os.ModeSymlink|0o777, 6); err != nil {
+		t.Errorf("Add(link): %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package fuse
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// fusermounts are the setuid helpers that mount FUSE filesystems for users who can't call
+// mount(2) themselves, newest first
+var fusermounts = []string{"fusermount3", "fusermount"}
+
+// Mount mounts an empty read-only FUSE filesystem named name at dir and returns its
+// /dev/fuse device, whose requests Serve answers. Root mounts it directly; other users go
+// through the fusermount helper, as libfuse does.
+func Mount(dir, name string) (*os.File, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	if os.Geteuid() == 0 {
+		return mountDirect(dir, name)
+	}
+	return mountHelper(dir, name)
+}
+
+// mountDirect mounts dir with mount(2), which takes CAP_SYS_ADMIN
+func mountDirect(dir, name string) (*os.File, error) {
+	fd, err := syscall.Open("/dev/fuse", syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error opening /dev/fuse: %v", err)
+	}
+	options := fmt.Sprintf("fd=%d,rootmode=40000,user_id=%d,group_id=%d,max_read=%d", fd, os.Getuid(), os.Getgid(), maxWrite)
+	if err := syscall.Mount(name, dir, "fuse.repo-pack", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_RDONLY, options); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("error mounting %s: %v", dir, err)
+	}
+	return os.NewFile(uintptr(fd), "/dev/fuse"), nil
+}
+
+// mountHelper has fusermount mount dir and pass back the device it opened over a socket
+func mountHelper(dir, name string) (*os.File, error) {
+	helper, err := findFusermount()
+	if err != nil {
+		return nil, err
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	local := os.NewFile(uintptr(fds[0]), "fusermount socket")
+	remote := os.NewFile(uintptr(fds[1]), "fusermount socket")
+	defer local.Close()
+
+	// Mount options are separated by commas, which a name can't hold
+	name = strings.ReplaceAll(name, ",", "_")
+	cmd := exec.Command(helper, "-o", "ro,nosuid,nodev,subtype=repo-pack,fsname="+name, "--", dir)
+	cmd.Stderr = os.Stderr
+	// The helper finds its end of the socket by the descriptor number in _FUSE_COMMFD
+	cmd.ExtraFiles = []*os.File{remote}
+	cmd.Env = append(os.Environ(), "_FUSE_COMMFD=3")
+	err = cmd.Start()
+	remote.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error running %s: %v", helper, err)
+	}
+
+	dev, recvErr := receiveFD(int(local.Fd()))
+	if err := cmd.Wait(); err != nil {
+		if dev != nil {
+			dev.Close()
+		}
+		return nil, fmt.Errorf("%s failed to mount %s: %v", helper, dir, err)
+	}
+	if recvErr != nil {
+		return nil, fmt.Errorf("error receiving the FUSE device from %s: %v", helper, recvErr)
+	}
+	return dev, nil
+}
+
+// receiveFD reads a file descriptor sent over the unix socket sock
+func receiveFD(sock int) (*os.File, error) {
+	buf := make([]byte, 1)
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := syscall.Recvmsg(sock, buf, oob, 0)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range messages {
+		fds, err := syscall.ParseUnixRights(&message)
+		if err == nil && len(fds) > 0 {
+			for _, extra := range fds[1:] {
+				syscall.Close(extra)
+			}
+			syscall.CloseOnExec(fds[0])
+			return os.NewFile(uintptr(fds[0]), "/dev/fuse"), nil
+		}
+	}
+	return nil, errors.New("no file descriptor received")
+}
+
+// findFusermount returns the path of the first fusermount helper installed
+func findFusermount() (string, error) {
+	for _, name := range fusermounts {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("mounting as a user needs %s, from the fuse3 or fuse package", fusermounts[0])
+}
+
+// Unmount unmounts the filesystem mounted at dir, ending its Serve. A lazy unmount detaches
+// it even while files of it are open.
+func Unmount(dir string) error {
+	if os.Geteuid() == 0 {
+		if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("error unmounting %s: %v", dir, err)
+		}
+		return nil
+	}
+	helper, err := findFusermount()
+	if err != nil {
+		return err
+	}
+	if out, err := exec.Command(helper, "-u", "-z", dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("error unmounting %s: %v: %s", dir, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,446 @@
+package fuse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Opcodes of the kernel requests served, from linux/fuse.h
+const (
+	opLookup      = 1
+	opForget      = 2
+	opGetattr     = 3
+	opReadlink    = 5
+	opOpen        = 14
+	opRead        = 15
+	opStatfs      = 17
+	opRelease     = 18
+	opFlush       = 25
+	opInit        = 26
+	opOpendir     = 27
+	opReaddir     = 28
+	opReleasedir  = 29
+	opAccess      = 34
+	opInterrupt   = 36
+	opDestroy     = 38
+	opBatchForget = 42
+)
+
+const (
+	// protocolMajor and protocolMinor are the version of the protocol spoken, whose messages
+	// have kept the layouts used here since
+	protocolMajor = 7
+	protocolMinor = 31
+	// maxWrite is the largest request the kernel is told to send, and readBufferSize leaves
+	// room for its headers
+	maxWrite       = 128 << 10
+	readBufferSize = maxWrite + 4096
+	// attrTimeout is how long the kernel may cache attributes. Sizes can change once a file
+	// is fetched, as the listing reports that of an LFS file's pointer.
+	attrTimeout = time.Second
+	// entryTimeout is how long the kernel may cache names, which never change
+	entryTimeout = time.Hour
+
+	inHeaderSize  = 40
+	outHeaderSize = 16
+	attrSize      = 88
+	direntSize    = 24
+
+	fuseAsyncRead  = 1 << 0
+	fopenDirectIO  = 1 << 0
+	fopenKeepCache = 1 << 1
+)
+
+var order = binary.NativeEndian
+
+// server answers the kernel's requests for one mount
+type server struct {
+	dev     io.ReadWriter
+	tree    *Tree
+	fs      FS
+	started time.Time
+	uid     uint32
+	gid     uint32
+
+	mu         sync.Mutex
+	handles    map[uint64]*os.File
+	nextHandle uint64
+	// sizes holds the sizes of files found to differ from the listing once fetched
+	sizes map[uint64]int64
+	// writeMu keeps replies whole, as each must reach the device in a single write
+	writeMu sync.Mutex
+	// warn receives failures to fetch content, which the kernel only learns of as EIO
+	warn func(err error)
+}
+
+// request is one kernel request, its payload following the header
+type request struct {
+	opcode uint32
+	unique uint64
+	nodeid uint64
+	data   []byte
+}
+
+// Serve answers the kernel's requests read from dev, the /dev/fuse device of a mount, with
+// tree and the content fs supplies, until the filesystem is unmounted. Requests are answered
+// concurrently, so a slow fetch doesn't hold up browsing. warn, when set, receives the errors
+// opening files, which reads only report as EIO.
+func Serve(dev io.ReadWriter, tree *Tree, fs FS, warn func(err error)) error {
+	tree.sortNames()
+	s := &server{
+		dev:     dev,
+		tree:    tree,
+		fs:      fs,
+		started: time.Now(),
+		uid:     uint32(os.Getuid()),
+		gid:     uint32(os.Getgid()),
+		handles: make(map[uint64]*os.File),
+		sizes:   make(map[uint64]int64),
+		warn:    warn,
+	}
+	defer s.closeHandles()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	buf := make([]byte, readBufferSize)
+	for {
+		n, err := dev.Read(buf)
+		switch {
+		// Interrupted reads and requests the kernel abandoned before they were read are retried
+		case errors.Is(err, syscall.EINTR), errors.Is(err, syscall.ENOENT), errors.Is(err, syscall.EAGAIN):
+			continue
+		// The device is gone once the filesystem is unmounted
+		case errors.Is(err, syscall.ENODEV), errors.Is(err, io.EOF):
+			return nil
+		case err != nil:
+			return fmt.Errorf("error reading FUSE request: %v", err)
+		}
+		if n < inHeaderSize {
+			return fmt.Errorf("short FUSE request of %d bytes", n)
+		}
+		req := request{
+			opcode: order.Uint32(buf[4:]),
+			unique: order.Uint64(buf[8:]),
+			nodeid: order.Uint64(buf[16:]),
+			data:   bytes.Clone(buf[inHeaderSize:min(n, int(order.Uint32(buf[0:])))]),
+		}
+		switch req.opcode {
+		case opInit:
+			s.init(req)
+		case opDestroy:
+			s.reply(req, 0, nil)
+			return nil
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.handle(req)
+			}()
+		}
+	}
+}
+
+// reply writes the answer to req: errno when non-zero, and otherwise the payload
+func (s *server) reply(req request, errno syscall.Errno, payload []byte) {
+	out := make([]byte, outHeaderSize, outHeaderSize+len(payload))
+	if errno == 0 {
+		out = append(out, payload...)
+	}
+	order.PutUint32(out[0:], uint32(len(out)))
+	order.PutUint32(out[4:], uint32(-int32(errno)))
+	order.PutUint64(out[8:], req.unique)
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	// A request interrupted meanwhile is answered with ENOENT, which is no reason to stop
+	s.dev.Write(out)
+}
+
+// init negotiates the protocol version, which the kernel opens every mount with
+func (s *server) init(req request) {
+	if len(req.data) < 16 {
+		s.reply(req, syscall.EIO, nil)
+		return
+	}
+	major := order.Uint32(req.data[0:])
+	minor := order.Uint32(req.data[4:])
+	if major < protocolMajor {
+		s.reply(req, syscall.EPROTO, nil)
+		return
+	}
+	if major > protocolMajor {
+		// The kernel asks again with the version answered
+		minor = protocolMinor
+	}
+	out := make([]byte, 64)
+	order.PutUint32(out[0:], protocolMajor)
+	order.PutUint32(out[4:], min(minor, protocolMinor))
+	order.PutUint32(out[8:], order.Uint32(req.data[8:]))
+	order.PutUint32(out[12:], order.Uint32(req.data[12:])&fuseAsyncRead)
+	order.PutUint16(out[16:], 16)
+	order.PutUint16(out[18:], 12)
+	order.PutUint32(out[20:], maxWrite)
+	order.PutUint32(out[24:], 1)
+	s.reply(req, 0, out)
+}
+
+// handle answers any request but those opening and closing the connection
+func (s *server) handle(req request) {
+	var n *node
+	if req.nodeid >= 1 && req.nodeid <= uint64(len(s.tree.nodes)) {
+		n = s.tree.nodes[req.nodeid-1]
+	}
+	switch req.opcode {
+	case opForget, opBatchForget, opInterrupt:
+		// Nodes live as long as the mount, and requests are answered whether interrupted or not
+		return
+	}
+	if n == nil {
+		s.reply(req, syscall.ENOENT, nil)
+		return
+	}
+
+	switch req.opcode {
+	case opLookup:
+		name, _, _ := bytes.Cut(req.data, []byte{0})
+		child, ok := n.children[string(name)]
+		if !ok {
+			s.reply(req, syscall.ENOENT, nil)
+			return
+		}
+		out := make([]byte, 40, 40+attrSize)
+		order.PutUint64(out[0:], child.ino)
+		order.PutUint64(out[8:], 1)
+		order.PutUint64(out[16:], uint64(entryTimeout/time.Second))
+		order.PutUint64(out[24:], uint64(attrTimeout/time.Second))
+		s.reply(req, 0, s.appendAttr(out, child))
+	case opGetattr:
+		out := make([]byte, 16, 16+attrSize)
+		order.PutUint64(out[0:], uint64(attrTimeout/time.Second))
+		s.reply(req, 0, s.appendAttr(out, n))
+	case opReadlink:
+		if n.mode&os.ModeSymlink == 0 {
+			s.reply(req, syscall.EINVAL, nil)
+			return
+		}
+		f, errno := s.open(n)
+		if errno != 0 {
+			s.reply(req, errno, nil)
+			return
+		}
+		target, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			s.reply(req, syscall.EIO, nil)
+			return
+		}
+		s.reply(req, 0, target)
+	case opOpendir:
+		if !n.mode.IsDir() {
+			s.reply(req, syscall.ENOTDIR, nil)
+			return
+		}
+		s.reply(req, 0, make([]byte, 16))
+	case opReaddir:
+		if len(req.data) < 20 {
+			s.reply(req, syscall.EIO, nil)
+			return
+		}
+		s.reply(req, 0, s.readdir(n, order.Uint64(req.data[8:]), int(order.Uint32(req.data[16:]))))
+	case opReleasedir, opFlush:
+		s.reply(req, 0, nil)
+	case opOpen:
+		if !n.mode.IsRegular() {
+			s.reply(req, syscall.EISDIR, nil)
+			return
+		}
+		if len(req.data) >= 4 && order.Uint32(req.data[0:])&syscall.O_ACCMODE != syscall.O_RDONLY {
+			s.reply(req, syscall.EROFS, nil)
+			return
+		}
+		f, errno := s.open(n)
+		if errno != 0 {
+			s.reply(req, errno, nil)
+			return
+		}
+		flags := uint32(fopenKeepCache)
+		if info, err := f.Stat(); err == nil && info.Size() != n.size {
+			// The kernel would stop reading at the size it was told, so it is bypassed
+			s.mu.Lock()
+			s.sizes[n.ino] = info.Size()
+			s.mu.Unlock()
+			flags = fopenDirectIO
+		}
+		s.mu.Lock()
+		s.nextHandle++
+		fh := s.nextHandle
+		s.handles[fh] = f
+		s.mu.Unlock()
+		out := make([]byte, 16)
+		order.PutUint64(out[0:], fh)
+		order.PutUint32(out[8:], flags)
+		s.reply(req, 0, out)
+	case opRead:
+		if len(req.data) < 20 {
+			s.reply(req, syscall.EIO, nil)
+			return
+		}
+		s.mu.Lock()
+		f := s.handles[order.Uint64(req.data[0:])]
+		s.mu.Unlock()
+		if f == nil {
+			s.reply(req, syscall.EBADF, nil)
+			return
+		}
+		buf := make([]byte, order.Uint32(req.data[16:]))
+		read, err := f.ReadAt(buf, int64(order.Uint64(req.data[8:])))
+		if err != nil && !errors.Is(err, io.EOF) {
+			s.reply(req, syscall.EIO, nil)
+			return
+		}
+		s.reply(req, 0, buf[:read])
+	case opRelease:
+		if len(req.data) >= 8 {
+			s.mu.Lock()
+			fh := order.Uint64(req.data[0:])
+			if f := s.handles[fh]; f != nil {
+				f.Close()
+				delete(s.handles, fh)
+			}
+			s.mu.Unlock()
+		}
+		s.reply(req, 0, nil)
+	case opStatfs:
+		out := make([]byte, 80)
+		order.PutUint64(out[0:], uint64(s.totalSize()+4095)/4096)
+		order.PutUint64(out[24:], uint64(len(s.tree.nodes)))
+		order.PutUint32(out[40:], 4096)
+		order.PutUint32(out[44:], 255)
+		order.PutUint32(out[48:], 4096)
+		s.reply(req, 0, out)
+	case opAccess:
+		const writeOK = 2
+		if len(req.data) >= 4 && order.Uint32(req.data[0:])&writeOK != 0 {
+			s.reply(req, syscall.EROFS, nil)
+			return
+		}
+		s.reply(req, 0, nil)
+	default:
+		// Writes never get this far on a read-only mount; anything else isn't supported
+		s.reply(req, syscall.ENOSYS, nil)
+	}
+}
+
+// open fetches the content of a file through fs
+func (s *server) open(n *node) (*os.File, syscall.Errno) {
+	f, err := s.fs.Open(n.path)
+	if err != nil {
+		if s.warn != nil {
+			s.warn(fmt.Errorf("error opening %s: %v", n.path, err))
+		}
+		return nil, syscall.EIO
+	}
+	return f, 0
+}
+
+// appendAttr appends the attributes of n, laid out as struct fuse_attr, to out
+func (s *server) appendAttr(out []byte, n *node) []byte {
+	size := n.size
+	s.mu.Lock()
+	if fetched, ok := s.sizes[n.ino]; ok {
+		size = fetched
+	}
+	s.mu.Unlock()
+
+	mode := uint32(n.mode.Perm())
+	nlink := uint32(1)
+	switch {
+	case n.mode.IsDir():
+		mode |= syscall.S_IFDIR
+		nlink = 2
+	case n.mode&os.ModeSymlink != 0:
+		mode |= syscall.S_IFLNK
+	default:
+		mode |= syscall.S_IFREG
+	}
+	now := uint64(s.started.Unix())
+	attr := make([]byte, attrSize)
+	order.PutUint64(attr[0:], n.ino)
+	order.PutUint64(attr[8:], uint64(size))
+	order.PutUint64(attr[16:], uint64(size+511)/512)
+	order.PutUint64(attr[24:], now)
+	order.PutUint64(attr[32:], now)
+	order.PutUint64(attr[40:], now)
+	order.PutUint32(attr[60:], mode)
+	order.PutUint32(attr[64:], nlink)
+	order.PutUint32(attr[68:], s.uid)
+	order.PutUint32(attr[72:], s.gid)
+	order.PutUint32(attr[80:], 4096)
+	return append(out, attr...)
+}
+
+// readdir returns the entries of directory n from offset on, laid out as struct fuse_dirent,
+// as many as fit in size bytes. Offsets count the entries, "." and ".." first.
+func (s *server) readdir(n *node, offset uint64, size int) []byte {
+	var out []byte
+	for i := offset; i < uint64(len(n.names))+2; i++ {
+		name, entry := ".", n
+		switch {
+		case i == 1:
+			name = ".."
+		case i > 1:
+			name = n.names[i-2]
+			entry = n.children[name]
+		}
+		length := (direntSize + len(name) + 7) &^ 7
+		if len(out)+length > size {
+			break
+		}
+		dirent := make([]byte, length)
+		order.PutUint64(dirent[0:], entry.ino)
+		order.PutUint64(dirent[8:], i+1)
+		order.PutUint32(dirent[16:], uint32(len(name)))
+		order.PutUint32(dirent[20:], direntType(entry.mode))
+		copy(dirent[direntSize:], name)
+		out = append(out, dirent...)
+	}
+	return out
+}
+
+// direntType returns the DT_* type of a directory entry of the given mode
+func direntType(mode os.FileMode) uint32 {
+	switch {
+	case mode.IsDir():
+		return syscall.DT_DIR
+	case mode&os.ModeSymlink != 0:
+		return syscall.DT_LNK
+	default:
+		return syscall.DT_REG
+	}
+}
+
+// totalSize adds up the sizes of the files of the tree
+func (s *server) totalSize() int64 {
+	var total int64
+	for _, n := range s.tree.nodes {
+		total += n.size
+	}
+	return total
+}
+
+// closeHandles closes the files left open when the filesystem goes away
+func (s *server) closeHandles() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for fh, f := range s.handles {
+		f.Close()
+		delete(s.handles, fh)
+	}
+}
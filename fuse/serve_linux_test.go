@@ -0,0 +1,189 @@
+package fuse_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"repo-pack/fuse"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// Opcodes of linux/fuse.h the test sends
+const (
+	opLookup   = 1
+	opGetattr  = 3
+	opReadlink = 5
+	opOpen     = 14
+	opRead     = 15
+	opInit     = 26
+	opOpendir  = 27
+	opReaddir  = 28
+	opDestroy  = 38
+)
+
+var order = binary.NativeEndian
+
+// device plays the kernel's side of /dev/fuse, handing Serve one request at a time and
+// returning its reply
+type device struct {
+	requests chan []byte
+	replies  chan []byte
+	unique   uint64
+}
+
+func (d *device) Read(p []byte) (int, error) {
+	req, ok := <-d.requests
+	if !ok {
+		return 0, io.EOF
+	}
+	return copy(p, req), nil
+}
+
+func (d *device) Write(p []byte) (int, error) {
+	d.replies <- bytes.Clone(p)
+	return len(p), nil
+}
+
+// call sends a request for node and returns the errno and payload of its reply
+func (d *device) call(t *testing.T, opcode uint32, node uint64, data []byte) (syscall.Errno, []byte) {
+	t.Helper()
+	d.unique++
+	req := make([]byte, 40, 40+len(data))
+	order.PutUint32(req[4:], opcode)
+	order.PutUint64(req[8:], d.unique)
+	order.PutUint64(req[16:], node)
+	req = append(req, data...)
+	order.PutUint32(req[0:], uint32(len(req)))
+	d.requests <- req
+
+	reply := <-d.replies
+	if got := order.Uint64(reply[8:]); got != d.unique {
+		t.Fatalf("reply to request %d answers %d", d.unique, got)
+	}
+	if int(order.Uint32(reply[0:])) != len(reply) {
+		t.Fatalf("reply length %d doesn't match its %d bytes", order.Uint32(reply[0:]), len(reply))
+	}
+	return syscall.Errno(-int32(order.Uint32(reply[4:]))), reply[16:]
+}
+
+// dirFS serves the files of a directory
+type dirFS string
+
+func (d dirFS) Open(p string) (*os.File, error) {
+	return os.Open(filepath.Join(string(d), filepath.FromSlash(p)))
+}
+
+func TestServe(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0o755)
+	os.WriteFile(filepath.Join(dir, "docs", "a.md"), []byte("hello, fuse\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "link"), []byte("docs/a.md"), 0o644)
+
+	tree := fuse.NewTree()
+	// The listing understates a.md, as it does for LFS files
+	tree.Add("docs/a.md", 0o444, 5)
+	tree.Add("docs/missing.md", 0o444, 3)
+	tree.Add("link", os.ModeSymlink|0o777, 9)
+
+	dev := &device{requests: make(chan []byte), replies: make(chan []byte)}
+	var warnings []error
+	done := make(chan error)
+	go func() {
+		done <- fuse.Serve(dev, tree, dirFS(dir), func(err error) { warnings = append(warnings, err) })
+	}()
+
+	initIn := make([]byte, 16)
+	order.PutUint32(initIn[0:], 7)
+	order.PutUint32(initIn[4:], 38)
+	if errno, out := dev.call(t, opInit, 0, initIn); errno != 0 || order.Uint32(out[0:]) != 7 || order.Uint32(out[4:]) != 31 {
+		t.Fatalf("INIT: errno %v, version %d.%d", errno, order.Uint32(out[0:]), order.Uint32(out[4:]))
+	}
+
+	lookup := func(parent uint64, name string) (uint64, uint32, uint64) {
+		errno, out := dev.call(t, opLookup, parent, append([]byte(name), 0))
+		if errno != 0 {
+			t.Fatalf("LOOKUP %s: %v", name, errno)
+		}
+		attr := out[40:]
+		return order.Uint64(out[0:]), order.Uint32(attr[60:]), order.Uint64(attr[8:])
+	}
+	docs, mode, _ := lookup(1, "docs")
+	if mode != syscall.S_IFDIR|0o555 {
+		t.Errorf("docs has mode %o", mode)
+	}
+	a, mode, size := lookup(docs, "a.md")
+	if mode != syscall.S_IFREG|0o444 || size != 5 {
+		t.Errorf("a.md has mode %o and size %d", mode, size)
+	}
+	if errno, _ := dev.call(t, opLookup, docs, []byte("b.md\x00")); errno != syscall.ENOENT {
+		t.Errorf("LOOKUP of a missing name: %v", errno)
+	}
+
+	// Entries are listed in order, after "." and ".."
+	if errno, _ := dev.call(t, opOpendir, docs, make([]byte, 8)); errno != 0 {
+		t.Fatalf("OPENDIR: %v", errno)
+	}
+	readdir := make([]byte, 40)
+	order.PutUint32(readdir[16:], 4096)
+	_, out := dev.call(t, opReaddir, docs, readdir)
+	var names []string
+	for len(out) >= 24 {
+		length := int(order.Uint32(out[16:]))
+		names = append(names, string(out[24:24+length]))
+		out = out[(24+length+7)&^7:]
+	}
+	if want := []string{".", "..", "a.md", "missing.md"}; !slices.Equal(names, want) {
+		t.Errorf("READDIR listed %q, want %q", names, want)
+	}
+
+	// Opening fetches the content; a size the listing got wrong is bypassed
+	if errno, _ := dev.call(t, opOpen, a, []byte{byte(syscall.O_WRONLY), 0, 0, 0, 0, 0, 0, 0}); errno != syscall.EROFS {
+		t.Errorf("OPEN for writing: %v", errno)
+	}
+	errno, out := dev.call(t, opOpen, a, make([]byte, 8))
+	if errno != 0 {
+		t.Fatalf("OPEN: %v", errno)
+	}
+	fh := order.Uint64(out[0:])
+	if flags := order.Uint32(out[8:]); flags&1 == 0 {
+		t.Errorf("OPEN should set FOPEN_DIRECT_IO for a changed size, got flags %#x", flags)
+	}
+	read := make([]byte, 40)
+	order.PutUint64(read[0:], fh)
+	order.PutUint64(read[8:], 7)
+	order.PutUint32(read[16:], 100)
+	if _, out := dev.call(t, opRead, a, read); string(out) != "fuse\n" {
+		t.Errorf("READ returned %q", out)
+	}
+	if _, out := dev.call(t, opGetattr, a, make([]byte, 16)); order.Uint64(out[16+8:]) != 12 {
+		t.Errorf("GETATTR after opening reports size %d", order.Uint64(out[16+8:]))
+	}
+
+	link, mode, _ := lookup(1, "link")
+	if mode != syscall.S_IFLNK|0o777 {
+		t.Errorf("link has mode %o", mode)
+	}
+	if _, out := dev.call(t, opReadlink, link, nil); string(out) != "docs/a.md" {
+		t.Errorf("READLINK returned %q", out)
+	}
+
+	missing, _, _ := lookup(docs, "missing.md")
+	if errno, _ := dev.call(t, opOpen, missing, make([]byte, 8)); errno != syscall.EIO {
+		t.Errorf("OPEN of a file that can't be fetched: %v", errno)
+	}
+
+	if errno, _ := dev.call(t, opDestroy, 0, nil); errno != 0 {
+		t.Errorf("DESTROY: %v", errno)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Error(), "missing.md") {
+		t.Errorf("expected a warning about missing.md, got %v", warnings)
+	}
+}
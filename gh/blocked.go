@@ -0,0 +1,70 @@
+package gh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrBlocked is returned for content GitHub withholds: with HTTP 451 after a DMCA takedown or
+// other legal notice, or with a 403 once the repository has been disabled
+var ErrBlocked = errors.New("blocked by GitHub")
+
+// blockedBody is the error GitHub's API returns for a blocked repository
+type blockedBody struct {
+	Message string `json:"message"`
+	Block   struct {
+		Reason  string `json:"reason"`
+		HTMLURL string `json:"html_url"`
+	} `json:"block"`
+}
+
+// blockedResponse returns an error wrapping ErrBlocked when resp withholds what, such as
+// "owner/repo" or a file path, and nil for any other failure. A 403 is only a block when its
+// message says so rather than, say, that the token lacks access, so its body is read.
+func blockedResponse(resp *http.Response, what string) error {
+	if resp.StatusCode != http.StatusUnavailableForLegalReasons &&
+		(resp.StatusCode != http.StatusForbidden || rateLimited(resp)) {
+		return nil
+	}
+
+	var body blockedBody
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json.Unmarshal(data, &body)
+	message := strings.ToLower(body.Message)
+	if resp.StatusCode == http.StatusForbidden && body.Block.Reason == "" &&
+		!strings.Contains(message, "blocked") && !strings.Contains(message, "disabled") {
+		return nil
+	}
+
+	var reason string
+	switch {
+	case resp.StatusCode == http.StatusUnavailableForLegalReasons:
+		reason = "is unavailable for legal reasons"
+	case strings.Contains(message, "disabled"):
+		reason = "has been disabled"
+	default:
+		reason = "is blocked"
+	}
+	if body.Block.Reason != "" {
+		reason += fmt.Sprintf(" (%s)", body.Block.Reason)
+	}
+	err := fmt.Errorf("%w: %s %s and can't be downloaded until GitHub restores access", ErrBlocked, what, reason)
+	if body.Block.HTMLURL != "" {
+		err = fmt.Errorf("%w; the notice is at %s", err, body.Block.HTMLURL)
+	}
+	return err
+}
+
+// blockedTarget names what an API path such as repos/owner/repo/git/trees/main is about, for
+// blockedResponse
+func blockedTarget(path string) string {
+	segments := strings.SplitN(path, "/", 4)
+	if len(segments) >= 3 && segments[0] == "repos" {
+		return segments[1] + "/" + strings.SplitN(segments[2], "?", 2)[0]
+	}
+	return path
+}
@@ -0,0 +1,166 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"repo-pack/helpers"
+)
+
+// Cache stores downloaded files by git blob SHA so unchanged files needn't be fetched again
+type Cache interface {
+	// Restore copies the blob cached under sha to dst and reports whether it was found
+	Restore(ctx context.Context, sha, dst string) (bool, error)
+	// Store adds the file at src to the cache under sha
+	Store(ctx context.Context, sha, src string) error
+}
+
+// FileCache is a Cache kept in a local directory, one file per blob SHA
+type FileCache struct {
+	dir string
+}
+
+// DefaultCacheDir returns the per-user cache directory for repo-pack
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "repo-pack"), nil
+}
+
+// NewFileCache creates a cache rooted at dir, creating it if necessary
+func NewFileCache(dir string) (*FileCache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory %s: %w", dir, err)
+	}
+	return &FileCache{dir: dir}, nil
+}
+
+// Dir returns the directory the cache is stored in
+func (c *FileCache) Dir() string {
+	return c.dir
+}
+
+// objectPath fans blobs out into subdirectories by the first two hex digits, as git does
+func (c *FileCache) objectPath(sha string) (string, error) {
+	if len(sha) < 3 {
+		return "", fmt.Errorf("invalid blob SHA: %q", sha)
+	}
+	return filepath.Join(c.dir, "objects", sha[:2], sha[2:]), nil
+}
+
+// Restore copies the blob cached under sha to dst and reports whether it was found
+func (c *FileCache) Restore(_ context.Context, sha, dst string) (bool, error) {
+	src, err := c.objectPath(sha)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err := copyFile(src, dst); err != nil {
+		return false, fmt.Errorf("error restoring %s from cache: %w", dst, err)
+	}
+	return true, nil
+}
+
+// Read returns the content of the blob cached under sha and reports whether it was found
+func (c *FileCache) Read(sha string) ([]byte, bool, error) {
+	src, err := c.objectPath(sha)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, err := os.ReadFile(src)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	return data, err == nil, err
+}
+
+// Store adds the file at src to the cache under sha
+func (c *FileCache) Store(_ context.Context, sha, src string) error {
+	dst, err := c.objectPath(sha)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("error adding %s to cache: %w", src, err)
+	}
+	defer in.Close()
+
+	if err := c.storeReader(dst, in, nil); err != nil {
+		return fmt.Errorf("error adding %s to cache: %w", src, err)
+	}
+	return nil
+}
+
+// storeReader writes r to the object at dst via a temporary file, so that concurrent
+// readers never see a partial blob. A non-nil check runs once r is written and keeps the
+// object out of the cache when it fails.
+func (c *FileCache) storeReader(dst string, r io.Reader, check func() error) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := helpers.CopyBuffered(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if check != nil {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// copyFile copies src to dst, creating dst's parent directories
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(dst, in)
+}
+
+// writeFile writes r to dst, creating dst's parent directories
+func writeFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := helpers.CopyBuffered(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
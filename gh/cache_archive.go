@@ -0,0 +1,190 @@
+package gh
+
+import (
+	"archive/tar"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// objectNameRegex matches the archive names of cache objects, e.g. objects/ce/0136...
+var objectNameRegex = regexp.MustCompile(`^objects/[0-9a-f]{2}/[0-9a-f]{38,62}$`)
+
+// lfsOIDRecord is the PAX record giving the SHA-256 of an archived blob holding Git LFS content
+const lfsOIDRecord = "REPOPACK.lfs-oid"
+
+// Export writes every cached blob to w as a tar stream and returns how many were written. Git
+// LFS content, cached under its pointer's SHA, is archived with its SHA-256 so Import can check it.
+func (c *FileCache) Export(w io.Writer) (int, error) {
+	tw := tar.NewWriter(w)
+	count := 0
+	err := filepath.WalkDir(filepath.Join(c.dir, "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(c.dir, p)
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:    filepath.ToSlash(rel),
+			Mode:    0o644,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, file); err != nil {
+			return err
+		}
+		oid := hex.EncodeToString(h.Sum(nil))
+		if pointsTo(filepath.Base(filepath.Dir(p))+d.Name(), oid, info.Size()) {
+			header.PAXRecords = map[string]string{lfsOIDRecord: oid}
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := io.Copy(tw, file); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("error exporting cache: %w", err)
+	}
+	return count, tw.Close()
+}
+
+// errBlobMismatch reports an archived blob whose content doesn't hash to its name
+var errBlobMismatch = errors.New("content doesn't match its blob SHA")
+
+// ImportStats counts the blobs of an Import
+type ImportStats struct {
+	// Added counts the blobs added to the cache, LFS those of them holding Git LFS content
+	Added int
+	LFS   int
+	// Mismatched counts the blobs left out as their content doesn't match their name
+	Mismatched int
+}
+
+// Import adds the blobs from a tar stream written by Export, skipping blobs already cached.
+// Each blob is hashed as it is read, and one whose content doesn't match the SHA it is named
+// by, as a corrupted or crafted archive would hold, is left out rather than restored by later
+// downloads. Git LFS content, cached under its pointer's SHA, is checked against the SHA-256
+// archived with it, which the pointer named by the blob SHA must give.
+func (c *FileCache) Import(r io.Reader) (ImportStats, error) {
+	var stats ImportStats
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return stats, nil
+		} else if err != nil {
+			return stats, fmt.Errorf("error importing cache: %w", err)
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// Only accept well-formed object names so an archive can't write outside the cache
+		if !objectNameRegex.MatchString(header.Name) {
+			return stats, fmt.Errorf("error importing cache: unexpected entry %s", header.Name)
+		}
+
+		_, name := path.Split(header.Name)
+		sha := path.Base(path.Dir(header.Name)) + name
+		dst, err := c.objectPath(sha)
+		if err != nil {
+			return stats, err
+		}
+		if _, err := os.Stat(dst); err == nil {
+			continue
+		}
+
+		h, expected := blobHash(sha, header.Size), sha
+		oid, lfs := header.PAXRecords[lfsOIDRecord]
+		if lfs {
+			if !pointsTo(sha, oid, header.Size) {
+				stats.Mismatched++
+				continue
+			}
+			h, expected = sha256.New(), oid
+		}
+		if h == nil {
+			stats.Mismatched++
+			continue
+		}
+		err = c.storeReader(dst, io.TeeReader(tr, h), func() error {
+			if hex.EncodeToString(h.Sum(nil)) != expected {
+				return errBlobMismatch
+			}
+			return nil
+		})
+		if errors.Is(err, errBlobMismatch) {
+			stats.Mismatched++
+			continue
+		}
+		if err != nil {
+			return stats, fmt.Errorf("error importing %s: %w", sha, err)
+		}
+		stats.Added++
+		if lfs {
+			stats.LFS++
+		}
+	}
+}
+
+// pointsTo reports whether sha names the Git LFS pointer to content of the given SHA-256 and
+// size, as written by git-lfs
+func pointsTo(sha, oid string, size int64) bool {
+	pointer := fmt.Sprintf("%s\noid sha256:%s\nsize %d\n", lfsPointerPrefix, oid, size)
+	h := blobHash(sha, int64(len(pointer)))
+	if h == nil {
+		return false
+	}
+	io.WriteString(h, pointer)
+	return hex.EncodeToString(h.Sum(nil)) == sha
+}
+
+// blobHash returns a hash of a git blob of the given size, primed with the blob header, in the
+// object format sha is written in: SHA-1 or, for SHA-256 repositories, SHA-256. It returns nil
+// for a SHA of neither length.
+func blobHash(sha string, size int64) hash.Hash {
+	var h hash.Hash
+	switch len(sha) {
+	case sha1.Size * 2:
+		h = sha1.New()
+	case sha256.Size * 2:
+		h = sha256.New()
+	default:
+		return nil
+	}
+	fmt.Fprintf(h, "blob %d\x00", size)
+	return h
+}
@@ -0,0 +1,82 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CacheStats summarises the blobs held by a FileCache
+type CacheStats struct {
+	Objects int
+	Bytes   int64
+}
+
+// Stats counts the cached blobs and their total size
+func (c *FileCache) Stats() (CacheStats, error) {
+	var stats CacheStats
+	err := filepath.WalkDir(filepath.Join(c.dir, "objects"), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(p, ".tmp") {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		stats.Objects++
+		stats.Bytes += info.Size()
+		return nil
+	})
+	return stats, err
+}
+
+// Clear removes every cached blob, along with the commits recorded as synced, which are
+// only meaningful while their blobs are cached
+func (c *FileCache) Clear() error {
+	for _, name := range []string{"objects", "synced"} {
+		if err := os.RemoveAll(filepath.Join(c.dir, name)); err != nil {
+			return err
+		}
+	}
+	return os.MkdirAll(filepath.Join(c.dir, "objects"), 0o755)
+}
+
+// LayeredCache consults caches in order, such as a local cache in front of a shared remote
+// one. A blob found in a later cache is copied into the earlier ones, and stores go to all.
+type LayeredCache []Cache
+
+// Restore tries each cache in turn. Errors are only returned when no cache had the blob.
+func (l LayeredCache) Restore(ctx context.Context, sha, dst string) (bool, error) {
+	var errs []error
+	for i, cache := range l {
+		found, err := cache.Restore(ctx, sha, dst)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !found {
+			continue
+		}
+		for _, earlier := range l[:i] {
+			if err := earlier.Store(ctx, sha, dst); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return true, errors.Join(errs...)
+	}
+	return false, errors.Join(errs...)
+}
+
+// Store adds the file to every cache
+func (l LayeredCache) Store(ctx context.Context, sha, src string) error {
+	var errs []error
+	for _, cache := range l {
+		if err := cache.Store(ctx, sha, src); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,240 @@
+package gh_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"repo-pack/gh"
+	"repo-pack/gitproto"
+	"sync"
+	"testing"
+)
+
+func TestFileCacheStoreAndRestore(t *testing.T) {
+	ctx := context.Background()
+	cache, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	work := t.TempDir()
+	src := filepath.Join(work, "src.txt")
+	if err := os.WriteFile(src, []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sha := "ce013625030ba8dba906f756967f9e9ca394464a"
+	dst := filepath.Join(work, "nested", "dst.txt")
+
+	if found, err := cache.Restore(ctx, sha, dst); err != nil || found {
+		t.Errorf("expected miss on empty cache, got found=%v err=%v", found, err)
+	}
+
+	if err := cache.Store(ctx, sha, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found, err := cache.Restore(ctx, sha, dst)
+	if err != nil || !found {
+		t.Fatalf("expected hit after store, got found=%v err=%v", found, err)
+	}
+
+	content, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != "hello\n" {
+		t.Errorf("expected restored content: %q, got: %q", "hello\n", content)
+	}
+}
+
+func TestFileCacheExportAndImport(t *testing.T) {
+	ctx := context.Background()
+	source, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "src.txt")
+	if err := os.WriteFile(src, []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const sha = "ce013625030ba8dba906f756967f9e9ca394464a"
+	// Stored under a name its content doesn't hash to, as a crafted archive would be
+	const forged = "1111111111111111111111111111111111111111"
+	for _, name := range []string{sha, forged} {
+		if err := source.Store(ctx, name, src); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Git LFS content is cached under its pointer's SHA
+	lfsContent := []byte("large content\n")
+	pointer := fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%x\nsize %d\n", sha256.Sum256(lfsContent), len(lfsContent))
+	pointerSHA := gitproto.HashObject(gitproto.TypeBlob, []byte(pointer))
+	lfsSrc := filepath.Join(t.TempDir(), "lfs.bin")
+	if err := os.WriteFile(lfsSrc, lfsContent, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.Store(ctx, pointerSHA, lfsSrc); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if count, err := source.Export(&archive); err != nil || count != 3 {
+		t.Fatalf("expected 3 exported blobs, got %d (%v)", count, err)
+	}
+	// Other content claiming the LFS content's SHA-256 doesn't pass for it. It replaces the two
+	// zero blocks ending the archive.
+	archive.Truncate(archive.Len() - 1024)
+	tw := tar.NewWriter(&archive)
+	tw.WriteHeader(&tar.Header{
+		Name:       "objects/22/22222222222222222222222222222222222222",
+		Mode:       0o644,
+		Size:       int64(len(lfsContent)),
+		PAXRecords: map[string]string{"REPOPACK.lfs-oid": fmt.Sprintf("%x", sha256.Sum256(lfsContent))},
+	})
+	tw.Write(lfsContent)
+	tw.Close()
+
+	target, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats, err := target.Import(bytes.NewReader(archive.Bytes()))
+	if err != nil || stats != (gh.ImportStats{Added: 2, LFS: 1, Mismatched: 2}) {
+		t.Fatalf("expected 2 blobs added, 1 of them LFS, and 2 mismatched, got %+v (%v)", stats, err)
+	}
+	if data, found, err := target.Read(sha); err != nil || !found || string(data) != "hello\n" {
+		t.Errorf("expected the imported blob, got %q found=%v (%v)", data, found, err)
+	}
+	if data, found, err := target.Read(pointerSHA); err != nil || !found || !bytes.Equal(data, lfsContent) {
+		t.Errorf("expected the imported LFS content, got %q found=%v (%v)", data, found, err)
+	}
+	for _, name := range []string{forged, "2222222222222222222222222222222222222222"} {
+		if _, found, _ := target.Read(name); found {
+			t.Errorf("expected the mismatched blob %s to be left out", name)
+		}
+	}
+}
+
+func TestRemoteCacheStoreAndRestore(t *testing.T) {
+	ctx := context.Background()
+	var mu sync.Mutex
+	blobs := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			blobs[r.URL.Path], _ = io.ReadAll(r.Body)
+		case http.MethodGet:
+			if r.URL.Path == "/blobs/denied" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			blob, ok := blobs[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(blob)
+		}
+	}))
+	defer server.Close()
+
+	cache, err := gh.NewRemoteCache(server.URL + "/blobs/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	work := t.TempDir()
+	src := filepath.Join(work, "src.txt")
+	if err := os.WriteFile(src, []byte("shared\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dst := filepath.Join(work, "dst.txt")
+	if found, err := cache.Restore(ctx, "abc123", dst); err != nil || found {
+		t.Errorf("expected miss on empty cache, got found=%v err=%v", found, err)
+	}
+	if _, err := cache.Restore(ctx, "denied", dst); err == nil {
+		t.Errorf("expected a forbidden blob to be reported rather than taken for a miss")
+	}
+
+	if err := cache.Store(ctx, "abc123", src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := blobs["/blobs/abc123"]; !ok {
+		t.Errorf("expected blob uploaded to /blobs/abc123, got: %v", blobs)
+	}
+
+	if found, err := cache.Restore(ctx, "abc123", dst); err != nil || !found {
+		t.Fatalf("expected hit after store, got found=%v err=%v", found, err)
+	}
+	if content, _ := os.ReadFile(dst); string(content) != "shared\n" {
+		t.Errorf("expected restored content: %q, got: %q", "shared\n", content)
+	}
+}
+
+func TestFileCacheStatsAndClear(t *testing.T) {
+	ctx := context.Background()
+	cache, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "src.txt")
+	if err := os.WriteFile(src, []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := cache.Store(ctx, "ce013625030ba8dba906f756967f9e9ca394464a", src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil || stats != (gh.CacheStats{Objects: 1, Bytes: 6}) {
+		t.Errorf("expected 1 object of 6 bytes, got %+v, %v", stats, err)
+	}
+
+	if err := cache.Clear(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats, err := cache.Stats(); err != nil || stats.Objects != 0 {
+		t.Errorf("expected an empty cache after clearing, got %+v, %v", stats, err)
+	}
+}
+
+func TestLayeredCacheBackfillsEarlierLayers(t *testing.T) {
+	ctx := context.Background()
+	local, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	shared, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	work := t.TempDir()
+	src := filepath.Join(work, "src.txt")
+	if err := os.WriteFile(src, []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sha := "ce013625030ba8dba906f756967f9e9ca394464a"
+	if err := shared.Store(ctx, sha, src); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	layered := gh.LayeredCache{local, shared}
+	if found, err := layered.Restore(ctx, sha, filepath.Join(work, "dst.txt")); err != nil || !found {
+		t.Fatalf("expected a hit from the shared layer, got found=%v err=%v", found, err)
+	}
+	if found, err := local.Restore(ctx, sha, filepath.Join(work, "again.txt")); err != nil || !found {
+		t.Errorf("expected the local layer to be backfilled, got found=%v err=%v", found, err)
+	}
+}
@@ -0,0 +1,48 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrCallLimitReached fails API requests once a CallLimit is used up
+var ErrCallLimitReached = errors.New("API call limit reached")
+
+// CallLimit caps how many API requests a client makes, so one run can't drain a token shared
+// with other jobs. Retries count as requests. A nil CallLimit never refuses a request.
+type CallLimit struct {
+	Max int
+
+	mu   sync.Mutex
+	made int
+}
+
+// NewCallLimit creates a limit allowing max API requests
+func NewCallLimit(max int) *CallLimit {
+	return &CallLimit{Max: max}
+}
+
+// Take counts a request against the limit, failing with ErrCallLimitReached once none are left
+func (l *CallLimit) Take() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.made >= l.Max {
+		return fmt.Errorf("%w (%d)", ErrCallLimitReached, l.Max)
+	}
+	l.made++
+	return nil
+}
+
+// Remaining returns how many requests are left, or -1 for a nil CallLimit
+func (l *CallLimit) Remaining() int {
+	if l == nil {
+		return -1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.Max - l.made
+}
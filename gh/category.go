@@ -0,0 +1,66 @@
+package gh
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+)
+
+// Error categories are stable names for why a file failed, for scripts reading --json output
+const (
+	CategoryRateLimit = "rate_limit"
+	CategoryNotFound  = "not_found"
+	CategoryAuth      = "auth"
+	CategoryNetwork   = "network"
+	CategoryDisk      = "disk"
+	CategoryLFS       = "lfs"
+	CategoryBlocked   = "blocked"
+	// CategoryOther covers failures outside the categories above, such as checksum mismatches
+	CategoryOther = "other"
+)
+
+// ErrorCategory classifies a download error into one of the Category constants. Failures
+// fetching Git LFS content are reported as lfs whatever their cause.
+func ErrorCategory(err error) string {
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		switch {
+		case fetchErr.LFS:
+			return CategoryLFS
+		case fetchErr.StatusCode == http.StatusUnavailableForLegalReasons || errors.Is(fetchErr.Err, ErrBlocked):
+			return CategoryBlocked
+		case fetchErr.RateLimited || fetchErr.StatusCode == http.StatusTooManyRequests:
+			return CategoryRateLimit
+		case fetchErr.StatusCode == http.StatusNotFound:
+			return CategoryNotFound
+		case fetchErr.StatusCode == http.StatusUnauthorized || fetchErr.StatusCode == http.StatusForbidden:
+			return CategoryAuth
+		case fetchErr.StatusCode == 0 || fetchErr.StatusCode >= 500:
+			return CategoryNetwork
+		}
+		return CategoryOther
+	}
+
+	var netErr net.Error
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case errors.Is(err, ErrRateLimitExceeded), errors.Is(err, ErrCallLimitReached):
+		return CategoryRateLimit
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrRepositoryNotFound):
+		return CategoryNotFound
+	case errors.Is(err, ErrInvalidToken):
+		return CategoryAuth
+	case errors.Is(err, ErrBlocked):
+		return CategoryBlocked
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.Is(err, syscall.ENOSPC):
+		return CategoryDisk
+	case errors.As(err, &netErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, ErrChaos):
+		return CategoryNetwork
+	}
+	return CategoryOther
+}
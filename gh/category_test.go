@@ -0,0 +1,44 @@
+package gh_test
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"repo-pack/gh"
+	"testing"
+)
+
+func TestErrorCategory(t *testing.T) {
+	cause := errors.New("failed")
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"429", &gh.FetchError{StatusCode: 429, Err: cause}, gh.CategoryRateLimit},
+		{"403 rate limited", &gh.FetchError{StatusCode: 403, RateLimited: true, Err: cause}, gh.CategoryRateLimit},
+		{"403", &gh.FetchError{StatusCode: 403, Err: cause}, gh.CategoryAuth},
+		{"401", &gh.FetchError{StatusCode: 401, Err: cause}, gh.CategoryAuth},
+		{"404", &gh.FetchError{StatusCode: 404, Err: cause}, gh.CategoryNotFound},
+		{"503", &gh.FetchError{StatusCode: 503, Err: cause}, gh.CategoryNetwork},
+		{"no response", &gh.FetchError{Err: &net.OpError{Op: "dial", Err: cause}}, gh.CategoryNetwork},
+		{"lfs 404", &gh.FetchError{StatusCode: 404, LFS: true, Err: cause}, gh.CategoryLFS},
+		{"451", &gh.FetchError{StatusCode: 451, Err: cause}, gh.CategoryBlocked},
+		{"403 disabled", &gh.FetchError{StatusCode: 403, Err: fmt.Errorf("%w: o/r has been disabled", gh.ErrBlocked)}, gh.CategoryBlocked},
+		{"blocked listing", fmt.Errorf("%w: o/r is blocked", gh.ErrBlocked), gh.CategoryBlocked},
+		{"rate limit", fmt.Errorf("listing: %w", gh.ErrRateLimitExceeded), gh.CategoryRateLimit},
+		{"missing repository", fmt.Errorf("%w: o/r", gh.ErrRepositoryNotFound), gh.CategoryNotFound},
+		{"invalid token", gh.ErrInvalidToken, gh.CategoryAuth},
+		{"disk", fmt.Errorf("error saving file a.go %w", &fs.PathError{Op: "open", Path: "a.go", Err: fs.ErrPermission}), gh.CategoryDisk},
+		{"checksum", fmt.Errorf("a.go: %w", gh.ErrChecksumMismatch), gh.CategoryOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gh.ErrorCategory(tt.err); got != tt.expected {
+				t.Errorf("expected category %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package gh
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrChaos is the network error injected by ChaosTransport
+var ErrChaos = errors.New("chaos: injected network failure")
+
+// ChaosTransport wraps Next with random failures and delays, to check that retries, resumes
+// and persisted state hold up on flaky networks. Failed requests get either a network error
+// or a 503 response, at random.
+type ChaosTransport struct {
+	Next http.RoundTripper
+	// FailureRate is the probability, from 0 to 1, that a request fails
+	FailureRate float64
+	// MaxDelay bounds the random delay added before each request
+	MaxDelay time.Duration
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// ParseChaos parses a chaos spec of comma-separated settings: p=<failure rate>,
+// delay=<maximum delay> and seed=<number> for reproducible runs, e.g. "p=0.1,delay=500ms"
+func ParseChaos(spec string) (*ChaosTransport, error) {
+	t := &ChaosTransport{}
+	seed := time.Now().UnixNano()
+	for _, setting := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(setting), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid chaos setting %q, expected key=value", setting)
+		}
+		var err error
+		switch key {
+		case "p":
+			t.FailureRate, err = strconv.ParseFloat(value, 64)
+			if err == nil && (t.FailureRate < 0 || t.FailureRate > 1) {
+				err = fmt.Errorf("must be between 0 and 1")
+			}
+		case "delay":
+			t.MaxDelay, err = time.ParseDuration(value)
+		case "seed":
+			seed, err = strconv.ParseInt(value, 10, 64)
+		default:
+			return nil, fmt.Errorf("unknown chaos setting %q, expected p, delay or seed", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid chaos setting %q: %v", setting, err)
+		}
+	}
+	t.rand = rand.New(rand.NewSource(seed))
+	return t, nil
+}
+
+// roll draws the fate of one request: whether it fails, how, and how long it is delayed
+func (t *ChaosTransport) roll() (fail, asResponse bool, delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rand == nil {
+		t.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	if t.MaxDelay > 0 {
+		delay = time.Duration(t.rand.Int63n(int64(t.MaxDelay)))
+	}
+	return t.rand.Float64() < t.FailureRate, t.rand.Intn(2) == 0, delay
+}
+
+// RoundTrip delays the request at random, then fails it or passes it on to Next
+func (t *ChaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fail, asResponse, delay := t.roll()
+	if delay > 0 {
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(delay):
+		}
+	}
+
+	switch {
+	case fail && asResponse:
+		return &http.Response{
+			Status:        "503 Service Unavailable",
+			StatusCode:    http.StatusServiceUnavailable,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{},
+			Body:          io.NopCloser(strings.NewReader("chaos: injected failure\n")),
+			ContentLength: -1,
+			Request:       req,
+		}, nil
+	case fail:
+		return nil, ErrChaos
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return next.RoundTrip(req)
+}
@@ -0,0 +1,44 @@
+package gh_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"repo-pack/gh"
+	"testing"
+)
+
+func TestChaosTransportFailsAtRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	transport, err := gh.ParseChaos("p=0.3,seed=1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	failed := 0
+	const requests = 200
+	for i := 0; i < requests; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			failed++
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			failed++
+		}
+		resp.Body.Close()
+	}
+	if failed < requests/10 || failed > requests/2 {
+		t.Errorf("expected roughly 30%% of %d requests to fail, got %d", requests, failed)
+	}
+
+	for _, invalid := range []string{"p=2", "p", "delay=soon", "speed=1"} {
+		if _, err := gh.ParseChaos(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"repo-pack/helpers"
+)
+
+const (
+	// DefaultBaseURL is the root of the public GitHub REST API
+	DefaultBaseURL = "https://api.github.com"
+	// DefaultRawBaseURL serves the raw content of public repository files
+	DefaultRawBaseURL = "https://raw.githubusercontent.com"
+	// DefaultMediaBaseURL serves the content of Git LFS files
+	DefaultMediaBaseURL = "https://media.githubusercontent.com/media"
+	// DefaultGitBaseURL hosts the git smart HTTP endpoints of repositories
+	DefaultGitBaseURL = "https://github.com"
+	// DefaultUserAgent identifies requests when no versioned User-Agent is configured
+	DefaultUserAgent = "repo-pack"
+)
+
+// Client talks to the GitHub API and content hosts. The zero value is not usable; create one
+// with NewClient and adjust its fields before use.
+type Client struct {
+	// BaseURL is the API root, without a trailing slash
+	BaseURL string
+	// RawBaseURL, MediaBaseURL and GitBaseURL are the roots raw files, LFS files and git
+	// repositories are served from; GitHub Enterprise Server instances use their own
+	RawBaseURL   string
+	MediaBaseURL string
+	GitBaseURL   string
+	// HTTPClient performs all requests; http.DefaultClient is used when nil
+	HTTPClient *http.Client
+	// Token authenticates API requests when set
+	Token string
+	// UserAgent is sent with every request; GitHub rejects API requests without one
+	UserAgent string
+	// MaxAttempts bounds how many times a request is tried
+	MaxAttempts int
+	// RetryDelay is the wait before the first retry, doubled for each one after
+	RetryDelay time.Duration
+	// RateLimiter, when set, pauses API requests as the rate limit nears exhaustion
+	RateLimiter *RateLimiter
+	// CallLimit, when set, caps the number of API requests made
+	CallLimit *CallLimit
+	// Throttle, when set, caps the combined throughput of file and tarball downloads
+	Throttle *helpers.Throttle
+
+	// lfsAttributes holds the .gitattributes files read to tell LFS pointers from content
+	lfsAttributes lfsAttributesCache
+}
+
+// NewClient creates a client for the public GitHub API using the given token, which may be empty
+func NewClient(token string) *Client {
+	return &Client{
+		BaseURL:      DefaultBaseURL,
+		RawBaseURL:   DefaultRawBaseURL,
+		MediaBaseURL: DefaultMediaBaseURL,
+		GitBaseURL:   DefaultGitBaseURL,
+		Token:        token,
+		UserAgent:    DefaultUserAgent,
+		MaxAttempts:  DefaultMaxAttempts,
+		RetryDelay:   DefaultRetryDelay,
+	}
+}
+
+// UserAgent builds a "repo-pack/<version>" User-Agent, appending suffix when non-empty
+// so that enterprise traffic can be attributed
+func UserAgent(version, suffix string) string {
+	ua := fmt.Sprintf("%s/%s", DefaultUserAgent, version)
+	if suffix = strings.TrimSpace(suffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// getAccept performs a GET request, adding the token only when authenticated is true and an
+// Accept header selecting the response media type when accept is non-empty
+func (c *Client) getAccept(ctx context.Context, url, accept string, authenticated bool) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	if authenticated && c.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
+	}
+
+	// Only the API is rate limited and capped per request; raw and LFS downloads never wait
+	if strings.HasPrefix(url, c.BaseURL) {
+		if err := c.CallLimit.Take(); err != nil {
+			return nil, err
+		}
+		if err := c.RateLimiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.httpClient().Do(req)
+	c.RateLimiter.Observe(resp)
+	return resp, err
+}
+
+// apiURL joins an API path onto the client's base URL
+func (c *Client) apiURL(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// API makes a GET request to the GitHub API for the given repos endpoint.
+// It returns the response body as a byte slice or an error if the request fails.
+func (c *Client) API(ctx context.Context, endpoint string) ([]byte, error) {
+	return c.apiGet(ctx, "repos/"+endpoint)
+}
+
+// apiGet makes an authenticated GET request for any API path and returns the response body.
+// Transient failures are retried like downloads, so a 502 while listing doesn't end the run.
+func (c *Client) apiGet(ctx context.Context, path string) ([]byte, error) {
+	resp, _, err := c.doRequestWithRetry(ctx, c.apiURL(path), "", true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if err := blockedResponse(resp, blockedTarget(path)); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("HTTP request failed with status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
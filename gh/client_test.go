@@ -0,0 +1,556 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/gh"
+	"repo-pack/model"
+	"strings"
+	"testing"
+)
+
+func TestClientViaTreesAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/git/trees/main" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("expected Authorization header: %q, got: %q", "Bearer secret", auth)
+		}
+		w.Write([]byte(`{"tree": [
+			{"type": "tree", "path": "dir"},
+			{"type": "blob", "path": "dir/a.go", "mode": "100644", "sha": "aaa", "size": 12},
+			{"type": "blob", "path": "other/b.go", "mode": "100644", "sha": "bbb", "size": 3}
+		], "truncated": false}`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "dir"}
+	files, truncated, err := client.ViaTreesAPI(context.Background(), components)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{{Path: "dir/a.go", Size: 12, SHA: "aaa", Mode: "100644"}}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+	if truncated {
+		t.Errorf("expected untruncated listing")
+	}
+}
+
+func TestClientListingCompletesTruncatedTrees(t *testing.T) {
+	responses := map[string]string{
+		"/repos/o/r/git/trees/main?recursive=1":    `{"tree": [{"type": "blob", "path": "mono/a.go", "sha": "aaa"}], "truncated": true}`,
+		"/repos/o/r/git/trees/main":                `{"tree": [{"type": "tree", "path": "mono", "sha": "monosha"}, {"type": "blob", "path": "root.go"}]}`,
+		"/repos/o/r/git/trees/monosha?recursive=1": `{"tree": [], "truncated": true}`,
+		"/repos/o/r/git/trees/monosha": `{"tree": [
+			{"type": "blob", "path": "a.go", "sha": "aaa", "size": 1},
+			{"type": "tree", "path": "small", "sha": "smallsha"},
+			{"type": "tree", "path": "huge", "sha": "hugesha"},
+			{"type": "commit", "path": "submodule", "sha": "subsha"}
+		]}`,
+		"/repos/o/r/git/trees/smallsha?recursive=1": `{"tree": [
+			{"type": "blob", "path": "b.go", "sha": "bbb", "size": 2},
+			{"type": "tree", "path": "deep", "sha": "deepsha"},
+			{"type": "blob", "path": "deep/c.go", "sha": "ccc", "size": 3, "mode": "100755"}
+		]}`,
+		"/repos/o/r/git/trees/hugesha?recursive=1": `{"tree": [], "truncated": true}`,
+		"/repos/o/r/git/trees/hugesha":             `{"tree": [], "truncated": true}`,
+		"/repos/o/r/contents/mono/huge?ref=main":   `[{"type": "file", "path": "mono/huge/d.go", "sha": "ddd", "size": 4}]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request: %s", r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "mono", RefResolved: true}
+	files, _, err := client.RepoListingSlashBranchSupport(context.Background(), &components)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{
+		{Path: "mono/a.go", SHA: "aaa", Size: 1},
+		{Path: "mono/small/b.go", SHA: "bbb", Size: 2},
+		{Path: "mono/small/deep/c.go", SHA: "ccc", Size: 3, Mode: "100755"},
+		{Path: "mono/huge/d.go", SHA: "ddd", Size: 4},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+}
+
+func TestClientListIterStreamsTruncatedTrees(t *testing.T) {
+	responses := map[string]string{
+		"/repos/o/r/git/trees/main?recursive=1": `{"tree": [], "truncated": true}`,
+		"/repos/o/r/git/trees/main": `{"tree": [
+			{"type": "blob", "path": "a.go", "sha": "aaa", "size": 1},
+			{"type": "commit", "path": "submodule", "sha": "subsha"},
+			{"type": "tree", "path": "one", "sha": "onesha"},
+			{"type": "tree", "path": "two", "sha": "twosha"}
+		]}`,
+		"/repos/o/r/git/trees/onesha?recursive=1": `{"tree": [{"type": "blob", "path": "b.go", "sha": "bbb", "size": 2}]}`,
+		"/repos/o/r/git/trees/twosha?recursive=1": `{"tree": [{"type": "blob", "path": "c.go", "sha": "ccc", "size": 3}]}`,
+	}
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request: %s", r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", RefResolved: true}
+
+	seq, listErr := client.ListIter(context.Background(), components)
+	if len(requests) != 0 {
+		t.Errorf("expected nothing to be requested before iterating, got %v", requests)
+	}
+	var files []model.FileInfo
+	seq(func(file model.FileInfo) bool {
+		files = append(files, file)
+		return true
+	})
+	if err := listErr(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []model.FileInfo{
+		{Path: "a.go", SHA: "aaa", Size: 1},
+		{Path: "one/b.go", SHA: "bbb", Size: 2},
+		{Path: "two/c.go", SHA: "ccc", Size: 3},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+
+	// Pages hold the files of one response each
+	pages, listErr := client.ListPages(context.Background(), components)
+	var sizes []int
+	pages(func(page []model.FileInfo) bool {
+		sizes = append(sizes, len(page))
+		return true
+	})
+	if err := listErr(); err != nil || !reflect.DeepEqual(sizes, []int{1, 1, 1}) {
+		t.Errorf("expected a page per response, got pages of %v (%v)", sizes, err)
+	}
+
+	// Stopping early leaves the rest of the tree unrequested
+	requests = nil
+	seq, listErr = client.ListIter(context.Background(), components)
+	var first []string
+	seq(func(file model.FileInfo) bool {
+		first = append(first, file.Path)
+		return len(first) < 2
+	})
+	if err := listErr(); err != nil || !reflect.DeepEqual(first, []string{"a.go", "one/b.go"}) {
+		t.Errorf("expected the first two files, got %v (%v)", first, err)
+	}
+	for _, request := range requests {
+		if strings.Contains(request, "twosha") {
+			t.Errorf("expected the listing to stop before %s", request)
+		}
+	}
+}
+
+func TestClientViaContentsAPI(t *testing.T) {
+	listings := map[string]string{
+		"/repos/owner/repo/contents/dir": `[
+			{"type": "file", "path": "dir/a.go", "sha": "aaa", "size": 12},
+			{"type": "dir", "path": "dir/sub dir"},
+			{"type": "submodule", "path": "dir/vendor"}
+		]`,
+		"/repos/owner/repo/contents/dir/sub dir": `[
+			{"type": "symlink", "path": "dir/sub dir/link", "sha": "ccc", "size": 4},
+			{"type": "dir", "path": "dir/sub dir/deeper"}
+		]`,
+		"/repos/owner/repo/contents/dir/sub dir/deeper": `[{"type": "file", "path": "dir/sub dir/deeper/b.go", "sha": "bbb", "size": 3}]`,
+	}
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		if ref := r.URL.Query().Get("ref"); ref != "release/1.0" {
+			t.Errorf("expected ref release/1.0, got %q", ref)
+		}
+		listing, ok := listings[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(listing))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "release/1.0", Dir: "dir/"}
+	files, err := client.ViaContentsAPI(context.Background(), components)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{
+		{Path: "dir/a.go", Size: 12, SHA: "aaa"},
+		{Path: "dir/sub dir/link", Size: 4, SHA: "ccc", Mode: "120000"},
+		{Path: "dir/sub dir/deeper/b.go", Size: 3, SHA: "bbb"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+	if len(requested) != 3 {
+		t.Errorf("expected one request per directory, got %v", requested)
+	}
+}
+
+func TestClientViaContentsAPIStopsOnBrokenListings(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		dir := strings.TrimPrefix(r.URL.Path, "/repos/owner/repo/contents/")
+		if dir == "loop" {
+			// A directory claiming to contain itself would be listed forever
+			w.Write([]byte(`[{"type": "dir", "path": "loop"}]`))
+			return
+		}
+		if dir == "full" {
+			// The contents API stops at 1,000 entries, leaving the rest of the directory out
+			entries := make([]string, 1000)
+			for i := range entries {
+				entries[i] = fmt.Sprintf(`{"type": "file", "path": "full/%d.txt"}`, i)
+			}
+			w.Write([]byte("[" + strings.Join(entries, ",") + "]"))
+			return
+		}
+		w.Write([]byte(`[{"type": "dir", "path": "` + dir + `/d"}]`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	for _, dir := range []string{"loop", "deep", "full"} {
+		requests = 0
+		components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: dir}
+		if _, err := client.ViaContentsAPI(context.Background(), components); err == nil {
+			t.Errorf("expected an error listing %s", dir)
+		}
+		if requests > 100 {
+			t.Errorf("expected listing %s to stop early, made %d requests", dir, requests)
+		}
+	}
+}
+
+func TestClientReportsBlockedRepositories(t *testing.T) {
+	responses := map[string]struct {
+		status int
+		body   string
+	}{
+		"/repos/o/dmca":     {451, `{"message": "Repository access blocked", "block": {"reason": "dmca", "html_url": "https://github.com/github/dmca/blob/master/notice.md"}}`},
+		"/repos/o/disabled": {403, `{"message": "This repository has been disabled."}`},
+		"/repos/o/private":  {403, `{"message": "Resource not accessible by integration"}`},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The repository and everything in it answer alike
+		segments := strings.SplitN(r.URL.Path, "/", 5)
+		response := responses[strings.Join(segments[:4], "/")]
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	for _, test := range []struct {
+		repo    string
+		blocked bool
+		message string
+	}{
+		{repo: "dmca", blocked: true, message: "o/dmca is unavailable for legal reasons (dmca) and can't be downloaded until GitHub restores access; the notice is at https://github.com/github/dmca/blob/master/notice.md"},
+		{repo: "disabled", blocked: true, message: "o/disabled has been disabled"},
+		{repo: "private"},
+	} {
+		components := model.RepoURLComponents{Owner: "o", Repository: test.repo, Ref: "main"}
+		_, err := client.FetchRepoIsPrivate(context.Background(), &components)
+		if errors.Is(err, gh.ErrBlocked) != test.blocked {
+			t.Errorf("expected %s blocked to be %v, got: %v", test.repo, test.blocked, err)
+			continue
+		}
+		if test.blocked && !strings.Contains(err.Error(), test.message) {
+			t.Errorf("expected the error for %s to contain %q, got: %v", test.repo, test.message, err)
+		}
+
+		_, _, err = client.ViaTreesAPI(context.Background(), components)
+		if errors.Is(err, gh.ErrBlocked) != test.blocked {
+			t.Errorf("expected listing %s blocked to be %v, got: %v", test.repo, test.blocked, err)
+		}
+	}
+}
+
+func TestClientSearchCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search/code" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		if q := r.URL.Query().Get("q"); q != "filename:Dockerfile repo:owner/repo" {
+			t.Errorf("unexpected query: %q", q)
+		}
+		w.Write([]byte(`{"total_count": 2, "items": [
+			{"path": "deploy/api/Dockerfile", "sha": "aaa"},
+			{"path": "Dockerfile", "sha": "bbb"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "deploy"}
+	files, err := client.SearchCode(context.Background(), components, "filename:Dockerfile")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{{Path: "deploy/api/Dockerfile", Size: -1, SHA: "aaa"}}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+}
+
+func TestClientPullRequestFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/pulls/7/files" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"filename": "dir/added.go", "status": "added", "sha": "aaa"},
+			{"filename": "dir/gone.go", "status": "removed", "sha": "bbb"},
+			{"filename": "other/changed.go", "status": "modified", "sha": "ccc"}
+		]`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Dir: "dir"}
+	files, err := client.PullRequestFiles(context.Background(), components, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{{Path: "dir/added.go", Size: -1, SHA: "aaa"}}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+}
+
+func TestClientFetchPrivateFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/owner/repo/contents/dir/a b.go" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		if ref := r.URL.Query().Get("ref"); ref != "feature/x" {
+			t.Errorf("expected ref: %q, got: %q", "feature/x", ref)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("expected Authorization header: %q, got: %q", "Bearer secret", auth)
+		}
+		if accept := r.Header.Get("Accept"); accept != "application/vnd.github.raw" {
+			t.Errorf("expected raw media type, got: %q", accept)
+		}
+		w.Write([]byte("package dir\n"))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+
+	outputDir := t.TempDir()
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "feature/x", Dir: "dir", Private: true}
+	file := model.FileInfo{Path: "dir/a b.go", Size: -1}
+	result, err := client.FetchPublicFile(context.Background(), file, &components, gh.FetchOptions{OutputDir: outputDir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	content, err := os.ReadFile(result.Path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(content) != "package dir\n" {
+		t.Errorf("expected content: %q, got: %q", "package dir\n", content)
+	}
+}
+
+func TestClientFetchVerifiesBlobSHA(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("package dir\n"))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "dir", Private: true}
+	opts := gh.FetchOptions{OutputDir: t.TempDir(), Verify: true}
+
+	file := model.FileInfo{Path: "dir/a.go", Size: 12, SHA: "10edbfd344ed3d4d21695e41a649220c3c841718"}
+	result, err := client.FetchPublicFile(context.Background(), file, &components, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Verified {
+		t.Errorf("expected matching content to be verified")
+	}
+
+	file.SHA = "0000000000000000000000000000000000000000"
+	result, err = client.FetchPublicFile(context.Background(), file, &components, opts)
+	if !errors.Is(err, gh.ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "dir", "a.go")); !os.IsNotExist(err) {
+		t.Errorf("expected the mismatched file to be removed, got: %v", err)
+	}
+}
+
+func TestClientFetchRetriesChecksumMismatch(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		// The first response is cut short, as by a dropped connection behind a proxy
+		if requests == 1 {
+			w.Write([]byte("package"))
+			return
+		}
+		w.Write([]byte("package dir\n"))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "dir", Private: true}
+	var warnings []error
+	opts := gh.FetchOptions{OutputDir: t.TempDir(), Verify: true, Warn: func(err error) { warnings = append(warnings, err) }}
+
+	file := model.FileInfo{Path: "dir/a.go", Size: 12, SHA: "10edbfd344ed3d4d21695e41a649220c3c841718"}
+	result, err := client.FetchPublicFile(context.Background(), file, &components, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Verified || requests != 2 || len(warnings) != 1 {
+		t.Errorf("expected a verified file after 2 requests and 1 warning, got verified %v after %d and %v", result.Verified, requests, warnings)
+	}
+
+	requests = 0
+	file.SHA = "0000000000000000000000000000000000000000"
+	if _, err := client.FetchPublicFile(context.Background(), file, &components, opts); !errors.Is(err, gh.ErrChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got: %v", err)
+	}
+	if requests != client.MaxAttempts {
+		t.Errorf("expected %d downloads of a file that never matches, got %d", client.MaxAttempts, requests)
+	}
+}
+
+func TestClientResolveCommit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/o/r/commits/release%2F1.0" && r.URL.RawPath != "/repos/o/r/commits/release%2F1.0" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Accept") != "application/vnd.github.sha" {
+			t.Errorf("expected the sha media type, got %q", r.Header.Get("Accept"))
+		}
+		w.Write([]byte("0123456789abcdef0123456789abcdef01234567"))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	sha, err := client.ResolveCommit(context.Background(), model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "release/1.0"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sha != "0123456789abcdef0123456789abcdef01234567" {
+		t.Errorf("unexpected sha %q", sha)
+	}
+}
+
+func TestClientOrgRepositories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orgs/acme/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		// A full first page makes the client ask for the next one
+		if r.URL.Query().Get("page") == "1" {
+			w.Write([]byte("[" + strings.Repeat(`{"name":"svc","default_branch":"main"},`, 99) + `{"name":"api","default_branch":"trunk","topics":["go"]}]`))
+			return
+		}
+		w.Write([]byte(`[{"name":"old","default_branch":"master","archived":true}]`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	repos, err := client.OrgRepositories(context.Background(), "acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(repos) != 101 {
+		t.Fatalf("expected 101 repositories over two pages, got %d", len(repos))
+	}
+	if repos[99].DefaultBranch != "trunk" || len(repos[99].Topics) != 1 || !repos[100].Archived {
+		t.Errorf("unexpected repositories %+v and %+v", repos[99], repos[100])
+	}
+}
+
+func TestClientMatchingRefs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/branches":
+			w.Write([]byte(`[{"name":"main"},{"name":"release/1.0"},{"name":"release/2.0"}]`))
+		case "/repos/o/r/tags":
+			w.Write([]byte(`[{"name":"release/2.0"},{"name":"v1.0.0"},{"name":"release/3.0"}]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	refs, err := client.MatchingRefs(context.Background(), model.RepoURLComponents{Owner: "o", Repository: "r"}, "release/*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []string{"release/1.0", "release/2.0", "release/3.0"}; !reflect.DeepEqual(refs, expected) {
+		t.Errorf("expected refs %v, got %v", expected, refs)
+	}
+}
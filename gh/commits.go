@@ -0,0 +1,111 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"repo-pack/model"
+)
+
+// commitInfo is the part of a commits API entry describing when it was committed
+type commitInfo struct {
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// LastModified returns when the file or directory at path was last changed on the ref
+func (c *Client) LastModified(ctx context.Context, components model.RepoURLComponents, path string) (time.Time, error) {
+	contents, err := c.API(
+		ctx,
+		fmt.Sprintf(
+			"%s/%s/commits?path=%s&sha=%s&per_page=1",
+			components.Owner,
+			components.Repository,
+			url.QueryEscape(path),
+			url.QueryEscape(components.ContentRef()),
+		),
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var commits []commitInfo
+	if err := json.Unmarshal(contents, &commits); err != nil {
+		return time.Time{}, err
+	}
+	if len(commits) == 0 {
+		return time.Time{}, fmt.Errorf("no commits found for %s", path)
+	}
+	return commits[0].Commit.Committer.Date, nil
+}
+
+// Verification is GitHub's signature check of a commit
+type Verification struct {
+	// SHA is the commit the ref resolved to
+	SHA      string
+	Verified bool
+	// Reason explains the verdict, e.g. "valid", "unsigned" or "unknown_key"
+	Reason string
+}
+
+// VerifyCommit resolves the ref, unless a commit is already pinned, and reports whether GitHub
+// verified the commit's signature
+func (c *Client) VerifyCommit(ctx context.Context, components model.RepoURLComponents) (Verification, error) {
+	contents, err := c.API(
+		ctx,
+		fmt.Sprintf("%s/%s/commits/%s", components.Owner, components.Repository, url.PathEscape(components.ContentRef())),
+	)
+	if err != nil {
+		return Verification{}, fmt.Errorf("failed to look up commit %s: %v", components.Ref, err)
+	}
+
+	var commit struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Verification struct {
+				Verified bool   `json:"verified"`
+				Reason   string `json:"reason"`
+			} `json:"verification"`
+		} `json:"commit"`
+	}
+	if err := json.Unmarshal(contents, &commit); err != nil {
+		return Verification{}, err
+	}
+
+	return Verification{
+		SHA:      commit.SHA,
+		Verified: commit.Commit.Verification.Verified,
+		Reason:   commit.Commit.Verification.Reason,
+	}, nil
+}
+
+// shaMediaType asks the commits API for just the commit SHA instead of the full commit
+const shaMediaType = "application/vnd.github.sha"
+
+// ResolveCommit returns the SHA of the commit the ref points to
+func (c *Client) ResolveCommit(ctx context.Context, components model.RepoURLComponents) (string, error) {
+	commitURL := c.apiURL(fmt.Sprintf("repos/%s/%s/commits/%s", components.Owner, components.Repository, url.PathEscape(components.Ref)))
+	resp, _, err := c.doRequestWithRetry(ctx, commitURL, shaMediaType, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", components.Ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to resolve %s: HTTP %s", components.Ref, resp.Status)
+	}
+
+	sha, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %v", components.Ref, err)
+	}
+	return strings.TrimSpace(string(sha)), nil
+}
@@ -5,10 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"path"
+	"slices"
 	"strings"
 
 	"repo-pack/model"
@@ -17,11 +15,26 @@ import (
 type Item struct {
 	Type string `json:"type"`
 	Path string `json:"path"`
+	Mode string `json:"mode,omitempty"`
 	URL  string `json:"url,omitempty"`
 	SHA  string `json:"sha,omitempty"`
 	Size int64  `json:"size,omitempty"`
 }
 
+// fileInfo converts a listing entry into the model shared with the download pipeline
+func (item Item) fileInfo() model.FileInfo {
+	// Both APIs list submodules by type, the Contents API without a mode
+	if item.Type == "commit" || item.Type == "submodule" {
+		item.Mode = submoduleMode
+	}
+	return model.FileInfo{
+		Path: item.Path,
+		Size: item.Size,
+		SHA:  item.SHA,
+		Mode: item.Mode,
+	}
+}
+
 type TreeResponse struct {
 	SHA       *string `json:"sha,omitempty"`
 	Tree      []Item  `json:"tree"`
@@ -30,116 +43,137 @@ type TreeResponse struct {
 
 var ErrNotFound = errors.New("not found")
 
-// API makes a GET request to the GitHub API with the given endpoint and optional authentication token.
-// It returns the response body as a byte slice or an error if the request fails.
-func API(ctx context.Context, endpoint, token string) ([]byte, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s", endpoint)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+// maxContentsDepth bounds how deep ViaContentsAPI descends below the listed directory
+const maxContentsDepth = 64
 
-	if token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-	}
+// maxContentsEntries is the most entries the Contents API lists for one directory; larger
+// directories are cut off there without saying so
+const maxContentsEntries = 1000
 
-	client := http.DefaultClient
-	resp, err := client.Do(req)
+// ViaContentsAPI retrieves a list of files in a GitHub repository directory using the Contents API.
+// It handles both files and subdirectories recursively, one request per directory. Symlinks are
+// listed as files, as the Trees API lists them, and submodules are left out.
+func (c *Client) ViaContentsAPI(ctx context.Context, urlComponents model.RepoURLComponents) ([]model.FileInfo, error) {
+	listing, err := c.contentsListing(ctx, urlComponents, strings.Trim(urlComponents.Dir, "/"), 0)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("HTTP request failed with status code: %d", resp.StatusCode)
-	}
+	files, _ := splitSubmodules(listing)
+	return files, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
+// contentsListing lists the files and submodules beneath dir, depth directories below the one
+// ViaContentsAPI was asked for
+func (c *Client) contentsListing(ctx context.Context, urlComponents model.RepoURLComponents, dir string, depth int) ([]model.FileInfo, error) {
+	files := []model.FileInfo{}
+	if _, err := c.walkContents(ctx, urlComponents, dir, depth, collect(&files)); err != nil {
 		return nil, err
 	}
-
-	return body, nil
+	return files, nil
 }
 
-// ViaContentsAPI retrieves a list of files in a GitHub repository directory using the Contents API.
-// It handles both files and subdirectories recursively.
-func ViaContentsAPI(ctx context.Context, urlComponents model.RepoURLComponents, token string) ([]string, error) {
-	files := []string{}
-	contents, err := API(
+// walkContents passes the files and submodules beneath dir to yield a page at a time, each
+// page holding those listed since the previous request, reporting whether yield asked for more
+func (c *Client) walkContents(ctx context.Context, urlComponents model.RepoURLComponents, dir string, depth int, yield func([]model.FileInfo) bool) (bool, error) {
+	if depth > maxContentsDepth {
+		return false, fmt.Errorf("directory %s is nested more than %d levels deep", dir, maxContentsDepth)
+	}
+
+	contents, err := c.API(
 		ctx,
 		fmt.Sprintf(
 			"%s/%s/contents/%s?ref=%s",
 			urlComponents.Owner,
 			urlComponents.Repository,
-			urlComponents.Dir,
-			urlComponents.Ref,
+			escapePath(dir),
+			url.QueryEscape(urlComponents.ContentRef()),
 		),
-		token,
 	)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
 
 	var items []Item
 	err = json.Unmarshal(contents, &items)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	if len(items) >= maxContentsEntries {
+		return false, fmt.Errorf("directory %s has %d entries or more, the most the contents API lists, so its listing would be incomplete", dir, maxContentsEntries)
 	}
 
+	var page []model.FileInfo
 	for _, item := range items {
 		switch item.Type {
-		case "file":
-			files = append(files, item.Path)
+		case "file", "submodule":
+			page = append(page, item.fileInfo())
+		case "symlink":
+			item.Mode = symlinkMode
+			page = append(page, item.fileInfo())
 		case "dir":
-			subFiles, err := ViaContentsAPI(ctx, urlComponents, token)
-			if err != nil {
-				return nil, err
+			// A directory can't contain itself, so this only guards against a broken response
+			if item.Path == dir || !inDir(item.Path, dir) {
+				return false, fmt.Errorf("directory %s lists %s as a subdirectory", dir, item.Path)
+			}
+			if !yieldPage(yield, page) {
+				return false, nil
+			}
+			page = nil
+			if more, err := c.walkContents(ctx, urlComponents, item.Path, depth+1, yield); !more || err != nil {
+				return false, err
 			}
-			files = append(files, subFiles...)
-		default:
-			return nil, fmt.Errorf("ignoring item with unknown type: %s", item.Type)
 		}
 	}
 
-	return files, nil
+	return yieldPage(yield, page), nil
+}
+
+// yieldPage passes a non-empty page to yield, reporting whether yield asked for more
+func yieldPage(yield func([]model.FileInfo) bool, page []model.FileInfo) bool {
+	return len(page) == 0 || yield(page)
+}
+
+// collect returns a yield function appending every page to files
+func collect(files *[]model.FileInfo) func([]model.FileInfo) bool {
+	return func(page []model.FileInfo) bool {
+		*files = append(*files, page...)
+		return true
+	}
 }
 
 // ViaTreesAPI retrieves a list of files in a GitHub repository directory using the Git Trees API.
 // It handles both files and subdirectories recursively, and indicates if the response was truncated.
-func ViaTreesAPI(
+// Submodules are left out.
+func (c *Client) ViaTreesAPI(
 	ctx context.Context,
 	urlComponents model.RepoURLComponents,
-	token string,
-) (files []string, truncated bool, err error) {
-	if !strings.HasSuffix(urlComponents.Dir, "/") {
-		urlComponents.Dir += "/"
-	}
-
-	files = []string{}
-	contents, err := API(
-		ctx,
-		fmt.Sprintf(
-			"%s/%s/git/trees/%s?recursive=1",
-			urlComponents.Owner,
-			urlComponents.Repository,
-			urlComponents.Ref,
-		),
-		token,
-	)
+) (files []model.FileInfo, truncated bool, err error) {
+	listing, truncated, err := c.treesListing(ctx, urlComponents)
 	if err != nil {
 		return nil, false, err
 	}
+	files, _ = splitSubmodules(listing)
+	return files, truncated, nil
+}
 
-	var treeResponse TreeResponse
-	err = json.Unmarshal(contents, &treeResponse)
+// treesListing lists the files and submodules of a directory with one recursive Trees API request
+func (c *Client) treesListing(
+	ctx context.Context,
+	urlComponents model.RepoURLComponents,
+) (files []model.FileInfo, truncated bool, err error) {
+	if urlComponents.Dir != "" && !strings.HasSuffix(urlComponents.Dir, "/") {
+		urlComponents.Dir += "/"
+	}
+
+	files = []model.FileInfo{}
+	treeResponse, err := c.tree(ctx, urlComponents, urlComponents.ContentRef(), true)
 	if err != nil {
 		return nil, false, err
 	}
 
 	for _, item := range treeResponse.Tree {
-		if item.Type == "blob" && strings.HasPrefix(item.Path, urlComponents.Dir) {
-			files = append(files, item.Path)
+		if (item.Type == "blob" || item.Type == "commit") && strings.HasPrefix(item.Path, urlComponents.Dir) {
+			files = append(files, item.fileInfo())
 		}
 	}
 
@@ -148,45 +182,210 @@ func ViaTreesAPI(
 	return files, truncated, nil
 }
 
+// ViaSubtrees lists a directory too large for one recursive Trees API response, as in huge
+// monorepos. It walks down to the directory's tree and lists it recursively, splitting any
+// subtree whose listing is still truncated into one request per subdirectory. A directory
+// with too many entries to list even on its own falls back to the Contents API. Submodules are
+// left out.
+func (c *Client) ViaSubtrees(ctx context.Context, urlComponents model.RepoURLComponents) ([]model.FileInfo, error) {
+	listing, err := c.subtrees(ctx, urlComponents)
+	if err != nil {
+		return nil, err
+	}
+	files, _ := splitSubmodules(listing)
+	return files, nil
+}
+
+// subtrees lists the files and submodules of a directory as ViaSubtrees does
+func (c *Client) subtrees(ctx context.Context, urlComponents model.RepoURLComponents) ([]model.FileInfo, error) {
+	files := []model.FileInfo{}
+	if _, err := c.walkSubtrees(ctx, urlComponents, collect(&files)); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// walkSubtrees passes the files and submodules of a directory to yield as ViaSubtrees lists
+// them, a page per response, reporting whether yield asked for more
+func (c *Client) walkSubtrees(ctx context.Context, urlComponents model.RepoURLComponents, yield func([]model.FileInfo) bool) (bool, error) {
+	dir := strings.Trim(urlComponents.Dir, "/")
+	sha := urlComponents.ContentRef()
+	if dir != "" {
+		for _, segment := range strings.Split(dir, "/") {
+			level, err := c.tree(ctx, urlComponents, sha, false)
+			if err != nil {
+				return false, err
+			}
+			i := slices.IndexFunc(level.Tree, func(item Item) bool { return item.Type == "tree" && item.Path == segment })
+			if i < 0 {
+				return false, fmt.Errorf("directory %s not found at %s", dir, urlComponents.Ref)
+			}
+			sha = level.Tree[i].SHA
+		}
+	}
+	return c.walkSubtree(ctx, urlComponents, sha, dir, yield)
+}
+
+// walkSubtree passes the files and submodules of the tree sha, which lies at dir in the
+// repository, to yield a page at a time, reporting whether yield asked for more
+func (c *Client) walkSubtree(ctx context.Context, urlComponents model.RepoURLComponents, sha, dir string, yield func([]model.FileInfo) bool) (bool, error) {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+
+	treeResponse, err := c.tree(ctx, urlComponents, sha, true)
+	if err != nil {
+		return false, err
+	}
+	if !treeResponse.Truncated {
+		var page []model.FileInfo
+		for _, item := range treeResponse.Tree {
+			if item.Type == "blob" || item.Type == "commit" {
+				item.Path = prefix + item.Path
+				page = append(page, item.fileInfo())
+			}
+		}
+		return yieldPage(yield, page), nil
+	}
+
+	level, err := c.tree(ctx, urlComponents, sha, false)
+	if err != nil {
+		return false, err
+	}
+	if level.Truncated {
+		return c.walkContents(ctx, urlComponents, dir, 0, yield)
+	}
+	var page []model.FileInfo
+	for _, item := range level.Tree {
+		switch item.Type {
+		case "blob", "commit":
+			item.Path = prefix + item.Path
+			page = append(page, item.fileInfo())
+		case "tree":
+			if !yieldPage(yield, page) {
+				return false, nil
+			}
+			page = nil
+			if more, err := c.walkSubtree(ctx, urlComponents, item.SHA, prefix+item.Path, yield); !more || err != nil {
+				return false, err
+			}
+		}
+	}
+	return yieldPage(yield, page), nil
+}
+
+// tree fetches the Trees API listing of treeish, a ref or tree SHA, with every level beneath
+// it when recursive
+func (c *Client) tree(ctx context.Context, urlComponents model.RepoURLComponents, treeish string, recursive bool) (TreeResponse, error) {
+	endpoint := fmt.Sprintf("%s/%s/git/trees/%s", urlComponents.Owner, urlComponents.Repository, treeish)
+	if recursive {
+		endpoint += "?recursive=1"
+	}
+	contents, err := c.API(ctx, endpoint)
+	if err != nil {
+		return TreeResponse{}, err
+	}
+
+	var treeResponse TreeResponse
+	if err := json.Unmarshal(contents, &treeResponse); err != nil {
+		return TreeResponse{}, err
+	}
+	return treeResponse, nil
+}
+
 // RepoListingSlashBranchSupport fetches repository listing recursively.
-// It uses the provided context, repository components, and token for authentication.
-// It returns the list of files, the final reference, and an error (if any).
-func RepoListingSlashBranchSupport(ctx context.Context, components *model.RepoURLComponents, token string) ([]string, string, error) {
-	var files []string
-	var isTruncated bool
+// It uses the provided context and repository components, authenticating with the client's token.
+// A ref taken from the URL is first settled with ResolveURLRef, so branches with slashes work.
+// Listings the Trees API truncates are completed with ViaSubtrees.
+// It returns the list of files with their sizes, the final reference, and an error (if any).
+// Submodules are left out; RepoListing returns them too.
+func (c *Client) RepoListingSlashBranchSupport(ctx context.Context, components *model.RepoURLComponents) ([]model.FileInfo, string, error) {
+	files, _, ref, err := c.RepoListing(ctx, components)
+	return files, ref, err
+}
 
-	ref := components.Ref
-	dir := components.Dir
+// RepoListing lists a repository directory as RepoListingSlashBranchSupport does, returning
+// the submodules beneath it apart from its files. A submodule's SHA is the commit it's pinned to.
+func (c *Client) RepoListing(ctx context.Context, components *model.RepoURLComponents) (files, submodules []model.FileInfo, ref string, err error) {
+	if err := c.ResolveURLRef(ctx, components); err != nil {
+		return nil, nil, "", err
+	}
 
-	decodedDir, err := url.QueryUnescape(dir)
+	listing, truncated, err := c.treesListing(ctx, *components)
 	if err != nil {
-		return nil, "", fmt.Errorf("error decoding: %s", dir)
-	}
-
-	dirParts := strings.Split(decodedDir, "/")
-
-	for len(dirParts) > 0 {
-		content, truncated, err := ViaTreesAPI(ctx, *components, token)
-		if err == nil {
-			files = content
-			isTruncated = truncated
-			break
-		} else if errors.Is(err, ErrNotFound) {
-			ref = path.Join(ref, dirParts[0])
-			dirParts = dirParts[1:]
-			components.Dir = strings.Join(dirParts, "/")
-		} else {
-			return nil, "", err
+		return nil, nil, "", err
+	}
+
+	if truncated {
+		if listing, err = c.subtrees(ctx, *components); err != nil {
+			return nil, nil, "", fmt.Errorf("listing truncated by the Trees API: %v", err)
 		}
 	}
 
-	if len(files) == 0 && isTruncated {
-		files, err := ViaContentsAPI(ctx, *components, token)
-		if err != nil {
-			return nil, "", err
+	files, submodules = splitSubmodules(listing)
+	return files, submodules, components.Ref, nil
+}
+
+// FileSeq yields the files of a listing one at a time, stopping when yield returns false. It
+// has the shape of iter.Seq[model.FileInfo], so Go 1.23 and later can range over it.
+type FileSeq func(yield func(model.FileInfo) bool)
+
+// PageSeq yields the files of a listing a page at a time, each page holding the files of one
+// API response, stopping when yield returns false
+type PageSeq func(yield func([]model.FileInfo) bool)
+
+// ListIter lists a repository directory as RepoListingSlashBranchSupport does, but yields the
+// files as each response arrives instead of collecting them, so work on the first files can
+// start before a listing of hundreds of thousands is complete. Nothing is requested until the
+// sequence is iterated. Once it ends, the returned function reports the error that cut it
+// short, if any; a listing stopped by yield isn't an error. Submodules are left out.
+func (c *Client) ListIter(ctx context.Context, components model.RepoURLComponents) (FileSeq, func() error) {
+	pages, listErr := c.ListPages(ctx, components)
+	seq := func(yield func(model.FileInfo) bool) {
+		pages(func(page []model.FileInfo) bool {
+			for _, file := range page {
+				if !yield(file) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return seq, listErr
+}
+
+// ListPages lists a repository directory as ListIter does, a page per response, for callers
+// with work to do once each response is handled, such as flushing output
+func (c *Client) ListPages(ctx context.Context, components model.RepoURLComponents) (PageSeq, func() error) {
+	var err error
+	seq := func(yield func([]model.FileInfo) bool) {
+		if err = c.ResolveURLRef(ctx, &components); err != nil {
+			return
+		}
+		files := func(page []model.FileInfo) bool {
+			page, _ = splitSubmodules(page)
+			return yieldPage(yield, page)
+		}
+
+		listing, truncated, treesErr := c.treesListing(ctx, components)
+		if treesErr != nil {
+			err = treesErr
+			return
+		}
+		if !truncated {
+			files(listing)
+			return
+		}
+		if _, subtreesErr := c.walkSubtrees(ctx, components, files); subtreesErr != nil {
+			err = fmt.Errorf("listing truncated by the Trees API: %v", subtreesErr)
 		}
-		return files, ref, nil
 	}
+	return seq, func() error { return err }
+}
 
-	return files, ref, nil
+// inDir reports whether a repository path lies beneath dir, where an empty dir is the root
+func inDir(filePath, dir string) bool {
+	dir = strings.Trim(dir, "/")
+	return dir == "" || strings.HasPrefix(filePath, dir+"/")
 }
@@ -0,0 +1,368 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"repo-pack/gitproto"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// DeltaStats summarises a download made with the git protocol strategy
+type DeltaStats struct {
+	Files    int
+	Restored int
+	Fetched  int
+}
+
+// remote returns the git smart HTTP endpoint of a repository
+func (c *Client) remote(components *model.RepoURLComponents) *gitproto.Remote {
+	return &gitproto.Remote{
+		URL:        fmt.Sprintf("%s/%s/%s.git", strings.TrimSuffix(c.GitBaseURL, "/"), components.Owner, components.Repository),
+		HTTPClient: c.HTTPClient,
+		Token:      c.Token,
+		UserAgent:  c.UserAgent,
+	}
+}
+
+// resolveGitRef resolves the URL's ref to a commit, moving leading directory segments into
+// the ref until it matches, which handles branch names containing slashes
+func resolveGitRef(ctx context.Context, remote *gitproto.Remote, components *model.RepoURLComponents) (string, error) {
+	if components.Commit != "" {
+		return components.Commit, nil
+	}
+	ref := components.Ref
+	dirParts := strings.Split(strings.Trim(components.Dir, "/"), "/")
+	for {
+		commit, err := remote.ResolveRef(ctx, ref)
+		if err == nil {
+			if components.IsFile && ref != components.Ref {
+				// Segments moved into the ref no longer belong to the file's path either
+				components.FilePath = strings.TrimPrefix(components.FilePath, strings.TrimPrefix(ref, components.Ref+"/")+"/")
+			}
+			components.Ref = ref
+			components.Dir = strings.Join(dirParts, "/")
+			return commit, nil
+		}
+		if len(dirParts) == 0 || dirParts[0] == "" {
+			return "", err
+		}
+		ref = path.Join(ref, dirParts[0])
+		dirParts = dirParts[1:]
+	}
+}
+
+// onlyFile keeps just the entry for filePath
+func onlyFile(files []model.FileInfo, filePath string) []model.FileInfo {
+	for _, file := range files {
+		if file.Path == filePath {
+			return []model.FileInfo{file}
+		}
+	}
+	return nil
+}
+
+// listGitTree walks the fetched trees of commit down to dir and returns the blobs beneath it
+func listGitTree(pack *gitproto.Pack, commit, dir string) ([]model.FileInfo, error) {
+	commitObject, ok := pack.Objects[commit]
+	if !ok {
+		return nil, fmt.Errorf("commit %s missing from pack", commit)
+	}
+	treeID, err := gitproto.CommitTree(commitObject.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	readTree := func(oid string) ([]gitproto.TreeEntry, error) {
+		tree, ok := pack.Objects[oid]
+		if !ok || tree.Type != gitproto.TypeTree {
+			return nil, fmt.Errorf("tree %s missing from pack", oid)
+		}
+		return gitproto.ParseTree(tree.Data)
+	}
+
+	dir = strings.Trim(dir, "/")
+	if dir != "" {
+		for _, name := range strings.Split(dir, "/") {
+			entries, err := readTree(treeID)
+			if err != nil {
+				return nil, err
+			}
+			found := false
+			for _, entry := range entries {
+				if entry.Name == name && entry.IsTree() {
+					treeID, found = entry.OID, true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("directory %s not found: %w", dir, ErrNotFound)
+			}
+		}
+	}
+
+	files := []model.FileInfo{}
+	var walk func(treeID, prefix string) error
+	walk = func(treeID, prefix string) error {
+		entries, err := readTree(treeID)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			entryPath := path.Join(prefix, entry.Name)
+			switch {
+			case entry.IsTree():
+				if err := walk(entry.OID, entryPath); err != nil {
+					return err
+				}
+			case entry.IsBlob():
+				files = append(files, model.FileInfo{Path: entryPath, SHA: entry.OID, Mode: entry.Mode, Size: -1})
+			}
+		}
+		return nil
+	}
+	return files, walk(treeID, dir)
+}
+
+// cachedBlob reads a blob from the cache for use as a delta base. LFS content is cached under
+// its pointer's ID, so the content is checked against the ID before it is trusted.
+func cachedBlob(cache *FileCache, oid string) (gitproto.Object, bool) {
+	data, found, err := cache.Read(oid)
+	if err != nil || !found || gitproto.HashObject(gitproto.TypeBlob, data) != oid {
+		return gitproto.Object{}, false
+	}
+	return gitproto.Object{Type: gitproto.TypeBlob, Data: data}, true
+}
+
+// syncScope names the selection a git strategy download covers: its directory or file and
+// the filters applied to it. The cache only holds the blobs of that selection, so the commit
+// synced is only offered as a have to later downloads of the same one.
+func syncScope(components *model.RepoURLComponents, opts FetchOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%q\x00%v\x00%v\x00%v", components.Dir, components.FilePath,
+		opts.Excludes, opts.Include, opts.Exclude, opts.TextOnly)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// FetchViaGit downloads a directory by negotiating packfiles over git's smart HTTP protocol,
+// like a depth-1 sparse checkout of just that directory. Only trees are fetched to list it.
+// With a cache, blobs already cached are restored locally and the rest are requested by ID
+// as a thin pack against the last commit synced of the same directory and filters, which
+// lets the server send deltas against blobs the cache holds. A nil cache fetches every blob.
+func (c *Client) FetchViaGit(
+	ctx context.Context,
+	components *model.RepoURLComponents,
+	cache *FileCache,
+	opts FetchOptions,
+) (DeltaStats, error) {
+	remote := c.remote(components)
+	commit, err := resolveGitRef(ctx, remote, components)
+	if err != nil {
+		return DeltaStats{}, err
+	}
+
+	trees, err := remote.Fetch(ctx, gitproto.FetchRequest{
+		Wants:  []string{commit},
+		Filter: "blob:none",
+		Depth:  1,
+	})
+	if err != nil {
+		return DeltaStats{}, fmt.Errorf("error fetching trees: %w", err)
+	}
+
+	files, err := listGitTree(trees, commit, components.Dir)
+	if err != nil {
+		return DeltaStats{}, err
+	}
+	if components.IsFile {
+		files = onlyFile(files, components.FilePath)
+		if len(files) == 0 {
+			return DeltaStats{}, fmt.Errorf("file %s not found at %s", components.FilePath, components.Ref)
+		}
+	}
+
+	if !components.IsFile {
+		files, _ = helpers.ExcludeNames(files, components.Dir, opts.Excludes)
+		files, _ = helpers.FilterGlobs(files, components.Dir, opts.Include, opts.Exclude)
+	}
+	if opts.TextOnly {
+		files, _ = helpers.FilterTextFiles(files)
+	}
+
+	stats := DeltaStats{Files: len(files)}
+	scope := syncScope(components, opts)
+	baseDir := filepath.Base(components.OutputRoot())
+	cacheOpts := opts
+	cacheOpts.Cache = nil
+	if cache != nil {
+		cacheOpts.Cache = cache
+	}
+
+	wanted := []string{}
+	for _, file := range files {
+		if result, found, err := restoreFromCache(ctx, file, baseDir, cacheOpts); err == nil && found {
+			if err := opts.finish(file, &result); err != nil && !errors.Is(err, ErrBinarySkipped) {
+				return stats, err
+			}
+			stats.Restored++
+			continue
+		}
+		wanted = append(wanted, file.SHA)
+	}
+
+	if len(wanted) > 0 {
+		var have string
+		if cache != nil {
+			have = cache.LastSynced(components.Owner, components.Repository, scope)
+		}
+		if err := c.fetchBlobs(ctx, remote, components, files, wanted, have, cache, opts); err != nil {
+			return stats, err
+		}
+		stats.Fetched = len(wanted)
+	}
+
+	if cache != nil {
+		if err := cache.SetLastSynced(components.Owner, components.Repository, scope, commit); err != nil {
+			opts.warn(err)
+		}
+	}
+	return stats, nil
+}
+
+// fetchBlobs requests the wanted blobs, as a thin pack against have when it is set, resolves
+// deltas against cached blobs and saves every file whose blob was wanted
+func (c *Client) fetchBlobs(
+	ctx context.Context,
+	remote *gitproto.Remote,
+	components *model.RepoURLComponents,
+	files []model.FileInfo,
+	wanted []string,
+	have string,
+	cache *FileCache,
+	opts FetchOptions,
+) error {
+	request := gitproto.FetchRequest{Wants: wanted}
+	if have != "" {
+		request.Haves = []string{have}
+		request.Thin = true
+	}
+
+	pack, err := remote.Fetch(ctx, request)
+	if err != nil && request.Thin {
+		// A thin pack that can't be read is asked for again whole, as one without haves
+		// refers to nothing outside itself
+		opts.warn(fmt.Errorf("error fetching blobs as a thin pack, fetching them whole: %v", err))
+		pack, err = remote.Fetch(ctx, gitproto.FetchRequest{Wants: wanted})
+	}
+	if err != nil {
+		return fmt.Errorf("error fetching blobs: %w", err)
+	}
+
+	lookup := func(oid string) (gitproto.Object, bool) {
+		if cache == nil {
+			return gitproto.Object{}, false
+		}
+		return cachedBlob(cache, oid)
+	}
+	if err := pack.Resolve(lookup); err != nil {
+		return err
+	}
+
+	// The have lets the server send deltas against any blob of that commit, not just those the
+	// cache holds. Blobs whose bases are missing are fetched again whole, without the have.
+	var unresolved []string
+	for _, oid := range wanted {
+		if _, ok := pack.Objects[oid]; !ok {
+			unresolved = append(unresolved, oid)
+		}
+	}
+	if len(unresolved) > 0 && request.Thin {
+		whole, err := remote.Fetch(ctx, gitproto.FetchRequest{Wants: unresolved})
+		if err != nil {
+			return fmt.Errorf("error fetching blobs: %w", err)
+		}
+		pack.Merge(whole)
+	}
+
+	want := make(map[string]bool, len(wanted))
+	for _, oid := range wanted {
+		want[oid] = true
+	}
+
+	baseDir := filepath.Base(components.OutputRoot())
+	for _, file := range files {
+		if !want[file.SHA] {
+			continue
+		}
+		blob, ok := pack.Objects[file.SHA]
+		if !ok {
+			return fmt.Errorf("blob for %s missing from pack", file.Path)
+		}
+
+		if c.isLfsPointer(ctx, *components, file, blob.Data, int64(len(blob.Data))) {
+			// The pointer only names the content, which lives on the LFS media host
+			fileOpts := opts
+			fileOpts.Cache = nil
+			if cache != nil {
+				fileOpts.Cache = cache
+			}
+			if _, err := c.FetchPublicFile(ctx, file, components, fileOpts); err != nil && !errors.Is(err, ErrBinarySkipped) {
+				return err
+			}
+			continue
+		}
+
+		opts.Progress.Add(int64(len(blob.Data)))
+		result, err := helpers.SaveFile(baseDir, file.Path, io.NopCloser(bytes.NewReader(blob.Data)), helpers.SaveOptions{
+			Size:      int64(len(blob.Data)),
+			Sparse:    opts.Sparse,
+			OutputDir: opts.OutputDir,
+		})
+		if err != nil {
+			return fmt.Errorf("error saving file %s %v", file.Path, err)
+		}
+		if cache != nil {
+			if err := cache.Store(ctx, file.SHA, result.Path); err != nil {
+				opts.warn(err)
+			}
+		}
+		if err := opts.finish(file, &result); err != nil && !errors.Is(err, ErrBinarySkipped) {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncedRefPath is where the last commit synced of a selection of a repository is recorded
+func (c *FileCache) syncedRefPath(owner, repository, scope string) string {
+	return filepath.Join(c.dir, "synced", owner, repository+"@"+scope)
+}
+
+// LastSynced returns the last commit downloaded with the git strategy from a repository, of
+// the selection scope names
+func (c *FileCache) LastSynced(owner, repository, scope string) string {
+	data, err := os.ReadFile(c.syncedRefPath(owner, repository, scope))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// SetLastSynced records the last commit downloaded of a selection of a repository
+func (c *FileCache) SetLastSynced(owner, repository, scope, commit string) error {
+	p := c.syncedRefPath(owner, repository, scope)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, []byte(commit+"\n"), 0o644)
+}
@@ -0,0 +1,299 @@
+package gh_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"repo-pack/gh"
+	"repo-pack/gitproto"
+	"repo-pack/model"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// pktLine encodes a pkt-line of git's wire protocol
+func pktLine(payload string) string {
+	return fmt.Sprintf("%04x%s", len(payload)+4, payload)
+}
+
+// packEntry is an object of a packfile built for a test: a whole object, or with base set,
+// a REF_DELTA rebuilding data from that object
+type packEntry struct {
+	objectType gitproto.ObjectType
+	data       []byte
+	base       string
+}
+
+// buildPackfile assembles a packfile holding entries
+func buildPackfile(entries []packEntry) []byte {
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(len(entries)))
+	for _, entry := range entries {
+		objectType, data := int(entry.objectType), entry.data
+		if entry.base != "" {
+			// The delta inserts all of data, whatever the base holds
+			objectType = 7
+			data = append(binary.AppendUvarint(binary.AppendUvarint(nil, 1), uint64(len(entry.data))), byte(len(entry.data)))
+			data = append(data, entry.data...)
+		}
+		size := len(data)
+		header := byte(objectType<<4 | size&0x0f)
+		for size >>= 4; size > 0; size >>= 7 {
+			pack.WriteByte(header | 0x80)
+			header = byte(size & 0x7f)
+		}
+		pack.WriteByte(header)
+		if entry.base != "" {
+			oid, _ := hex.DecodeString(entry.base)
+			pack.Write(oid)
+		}
+		zw := zlib.NewWriter(&pack)
+		zw.Write(data)
+		zw.Close()
+	}
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+	return pack.Bytes()
+}
+
+// gitRepo is a commit of a repository held as objects, with its trees built from files
+type gitRepo struct {
+	commit  string
+	objects map[string]packEntry
+	trees   []string
+}
+
+func newGitRepo(files map[string]string) *gitRepo {
+	repo := &gitRepo{objects: map[string]packEntry{}}
+	var writeTree func(dir string) string
+	writeTree = func(dir string) string {
+		entries := map[string]string{}
+		for name, content := range files {
+			rel, ok := strings.CutPrefix(name, dir)
+			if !ok {
+				continue
+			}
+			if sub, _, nested := strings.Cut(rel, "/"); nested {
+				entries[sub] = "40000 " + sub + "\x00" + writeTree(dir+sub+"/")
+			} else {
+				entries[rel] = "100644 " + rel + "\x00" + repo.add(gitproto.TypeBlob, []byte(content))
+			}
+		}
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var tree bytes.Buffer
+		for _, name := range names {
+			prefix, oid, _ := strings.Cut(entries[name], "\x00")
+			raw, _ := hex.DecodeString(oid)
+			tree.WriteString(prefix + "\x00")
+			tree.Write(raw)
+		}
+		oid := repo.add(gitproto.TypeTree, tree.Bytes())
+		repo.trees = append(repo.trees, oid)
+		return oid
+	}
+	root := writeTree("")
+	repo.commit = repo.add(gitproto.TypeCommit, []byte("tree "+root+"\nauthor A <a@example.com> 0 +0000\ncommitter A <a@example.com> 0 +0000\n\nsync\n"))
+	return repo
+}
+
+func (r *gitRepo) add(objectType gitproto.ObjectType, data []byte) string {
+	oid := gitproto.HashObject(objectType, data)
+	r.objects[oid] = packEntry{objectType: objectType, data: data}
+	return oid
+}
+
+// gitServer fakes GitHub's smart HTTP endpoint of o/r serving repo at refs/heads/main. Blobs
+// requested as a thin pack come back as deltas against a base the client never had, as
+// they may when the have covers more than the client's cache.
+type gitServer struct {
+	mu      sync.Mutex
+	repo    *gitRepo
+	fetches [][]string
+	// corruptThin answers thin pack requests with a pack that can't be parsed
+	corruptThin bool
+}
+
+func (s *gitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/o/r.git/info/refs":
+		fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000"+pktLine("version 2\n")+
+			pktLine("ls-refs\n")+pktLine("fetch=shallow filter\n")+"0000")
+	case "/o/r.git/git-upload-pack":
+		body := new(bytes.Buffer)
+		body.ReadFrom(r.Body)
+		var args []string
+		for data := body.String(); len(data) >= 4; {
+			var length int
+			fmt.Sscanf(data[:4], "%04x", &length)
+			if length < 4 {
+				data = data[4:]
+				continue
+			}
+			args = append(args, strings.TrimSuffix(data[4:length], "\n"))
+			data = data[length:]
+		}
+
+		if args[0] == "command=ls-refs" {
+			fmt.Fprint(w, pktLine(s.repo.commit+" refs/heads/main\n")+"0000")
+			return
+		}
+		s.fetches = append(s.fetches, args)
+		var entries []packEntry
+		thin := false
+		for _, arg := range args {
+			thin = thin || arg == "thin-pack"
+		}
+		for _, arg := range args {
+			switch {
+			case arg == "filter blob:none":
+				entries = append(entries, s.repo.objects[s.repo.commit])
+				for _, tree := range s.repo.trees {
+					entries = append(entries, s.repo.objects[tree])
+				}
+			case strings.HasPrefix(arg, "want ") && s.repo.objects[arg[5:]].objectType == gitproto.TypeBlob:
+				entry := s.repo.objects[arg[5:]]
+				if thin {
+					entry.base = gitproto.HashObject(gitproto.TypeBlob, []byte("a blob outside the directory\n"))
+				}
+				entries = append(entries, entry)
+			}
+		}
+		var out strings.Builder
+		out.WriteString(pktLine("packfile\n"))
+		pack := buildPackfile(entries)
+		if thin && s.corruptThin {
+			pack = pack[:len(pack)-1]
+		}
+		for len(pack) > 0 {
+			n := min(len(pack), 1000)
+			out.WriteString(pktLine("\x01" + string(pack[:n])))
+			pack = pack[n:]
+		}
+		out.WriteString("0000")
+		fmt.Fprint(w, out.String())
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// blobFetches returns the blob requests made since the call before, each as its haves
+func (s *gitServer) blobFetches() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var fetches [][]string
+	for _, args := range s.fetches {
+		haves := []string{}
+		blobs := true
+		for _, arg := range args {
+			if arg == "filter blob:none" {
+				blobs = false
+			}
+			if have, ok := strings.CutPrefix(arg, "have "); ok {
+				haves = append(haves, have)
+			}
+		}
+		if blobs {
+			fetches = append(fetches, haves)
+		}
+	}
+	s.fetches = nil
+	return fetches
+}
+
+func TestClientFetchViaGit(t *testing.T) {
+	files := map[string]string{
+		"docs/a.md":   "alpha\n",
+		"docs/b.md":   "beta\n",
+		"src/main.go": "package main\n",
+	}
+	first := newGitRepo(files)
+	server := &gitServer{repo: first}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client := gh.NewClient("")
+	client.GitBaseURL = httpServer.URL
+	cache, err := gh.NewFileCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	fetch := func(dir string) gh.DeltaStats {
+		t.Helper()
+		components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: dir}
+		stats, err := client.FetchViaGit(context.Background(), &components, cache, gh.FetchOptions{OutputDir: out})
+		if err != nil {
+			t.Fatalf("FetchViaGit %s: unexpected error: %v", dir, err)
+		}
+		return stats
+	}
+	expectContent := func(path, expected string) {
+		t.Helper()
+		if data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(path))); err != nil || string(data) != expected {
+			t.Errorf("expected %s to hold %q, got %q (%v)", path, expected, data, err)
+		}
+	}
+
+	if stats := fetch("docs"); stats.Files != 2 || stats.Fetched != 2 {
+		t.Errorf("expected both docs fetched, got %+v", stats)
+	}
+	if fetches := server.blobFetches(); len(fetches) != 1 || len(fetches[0]) != 0 {
+		t.Errorf("expected one blob fetch without haves, got %v", fetches)
+	}
+	expectContent("docs/a.md", "alpha\n")
+
+	// The commit synced for docs says nothing of what the cache holds of src
+	fetch("src")
+	if fetches := server.blobFetches(); len(fetches) != 1 || len(fetches[0]) != 0 {
+		t.Errorf("expected another directory to be fetched without haves, got %v", fetches)
+	}
+	expectContent("src/main.go", "package main\n")
+
+	// Once docs changes, the changed blob is requested against the commit synced before. The
+	// server's delta needs a base the cache lacks, so the blob is fetched again whole.
+	files["docs/a.md"] = "alpha, revised\n"
+	server.mu.Lock()
+	server.repo = newGitRepo(files)
+	server.mu.Unlock()
+	if stats := fetch("docs"); stats.Restored != 1 || stats.Fetched != 1 {
+		t.Errorf("expected b.md restored and a.md fetched, got %+v", stats)
+	}
+	fetches := server.blobFetches()
+	if len(fetches) != 2 || len(fetches[0]) != 1 || fetches[0][0] != first.commit || len(fetches[1]) != 0 {
+		t.Errorf("expected a thin fetch against %s, then one without haves, got %v", first.commit, fetches)
+	}
+	expectContent("docs/a.md", "alpha, revised\n")
+	expectContent("docs/b.md", "beta\n")
+
+	// A thin pack that can't be parsed is fetched again whole
+	second := server.repo
+	files["docs/a.md"] = "alpha, revised twice\n"
+	server.mu.Lock()
+	server.repo = newGitRepo(files)
+	server.corruptThin = true
+	server.mu.Unlock()
+	fetch("docs")
+	fetches = server.blobFetches()
+	if len(fetches) != 2 || len(fetches[0]) != 1 || fetches[0][0] != second.commit || len(fetches[1]) != 0 {
+		t.Errorf("expected a thin fetch against %s, then one without haves, got %v", second.commit, fetches)
+	}
+	expectContent("docs/a.md", "alpha, revised twice\n")
+}
@@ -9,9 +9,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
+	"time"
 
 	"repo-pack/helpers"
 	"repo-pack/model"
@@ -23,6 +24,8 @@ var (
 	ErrRepositoryNotFound = errors.New("repository not found")
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrFetchError         = errors.New("could not obtain repository data from the GitHub API")
+	ErrBinarySkipped      = errors.New("skipped binary file")
+	ErrChecksumMismatch   = errors.New("checksum mismatch")
 )
 
 // RepoInfo represents information about a repository
@@ -31,16 +34,9 @@ type RepoInfo struct {
 }
 
 // FetchRepoIsPrivate checks if a repository is private or not on GitHub.
-func FetchRepoIsPrivate(ctx context.Context, components *model.RepoURLComponents, token string) (bool, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s", components.Owner, components.Repository)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return false, err
-	}
-
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
-
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) FetchRepoIsPrivate(ctx context.Context, components *model.RepoURLComponents) (bool, error) {
+	url := c.apiURL(fmt.Sprintf("repos/%s/%s", components.Owner, components.Repository))
+	resp, _, err := c.doRequestWithRetry(ctx, url, "", true)
 	if err != nil {
 		return false, err
 	}
@@ -48,13 +44,16 @@ func FetchRepoIsPrivate(ctx context.Context, components *model.RepoURLComponents
 
 	switch resp.StatusCode {
 	case http.StatusNotFound:
-		return false, fmt.Errorf("repo not found: %s/%s", components.Owner, components.Repository)
+		return false, fmt.Errorf("%w: %s/%s", ErrRepositoryNotFound, components.Owner, components.Repository)
 	case http.StatusUnauthorized:
 		return false, ErrInvalidToken
-	case http.StatusForbidden:
-		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+	case http.StatusForbidden, http.StatusTooManyRequests, http.StatusUnavailableForLegalReasons:
+		if rateLimited(resp) {
 			return false, ErrRateLimitExceeded
 		}
+		if err := blockedResponse(resp, components.Owner+"/"+components.Repository); err != nil {
+			return false, err
+		}
 	case http.StatusOK:
 		var repoInfo RepoInfo
 		if err := json.NewDecoder(resp.Body).Decode(&repoInfo); err != nil {
@@ -68,85 +67,382 @@ func FetchRepoIsPrivate(ctx context.Context, components *model.RepoURLComponents
 	return false, nil
 }
 
-// isLfsResponse checks if the HTTP response potentially contains a Git LFS response.
-func isLfsResponse(res *http.Response) bool {
-	if contentLength, err := strconv.Atoi(res.Header.Get("Content-Length")); err == nil && 128 < contentLength &&
-		contentLength < 140 {
-		bufr := make([]byte, 40)
-		_, err := io.ReadFull(res.Body, bufr)
+// FetchOptions controls how downloaded bodies are held in memory before being saved
+type FetchOptions struct {
+	// StreamThreshold is the size in bytes above which bodies are always streamed to disk
+	StreamThreshold int64
+	// Budget bounds the bytes buffered in memory across all in-flight downloads
+	Budget *helpers.MemoryBudget
+	// Sparse writes all-zero blocks as holes instead of allocating them
+	Sparse bool
+	// Progress records downloaded bytes and corrects size estimates from Content-Length
+	Progress *helpers.ByteProgress
+	// Cache is consulted by blob SHA before downloading and filled afterwards; nil disables caching
+	Cache Cache
+	// Warn receives non-fatal problems, such as an unreachable cache, that don't fail the download
+	Warn func(err error)
+	// OutputDir is the directory files are saved under; the working directory when empty
+	OutputDir string
+	// Transform rewrites each file once saved; nil leaves content as downloaded
+	Transform helpers.Transform
+	// Templates renders files with a template extension once saved; nil renders nothing
+	Templates *helpers.Templates
+	// TextOnly discards files whose content turns out to be binary
+	TextOnly bool
+	// Verify checks saved content against the listing's git blob SHA, failing files that
+	// don't match with ErrChecksumMismatch
+	Verify bool
+	// Excludes are names of files and directories left out of git strategy listings
+	Excludes []string
+	// Include and Exclude select files from git strategy listings by gitignore-style pattern
+	Include, Exclude []helpers.Glob
+}
+
+func (opts FetchOptions) warn(err error) {
+	if opts.Warn != nil {
+		opts.Warn(err)
+	}
+}
+
+// finish post-processes a saved file. With TextOnly, binary content is removed again and
+// ErrBinarySkipped returned. Otherwise the configured transform is applied and templates are
+// rendered, updating result.Path when rendering drops the template extension, and the file is
+// given its git mode, making scripts executable and recreating symlinks. It runs after the
+// cache has been filled, so cached blobs always hold the original content.
+func (opts FetchOptions) finish(file model.FileInfo, result *helpers.SaveResult) error {
+	path := file.Path
+	if opts.TextOnly {
+		binary, err := helpers.IsBinaryFile(result.Path)
 		if err != nil {
-			return false
+			return err
+		}
+		if binary {
+			os.Remove(result.Path)
+			return ErrBinarySkipped
 		}
+	}
+	if file.Mode == symlinkMode {
+		// A link's content is its target, which transforms and templates have no business changing
+		if err := helpers.ApplyMode(result.Path, file.Mode); err != nil {
+			opts.warn(fmt.Errorf("keeping symlink %s as a file holding its target: %v", path, err))
+		}
+		return nil
+	}
+	if opts.Transform != nil {
+		if err := helpers.TransformFile(result.Path, path, opts.Transform); err != nil {
+			return fmt.Errorf("error transforming %s: %v", path, err)
+		}
+	}
+	if opts.Templates.Matches(path) {
+		rendered, err := opts.Templates.RenderFile(result.Path)
+		if err != nil {
+			return fmt.Errorf("error rendering template %s: %v", path, err)
+		}
+		result.Path = rendered
+	}
+	if err := helpers.ApplyMode(result.Path, file.Mode); err != nil {
+		return fmt.Errorf("error setting the mode of %s: %v", path, err)
+	}
+	return nil
+}
+
+// bufferBody reads a small response fully into memory when it fits the threshold and budget,
+// freeing the connection early. Larger or unknown-length bodies are returned as-is to be streamed.
+// The returned release func must be called once the body has been consumed.
+func (opts FetchOptions) bufferBody(resp *http.Response) (io.ReadCloser, func(), error) {
+	size := resp.ContentLength
+	if size < 0 || size > opts.StreamThreshold || !opts.Budget.TryAcquire(size) {
+		return resp.Body, func() {}, nil
+	}
+
+	buf := make([]byte, size)
+	_, err := io.ReadFull(resp.Body, buf)
+	resp.Body.Close()
+	if err != nil {
+		opts.Budget.Release(size)
+		return nil, nil, err
+	}
+	return io.NopCloser(bytes.NewReader(buf)), func() { opts.Budget.Release(size) }, nil
+}
+
+// restoreFromCache copies a file out of the cache when its blob is present
+func restoreFromCache(ctx context.Context, file model.FileInfo, baseDir string, opts FetchOptions) (helpers.SaveResult, bool, error) {
+	if opts.Cache == nil || file.SHA == "" {
+		return helpers.SaveResult{}, false, nil
+	}
+
+	dst, err := helpers.OutputPath(opts.OutputDir, baseDir, file.Path)
+	if err != nil {
+		return helpers.SaveResult{}, false, err
+	}
+
+	if err := helpers.RemoveLinks(opts.OutputDir, dst); err != nil {
+		return helpers.SaveResult{}, false, err
+	}
+	found, err := opts.Cache.Restore(ctx, file.SHA, dst)
+	if err != nil || !found {
+		return helpers.SaveResult{}, false, err
+	}
 
-		restOfBody, err := io.ReadAll(res.Body)
+	size := file.Size
+	if size < 0 {
+		info, err := os.Stat(dst)
 		if err != nil {
-			return false
+			return helpers.SaveResult{}, false, err
 		}
+		size = info.Size()
+	}
 
-		res.Body.Close()
-		fullBody := append(bufr, restOfBody...)
-		res.Body = io.NopCloser(bytes.NewReader(fullBody))
+	opts.Progress.Add(size)
+	return helpers.SaveResult{Path: dst, Written: size, BlobSHA: file.SHA, Cached: true}, true, nil
+}
 
-		return strings.HasPrefix(string(bufr), "version https://git-lfs.github.com/spec/v1")
+// verifyBlob compares the blob SHA of saved content with the one the listing reported,
+// marking result verified when they match
+func verifyBlob(file model.FileInfo, result *helpers.SaveResult, blobSHA string) error {
+	if blobSHA != file.SHA {
+		return fmt.Errorf("%w: %s has blob %s, expected %s", ErrChecksumMismatch, file.Path, blobSHA, file.SHA)
 	}
-	return false
+	result.Verified = true
+	return nil
 }
 
-// FetchPublicFile downloads a file from a public GitHub repository, handling Git LFS if necessary and saves it.
-func FetchPublicFile(ctx context.Context, path string, components *model.RepoURLComponents) error {
-	user := components.Owner
-	repository := components.Repository
-	ref := components.Ref
+// verifyCached hashes a file restored from the cache, which records the blob SHA it was
+// stored under rather than one computed from the content. LFS content, cached under its
+// pointer's SHA, is recognised by not having the pointer's size and left unverified.
+func verifyCached(file model.FileInfo, result *helpers.SaveResult) error {
+	if file.LFS || file.Size >= 0 && result.Written != file.Size {
+		return nil
+	}
+	blobSHA, err := helpers.ComputeBlobSHA(result.Path)
+	if err != nil {
+		return err
+	}
+	return verifyBlob(file, result, blobSHA)
+}
 
-	rawURL := fmt.Sprintf(
-		"https://raw.githubusercontent.com/%s/%s/%s/%s",
-		user,
-		repository,
-		ref,
+// rawFileURL returns where the raw content of a repository file is served
+func (c *Client) rawFileURL(components model.RepoURLComponents, path string) string {
+	return fmt.Sprintf(
+		"%s/%s/%s/%s/%s",
+		strings.TrimSuffix(c.RawBaseURL, "/"),
+		components.Owner,
+		components.Repository,
+		components.ContentRef(),
 		url.PathEscape(path),
 	)
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
-	if err != nil {
-		return fmt.Errorf("creating request for %s: %w", path, err)
+// rawMediaType asks the contents API for a file's raw bytes instead of base64 wrapped in JSON
+const rawMediaType = "application/vnd.github.raw"
+
+// fileURL returns where a repository file's content is downloaded from, along with the Accept
+// header to request it with. Public files come from the raw host, which doesn't count against
+// the API rate limit; private ones through the contents API, which honours the token.
+func (c *Client) fileURL(components model.RepoURLComponents, path string) (string, string) {
+	if !components.Private {
+		return c.rawFileURL(components, path), ""
 	}
+	return c.apiURL(fmt.Sprintf(
+		"repos/%s/%s/contents/%s?ref=%s",
+		components.Owner,
+		components.Repository,
+		escapePath(path),
+		url.QueryEscape(components.ContentRef()),
+	)), rawMediaType
+}
+
+// escapePath escapes each segment of a repository path, keeping the slashes between them
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// RawFile reads a small repository file, such as a config file, into memory
+func (c *Client) RawFile(ctx context.Context, components model.RepoURLComponents, path string) ([]byte, error) {
+	fileURL, accept := c.fileURL(components, path)
+	resp, _, err := c.doRequestWithRetry(ctx, fileURL, accept, components.Private)
 	if err != nil {
-		return fmt.Errorf("HTTP error for %s: %w", path, err)
+		return nil, fmt.Errorf("HTTP error for %s: %w", path, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return fmt.Errorf("HTTP %s for %s", resp.Status, path)
-	}
-
-	if isLfsResponse(resp) {
-		lfsURL := fmt.Sprintf(
-			"https://media.githubusercontent.com/media/%s/%s/%s/%s",
-			user,
-			repository,
-			ref,
-			url.PathEscape(path),
-		)
-		req, err = http.NewRequestWithContext(ctx, http.MethodGet, lfsURL, nil)
+		return nil, fmt.Errorf("HTTP %s for %s", resp.Status, path)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// lfsFileURL returns where the content of a Git LFS file is served
+func (c *Client) lfsFileURL(components model.RepoURLComponents, path string) string {
+	return fmt.Sprintf(
+		"%s/%s/%s/%s/%s",
+		strings.TrimSuffix(c.MediaBaseURL, "/"),
+		components.Owner,
+		components.Repository,
+		components.ContentRef(),
+		url.PathEscape(path),
+	)
+}
+
+// openContent requests a file's content, following a Git LFS pointer to the file it stands
+// for. lfs reports whether it did. Files already known to be in LFS are requested from the
+// LFS host directly. Failures are returned as a *FetchError.
+func (c *Client) openContent(ctx context.Context, file model.FileInfo, components *model.RepoURLComponents) (resp *http.Response, lfs bool, err error) {
+	path := file.Path
+	start := time.Now()
+
+	var attempts int
+	if !file.LFS {
+		fileURL, accept := c.fileURL(*components, file.SourcePath())
+		resp, attempts, err = c.doRequestWithRetry(ctx, fileURL, accept, components.Private)
 		if err != nil {
-			return fmt.Errorf("error creating LFS request for %s: %w", path, err)
+			return nil, false, &FetchError{Path: path, Attempts: attempts, Elapsed: time.Since(start),
+				Err: fmt.Errorf("HTTP error for %s: %w", path, helpers.WithFDHint(err))}
 		}
-		resp, err = http.DefaultClient.Do(req)
-		if err != nil {
-			resp.Body.Close()
-			return fmt.Errorf("HTTP error for LFS %s: %w", path, err)
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			err := blockedResponse(resp, path)
+			if err == nil {
+				err = fmt.Errorf("HTTP %s for %s", resp.Status, path)
+			}
+			return nil, false, &FetchError{Path: path, Attempts: attempts, StatusCode: resp.StatusCode,
+				RateLimited: rateLimited(resp), Elapsed: time.Since(start), Err: err}
+		}
+		if !c.isLfsResponse(ctx, resp, file, *components) {
+			resp.Body = c.Throttle.Reader(ctx, resp.Body)
+			return resp, false, nil
 		}
+		resp.Body.Close()
 	}
 
-	err = helpers.SaveFile(filepath.Base(components.Dir), path, resp.Body)
+	resp, attempts, err = c.doRequestWithRetry(ctx, c.lfsFileURL(*components, file.SourcePath()), "", components.Private)
 	if err != nil {
-		resp.Body.Close()
-		return fmt.Errorf("error saving file %s %v", path, err)
+		return nil, true, &FetchError{Path: path, Attempts: attempts, LFS: true, Elapsed: time.Since(start),
+			Err: fmt.Errorf("HTTP error for LFS %s: %w", path, helpers.WithFDHint(err))}
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		err := blockedResponse(resp, path)
+		if err == nil {
+			err = fmt.Errorf("HTTP %s for LFS %s", resp.Status, path)
+		}
+		return nil, true, &FetchError{Path: path, Attempts: attempts, StatusCode: resp.StatusCode,
+			RateLimited: rateLimited(resp), LFS: true, Elapsed: time.Since(start), Err: err}
 	}
+	resp.Body = c.Throttle.Reader(ctx, resp.Body)
+	return resp, true, nil
+}
 
-	return nil
+// OpenFile streams a file's content without saving it, as for --stdout. size is the content
+// length, or -1 when the server doesn't report it. The caller closes the content.
+func (c *Client) OpenFile(ctx context.Context, file model.FileInfo, components *model.RepoURLComponents) (content io.ReadCloser, size int64, err error) {
+	resp, _, err := c.openContent(ctx, file, components)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
 }
 
+// FetchPublicFile downloads a file from a GitHub repository, handling Git LFS if necessary and saves it.
+// Files of private repositories are downloaded through the contents API with the client's token.
+// The returned result carries the content hashes computed while the file was written.
+func (c *Client) FetchPublicFile(ctx context.Context, file model.FileInfo, components *model.RepoURLComponents, opts FetchOptions) (helpers.SaveResult, error) {
+	path := file.Path
+	baseDir := filepath.Base(components.OutputRoot())
+
+	// A cache failure only costs a download, so report it and fetch from GitHub instead
+	result, found, err := restoreFromCache(ctx, file, baseDir, opts)
+	if err != nil {
+		opts.warn(err)
+	}
+	if found && opts.Verify {
+		if err := verifyCached(file, &result); err != nil {
+			opts.warn(fmt.Errorf("discarding cached %s: %v", path, err))
+			found = false
+		}
+	}
+	if found {
+		if err := opts.finish(file, &result); err != nil {
+			return helpers.SaveResult{}, err
+		}
+		return result, nil
+	}
+
+	// A mismatch is usually a truncated or mangled response, so the file is fetched again as
+	// often as a failing request would be
+	var lfs bool
+	for attempt := 1; ; attempt++ {
+		result, lfs, err = c.downloadFile(ctx, file, components, baseDir, opts)
+		if !errors.Is(err, ErrChecksumMismatch) {
+			break
+		}
+		if attempt >= c.MaxAttempts {
+			return helpers.SaveResult{}, fmt.Errorf("%w (downloaded %d times)", err, attempt)
+		}
+		opts.warn(fmt.Errorf("%v, downloading it again", err))
+	}
+	if err != nil {
+		return helpers.SaveResult{}, err
+	}
+
+	// LFS content never matches the pointer's blob SHA, but the pointer still identifies it
+	if opts.Cache != nil && file.SHA != "" && (lfs || result.BlobSHA == file.SHA) {
+		if err := opts.Cache.Store(ctx, file.SHA, result.Path); err != nil {
+			opts.warn(err)
+		}
+	}
+
+	if err := opts.finish(file, &result); err != nil {
+		return helpers.SaveResult{}, err
+	}
+	return result, nil
+}
+
+// downloadFile fetches and saves a file once, reporting whether its content came from Git LFS.
+// With opts.Verify, content not matching the listing is removed again and ErrChecksumMismatch
+// returned.
+func (c *Client) downloadFile(ctx context.Context, file model.FileInfo, components *model.RepoURLComponents, baseDir string, opts FetchOptions) (helpers.SaveResult, bool, error) {
+	path := file.Path
+	resp, lfs, err := c.openContent(ctx, file, components)
+	if err != nil {
+		return helpers.SaveResult{}, false, err
+	}
+	defer resp.Body.Close()
+
+	opts.Progress.Resolve(path, resp.ContentLength)
+	resp.Body = opts.Progress.Reader(path, resp.Body)
+
+	body, release, err := opts.bufferBody(resp)
+	if err != nil {
+		return helpers.SaveResult{}, false, fmt.Errorf("error reading body for %s: %w", path, err)
+	}
+	defer release()
+
+	// Compressed and chunked responses don't say how long they are, but the listing does, which
+	// lets the blob SHA be hashed as the content is written instead of read back afterwards
+	size := resp.ContentLength
+	if size < 0 && !lfs {
+		size = file.Size
+	}
+	result, err := helpers.SaveFile(baseDir, path, body, helpers.SaveOptions{
+		Size:      size,
+		Sparse:    opts.Sparse,
+		OutputDir: opts.OutputDir,
+	})
+	if err != nil {
+		return helpers.SaveResult{}, false, fmt.Errorf("error saving file %s %w", path, err)
+	}
+
+	// LFS content is checked against its pointer, whose blob SHA the listing reports instead
+	if opts.Verify && file.SHA != "" && !lfs {
+		if err := verifyBlob(file, &result, result.BlobSHA); err != nil {
+			os.Remove(result.Path)
+			return helpers.SaveResult{}, false, err
+		}
+	}
+	return result, lfs, nil
+}
@@ -0,0 +1,49 @@
+package gh
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultTimeout bounds connecting to a server and waiting for its response headers
+	DefaultTimeout = 30 * time.Second
+	// DefaultPoolSize is how many idle connections are kept per host when no concurrency is given
+	DefaultPoolSize = 10
+)
+
+// HTTPOptions configure the HTTP client every request of a run shares
+type HTTPOptions struct {
+	// Timeout bounds connecting, the TLS handshake and waiting for response headers, each on
+	// its own; reading a body has no deadline, so large files aren't cut off. 0 means no limit.
+	Timeout time.Duration
+	// PoolSize is how many idle connections are kept per host, which should match the number
+	// of concurrent downloads so each keeps reusing its connection
+	PoolSize int
+	// HTTP2 allows HTTP/2 with servers offering it, multiplexing downloads over one connection
+	HTTP2 bool
+}
+
+// NewHTTPClient creates the client requests are made with, pooling connections per opts
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	return &http.Client{Transport: NewTransport(opts)}
+}
+
+// NewTransport creates a transport like http.DefaultTransport, with the timeouts and
+// connection pool of opts
+func NewTransport(opts HTTPOptions) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: opts.Timeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = opts.Timeout
+	transport.ResponseHeaderTimeout = opts.Timeout
+	transport.MaxIdleConnsPerHost = max(opts.PoolSize, 1)
+	transport.MaxIdleConns = max(transport.MaxIdleConns, transport.MaxIdleConnsPerHost*4)
+	transport.ForceAttemptHTTP2 = opts.HTTP2
+	if !opts.HTTP2 {
+		// A non-nil empty map is how a transport is kept from negotiating HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport
+}
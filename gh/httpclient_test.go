@@ -0,0 +1,31 @@
+package gh_test
+
+import (
+	"repo-pack/gh"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport := gh.NewTransport(gh.HTTPOptions{Timeout: 5 * time.Second, PoolSize: 40, HTTP2: true})
+	if transport.MaxIdleConnsPerHost != 40 {
+		t.Errorf("expected 40 idle connections per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.MaxIdleConns < 40 {
+		t.Errorf("expected room for the per-host pool in total, got %d", transport.MaxIdleConns)
+	}
+	if transport.ResponseHeaderTimeout != 5*time.Second || transport.TLSHandshakeTimeout != 5*time.Second {
+		t.Errorf("expected 5s timeouts, got %s and %s", transport.ResponseHeaderTimeout, transport.TLSHandshakeTimeout)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Errorf("expected HTTP/2 to be attempted")
+	}
+
+	transport = gh.NewTransport(gh.HTTPOptions{})
+	if transport.MaxIdleConnsPerHost != 1 {
+		t.Errorf("expected at least one idle connection per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Errorf("expected HTTP/2 to be turned off")
+	}
+}
@@ -0,0 +1,103 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// maxLFSPointerSize bounds the size of a Git LFS pointer; larger content is never one
+const maxLFSPointerSize = 1024
+
+// lfsPointerPrefix starts the content of every Git LFS pointer file
+const lfsPointerPrefix = "version https://git-lfs.github.com/spec/v1"
+
+// lfsAttributesCache holds the .gitattributes files read so far, by repository, ref and directory
+type lfsAttributesCache struct {
+	mu      sync.Mutex
+	entries map[string]*lfsAttributesEntry
+}
+
+type lfsAttributesEntry struct {
+	once  sync.Once
+	attrs helpers.LFSAttributes
+}
+
+// gitattributes reads the .gitattributes file of a repository directory once per run. A
+// directory without one, or whose file can't be read, has no rules.
+func (c *Client) gitattributes(ctx context.Context, components model.RepoURLComponents, dir string) helpers.LFSAttributes {
+	key := components.Owner + "/" + components.Repository + "@" + components.ContentRef() + ":" + dir
+	c.lfsAttributes.mu.Lock()
+	if c.lfsAttributes.entries == nil {
+		c.lfsAttributes.entries = make(map[string]*lfsAttributesEntry)
+	}
+	entry, ok := c.lfsAttributes.entries[key]
+	if !ok {
+		entry = &lfsAttributesEntry{}
+		c.lfsAttributes.entries[key] = entry
+	}
+	c.lfsAttributes.mu.Unlock()
+
+	entry.once.Do(func() {
+		data, err := c.RawFile(ctx, components, path.Join(dir, ".gitattributes"))
+		if err == nil {
+			entry.attrs = helpers.ParseGitattributes(dir, data)
+		}
+	})
+	return entry.attrs
+}
+
+// lfsTracked reports whether the .gitattributes files of the repository root and of each
+// directory down to the one being downloaded route file through Git LFS. Files in LFS are
+// nearly always tracked from the root, so .gitattributes files further down aren't read.
+func (c *Client) lfsTracked(ctx context.Context, components model.RepoURLComponents, file model.FileInfo) bool {
+	dirs := []string{""}
+	if dir := strings.Trim(components.Dir, "/"); dir != "" {
+		segments := strings.Split(dir, "/")
+		for i := range segments {
+			dirs = append(dirs, strings.Join(segments[:i+1], "/"))
+		}
+	}
+	attrs := make([]helpers.LFSAttributes, 0, len(dirs))
+	for _, dir := range dirs {
+		attrs = append(attrs, c.gitattributes(ctx, components, dir))
+	}
+	return helpers.LFSTracked(attrs, file.SourcePath())
+}
+
+// isLfsPointer reports whether content of the given size starting with head is a Git LFS
+// pointer rather than the file itself: small enough to be one, starting with a pointer's
+// first line, for a file .gitattributes routes through LFS. .gitattributes is only looked up
+// for content that could be a pointer. Every strategy tells pointers apart this way, so a file
+// that merely looks like one is saved as it is however it is downloaded.
+func (c *Client) isLfsPointer(ctx context.Context, components model.RepoURLComponents, file model.FileInfo, head []byte, size int64) bool {
+	return size < maxLFSPointerSize && bytes.HasPrefix(head, []byte(lfsPointerPrefix+"\n")) &&
+		c.lfsTracked(ctx, components, file)
+}
+
+// isLfsResponse reports whether a raw response holds a Git LFS pointer instead of the file's
+// content, as isLfsPointer tells. The pointer's first line is read first and put back in
+// front of the rest of the body.
+func (c *Client) isLfsResponse(ctx context.Context, res *http.Response, file model.FileInfo, components model.RepoURLComponents) bool {
+	if res.ContentLength >= maxLFSPointerSize {
+		return false
+	}
+	head := make([]byte, len(lfsPointerPrefix)+1)
+	n, _ := io.ReadFull(res.Body, head)
+	head = head[:n]
+	res.Body = readCloser{io.MultiReader(bytes.NewReader(head), res.Body), res.Body}
+	return c.isLfsPointer(ctx, components, file, head, res.ContentLength)
+}
+
+// readCloser reads from one reader and closes another
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
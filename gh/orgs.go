@@ -0,0 +1,41 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+const orgReposPageSize = 100
+
+// Repository describes a repository as listed by the repos APIs
+type Repository struct {
+	Name          string   `json:"name"`
+	DefaultBranch string   `json:"default_branch"`
+	Topics        []string `json:"topics"`
+	Private       bool     `json:"private"`
+	Fork          bool     `json:"fork"`
+	Archived      bool     `json:"archived"`
+}
+
+// OrgRepositories lists every repository of the organization org that the client's token can see
+func (c *Client) OrgRepositories(ctx context.Context, org string) ([]Repository, error) {
+	repos := []Repository{}
+	for page := 1; ; page++ {
+		contents, err := c.apiGet(ctx, fmt.Sprintf("orgs/%s/repos?per_page=%d&page=%d", url.PathEscape(org), orgReposPageSize, page))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of %s: %v", org, err)
+		}
+
+		var listed []Repository
+		if err := json.Unmarshal(contents, &listed); err != nil {
+			return nil, err
+		}
+		repos = append(repos, listed...)
+
+		if len(listed) < orgReposPageSize {
+			return repos, nil
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"repo-pack/model"
+)
+
+// pullRequest is the part of a pulls API response describing the PR's head commit
+type pullRequest struct {
+	Head struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+}
+
+// PullRequestHead resolves a pull request to its head branch name and commit SHA. Head
+// commits are reachable from the base repository even when the PR comes from a fork.
+func (c *Client) PullRequestHead(ctx context.Context, components model.RepoURLComponents, number int) (ref, sha string, err error) {
+	contents, err := c.API(ctx, fmt.Sprintf("%s/%s/pulls/%d", components.Owner, components.Repository, number))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to look up pull request #%d: %v", number, err)
+	}
+
+	var pr pullRequest
+	if err := json.Unmarshal(contents, &pr); err != nil {
+		return "", "", err
+	}
+	if pr.Head.SHA == "" {
+		return "", "", fmt.Errorf("pull request #%d has no head commit", number)
+	}
+	return pr.Head.Ref, pr.Head.SHA, nil
+}
+
+const (
+	pullFilesPageSize = 100
+	// pullFilesMaxPages covers the 3000 files the pulls API lists at most
+	pullFilesMaxPages = 30
+)
+
+// pullRequestFile is one entry of a pull request's changed files
+type pullRequestFile struct {
+	Filename string `json:"filename"`
+	Status   string `json:"status"`
+	SHA      string `json:"sha"`
+}
+
+// PullRequestFiles lists the files a pull request adds or modifies inside components.Dir,
+// leaving out removed files. Sizes aren't reported by the pulls API, so they are -1.
+func (c *Client) PullRequestFiles(ctx context.Context, components model.RepoURLComponents, number int) ([]model.FileInfo, error) {
+	files := []model.FileInfo{}
+	for page := 1; page <= pullFilesMaxPages; page++ {
+		contents, err := c.API(
+			ctx,
+			fmt.Sprintf(
+				"%s/%s/pulls/%d/files?per_page=%d&page=%d",
+				components.Owner,
+				components.Repository,
+				number,
+				pullFilesPageSize,
+				page,
+			),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list files of pull request #%d: %v", number, err)
+		}
+
+		var changed []pullRequestFile
+		if err := json.Unmarshal(contents, &changed); err != nil {
+			return nil, err
+		}
+
+		for _, file := range changed {
+			if file.Status == "removed" || !inDir(file.Filename, components.Dir) {
+				continue
+			}
+			files = append(files, model.FileInfo{Path: file.Filename, Size: -1, SHA: file.SHA})
+		}
+
+		if len(changed) < pullFilesPageSize {
+			break
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,121 @@
+package gh
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces API requests by the rate limit headers of earlier responses. Once the
+// remaining requests fall to Reserve, requests pause until the limit resets instead of failing
+// mid-download. A nil RateLimiter never pauses.
+type RateLimiter struct {
+	// Reserve is how many requests of the limit are left unused, covering requests already in
+	// flight when the count was read
+	Reserve int
+	// Countdown is called about once a second while requests are paused, with the time left
+	// until the limit resets, and with 0 once they resume
+	Countdown func(left time.Duration)
+
+	mu        sync.Mutex
+	known     bool
+	remaining int
+	limit     int
+	reset     time.Time
+	// pause lets a single request wait out the reset at a time, so one countdown is shown
+	pause sync.Mutex
+}
+
+// NewRateLimiter creates a limiter keeping reserve requests of the limit unused
+func NewRateLimiter(reserve int) *RateLimiter {
+	return &RateLimiter{Reserve: reserve}
+}
+
+// Observe records the rate limit a response reports. Responses without rate limit headers,
+// such as raw file downloads, and those for other limits such as search are ignored.
+func (l *RateLimiter) Observe(resp *http.Response) {
+	if l == nil || resp == nil {
+		return
+	}
+	if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" && resource != "core" {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		return
+	}
+	resetUnix, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	reset := time.Unix(resetUnix, 0)
+	limit, _ := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case !l.known || reset.After(l.reset):
+		l.known, l.remaining, l.limit, l.reset = true, remaining, limit, reset
+	case reset.Equal(l.reset):
+		// Concurrent responses arrive out of order; the lowest count is the latest
+		l.remaining = min(l.remaining, remaining)
+	}
+}
+
+// Quota returns the requests left and the hourly limit, as last reported, and when the limit
+// resets. ok is false until a response has reported them. A limit of 0 wasn't reported.
+func (l *RateLimiter) Quota() (remaining, limit int, reset time.Time, ok bool) {
+	if l == nil {
+		return 0, 0, time.Time{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.known || time.Now().After(l.reset) {
+		return 0, 0, time.Time{}, false
+	}
+	return l.remaining, l.limit, l.reset, true
+}
+
+// Wait blocks until a request can be made without dipping into the reserve, then counts it
+// against the limit
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.pause.Lock()
+	defer l.pause.Unlock()
+
+	paused := false
+	for {
+		l.mu.Lock()
+		left := time.Until(l.reset)
+		exhausted := l.known && l.remaining <= l.Reserve && left > 0
+		if !exhausted {
+			if l.known && left > 0 {
+				l.remaining--
+			}
+			l.mu.Unlock()
+			if paused {
+				l.countdown(0)
+			}
+			return nil
+		}
+		l.mu.Unlock()
+
+		paused = true
+		l.countdown(left)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(min(left, time.Second)):
+		}
+	}
+}
+
+func (l *RateLimiter) countdown(left time.Duration) {
+	if l.Countdown != nil {
+		l.Countdown(left)
+	}
+}
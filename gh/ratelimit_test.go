@@ -0,0 +1,105 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"repo-pack/gh"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func rateLimitResponse(remaining int, reset time.Time) *http.Response {
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return &http.Response{Header: header}
+}
+
+func TestRateLimiterPausesNearExhaustion(t *testing.T) {
+	limiter := gh.NewRateLimiter(5)
+	var countdowns []time.Duration
+	limiter.Countdown = func(left time.Duration) {
+		countdowns = append(countdowns, left)
+	}
+
+	reset := time.Now().Add(time.Hour)
+	limiter.Observe(rateLimitResponse(6, reset))
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("expected a request above the reserve to go ahead, got %v", err)
+	}
+
+	// The request just made used the sixth, so the next would dip into the reserve
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the request to pause until the deadline, got %v", err)
+	}
+	if len(countdowns) == 0 || countdowns[0] < 59*time.Minute {
+		t.Errorf("expected a countdown to the reset, got %v", countdowns)
+	}
+
+	// Responses for other limits, like search, don't count against the core limit
+	search := rateLimitResponse(0, reset.Add(time.Hour))
+	search.Header.Set("X-RateLimit-Resource", "search")
+	limiter.Observe(search)
+
+	// A new window restores the allowance
+	limiter.Observe(rateLimitResponse(5000, reset.Add(time.Hour)))
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Errorf("expected requests to resume after the reset, got %v", err)
+	}
+	if remaining, _, resetAt, ok := limiter.Quota(); !ok || remaining != 4999 || !resetAt.Equal(reset.Add(time.Hour).Truncate(time.Second)) {
+		t.Errorf("expected 4999 requests left until the new reset, got %d until %s (known %v)", remaining, resetAt, ok)
+	}
+}
+
+func TestClientWaitsForRateLimitReset(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		w.Write([]byte(`{"name":"main","commit":{"sha":"abc"}}`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	client.RateLimiter = gh.NewRateLimiter(0)
+
+	if _, err := client.API(context.Background(), "o/r/branches/main"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.API(ctx, "o/r/branches/main"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second request to wait for the reset, got %v", err)
+	}
+}
+
+func TestClientCallLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	client.RetryDelay = time.Millisecond
+	client.CallLimit = gh.NewCallLimit(2)
+
+	// Retries count against the limit, and the request refused by it isn't retried
+	if _, err := client.API(context.Background(), "o/r/branches/main"); !errors.Is(err, gh.ErrCallLimitReached) {
+		t.Errorf("expected the retries to stop at the call limit, got %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests to reach the server, got %d", requests)
+	}
+	if left := client.CallLimit.Remaining(); left != 0 {
+		t.Errorf("expected no calls left, got %d", left)
+	}
+}
@@ -0,0 +1,139 @@
+package gh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// fixture is a recorded HTTP exchange. Request headers are never stored, so tokens don't
+// end up in fixture directories.
+type fixture struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// fixturePath names the fixture file for a request after a hash of its method, URL and body.
+// The body tells apart requests posted to one URL, such as git protocol commands.
+func fixturePath(dir string, req *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, req.Method+" "+req.URL.String())
+	if len(body) > 0 {
+		h.Write([]byte{0})
+		h.Write(body)
+	}
+	return filepath.Join(dir, hex.EncodeToString(h.Sum(nil)[:8])+".json")
+}
+
+// readRequestBody reads the body of req, returning a copy of req whose body can still be sent
+func readRequestBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading body of %s %s: %w", req.Method, req.URL, err)
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return req, body, nil
+}
+
+// RecordingTransport performs requests with Next and saves every response under Dir
+type RecordingTransport struct {
+	Dir  string
+	Next http.RoundTripper
+}
+
+// RoundTrip performs the request and records the complete response before returning it
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	req, reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+	data, err := json.MarshalIndent(fixture{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: header,
+		Body:   body,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating fixture directory %s: %w", t.Dir, err)
+	}
+	if err := os.WriteFile(fixturePath(t.Dir, req, reqBody), data, 0o644); err != nil {
+		return nil, fmt.Errorf("error recording %s %s: %w", req.Method, req.URL, err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// ReplayTransport answers requests from fixtures previously saved by RecordingTransport,
+// without touching the network
+type ReplayTransport struct {
+	Dir string
+}
+
+// RoundTrip returns the recorded response for the request, or an error if none was recorded
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, reqBody, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fixturePath(t.Dir, req, reqBody))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var recorded fixture
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return nil, fmt.Errorf("error reading fixture for %s %s: %w", req.Method, req.URL, err)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recorded.Header,
+		Body:          io.NopCloser(bytes.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
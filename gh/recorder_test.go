@@ -0,0 +1,80 @@
+package gh_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"repo-pack/gh"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("recorded body"))
+	}))
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: &gh.RecordingTransport{Dir: dir}}
+	resp, err := recorder.Get(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	server.Close()
+
+	replayer := &http.Client{Transport: &gh.ReplayTransport{Dir: dir}}
+	resp, err = replayer.Get(server.URL + "/file")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusTeapot || string(body) != "recorded body" {
+		t.Errorf("expected replayed 418 %q, got: %d %q", "recorded body", resp.StatusCode, body)
+	}
+
+	if _, err := replayer.Get(server.URL + "/missing"); err == nil {
+		t.Errorf("expected error for unrecorded request, got: nil")
+	}
+}
+
+func TestRecordThenReplayTellsBodiesApart(t *testing.T) {
+	// Git protocol commands are all posted to the same URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("answer to " + string(body)))
+	}))
+
+	dir := t.TempDir()
+	recorder := &http.Client{Transport: &gh.RecordingTransport{Dir: dir}}
+	for _, command := range []string{"ls-refs", "fetch"} {
+		resp, err := recorder.Post(server.URL+"/o/r.git/git-upload-pack", "text/plain", strings.NewReader(command))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "answer to "+command {
+			t.Errorf("expected the request body to reach the server, got %q", body)
+		}
+		resp.Body.Close()
+	}
+	server.Close()
+
+	replayer := &http.Client{Transport: &gh.ReplayTransport{Dir: dir}}
+	for _, command := range []string{"fetch", "ls-refs"} {
+		resp, err := replayer.Post(server.URL+"/o/r.git/git-upload-pack", "text/plain", strings.NewReader(command))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "answer to "+command {
+			t.Errorf("expected the answer recorded for %s, got %q", command, body)
+		}
+	}
+	if _, err := replayer.Post(server.URL+"/o/r.git/git-upload-pack", "text/plain", strings.NewReader("other")); err == nil {
+		t.Errorf("expected error for an unrecorded body, got: nil")
+	}
+}
@@ -0,0 +1,176 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"repo-pack/gitproto"
+	"repo-pack/model"
+)
+
+// maxTagDepth bounds how many annotated tags pointing at tags are followed to reach a commit
+const maxTagDepth = 5
+
+// ResolvedRef is a ref given explicitly, such as with --ref, and the commit it points to
+type ResolvedRef struct {
+	Name string
+	// Kind is "branch", "tag" or "commit"
+	Kind string
+	SHA  string
+}
+
+// gitObject is the object a ref or annotated tag points to in the Git database API
+type gitObject struct {
+	Type string `json:"type"`
+	SHA  string `json:"sha"`
+}
+
+// ResolveRef looks ref up exactly with the Git refs API, as a branch and then as a tag, so a
+// name like v1.2.3 or release/2.0 resolves without guessing where it ends. A full 40-character
+// SHA is checked to be a commit instead. Annotated tags are followed to the commit they tag.
+func (c *Client) ResolveRef(ctx context.Context, components model.RepoURLComponents, ref string) (ResolvedRef, error) {
+	repo := fmt.Sprintf("repos/%s/%s", components.Owner, components.Repository)
+
+	if gitproto.IsObjectID(ref) {
+		var commit struct {
+			SHA string `json:"sha"`
+		}
+		found, err := c.getJSON(ctx, fmt.Sprintf("%s/git/commits/%s", repo, ref), &commit)
+		if err != nil {
+			return ResolvedRef{}, fmt.Errorf("failed to look up commit %s: %v", ref, err)
+		}
+		if !found {
+			return ResolvedRef{}, fmt.Errorf("commit %s not found in %s/%s", ref, components.Owner, components.Repository)
+		}
+		return ResolvedRef{Name: ref, Kind: "commit", SHA: commit.SHA}, nil
+	}
+
+	for _, kind := range []struct{ namespace, name string }{{"heads", "branch"}, {"tags", "tag"}} {
+		var gitRef struct {
+			Object gitObject `json:"object"`
+		}
+		found, err := c.getJSON(ctx, fmt.Sprintf("%s/git/ref/%s/%s", repo, kind.namespace, escapePath(ref)), &gitRef)
+		if err != nil {
+			return ResolvedRef{}, fmt.Errorf("failed to look up ref %s: %v", ref, err)
+		}
+		if !found {
+			continue
+		}
+
+		object := gitRef.Object
+		for depth := 0; object.Type == "tag"; depth++ {
+			if depth == maxTagDepth {
+				return ResolvedRef{}, fmt.Errorf("tag %s is nested too deeply", ref)
+			}
+			var tag struct {
+				Object gitObject `json:"object"`
+			}
+			if _, err := c.getJSON(ctx, fmt.Sprintf("%s/git/tags/%s", repo, object.SHA), &tag); err != nil {
+				return ResolvedRef{}, fmt.Errorf("failed to look up tag %s: %v", ref, err)
+			}
+			object = tag.Object
+		}
+		if object.Type != "commit" {
+			return ResolvedRef{}, fmt.Errorf("%s %s points to a %s, not a commit", kind.name, ref, object.Type)
+		}
+		return ResolvedRef{Name: ref, Kind: kind.name, SHA: object.SHA}, nil
+	}
+	return ResolvedRef{}, fmt.Errorf("no branch or tag named %s in %s/%s", ref, components.Owner, components.Repository)
+}
+
+// ResolveURLRef settles where the ref ends in a URL such as /tree/feat/new-feature/docs, which
+// is parsed with Ref "feat". It asks the Git refs API for the branches, then the tags, starting
+// with "feat/" and keeps the longest one the URL path begins with, the rest being the
+// directory. When none match, the first segment is the ref. Components whose ref is already
+// settled are left as they are.
+func (c *Client) ResolveURLRef(ctx context.Context, components *model.RepoURLComponents) error {
+	rest := components.Dir
+	if components.IsFile {
+		rest = components.FilePath
+	}
+	if components.RefResolved || components.Ref == "" || strings.Trim(rest, "/") == "" {
+		components.RefResolved = true
+		return nil
+	}
+
+	full := components.Ref + "/" + rest
+	longest := components.Ref
+	for _, namespace := range []string{"heads", "tags"} {
+		names, err := c.matchingRefs(ctx, *components, namespace, components.Ref+"/")
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if len(name) > len(longest) && (full == name || strings.HasPrefix(full, name+"/")) {
+				longest = name
+			}
+		}
+	}
+
+	components.RefResolved = true
+	if longest == components.Ref {
+		return nil
+	}
+	rest = strings.TrimPrefix(strings.TrimPrefix(full, longest), "/")
+	components.Ref = longest
+	if components.IsFile {
+		if strings.Trim(rest, "/") == "" {
+			return fmt.Errorf("the URL names ref %s but no file in it", longest)
+		}
+		components.FilePath = strings.Trim(rest, "/")
+		components.Dir = strings.TrimSuffix(path.Dir(components.FilePath), ".")
+	} else {
+		components.Dir = rest
+	}
+	return nil
+}
+
+// matchingRefs lists the names of the branches ("heads") or tags ("tags") starting with prefix
+func (c *Client) matchingRefs(ctx context.Context, components model.RepoURLComponents, namespace, prefix string) ([]string, error) {
+	var names []string
+	for page := 1; ; page++ {
+		var refs []struct {
+			Ref string `json:"ref"`
+		}
+		endpoint := fmt.Sprintf("repos/%s/%s/git/matching-refs/%s/%s?per_page=%d&page=%d",
+			components.Owner, components.Repository, namespace, escapePath(prefix), refsPageSize, page)
+		found, err := c.getJSON(ctx, endpoint, &refs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up refs starting with %s: %v", prefix, err)
+		}
+		for _, ref := range refs {
+			names = append(names, strings.TrimPrefix(ref.Ref, "refs/"+namespace+"/"))
+		}
+		if !found || len(refs) < refsPageSize {
+			return names, nil
+		}
+	}
+}
+
+// getJSON decodes the API response for endpoint into v, reporting a 404 as not found rather than
+// as an error
+func (c *Client) getJSON(ctx context.Context, endpoint string, v any) (bool, error) {
+	resp, _, err := c.doRequestWithRetry(ctx, c.apiURL(endpoint), "", true)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		if err := blockedResponse(resp, blockedTarget(endpoint)); err != nil {
+			return false, err
+		}
+		return false, fmt.Errorf("HTTP request failed with status code: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
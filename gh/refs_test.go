@@ -0,0 +1,102 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"repo-pack/gh"
+	"repo-pack/model"
+	"strings"
+	"testing"
+)
+
+func TestClientResolveRef(t *testing.T) {
+	const commitSHA = "0123456789abcdef0123456789abcdef01234567"
+	responses := map[string]string{
+		"/repos/o/r/git/ref/heads/release/2.0": `{"object":{"type":"commit","sha":"` + commitSHA + `"}}`,
+		"/repos/o/r/git/ref/tags/v1.2.3":       `{"object":{"type":"tag","sha":"tagsha"}}`,
+		"/repos/o/r/git/tags/tagsha":           `{"object":{"type":"commit","sha":"` + commitSHA + `"}}`,
+		"/repos/o/r/git/commits/" + commitSHA:  `{"sha":"` + commitSHA + `"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+
+	for _, test := range []struct {
+		ref  string
+		kind string
+	}{
+		{ref: "release/2.0", kind: "branch"},
+		{ref: "v1.2.3", kind: "tag"},
+		{ref: commitSHA, kind: "commit"},
+	} {
+		resolved, err := client.ResolveRef(context.Background(), components, test.ref)
+		if err != nil {
+			t.Errorf("unexpected error resolving %s: %v", test.ref, err)
+			continue
+		}
+		if expected := (gh.ResolvedRef{Name: test.ref, Kind: test.kind, SHA: commitSHA}); resolved != expected {
+			t.Errorf("expected %s to resolve to %+v, got %+v", test.ref, expected, resolved)
+		}
+	}
+
+	for _, ref := range []string{"v9", strings.Repeat("f", 40)} {
+		if _, err := client.ResolveRef(context.Background(), components, ref); err == nil {
+			t.Errorf("expected an error for the unknown ref %s", ref)
+		}
+	}
+}
+
+func TestClientResolveURLRef(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/git/matching-refs/heads/feat/":
+			w.Write([]byte(`[{"ref":"refs/heads/feat/new"},{"ref":"refs/heads/feat/new-feature"}]`))
+		case "/repos/o/r/git/matching-refs/tags/feat/":
+			w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	for _, test := range []struct {
+		components model.RepoURLComponents
+		expected   model.RepoURLComponents
+	}{
+		{
+			components: model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "feat", Dir: "new-feature/docs"},
+			expected:   model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "feat/new-feature", Dir: "docs", RefResolved: true},
+		},
+		{
+			components: model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "feat", Dir: "new-feature/src", FilePath: "new-feature/src/main.go", IsFile: true},
+			expected:   model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "feat/new-feature", Dir: "src", FilePath: "src/main.go", IsFile: true, RefResolved: true},
+		},
+		{
+			components: model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "feat/docs"},
+			expected:   model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "feat/docs", RefResolved: true},
+		},
+	} {
+		components := test.components
+		if err := client.ResolveURLRef(context.Background(), &components); err != nil {
+			t.Errorf("unexpected error for %+v: %v", test.components, err)
+			continue
+		}
+		if components != test.expected {
+			t.Errorf("expected %+v, got %+v", test.expected, components)
+		}
+	}
+}
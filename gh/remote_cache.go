@@ -0,0 +1,132 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// remoteCacheTimeout bounds a single upload or download, within the caller's own deadline
+const remoteCacheTimeout = 5 * time.Minute
+
+// RemoteCache is a Cache shared over HTTP, so that several machines reuse one set of blobs.
+// Blobs are fetched with GET and uploaded with PUT at <BaseURL>/<sha>.
+type RemoteCache struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// sign authorizes requests before they are sent, e.g. with AWS Signature V4
+	sign func(req *http.Request) error
+}
+
+// NewRemoteCache creates a remote cache from an http(s):// base URL or an s3://bucket/prefix
+// location. S3 requests are signed with credentials from the standard AWS_* environment.
+func NewRemoteCache(location string) (*RemoteCache, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote cache URL: %s", location)
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+		return &RemoteCache{BaseURL: strings.TrimSuffix(location, "/")}, nil
+	case "s3":
+		signer, err := s3SignerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return &RemoteCache{
+			BaseURL: signer.bucketURL(parsed.Host, strings.Trim(parsed.Path, "/")),
+			sign:    signer.sign,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported remote cache scheme %q, use http(s):// or s3://", parsed.Scheme)
+	}
+}
+
+func (c *RemoteCache) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *RemoteCache) do(req *http.Request) (*http.Response, error) {
+	if c.sign != nil {
+		if err := c.sign(req); err != nil {
+			return nil, err
+		}
+	}
+	return c.httpClient().Do(req)
+}
+
+// Restore downloads the blob stored under sha to dst and reports whether it was found
+func (c *RemoteCache) Restore(ctx context.Context, sha, dst string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, remoteCacheTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/"+sha, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return false, fmt.Errorf("error reading remote cache: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return false, nil
+	case resp.StatusCode == http.StatusForbidden:
+		// S3 also answers 403 for missing keys when the credentials can't list the bucket, but
+		// taking every 403 for a miss would hide credentials that can't read the cache at all
+		return false, fmt.Errorf("remote cache denied access to %s (%s); S3 credentials need s3:GetObject, and s3:ListBucket for missing blobs to be reported as such", sha, resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("remote cache returned %s for %s", resp.Status, sha)
+	}
+
+	if err := writeFile(dst, resp.Body); err != nil {
+		return false, fmt.Errorf("error restoring %s from remote cache: %w", dst, err)
+	}
+	return true, nil
+}
+
+// Store uploads the file at src under sha
+func (c *RemoteCache) Store(ctx context.Context, sha, src string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteCacheTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.BaseURL+"/"+sha, file)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("error writing remote cache: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("remote cache returned %s storing %s", resp.Status, sha)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is how many times a request is tried before it is reported as failed
+	DefaultMaxAttempts = 3
+	// DefaultRetryDelay is the wait before the first retry, doubled for each one after
+	DefaultRetryDelay = time.Second
+	// maxRetryWait caps how long a server may ask us to wait before retrying; a longer
+	// Retry-After or rate limit reset fails the request instead of stalling the run
+	maxRetryWait = time.Minute
+)
+
+// FetchError describes a file that failed permanently, with enough detail to tell rate
+// limiting or flaky networks apart from files that genuinely don't exist
+type FetchError struct {
+	Path     string
+	Attempts int
+	// StatusCode is the last HTTP status received, or 0 when no response arrived
+	StatusCode int
+	// RateLimited is set when the last response reported an exhausted rate limit
+	RateLimited bool
+	// LFS is set when the failed request was for a Git LFS file's content
+	LFS     bool
+	Elapsed time.Duration
+	Err     error
+}
+
+func (e *FetchError) Error() string {
+	attempts := "1 attempt"
+	if e.Attempts != 1 {
+		attempts = fmt.Sprintf("%d attempts", e.Attempts)
+	}
+	status := "no response"
+	if e.StatusCode != 0 {
+		status = fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%v (%s, %s, %s)", e.Err, attempts, status, e.Elapsed.Round(time.Millisecond))
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// retryable reports whether a request that failed this way may succeed if repeated. Besides
+// 429s and 5xx responses, that includes 403s carrying rate limit headers, which is how GitHub
+// reports both primary and secondary rate limits.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, ErrCallLimitReached)
+	}
+	return rateLimited(resp) || resp.StatusCode >= 500
+}
+
+// rateLimited reports whether a response turns the request away for exceeding a rate limit
+func rateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusForbidden {
+		return resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0"
+	}
+	return resp.StatusCode == http.StatusTooManyRequests
+}
+
+// retryAfter returns the wait a response asks for, from Retry-After in seconds or as a date,
+// or from the rate limit reset time once the limit is exhausted. ok is false when the
+// response names no wait.
+func retryAfter(resp *http.Response, now time.Time) (wait time.Duration, ok bool) {
+	if resp == nil {
+		return 0, false
+	}
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(max(seconds, 0)) * time.Second, true
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return max(at.Sub(now), 0), true
+		}
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			return max(time.Unix(reset, 0).Sub(now), 0), true
+		}
+	}
+	return 0, false
+}
+
+// doRequestWithRetry performs a GET like getAccept, repeating it with exponential backoff after
+// network errors, rate limiting and 5xx responses. A wait requested through Retry-After or a
+// rate limit reset replaces the backoff delay. It returns the last response or error along
+// with the number of attempts made.
+func (c *Client) doRequestWithRetry(ctx context.Context, url, accept string, authenticated bool) (*http.Response, int, error) {
+	maxAttempts := c.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	delay := c.RetryDelay
+	for attempt := 1; ; attempt++ {
+		resp, err := c.getAccept(ctx, url, accept, authenticated)
+		if attempt >= maxAttempts || !retryable(ctx, resp, err) {
+			return resp, attempt, err
+		}
+
+		wait := delay
+		if requested, ok := retryAfter(resp, time.Now()); ok {
+			if requested > maxRetryWait {
+				return resp, attempt, err
+			}
+			wait = requested
+		}
+		if resp != nil {
+			// Draining lets the connection be reused for the next attempt
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,111 @@
+package gh_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"repo-pack/gh"
+	"repo-pack/model"
+	"testing"
+	"time"
+)
+
+func TestFetchErrorDescribesAttempts(t *testing.T) {
+	cause := errors.New("HTTP 429 Too Many Requests for dir/a.go")
+	err := &gh.FetchError{Path: "dir/a.go", Attempts: 3, StatusCode: 429, Elapsed: 7 * time.Second, Err: cause}
+
+	expected := "HTTP 429 Too Many Requests for dir/a.go (3 attempts, HTTP 429, 7s)"
+	if err.Error() != expected {
+		t.Errorf("expected error: %q, got: %q", expected, err.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected the cause to be unwrapped")
+	}
+}
+
+func TestListingRetriesTransientFailures(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			w.WriteHeader(http.StatusBadGateway)
+		case 2:
+			// The requested wait replaces the much longer backoff delay
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`{"tree": [{"type": "blob", "path": "dir/a.go", "sha": "aaa", "size": 1}]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	client.RetryDelay = time.Millisecond
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "dir"}
+	start := time.Now()
+	files, _, err := client.ViaTreesAPI(context.Background(), components)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || calls != 3 {
+		t.Errorf("expected 1 file after 3 requests, got %d files after %d requests", len(files), calls)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Retry-After: 0 to retry immediately, took %v", elapsed)
+	}
+}
+
+func TestRetryGivesUpOnLongRetryAfter(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main"}
+	if _, _, err := client.ViaTreesAPI(context.Background(), components); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if calls != 1 {
+		t.Errorf("expected no retry when asked to wait an hour, got %d requests", calls)
+	}
+}
+
+func TestVisibilityCheckRetriesUpToMaxAttempts(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			// Secondary rate limits arrive as 403s naming a wait
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"private": true}`))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo"}
+
+	private, err := client.FetchRepoIsPrivate(context.Background(), &components)
+	if err != nil || !private || calls != 2 {
+		t.Fatalf("expected the 403 to be retried, got private=%v after %d requests (%v)", private, calls, err)
+	}
+
+	calls = 0
+	client.MaxAttempts = 1
+	if _, err := client.FetchRepoIsPrivate(context.Background(), &components); err == nil || calls != 1 {
+		t.Errorf("expected a single attempt to give up on the 403, got %d requests (%v)", calls, err)
+	}
+}
@@ -0,0 +1,64 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"repo-pack/model"
+)
+
+const (
+	searchPageSize = 100
+	// searchMaxResults is the most results the code search API returns for one query
+	searchMaxResults = 1000
+)
+
+// codeSearchResponse is one page of code search API results
+type codeSearchResponse struct {
+	TotalCount int `json:"total_count"`
+	Items      []struct {
+		Path string `json:"path"`
+		SHA  string `json:"sha"`
+	} `json:"items"`
+}
+
+// SearchCode runs a code search query scoped to the repository and returns the matching files
+// inside components.Dir. Code search only indexes the default branch and requires a token.
+// Sizes aren't reported by the search API, so they are -1.
+func (c *Client) SearchCode(ctx context.Context, components model.RepoURLComponents, query string) ([]model.FileInfo, error) {
+	q := fmt.Sprintf("%s repo:%s/%s", strings.TrimSpace(query), components.Owner, components.Repository)
+
+	seen := map[string]bool{}
+	files := []model.FileInfo{}
+	for page, fetched := 1, 0; fetched < searchMaxResults; page++ {
+		contents, err := c.apiGet(
+			ctx,
+			fmt.Sprintf("search/code?q=%s&per_page=%d&page=%d", url.QueryEscape(q), searchPageSize, page),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("code search failed: %v", err)
+		}
+
+		var result codeSearchResponse
+		if err := json.Unmarshal(contents, &result); err != nil {
+			return nil, err
+		}
+
+		for _, item := range result.Items {
+			if seen[item.Path] || !inDir(item.Path, components.Dir) {
+				continue
+			}
+			seen[item.Path] = true
+			files = append(files, model.FileInfo{Path: item.Path, Size: -1, SHA: item.SHA})
+		}
+
+		fetched += len(result.Items)
+		if len(result.Items) < searchPageSize || fetched >= result.TotalCount {
+			break
+		}
+	}
+	return files, nil
+}
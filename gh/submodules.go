@@ -0,0 +1,173 @@
+package gh
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// submoduleMode is the git file mode of a submodule, a commit of another repository
+const submoduleMode = "160000"
+
+// Submodule is a submodule found in a listing: where it's checked out, the commit pinned
+// there and the URL .gitmodules gives for its repository, empty when it gives none
+type Submodule struct {
+	Path   string
+	Commit string
+	URL    string
+}
+
+var (
+	gitmodulesSection = regexp.MustCompile(`^\[\s*submodule\s+"(.*)"\s*\]$`)
+	gitmodulesKey     = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*)\s*=\s*(.*)$`)
+	// scpLikeURL is the user@host:owner/repo form git accepts for ssh remotes
+	scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+)
+
+// ParseGitmodules reads the path and URL of each submodule a .gitmodules file declares,
+// returning the URLs by path. Other keys and sections are ignored.
+func ParseGitmodules(data []byte) (map[string]string, error) {
+	type section struct{ path, url string }
+	var sections []*section
+	var current *section
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if strings.HasPrefix(text, "[") {
+			current = nil
+			if gitmodulesSection.MatchString(text) {
+				current = &section{}
+				sections = append(sections, current)
+			}
+			continue
+		}
+		m := gitmodulesKey.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf(".gitmodules line %d isn't a key = value line", line)
+		}
+		if current == nil {
+			continue
+		}
+		value := strings.Trim(m[2], `"`)
+		switch strings.ToLower(m[1]) {
+		case "path":
+			current.path = path.Clean(value)
+		case "url":
+			current.url = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]string, len(sections))
+	for _, s := range sections {
+		if s.path != "" && s.url != "" {
+			urls[s.path] = s.url
+		}
+	}
+	return urls, nil
+}
+
+// Submodules pairs the submodule entries of a listing of components with the URLs the
+// repository's .gitmodules gives for them. A repository without a readable .gitmodules
+// yields submodules without URLs rather than an error.
+func (c *Client) Submodules(ctx context.Context, components model.RepoURLComponents, entries []model.FileInfo) ([]Submodule, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	root := components
+	root.Dir, root.FilePath, root.IsFile = "", "", false
+	var urls map[string]string
+	if data, err := c.RawFile(ctx, root, ".gitmodules"); err == nil {
+		if urls, err = ParseGitmodules(data); err != nil {
+			return nil, err
+		}
+	}
+
+	submodules := make([]Submodule, 0, len(entries))
+	for _, entry := range entries {
+		submodules = append(submodules, Submodule{Path: entry.Path, Commit: entry.SHA, URL: urls[entry.Path]})
+	}
+	return submodules, nil
+}
+
+// SubmoduleRepository returns the repository a submodule of parent comes from, pinned to the
+// submodule's commit. Its URL may be an https or ssh URL, or relative to parent's own URL as
+// in ../other.git; only repositories on the client's git host can be downloaded.
+func (c *Client) SubmoduleRepository(parent model.RepoURLComponents, submodule Submodule) (model.RepoURLComponents, error) {
+	if submodule.URL == "" {
+		return model.RepoURLComponents{}, fmt.Errorf("submodule %s has no URL in .gitmodules", submodule.Path)
+	}
+	gitBase, err := url.Parse(c.GitBaseURL)
+	if err != nil {
+		return model.RepoURLComponents{}, err
+	}
+
+	var host, repoPath string
+	switch {
+	case strings.HasPrefix(submodule.URL, "./") || strings.HasPrefix(submodule.URL, "../"):
+		host = gitBase.Hostname()
+		repoPath = path.Join("/", parent.Owner, parent.Repository, submodule.URL)
+	case strings.Contains(submodule.URL, "://"):
+		parsed, err := url.Parse(submodule.URL)
+		if err != nil {
+			return model.RepoURLComponents{}, fmt.Errorf("submodule %s has an invalid URL %q", submodule.Path, submodule.URL)
+		}
+		host, repoPath = parsed.Hostname(), parsed.Path
+	default:
+		m := scpLikeURL.FindStringSubmatch(submodule.URL)
+		if m == nil {
+			return model.RepoURLComponents{}, fmt.Errorf("submodule %s has an unsupported URL %q", submodule.Path, submodule.URL)
+		}
+		host, repoPath = m[1], m[2]
+	}
+	if !sameHost(host, gitBase.Hostname()) {
+		return model.RepoURLComponents{}, fmt.Errorf("submodule %s is hosted on %s, not %s", submodule.Path, host, gitBase.Hostname())
+	}
+
+	// Enterprise servers may serve repositories below a path prefix
+	repoPath = strings.TrimPrefix(path.Clean("/"+repoPath), strings.TrimSuffix(gitBase.Path, "/"))
+	owner, repository, ok := strings.Cut(strings.Trim(repoPath, "/"), "/")
+	repository = strings.TrimSuffix(repository, ".git")
+	if !ok || owner == "" || repository == "" || strings.Contains(repository, "/") {
+		return model.RepoURLComponents{}, fmt.Errorf("submodule %s has URL %q, which doesn't name a repository", submodule.Path, submodule.URL)
+	}
+	return model.RepoURLComponents{
+		Owner:       owner,
+		Repository:  repository,
+		Ref:         submodule.Commit,
+		RefResolved: true,
+		Commit:      submodule.Commit,
+	}, nil
+}
+
+// sameHost reports whether two host names are the same, taking www.github.com for github.com
+func sameHost(a, b string) bool {
+	a, b = strings.TrimPrefix(strings.ToLower(a), "www."), strings.TrimPrefix(strings.ToLower(b), "www.")
+	return a == b
+}
+
+// splitSubmodules separates the submodule entries of a listing from its files
+func splitSubmodules(listing []model.FileInfo) (files, submodules []model.FileInfo) {
+	files = []model.FileInfo{}
+	for _, entry := range listing {
+		if entry.Mode == submoduleMode {
+			submodules = append(submodules, entry)
+		} else {
+			files = append(files, entry)
+		}
+	}
+	return files, submodules
+}
@@ -0,0 +1,120 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"repo-pack/gh"
+	"repo-pack/model"
+)
+
+func TestParseGitmodules(t *testing.T) {
+	data := []byte(`# vendored dependencies
+[submodule "lib"]
+	path = vendor/lib
+	url = https://github.com/o/lib.git
+[submodule "docs"]
+	path = "docs/theme/"
+	url = ../theme.git
+	branch = main
+[core]
+	path = not/a/submodule
+	url = https://example.com/x.git
+[submodule "incomplete"]
+	path = missing/url
+`)
+	urls, err := gh.ParseGitmodules(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"vendor/lib": "https://github.com/o/lib.git",
+		"docs/theme": "../theme.git",
+	}
+	if !reflect.DeepEqual(urls, expected) {
+		t.Errorf("expected %v, got %v", expected, urls)
+	}
+
+	if _, err := gh.ParseGitmodules([]byte("[submodule \"x\"]\n\tpath\n")); err == nil {
+		t.Errorf("expected an error for a line without a value")
+	}
+}
+
+func TestClientSubmoduleRepository(t *testing.T) {
+	client := gh.NewClient("")
+	parent := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+
+	tests := []struct {
+		url, owner, repository string
+	}{
+		{"https://github.com/other/lib.git", "other", "lib"},
+		{"https://github.com/other/lib", "other", "lib"},
+		{"git@github.com:other/lib.git", "other", "lib"},
+		{"ssh://git@github.com/other/lib.git", "other", "lib"},
+		{"../lib.git", "o", "lib"},
+		{"../../team/lib", "team", "lib"},
+	}
+	for _, tt := range tests {
+		components, err := client.SubmoduleRepository(parent, gh.Submodule{Path: "lib", Commit: "abc", URL: tt.url})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.url, err)
+			continue
+		}
+		expected := model.RepoURLComponents{Owner: tt.owner, Repository: tt.repository, Ref: "abc", RefResolved: true, Commit: "abc"}
+		if components != expected {
+			t.Errorf("%s: expected %+v, got %+v", tt.url, expected, components)
+		}
+	}
+
+	for _, url := range []string{"", "https://gitlab.com/other/lib.git", "https://github.com/other", "../../../lib.git", "not a url"} {
+		if _, err := client.SubmoduleRepository(parent, gh.Submodule{Path: "lib", Commit: "abc", URL: url}); err == nil {
+			t.Errorf("%q: expected an error", url)
+		}
+	}
+}
+
+func TestClientRepoListingReturnsSubmodules(t *testing.T) {
+	responses := map[string]string{
+		"/repos/o/r/git/trees/main?recursive=1": `{"tree": [
+			{"type": "blob", "path": "docs/a.md", "mode": "100644", "sha": "aaa", "size": 1},
+			{"type": "commit", "path": "docs/theme", "mode": "160000", "sha": "themesha"},
+			{"type": "commit", "path": "vendor/lib", "mode": "160000", "sha": "libsha"}
+		], "truncated": false}`,
+		"/raw/o/r/main/.gitmodules": "[submodule \"theme\"]\n\tpath = docs/theme\n\turl = ../theme.git\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			t.Errorf("unexpected request: %s", r.URL.RequestURI())
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	client.RawBaseURL = server.URL + "/raw"
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "docs", RefResolved: true}
+	files, entries, _, err := client.RepoListing(context.Background(), &components)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []model.FileInfo{{Path: "docs/a.md", SHA: "aaa", Size: 1, Mode: "100644"}}; !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files %+v, got %+v", expected, files)
+	}
+
+	submodules, err := client.Submodules(context.Background(), components, entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []gh.Submodule{{Path: "docs/theme", Commit: "themesha", URL: "../theme.git"}}
+	if !reflect.DeepEqual(submodules, expected) {
+		t.Errorf("expected submodules %+v, got %+v", expected, submodules)
+	}
+}
@@ -0,0 +1,189 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"repo-pack/model"
+)
+
+const (
+	// symlinkMode is the git file mode of a symbolic link
+	symlinkMode = "120000"
+	// regularMode is the git file mode of a file that isn't executable
+	regularMode = "100644"
+)
+
+// FollowSymlinks replaces symlinks pointing at directories inside the repository with the files
+// of their target, listed under the link's path and downloaded from the target. Links to files,
+// links leaving the repository and links that would loop back on themselves are kept as they
+// are, the latter reported to warn.
+func (c *Client) FollowSymlinks(
+	ctx context.Context,
+	components model.RepoURLComponents,
+	files []model.FileInfo,
+	warn func(error),
+) ([]model.FileInfo, error) {
+	return c.resolveSymlinks(ctx, components, files, warn, false)
+}
+
+// DereferenceSymlinks follows symlinks as FollowSymlinks does, and also replaces links to files
+// inside the repository with their target, downloaded under the link's path, so no links are
+// left to recreate. Links it can't resolve, leaving the repository, dangling or looping, are
+// reported to warn and kept as regular files holding their target.
+func (c *Client) DereferenceSymlinks(
+	ctx context.Context,
+	components model.RepoURLComponents,
+	files []model.FileInfo,
+	warn func(error),
+) ([]model.FileInfo, error) {
+	return c.resolveSymlinks(ctx, components, files, warn, true)
+}
+
+func (c *Client) resolveSymlinks(
+	ctx context.Context,
+	components model.RepoURLComponents,
+	files []model.FileInfo,
+	warn func(error),
+	dereference bool,
+) ([]model.FileInfo, error) {
+	if !slices.ContainsFunc(files, func(f model.FileInfo) bool { return f.Mode == symlinkMode }) {
+		return files, nil
+	}
+
+	// Targets may lie anywhere in the repository, so they're resolved against the whole tree
+	root := components
+	root.Dir = ""
+	tree, _, err := c.ViaTreesAPI(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository for symlinks: %v", err)
+	}
+
+	// The requested directory counts as being expanded, so links back into it are cycles too
+	r := &symlinkResolver{client: c, components: components, tree: tree, warn: warn, dereference: dereference}
+	return r.expand(ctx, files, []string{strings.Trim(components.Dir, "/")})
+}
+
+type symlinkResolver struct {
+	client     *Client
+	components model.RepoURLComponents
+	tree       []model.FileInfo
+	warn       func(error)
+	// dereference replaces links to files with their target too
+	dereference bool
+}
+
+// expand follows the directory symlinks among files. chain holds the targets already being
+// expanded, so a link back into one of them is detected as a cycle.
+func (r *symlinkResolver) expand(ctx context.Context, files []model.FileInfo, chain []string) ([]model.FileInfo, error) {
+	expanded := make([]model.FileInfo, 0, len(files))
+	for _, file := range files {
+		if file.Mode != symlinkMode {
+			expanded = append(expanded, file)
+			continue
+		}
+
+		target, members, err := r.resolve(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		if members == nil && r.dereference {
+			dereferenced, err := r.dereferenceFile(ctx, file, target, chain)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, dereferenced...)
+			continue
+		}
+		if members == nil {
+			expanded = append(expanded, file)
+			continue
+		}
+		if inDir(file.SourcePath(), target) || slices.Contains(chain, target) {
+			r.warnf("not following symlink %s: it loops back into %s", file.Path, target)
+			expanded = append(expanded, r.keep(file))
+			continue
+		}
+
+		linked := make([]model.FileInfo, len(members))
+		for i, member := range members {
+			member.Source = member.Path
+			member.Path = file.Path + strings.TrimPrefix(member.Path, target)
+			linked[i] = member
+		}
+		linked, err = r.expand(ctx, linked, append(chain[:len(chain):len(chain)], target))
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, linked...)
+	}
+	return expanded, nil
+}
+
+// dereferenceFile replaces a link with the file it points to, following links to links
+func (r *symlinkResolver) dereferenceFile(ctx context.Context, link model.FileInfo, target string, chain []string) ([]model.FileInfo, error) {
+	index := slices.IndexFunc(r.tree, func(f model.FileInfo) bool { return f.Path == target })
+	if target == "" || index < 0 {
+		r.warnf("not dereferencing symlink %s: its target is missing or outside the repository", link.Path)
+		return []model.FileInfo{r.keep(link)}, nil
+	}
+	if slices.Contains(chain, target) {
+		r.warnf("not dereferencing symlink %s: it loops back to %s", link.Path, target)
+		return []model.FileInfo{r.keep(link)}, nil
+	}
+
+	dest := r.tree[index]
+	dereferenced := link
+	dereferenced.Source, dereferenced.Mode, dereferenced.SHA, dereferenced.Size = dest.Path, dest.Mode, dest.SHA, dest.Size
+	if dest.Mode != symlinkMode {
+		return []model.FileInfo{dereferenced}, nil
+	}
+	return r.expand(ctx, []model.FileInfo{dereferenced}, append(chain[:len(chain):len(chain)], target))
+}
+
+// keep returns a link that isn't followed as it is saved: recreated as a link, or when
+// dereferencing, as a regular file holding its target
+func (r *symlinkResolver) keep(link model.FileInfo) model.FileInfo {
+	if r.dereference {
+		link.Mode = regularMode
+	}
+	return link
+}
+
+// resolve reads a symlink's target and returns it with the files beneath it. No files are
+// returned when the target is a file, missing or outside the repository.
+func (r *symlinkResolver) resolve(ctx context.Context, link model.FileInfo) (string, []model.FileInfo, error) {
+	data, err := r.client.RawFile(ctx, r.components, link.SourcePath())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read symlink %s: %v", link.Path, err)
+	}
+
+	dest := strings.TrimSpace(string(data))
+	if path.IsAbs(dest) {
+		return "", nil, nil
+	}
+	target := path.Join(path.Dir(link.SourcePath()), dest)
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", nil, nil
+	}
+	if target == "." {
+		target = ""
+	}
+
+	var members []model.FileInfo
+	for _, file := range r.tree {
+		if inDir(file.Path, target) {
+			members = append(members, file)
+		}
+	}
+	return target, members, nil
+}
+
+func (r *symlinkResolver) warnf(format string, args ...any) {
+	if r.warn != nil {
+		r.warn(fmt.Errorf(format, args...))
+	}
+}
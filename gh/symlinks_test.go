@@ -0,0 +1,133 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"repo-pack/gh"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestClientFollowSymlinks(t *testing.T) {
+	links := map[string]string{
+		"/repos/owner/repo/contents/app/shared": "../assets",
+		"/repos/owner/repo/contents/assets/up":  "../app",
+		"/repos/owner/repo/contents/app/readme": "../README.md",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/owner/repo/git/trees/main" {
+			w.Write([]byte(`{"tree": [
+				{"type": "blob", "path": "README.md", "mode": "100644", "sha": "r", "size": 1},
+				{"type": "blob", "path": "app/main.go", "mode": "100644", "sha": "m", "size": 2},
+				{"type": "blob", "path": "app/readme", "mode": "120000", "sha": "l1", "size": 12},
+				{"type": "blob", "path": "app/shared", "mode": "120000", "sha": "l2", "size": 9},
+				{"type": "blob", "path": "assets/logo.svg", "mode": "100644", "sha": "s", "size": 3},
+				{"type": "blob", "path": "assets/up", "mode": "120000", "sha": "l3", "size": 6}
+			], "truncated": false}`))
+			return
+		}
+		target, ok := links[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		w.Write([]byte(target))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "app", Private: true}
+	files := []model.FileInfo{
+		{Path: "app/main.go", Size: 2, SHA: "m", Mode: "100644"},
+		{Path: "app/readme", Size: 12, SHA: "l1", Mode: "120000"},
+		{Path: "app/shared", Size: 9, SHA: "l2", Mode: "120000"},
+	}
+	var warnings []error
+	files, err := client.FollowSymlinks(context.Background(), components, files, func(err error) {
+		warnings = append(warnings, err)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{
+		{Path: "app/main.go", Size: 2, SHA: "m", Mode: "100644"},
+		{Path: "app/readme", Size: 12, SHA: "l1", Mode: "120000"},
+		{Path: "app/shared/logo.svg", Size: 3, SHA: "s", Mode: "100644", Source: "assets/logo.svg"},
+		{Path: "app/shared/up", Size: 6, SHA: "l3", Mode: "120000", Source: "assets/up"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("expected the loop through assets/up to be reported once, got: %v", warnings)
+	}
+}
+
+func TestClientDereferenceSymlinks(t *testing.T) {
+	links := map[string]string{
+		"/repos/owner/repo/contents/app/readme":  "../README.md",
+		"/repos/owner/repo/contents/app/chain":   "readme",
+		"/repos/owner/repo/contents/app/outside": "../../etc/passwd",
+		"/repos/owner/repo/contents/app/shared":  "../assets",
+		"/repos/owner/repo/contents/assets/up":   "../app",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/owner/repo/git/trees/main" {
+			w.Write([]byte(`{"tree": [
+				{"type": "blob", "path": "README.md", "mode": "100644", "sha": "r", "size": 1},
+				{"type": "blob", "path": "app/chain", "mode": "120000", "sha": "l4", "size": 6},
+				{"type": "blob", "path": "app/outside", "mode": "120000", "sha": "l5", "size": 16},
+				{"type": "blob", "path": "app/readme", "mode": "120000", "sha": "l1", "size": 12},
+				{"type": "blob", "path": "app/run.sh", "mode": "100755", "sha": "x", "size": 5},
+				{"type": "blob", "path": "app/shared", "mode": "120000", "sha": "l2", "size": 9},
+				{"type": "blob", "path": "assets/logo.svg", "mode": "100644", "sha": "s", "size": 3},
+				{"type": "blob", "path": "assets/up", "mode": "120000", "sha": "l3", "size": 6}
+			], "truncated": false}`))
+			return
+		}
+		target, ok := links[r.URL.Path]
+		if !ok {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		w.Write([]byte(target))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("secret")
+	client.BaseURL = server.URL
+
+	components := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "app", Private: true}
+	files := []model.FileInfo{
+		{Path: "app/chain", Size: 6, SHA: "l4", Mode: "120000"},
+		{Path: "app/outside", Size: 16, SHA: "l5", Mode: "120000"},
+		{Path: "app/readme", Size: 12, SHA: "l1", Mode: "120000"},
+		{Path: "app/run.sh", Size: 5, SHA: "x", Mode: "100755"},
+		{Path: "app/shared", Size: 9, SHA: "l2", Mode: "120000"},
+	}
+	var warnings []error
+	files, err := client.DereferenceSymlinks(context.Background(), components, files, func(err error) {
+		warnings = append(warnings, err)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []model.FileInfo{
+		{Path: "app/chain", Size: 1, SHA: "r", Mode: "100644", Source: "README.md"},
+		{Path: "app/outside", Size: 16, SHA: "l5", Mode: "100644"},
+		{Path: "app/readme", Size: 1, SHA: "r", Mode: "100644", Source: "README.md"},
+		{Path: "app/run.sh", Size: 5, SHA: "x", Mode: "100755"},
+		{Path: "app/shared/logo.svg", Size: 3, SHA: "s", Mode: "100644", Source: "assets/logo.svg"},
+		{Path: "app/shared/up", Size: 6, SHA: "l3", Mode: "100644", Source: "assets/up"},
+	}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("expected files: %+v, got: %+v", expected, files)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("expected the link outside the repository and the loop to be reported, got: %v", warnings)
+	}
+}
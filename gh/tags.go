@@ -0,0 +1,96 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+const refsPageSize = 100
+
+// Tags lists the names of every tag in the repository
+func (c *Client) Tags(ctx context.Context, components model.RepoURLComponents) ([]string, error) {
+	return c.refNames(ctx, components, "tags")
+}
+
+// Branches lists the names of every branch in the repository
+func (c *Client) Branches(ctx context.Context, components model.RepoURLComponents) ([]string, error) {
+	return c.refNames(ctx, components, "branches")
+}
+
+// refNames lists the names from every page of the repository's tags or branches endpoint
+func (c *Client) refNames(ctx context.Context, components model.RepoURLComponents, kind string) ([]string, error) {
+	names := []string{}
+	for page := 1; ; page++ {
+		contents, err := c.API(
+			ctx,
+			fmt.Sprintf("%s/%s/%s?per_page=%d&page=%d", components.Owner, components.Repository, kind, refsPageSize, page),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", kind, err)
+		}
+
+		var refs []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(contents, &refs); err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+		}
+
+		if len(refs) < refsPageSize {
+			return names, nil
+		}
+	}
+}
+
+// MatchingRefs lists the branches, then the tags, whose name matches the glob pattern, such as
+// "release/*" or "v*". A name that is both a branch and a tag is listed once.
+func (c *Client) MatchingRefs(ctx context.Context, components model.RepoURLComponents, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid ref pattern %q: %v", pattern, err)
+	}
+	branches, err := c.Branches(ctx, components)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := c.Tags(ctx, components)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var matching []string
+	for _, name := range append(branches, tags...) {
+		if ok, _ := path.Match(pattern, name); ok && !seen[name] {
+			seen[name] = true
+			matching = append(matching, name)
+		}
+	}
+	return matching, nil
+}
+
+// ResolveVersionRange picks the highest tag satisfying a range such as "latest" or "^1.2"
+func (c *Client) ResolveVersionRange(ctx context.Context, components model.RepoURLComponents, expr string) (string, error) {
+	r, err := helpers.ParseVersionRange(expr)
+	if err != nil {
+		return "", err
+	}
+
+	tags, err := c.Tags(ctx, components)
+	if err != nil {
+		return "", err
+	}
+
+	tag, ok := helpers.HighestMatchingTag(tags, r)
+	if !ok {
+		return "", fmt.Errorf("no tag of %s/%s matches %q", components.Owner, components.Repository, expr)
+	}
+	return tag, nil
+}
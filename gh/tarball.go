@@ -0,0 +1,155 @@
+package gh
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// TarballStats summarises a download made with the tarball strategy
+type TarballStats struct {
+	Files   int
+	Skipped int
+	Bytes   int64
+}
+
+// FetchViaTarball streams the repository tarball at the pinned commit or ref and saves the
+// given files from it as FetchPublicFile would, in a single request however many files there
+// are. The tarball holds the whole repository, so other entries are read past without being
+// saved. It returns the files it couldn't take from the tarball, to be downloaded
+// individually: Git LFS files, which it only holds pointers to, files missing from it, and
+// with opts.Verify, files whose content doesn't match the listing because the ref moved in
+// between.
+func (c *Client) FetchViaTarball(
+	ctx context.Context,
+	components *model.RepoURLComponents,
+	files []model.FileInfo,
+	opts FetchOptions,
+) (TarballStats, []model.FileInfo, error) {
+	var stats TarballStats
+	wanted := make(map[string]model.FileInfo, len(files))
+	for _, file := range files {
+		wanted[file.Path] = file
+	}
+
+	tarballURL := c.apiURL(fmt.Sprintf("repos/%s/%s/tarball/%s", components.Owner, components.Repository, url.PathEscape(components.ContentRef())))
+	start := time.Now()
+	resp, attempts, err := c.doRequestWithRetry(ctx, tarballURL, "", true)
+	if err != nil {
+		return stats, nil, &FetchError{Path: tarballURL, Attempts: attempts, Elapsed: time.Since(start),
+			Err: fmt.Errorf("HTTP error for tarball: %w", err)}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := blockedResponse(resp, components.Owner+"/"+components.Repository)
+		if err == nil {
+			err = fmt.Errorf("HTTP %s for tarball", resp.Status)
+		}
+		return stats, nil, &FetchError{Path: tarballURL, Attempts: attempts, StatusCode: resp.StatusCode,
+			RateLimited: rateLimited(resp), Elapsed: time.Since(start), Err: err}
+	}
+
+	gz, err := gzip.NewReader(c.Throttle.Reader(ctx, resp.Body))
+	if err != nil {
+		return stats, nil, fmt.Errorf("error reading tarball: %v", err)
+	}
+	defer gz.Close()
+
+	var deferred []model.FileInfo
+	baseDir := filepath.Base(components.OutputRoot())
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return stats, nil, fmt.Errorf("error reading tarball: %v", err)
+		}
+
+		// Entries sit under a single owner-repo-sha directory
+		_, rel, _ := strings.Cut(header.Name, "/")
+		file, ok := wanted[rel]
+		if !ok {
+			continue
+		}
+
+		var content io.Reader = tr
+		switch header.Typeflag {
+		case tar.TypeReg:
+		case tar.TypeSymlink:
+			// Links are saved holding their target, as raw downloads of them are
+			content = strings.NewReader(header.Linkname)
+			header.Size = int64(len(header.Linkname))
+		default:
+			continue
+		}
+		delete(wanted, rel)
+
+		br := bufio.NewReader(content)
+		if head, _ := br.Peek(len(lfsPointerPrefix) + 1); c.isLfsPointer(ctx, *components, file, head, header.Size) {
+			deferred = append(deferred, file)
+			continue
+		}
+
+		switch err := saveTarballEntry(ctx, file, baseDir, br, header.Size, opts); {
+		case errors.Is(err, ErrBinarySkipped):
+			stats.Skipped++
+		case errors.Is(err, ErrChecksumMismatch):
+			opts.warn(err)
+			deferred = append(deferred, file)
+		case err != nil:
+			return stats, nil, err
+		default:
+			stats.Files++
+			stats.Bytes += header.Size
+		}
+	}
+
+	for _, file := range files {
+		if _, missing := wanted[file.Path]; missing {
+			deferred = append(deferred, file)
+		}
+	}
+	return stats, deferred, nil
+}
+
+// saveTarballEntry saves the content of one tarball entry, storing it in the cache when its
+// blob SHA matches the listing
+func saveTarballEntry(ctx context.Context, file model.FileInfo, baseDir string, content io.Reader, size int64, opts FetchOptions) error {
+	opts.Progress.Resolve(file.Path, size)
+	result, err := helpers.SaveFile(baseDir, file.Path, opts.Progress.Reader(file.Path, io.NopCloser(content)), helpers.SaveOptions{
+		Size:      size,
+		Sparse:    opts.Sparse,
+		OutputDir: opts.OutputDir,
+	})
+	if err != nil {
+		return fmt.Errorf("error saving file %s %w", file.Path, err)
+	}
+
+	if opts.Verify && file.SHA != "" {
+		if err := verifyBlob(file, &result, result.BlobSHA); err != nil {
+			os.Remove(result.Path)
+			return err
+		}
+	}
+	if opts.Cache != nil && file.SHA != "" && result.BlobSHA == file.SHA {
+		if err := opts.Cache.Store(ctx, file.SHA, result.Path); err != nil {
+			opts.warn(err)
+		}
+	}
+	return opts.finish(file, &result)
+}
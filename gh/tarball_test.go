@@ -0,0 +1,97 @@
+package gh_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/gh"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestClientFetchViaTarball(t *testing.T) {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for _, entry := range []struct {
+		name, content, link string
+	}{
+		{name: "o-r-abc123/"},
+		{name: "o-r-abc123/docs/a.md", content: "# A\n"},
+		{name: "o-r-abc123/docs/model.bin", content: "version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 42\n"},
+		{name: "o-r-abc123/docs/pointer.md", content: "version https://git-lfs.github.com/spec/v1\n"},
+		{name: "o-r-abc123/docs/latest", link: "a.md"},
+		{name: "o-r-abc123/README.md", content: "outside the directory\n"},
+	} {
+		header := &tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		switch {
+		case entry.link != "":
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, entry.link, 0
+		case entry.name[len(entry.name)-1] == '/':
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(entry.content))
+	}
+	tw.Close()
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/o/r/tarball/main":
+			w.Write(archive.Bytes())
+		case "/raw/o/r/main/.gitattributes":
+			w.Write([]byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.BaseURL = server.URL
+	client.RawBaseURL = server.URL + "/raw"
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "docs"}
+	files := []model.FileInfo{
+		{Path: "docs/a.md", Size: 4},
+		{Path: "docs/model.bin", Size: 70},
+		{Path: "docs/pointer.md", Size: 43},
+		{Path: "docs/latest", Size: 4},
+		{Path: "docs/missing.md", Size: 1},
+	}
+	opts := gh.FetchOptions{OutputDir: t.TempDir()}
+
+	stats, deferred, err := client.FetchViaTarball(context.Background(), &components, files, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// pointer.md only looks like a pointer, as .gitattributes doesn't route it through LFS
+	if stats.Files != 3 {
+		t.Errorf("expected 3 files extracted, got %+v", stats)
+	}
+	if expected := []model.FileInfo{files[1], files[4]}; !reflect.DeepEqual(deferred, expected) {
+		t.Errorf("expected the LFS and missing files to be deferred, got %v", deferred)
+	}
+
+	for path, expected := range map[string]string{
+		"docs/a.md":       "# A\n",
+		"docs/latest":     "a.md",
+		"docs/pointer.md": "version https://git-lfs.github.com/spec/v1\n",
+	} {
+		content, err := os.ReadFile(filepath.Join(opts.OutputDir, filepath.FromSlash(path)))
+		if err != nil || string(content) != expected {
+			t.Errorf("expected %s to hold %q, got %q (%v)", path, expected, content, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("expected entries outside the directory to be left out")
+	}
+}
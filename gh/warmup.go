@@ -0,0 +1,124 @@
+package gh
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"repo-pack/model"
+)
+
+// MaxWarmUpConcurrency caps how many HEAD requests WarmUp has in flight
+const MaxWarmUpConcurrency = 16
+
+// WarmUpStats counts what a warm-up pass learned
+type WarmUpStats struct {
+	// Probed is how many files were sent a HEAD request
+	Probed int
+	// Sized is how many files whose size the listing didn't report now have one
+	Sized int
+	// LFS is how many files turned out to be stored in Git LFS
+	LFS int
+}
+
+// WarmUp probes files with HEAD requests before they're downloaded, at most concurrency at a
+// time, so that sizes, progress totals and scheduling are right before transfers start. Files
+// the listing has no size for get the one the raw host reports. Files .gitattributes routes
+// through Git LFS whose size is that of a pointer are looked up on the LFS host; those it
+// serves with another size are marked LFS, given the size of their content and later
+// downloaded from the LFS host directly. Probes that fail leave the file as listed. Files of
+// private repositories aren't probed, as every request for them would count against the API
+// rate limit. It returns the updated files.
+func (c *Client) WarmUp(ctx context.Context, components model.RepoURLComponents, files []model.FileInfo, concurrency int) ([]model.FileInfo, WarmUpStats) {
+	var stats WarmUpStats
+	if components.Private {
+		return files, stats
+	}
+	var candidates []int
+	for i, file := range files {
+		if !file.LFS && file.Mode != symlinkMode &&
+			(file.Size < 0 || file.Size < maxLFSPointerSize && c.lfsTracked(ctx, components, file)) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return files, stats
+	}
+
+	warmed := make([]model.FileInfo, len(files))
+	copy(warmed, files)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	indices := make(chan int)
+	for n := min(max(concurrency, 1), MaxWarmUpConcurrency, len(candidates)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				file, sized, lfs := c.probe(ctx, components, warmed[i])
+				mu.Lock()
+				warmed[i] = file
+				stats.Probed++
+				if sized {
+					stats.Sized++
+				}
+				if lfs {
+					stats.LFS++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range candidates {
+		if ctx.Err() != nil {
+			break
+		}
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+	return warmed, stats
+}
+
+// probe learns what it can about one file with HEAD requests, reporting whether it found the
+// file's size and whether the file is stored in LFS
+func (c *Client) probe(ctx context.Context, components model.RepoURLComponents, file model.FileInfo) (model.FileInfo, bool, bool) {
+	sized := false
+	if file.Size < 0 {
+		size, ok := c.headSize(ctx, c.rawFileURL(components, file.SourcePath()))
+		if !ok {
+			return file, false, false
+		}
+		file.Size, sized = size, true
+	}
+	if file.Size >= maxLFSPointerSize || !c.lfsTracked(ctx, components, file) {
+		return file, sized, false
+	}
+	// The LFS host may serve files outside LFS as they are, so only a different size tells
+	size, ok := c.headSize(ctx, c.lfsFileURL(components, file.SourcePath()))
+	if !ok || size == file.Size {
+		return file, sized, false
+	}
+	file.Size, file.LFS = size, true
+	return file, sized, true
+}
+
+// headSize requests url with HEAD, returning the Content-Length of a successful response
+func (c *Client) headSize(ctx context.Context, url string) (int64, bool) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, false
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0, false
+	}
+	return resp.ContentLength, true
+}
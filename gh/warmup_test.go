@@ -0,0 +1,151 @@
+package gh_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"repo-pack/gh"
+	"repo-pack/model"
+)
+
+func TestClientWarmUp(t *testing.T) {
+	pointer := strings.Repeat("p", 132)
+	content := map[string]string{
+		"/raw/o/r/main/unknown.txt":   "0123456789",
+		"/raw/o/r/main/model.bin":     pointer,
+		"/media/o/r/main/model.bin":   strings.Repeat("m", 5000),
+		"/raw/o/r/main/notes.txt":     pointer,
+		"/media/o/r/main/notes.txt":   pointer,
+		"/raw/o/r/main/pointer.txt":   pointer,
+		"/raw/o/r/main/unknown.bin":   pointer,
+		"/media/o/r/main/unknown.bin": strings.Repeat("u", 70000),
+	}
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/raw/o/r/main/.gitattributes" {
+			w.Write([]byte("*.bin filter=lfs diff=lfs merge=lfs -text\nnotes.txt filter=lfs\n"))
+			return
+		}
+		if r.Method != http.MethodHead {
+			t.Errorf("unexpected %s request for %s", r.Method, r.URL.Path)
+		}
+		mu.Lock()
+		requests = append(requests, r.URL.Path)
+		mu.Unlock()
+		body, ok := content[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.RawBaseURL = server.URL + "/raw"
+	client.MediaBaseURL = server.URL + "/media"
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+	files := []model.FileInfo{
+		{Path: "small.txt", Size: 12},
+		{Path: "unknown.txt", Size: -1},
+		{Path: "model.bin", Size: 132},
+		{Path: "notes.txt", Size: 132},
+		{Path: "pointer.txt", Size: 132},
+		{Path: "unknown.bin", Size: -1},
+	}
+	warmed, stats := client.WarmUp(context.Background(), components, files, 4)
+
+	expected := []model.FileInfo{
+		{Path: "small.txt", Size: 12},
+		{Path: "unknown.txt", Size: 10},
+		{Path: "model.bin", Size: 5000, LFS: true},
+		{Path: "notes.txt", Size: 132},
+		{Path: "pointer.txt", Size: 132},
+		{Path: "unknown.bin", Size: 70000, LFS: true},
+	}
+	if !reflect.DeepEqual(warmed, expected) {
+		t.Errorf("expected %+v, got %+v", expected, warmed)
+	}
+	if want := (gh.WarmUpStats{Probed: 4, Sized: 2, LFS: 2}); stats != want {
+		t.Errorf("expected stats %+v, got %+v", want, stats)
+	}
+	if files[2].LFS || files[1].Size != -1 {
+		t.Errorf("expected the listing to be left as it was, got %+v", files)
+	}
+	for _, path := range requests {
+		if strings.HasSuffix(path, "small.txt") || strings.HasSuffix(path, "pointer.txt") {
+			t.Errorf("expected files with a known size outside LFS not to be probed, got a request for %s", path)
+		}
+	}
+
+	if _, stats := client.WarmUp(context.Background(), model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Private: true}, files, 4); stats.Probed != 0 {
+		t.Errorf("expected private files not to be probed, got %+v", stats)
+	}
+}
+
+func TestClientFetchKnownLFSFileDirectly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/media/o/r/main/model.bin" {
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+		}
+		w.Write([]byte("weights"))
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.RawBaseURL = server.URL + "/raw"
+	client.MediaBaseURL = server.URL + "/media"
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+	file := model.FileInfo{Path: "model.bin", Size: 7, LFS: true}
+	result, err := client.FetchPublicFile(context.Background(), file, &components, gh.FetchOptions{OutputDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, err := os.ReadFile(result.Path); err != nil || string(data) != "weights" {
+		t.Errorf("expected the LFS content, got %q (%v)", data, err)
+	}
+}
+
+func TestClientFetchTellsPointersByGitattributes(t *testing.T) {
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 7\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/raw/o/r/main/.gitattributes":
+			w.Write([]byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"))
+		case "/raw/o/r/main/model.bin", "/raw/o/r/main/pointer.md":
+			w.Write([]byte(pointer))
+		case "/media/o/r/main/model.bin":
+			w.Write([]byte("weights"))
+		default:
+			t.Errorf("unexpected request path: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := gh.NewClient("")
+	client.RawBaseURL = server.URL + "/raw"
+	client.MediaBaseURL = server.URL + "/media"
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+
+	for path, want := range map[string]string{"model.bin": "weights", "pointer.md": pointer} {
+		file := model.FileInfo{Path: path, Size: int64(len(pointer))}
+		result, err := client.FetchPublicFile(context.Background(), file, &components, gh.FetchOptions{OutputDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", path, err)
+		}
+		if data, err := os.ReadFile(result.Path); err != nil || string(data) != want {
+			t.Errorf("%s: expected %q, got %q (%v)", path, want, data, err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package gitproto
+
+import "fmt"
+
+// readDeltaSize decodes a little-endian base-128 size from the start of a delta
+func readDeltaSize(delta []byte) (uint64, []byte, error) {
+	var size uint64
+	var shift uint
+	for i, b := range delta {
+		size |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return size, delta[i+1:], nil
+		}
+		shift += 7
+		if shift > 63 {
+			break
+		}
+	}
+	return 0, nil, fmt.Errorf("malformed delta size")
+}
+
+// applyDelta reconstructs an object from its base and a git delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	baseSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+	if baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("delta base is %d bytes, expected %d", len(base), baseSize)
+	}
+
+	resultSize, delta, err := readDeltaSize(delta)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each instruction byte yields at most a copy of 64KB of the base or 127 inserted bytes, so
+	// a larger size comes from a corrupt delta and mustn't be allocated
+	limit := uint64(len(delta)) * max(min(uint64(len(base)), 0x10000), 0x7f)
+	if resultSize > limit {
+		return nil, fmt.Errorf("delta claims %d bytes, more than its %d instruction bytes can produce", resultSize, len(delta))
+	}
+	result := make([]byte, 0, resultSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			// Copy from base: bits 0-3 select offset bytes, bits 4-6 select size bytes
+			var offset, size uint64
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, fmt.Errorf("truncated delta copy instruction")
+				}
+				if i < 4 {
+					offset |= uint64(delta[0]) << (8 * i)
+				} else {
+					size |= uint64(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > uint64(len(base)) {
+				return nil, fmt.Errorf("delta copy out of bounds")
+			}
+			result = append(result, base[offset:offset+size]...)
+		case op != 0:
+			// Insert the next op bytes verbatim
+			if int(op) > len(delta) {
+				return nil, fmt.Errorf("truncated delta insert instruction")
+			}
+			result = append(result, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, fmt.Errorf("invalid delta instruction")
+		}
+	}
+
+	if uint64(len(result)) != resultSize {
+		return nil, fmt.Errorf("delta produced %d bytes, expected %d", len(result), resultSize)
+	}
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package gitproto
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+)
+
+// ObjectType is a git object type as encoded in packfiles
+type ObjectType int
+
+const (
+	TypeCommit ObjectType = 1
+	TypeTree   ObjectType = 2
+	TypeBlob   ObjectType = 3
+	TypeTag    ObjectType = 4
+)
+
+func (t ObjectType) String() string {
+	switch t {
+	case TypeCommit:
+		return "commit"
+	case TypeTree:
+		return "tree"
+	case TypeBlob:
+		return "blob"
+	case TypeTag:
+		return "tag"
+	default:
+		return fmt.Sprintf("type %d", int(t))
+	}
+}
+
+// Object is a git object decoded from a packfile
+type Object struct {
+	Type ObjectType
+	Data []byte
+}
+
+// HashObject returns the hex object ID of an object with the given type and content
+func HashObject(objectType ObjectType, data []byte) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objectType, len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// TreeEntry is a single entry of a git tree object
+type TreeEntry struct {
+	Mode string
+	Name string
+	OID  string
+}
+
+// IsTree reports whether the entry is a subdirectory
+func (e TreeEntry) IsTree() bool {
+	return e.Mode == "40000"
+}
+
+// IsBlob reports whether the entry is a regular file, executable or symlink
+func (e TreeEntry) IsBlob() bool {
+	return e.Mode == "100644" || e.Mode == "100755" || e.Mode == "120000"
+}
+
+// ParseTree decodes the entries of a tree object
+func ParseTree(data []byte) ([]TreeEntry, error) {
+	entries := []TreeEntry{}
+	for len(data) > 0 {
+		space := bytes.IndexByte(data, ' ')
+		if space < 0 {
+			return nil, fmt.Errorf("malformed tree entry: missing mode")
+		}
+		nul := bytes.IndexByte(data[space:], 0)
+		if nul < 0 || space+nul+1+sha1.Size > len(data) {
+			return nil, fmt.Errorf("malformed tree entry: truncated")
+		}
+		nul += space
+
+		entries = append(entries, TreeEntry{
+			Mode: string(data[:space]),
+			Name: string(data[space+1 : nul]),
+			OID:  hex.EncodeToString(data[nul+1 : nul+1+sha1.Size]),
+		})
+		data = data[nul+1+sha1.Size:]
+	}
+	return entries, nil
+}
+
+// CommitTree returns the root tree ID recorded in a commit object
+func CommitTree(data []byte) (string, error) {
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	oid, ok := bytes.CutPrefix(line, []byte("tree "))
+	if !ok || len(oid) != 2*sha1.Size {
+		return "", fmt.Errorf("malformed commit: missing tree")
+	}
+	return string(oid), nil
+}
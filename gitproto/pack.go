@@ -0,0 +1,275 @@
+package gitproto
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const (
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+// Pack holds the objects decoded from one or more packfiles, keyed by object ID
+type Pack struct {
+	Objects map[string]Object
+	// pending holds REF_DELTA objects whose base is neither in the pack nor resolved yet,
+	// which is how thin packs refer to objects the client already has
+	pending []*refDelta
+}
+
+type refDelta struct {
+	base  string
+	delta []byte
+	// dependents are OFS_DELTA objects whose base is this delta's result, applied once it
+	// is resolved. Their base is empty.
+	dependents []*refDelta
+}
+
+// packReader tracks the offset and running checksum of a packfile as it is consumed.
+// It implements io.ByteReader so that zlib never reads past the end of an object.
+type packReader struct {
+	r      *bufio.Reader
+	offset int64
+	hash   hash.Hash
+}
+
+func (p *packReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.hash.Write(b[:n])
+	p.offset += int64(n)
+	return n, err
+}
+
+func (p *packReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.hash.Write([]byte{b})
+		p.offset++
+	}
+	return b, err
+}
+
+// ParsePack decodes a packfile stream, resolving deltas against objects in the same pack.
+// REF_DELTA objects with an unknown base are kept for Resolve, along with the OFS_DELTA
+// objects built on them.
+func ParsePack(r io.Reader) (*Pack, error) {
+	pr := &packReader{r: bufio.NewReader(r), hash: sha1.New()}
+
+	var header [12]byte
+	if _, err := io.ReadFull(pr, header[:]); err != nil {
+		return nil, fmt.Errorf("error reading pack header: %w", err)
+	}
+	if string(header[:4]) != "PACK" {
+		return nil, fmt.Errorf("invalid pack signature")
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		return nil, fmt.Errorf("unsupported pack version %d", version)
+	}
+	count := binary.BigEndian.Uint32(header[8:12])
+
+	pack := &Pack{Objects: make(map[string]Object, count)}
+	byOffset := make(map[int64]Object, count)
+	// unresolved holds the deltas at each offset still waiting for a base outside the pack
+	unresolved := map[int64]*refDelta{}
+
+	for i := uint32(0); i < count; i++ {
+		offset := pr.offset
+		objectType, _, err := readObjectHeader(pr)
+		if err != nil {
+			return nil, fmt.Errorf("error reading object %d: %w", i, err)
+		}
+
+		var object Object
+		switch objectType {
+		case typeOfsDelta:
+			distance, err := readOffsetDistance(pr)
+			if err != nil {
+				return nil, err
+			}
+			delta, err := inflate(pr)
+			if err != nil {
+				return nil, err
+			}
+			base, ok := byOffset[offset-distance]
+			if !ok {
+				parent, ok := unresolved[offset-distance]
+				if !ok {
+					return nil, fmt.Errorf("delta base at offset %d not found", offset-distance)
+				}
+				dependent := &refDelta{delta: delta}
+				parent.dependents = append(parent.dependents, dependent)
+				unresolved[offset] = dependent
+				continue
+			}
+			data, err := applyDelta(base.Data, delta)
+			if err != nil {
+				return nil, err
+			}
+			object = Object{Type: base.Type, Data: data}
+		case typeRefDelta:
+			var oid [sha1.Size]byte
+			if _, err := io.ReadFull(pr, oid[:]); err != nil {
+				return nil, err
+			}
+			delta, err := inflate(pr)
+			if err != nil {
+				return nil, err
+			}
+			base, ok := pack.Objects[hex.EncodeToString(oid[:])]
+			if !ok {
+				pending := &refDelta{base: hex.EncodeToString(oid[:]), delta: delta}
+				pack.pending = append(pack.pending, pending)
+				unresolved[offset] = pending
+				continue
+			}
+			data, err := applyDelta(base.Data, delta)
+			if err != nil {
+				return nil, err
+			}
+			object = Object{Type: base.Type, Data: data}
+		case int(TypeCommit), int(TypeTree), int(TypeBlob), int(TypeTag):
+			data, err := inflate(pr)
+			if err != nil {
+				return nil, err
+			}
+			object = Object{Type: ObjectType(objectType), Data: data}
+		default:
+			return nil, fmt.Errorf("unknown object type %d", objectType)
+		}
+
+		byOffset[offset] = object
+		pack.Objects[HashObject(object.Type, object.Data)] = object
+	}
+
+	expected := pr.hash.Sum(nil)
+	var trailer [sha1.Size]byte
+	if _, err := io.ReadFull(pr.r, trailer[:]); err != nil {
+		return nil, fmt.Errorf("error reading pack checksum: %w", err)
+	}
+	if !bytes.Equal(trailer[:], expected) {
+		return nil, fmt.Errorf("pack checksum mismatch")
+	}
+
+	return pack, pack.Resolve(nil)
+}
+
+// Resolve applies pending deltas whose bases are now available, either in the pack or from
+// lookup, which may be nil. Deltas whose base is still missing remain pending.
+func (p *Pack) Resolve(lookup func(oid string) (Object, bool)) error {
+	for progress := true; progress && len(p.pending) > 0; {
+		progress = false
+		remaining := p.pending[:0]
+		for _, pending := range p.pending {
+			base, ok := p.Objects[pending.base]
+			if !ok && lookup != nil {
+				base, ok = lookup(pending.base)
+			}
+			if !ok {
+				remaining = append(remaining, pending)
+				continue
+			}
+
+			if err := p.apply(base, pending); err != nil {
+				return err
+			}
+			progress = true
+		}
+		p.pending = remaining
+	}
+	return nil
+}
+
+// apply adds the object d rebuilds from base to the pack, then those its dependents rebuild
+func (p *Pack) apply(base Object, d *refDelta) error {
+	data, err := applyDelta(base.Data, d.delta)
+	if err != nil {
+		return err
+	}
+	object := Object{Type: base.Type, Data: data}
+	p.Objects[HashObject(object.Type, data)] = object
+	for _, dependent := range d.dependents {
+		if err := p.apply(object, dependent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MissingBases returns the IDs of delta bases that could not be found
+func (p *Pack) MissingBases() []string {
+	seen := map[string]bool{}
+	missing := []string{}
+	for _, pending := range p.pending {
+		if !seen[pending.base] {
+			seen[pending.base] = true
+			missing = append(missing, pending.base)
+		}
+	}
+	return missing
+}
+
+// Merge adds the objects and pending deltas of other to p
+func (p *Pack) Merge(other *Pack) {
+	for oid, object := range other.Objects {
+		p.Objects[oid] = object
+	}
+	p.pending = append(p.pending, other.pending...)
+}
+
+// readObjectHeader decodes an object's type and inflated size
+func readObjectHeader(r io.ByteReader) (int, uint64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	objectType := int(b>>4) & 0x7
+	size := uint64(b & 0x0f)
+	shift := uint(4)
+	for b&0x80 != 0 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= uint64(b&0x7f) << shift
+		shift += 7
+	}
+	return objectType, size, nil
+}
+
+// readOffsetDistance decodes the distance back to an OFS_DELTA base
+func readOffsetDistance(r io.ByteReader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	distance := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		distance = ((distance + 1) << 7) | int64(b&0x7f)
+	}
+	return distance, nil
+}
+
+// inflate reads one zlib stream without consuming bytes past its end
+func inflate(r *packReader) ([]byte, error) {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("error inflating object: %w", err)
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("error inflating object: %w", err)
+	}
+	return data, nil
+}
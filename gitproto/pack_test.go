@@ -0,0 +1,135 @@
+package gitproto
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// buildPack assembles a packfile holding one blob and one REF_DELTA against base
+func buildPack(t *testing.T, blob []byte, base string, delta []byte) []byte {
+	t.Helper()
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+
+	writeObject := func(objectType int, size int, prefix []byte, data []byte) {
+		// Sizes here stay below 16 bytes, so the header fits in a single byte
+		pack.WriteByte(byte(objectType<<4 | size))
+		pack.Write(prefix)
+		zw := zlib.NewWriter(&pack)
+		zw.Write(data)
+		zw.Close()
+	}
+
+	writeObject(int(TypeBlob), len(blob), nil, blob)
+	baseID, _ := hex.DecodeString(base)
+	writeObject(typeRefDelta, len(delta), baseID, delta)
+
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+	return pack.Bytes()
+}
+
+func TestParsePackResolvesThinDeltas(t *testing.T) {
+	base := []byte("hello world\n")
+	baseID := HashObject(TypeBlob, base)
+
+	// Copy "hello " from the base, then insert "git\n"
+	delta := []byte{byte(len(base)), 10, 0x90, 6, 4, 'g', 'i', 't', '\n'}
+	pack, err := ParsePack(bytes.NewReader(buildPack(t, []byte("other\n"), baseID, delta)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if missing := pack.MissingBases(); len(missing) != 1 || missing[0] != baseID {
+		t.Fatalf("expected missing base %s, got: %v", baseID, missing)
+	}
+
+	err = pack.Resolve(func(oid string) (Object, bool) {
+		return Object{Type: TypeBlob, Data: base}, oid == baseID
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resolved, ok := pack.Objects[HashObject(TypeBlob, []byte("hello git\n"))]
+	if !ok || string(resolved.Data) != "hello git\n" {
+		t.Errorf("expected resolved blob %q, got: %q (found=%v)", "hello git\n", resolved.Data, ok)
+	}
+	if len(pack.MissingBases()) != 0 {
+		t.Errorf("expected no missing bases, got: %v", pack.MissingBases())
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	oid := bytes.Repeat([]byte{0xab}, sha1.Size)
+	data := append([]byte("100755 run.sh\x00"), oid...)
+	data = append(data, append([]byte("40000 sub\x00"), oid...)...)
+
+	entries, err := ParseTree(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "run.sh" || !entries[0].IsBlob() || !entries[1].IsTree() {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestParsePackResolvesOffsetDeltasOnThinDeltas(t *testing.T) {
+	base := []byte("hello world\n")
+	baseID := HashObject(TypeBlob, base)
+
+	var pack bytes.Buffer
+	pack.WriteString("PACK")
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	binary.Write(&pack, binary.BigEndian, uint32(2))
+	writeDelta := func(header []byte, delta []byte) {
+		pack.Write(header)
+		zw := zlib.NewWriter(&pack)
+		zw.Write(delta)
+		zw.Close()
+	}
+
+	// A REF_DELTA against a blob outside the pack rebuilds "hello git\n"
+	refOffset := pack.Len()
+	baseRaw, _ := hex.DecodeString(baseID)
+	writeDelta(append([]byte{byte(typeRefDelta<<4 | 9)}, baseRaw...), []byte{12, 10, 0x90, 6, 4, 'g', 'i', 't', '\n'})
+	// An OFS_DELTA on that result copies "hello git" and inserts "!\n"
+	distance := pack.Len() - refOffset
+	writeDelta([]byte{byte(typeOfsDelta<<4 | 7), byte(distance)}, []byte{10, 11, 0x90, 9, 2, '!', '\n'})
+	sum := sha1.Sum(pack.Bytes())
+	pack.Write(sum[:])
+
+	parsed, err := ParsePack(bytes.NewReader(pack.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if missing := parsed.MissingBases(); len(missing) != 1 || missing[0] != baseID {
+		t.Fatalf("expected missing base %s, got: %v", baseID, missing)
+	}
+
+	err = parsed.Resolve(func(oid string) (Object, bool) {
+		return Object{Type: TypeBlob, Data: base}, oid == baseID
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{"hello git\n", "hello git!\n"} {
+		if _, ok := parsed.Objects[HashObject(TypeBlob, []byte(expected))]; !ok {
+			t.Errorf("expected %q resolved", expected)
+		}
+	}
+}
+
+func TestApplyDeltaRejectsImpossibleSizes(t *testing.T) {
+	// A result size of 2^62 bytes from a single copy instruction
+	delta := []byte{5, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x40, 0x90, 5}
+	if _, err := applyDelta([]byte("hello"), delta); err == nil {
+		t.Errorf("expected an impossible result size to be rejected")
+	}
+}
@@ -0,0 +1,135 @@
+package gitproto
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Special pkt-lines that carry no payload
+const (
+	pktData = iota
+	pktFlush
+	pktDelim
+	pktResponseEnd
+)
+
+const maxPktLen = 65520
+
+// writePkt appends a pkt-line carrying payload to buf
+func writePkt(buf *bytes.Buffer, payload string) {
+	fmt.Fprintf(buf, "%04x%s", len(payload)+4, payload)
+}
+
+// writeFlush appends a flush-pkt (0000) to buf
+func writeFlush(buf *bytes.Buffer) {
+	buf.WriteString("0000")
+}
+
+// writeDelim appends a delim-pkt (0001) to buf
+func writeDelim(buf *bytes.Buffer) {
+	buf.WriteString("0001")
+}
+
+// pktReader reads pkt-lines from a protocol stream
+type pktReader struct {
+	r   io.Reader
+	buf [maxPktLen]byte
+}
+
+func newPktReader(r io.Reader) *pktReader {
+	return &pktReader{r: r}
+}
+
+// next returns the kind of the next pkt-line and, for data pkts, its payload. The payload
+// is only valid until the following call.
+func (p *pktReader) next() (int, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+
+	length, err := strconv.ParseUint(string(header[:]), 16, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid pkt-line length %q", header)
+	}
+
+	switch length {
+	case 0:
+		return pktFlush, nil, nil
+	case 1:
+		return pktDelim, nil, nil
+	case 2:
+		return pktResponseEnd, nil, nil
+	case 3:
+		return 0, nil, fmt.Errorf("invalid pkt-line length %q", header)
+	}
+
+	if length > maxPktLen {
+		return 0, nil, fmt.Errorf("pkt-line of %d bytes exceeds the protocol limit", length)
+	}
+
+	payload := p.buf[:length-4]
+	if _, err := io.ReadFull(p.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return pktData, payload, nil
+}
+
+// nextLine returns the next data pkt as a string without its trailing newline, along with
+// the pkt kind so callers can tell when a flush or delim pkt ends a section
+func (p *pktReader) nextLine() (line string, kind int, err error) {
+	kind, payload, err := p.next()
+	if err != nil || kind != pktData {
+		return "", kind, err
+	}
+	return string(bytes.TrimSuffix(payload, []byte("\n"))), kind, nil
+}
+
+// sidebandReader exposes band 1 of a side-band-64k stream as a plain reader, reporting
+// band 3 as an error and discarding band 2 progress messages
+type sidebandReader struct {
+	pkts    *pktReader
+	pending []byte
+	done    bool
+}
+
+func (s *sidebandReader) Read(b []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.done {
+			return 0, io.EOF
+		}
+
+		kind, payload, err := s.pkts.next()
+		if err != nil {
+			return 0, err
+		}
+		if kind != pktData {
+			s.done = true
+			continue
+		}
+		if len(payload) == 0 {
+			continue
+		}
+
+		switch payload[0] {
+		case 1:
+			s.pending = payload[1:]
+		case 2:
+			// Progress messages are not shown
+		case 3:
+			return 0, fmt.Errorf("remote error: %s", bytes.TrimSpace(payload[1:]))
+		default:
+			return 0, fmt.Errorf("invalid sideband channel %d", payload[0])
+		}
+	}
+
+	n := copy(b, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
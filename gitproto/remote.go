@@ -0,0 +1,274 @@
+package gitproto
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Remote is a git repository served over the smart HTTP protocol (version 2)
+type Remote struct {
+	// URL is the repository URL, e.g. https://github.com/owner/repo.git
+	URL        string
+	HTTPClient *http.Client
+	// Token authenticates as a GitHub access token when set
+	Token     string
+	UserAgent string
+
+	capabilities map[string]string
+}
+
+// FetchRequest describes the objects to request from a remote
+type FetchRequest struct {
+	Wants []string
+	// Haves are commits the client already has, letting the server send deltas against them
+	Haves []string
+	// Filter is a partial clone filter such as "blob:none"
+	Filter string
+	// Depth limits history when non-zero
+	Depth int
+	// Thin allows deltas against objects that are only reachable from Haves
+	Thin bool
+}
+
+func (r *Remote) httpClient() *http.Client {
+	if r.HTTPClient != nil {
+		return r.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (r *Remote) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Git-Protocol", "version=2")
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+	if r.Token != "" {
+		req.SetBasicAuth("x-access-token", r.Token)
+	}
+	return req, nil
+}
+
+// discover fetches the protocol v2 capability advertisement
+func (r *Remote) discover(ctx context.Context) error {
+	if r.capabilities != nil {
+		return nil
+	}
+
+	req, err := r.newRequest(ctx, http.MethodGet, r.URL+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("git capability discovery failed: %s", resp.Status)
+	}
+
+	pkts := newPktReader(resp.Body)
+	capabilities := map[string]string{}
+	sawVersion := false
+	for {
+		line, kind, err := pkts.nextLine()
+		if err != nil {
+			return fmt.Errorf("error reading git capabilities: %w", err)
+		}
+		if kind == pktFlush {
+			// Smart HTTP prefixes the advertisement with a service line and a flush
+			if sawVersion {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "# service=") {
+			continue
+		}
+		if line == "version 2" {
+			sawVersion = true
+			continue
+		}
+		if !sawVersion {
+			return fmt.Errorf("remote does not support git protocol version 2")
+		}
+		key, value, _ := strings.Cut(line, "=")
+		capabilities[key] = value
+	}
+
+	r.capabilities = capabilities
+	return nil
+}
+
+// command sends a protocol v2 command and returns the response body
+func (r *Remote) command(ctx context.Context, name string, args []string) (io.ReadCloser, error) {
+	if err := r.discover(ctx); err != nil {
+		return nil, err
+	}
+	if _, ok := r.capabilities[name]; !ok {
+		return nil, fmt.Errorf("remote does not support the %s command", name)
+	}
+
+	var body bytes.Buffer
+	writePkt(&body, "command="+name+"\n")
+	if agent, ok := r.capabilities["agent"]; ok && agent != "" {
+		writePkt(&body, "agent=repo-pack\n")
+	}
+	writeDelim(&body)
+	for _, arg := range args {
+		writePkt(&body, arg+"\n")
+	}
+	writeFlush(&body)
+
+	req, err := r.newRequest(ctx, http.MethodPost, r.URL+"/git-upload-pack", &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-git-upload-pack-request")
+	req.Header.Set("Accept", "application/x-git-upload-pack-result")
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("git %s failed: %s", name, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// supports reports whether the named command advertises the given feature, e.g. fetch=filter
+func (r *Remote) supports(command, feature string) bool {
+	for _, f := range strings.Fields(r.capabilities[command]) {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveRef returns the commit ID for a branch, tag or full ref name. A full commit ID is
+// returned unchanged.
+func (r *Remote) ResolveRef(ctx context.Context, ref string) (string, error) {
+	if IsObjectID(ref) {
+		return ref, nil
+	}
+
+	candidates := []string{ref, "refs/heads/" + ref, "refs/tags/" + ref}
+	args := []string{"peel"}
+	for _, candidate := range candidates {
+		args = append(args, "ref-prefix "+candidate)
+	}
+
+	body, err := r.command(ctx, "ls-refs", args)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	refs := map[string]string{}
+	pkts := newPktReader(body)
+	for {
+		line, kind, err := pkts.nextLine()
+		if err != nil {
+			return "", fmt.Errorf("error reading refs: %w", err)
+		}
+		if kind != pktData {
+			break
+		}
+
+		// "<oid> <name> [peeled:<oid>]": annotated tags resolve to the peeled commit
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		oid := fields[0]
+		for _, attr := range fields[2:] {
+			if peeled, ok := strings.CutPrefix(attr, "peeled:"); ok {
+				oid = peeled
+			}
+		}
+		refs[fields[1]] = oid
+	}
+
+	for _, candidate := range candidates {
+		if oid, ok := refs[candidate]; ok {
+			return oid, nil
+		}
+	}
+	return "", fmt.Errorf("ref %s not found", ref)
+}
+
+// Fetch requests objects from the remote and decodes the returned packfile
+func (r *Remote) Fetch(ctx context.Context, request FetchRequest) (*Pack, error) {
+	if err := r.discover(ctx); err != nil {
+		return nil, err
+	}
+	if request.Filter != "" && !r.supports("fetch", "filter") {
+		return nil, fmt.Errorf("remote does not support partial clone filters")
+	}
+
+	args := []string{"ofs-delta", "no-progress"}
+	if request.Thin {
+		args = append(args, "thin-pack")
+	}
+	if request.Depth > 0 {
+		args = append(args, fmt.Sprintf("deepen %d", request.Depth))
+	}
+	if request.Filter != "" {
+		args = append(args, "filter "+request.Filter)
+	}
+	for _, want := range request.Wants {
+		args = append(args, "want "+want)
+	}
+	for _, have := range request.Haves {
+		args = append(args, "have "+have)
+	}
+	args = append(args, "done")
+
+	body, err := r.command(ctx, "fetch", args)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	// Skip the acknowledgments, shallow-info and wanted-refs sections up to the packfile
+	pkts := newPktReader(body)
+	for {
+		line, kind, err := pkts.nextLine()
+		if err != nil {
+			return nil, fmt.Errorf("error reading fetch response: %w", err)
+		}
+		if kind == pktFlush {
+			return nil, fmt.Errorf("fetch response contained no packfile")
+		}
+		if kind == pktData && line == "packfile" {
+			break
+		}
+	}
+
+	return ParsePack(&sidebandReader{pkts: pkts})
+}
+
+// IsObjectID reports whether s is a full hex SHA-1 object ID
+func IsObjectID(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
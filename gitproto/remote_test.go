@@ -0,0 +1,175 @@
+package gitproto
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smartServer fakes git's smart HTTP protocol version 2 for the repository /o/r.git. It
+// answers ls-refs with refs and fetch with pack, and records the arguments of every command.
+type smartServer struct {
+	refs  []string
+	pack  []byte
+	fetch string
+
+	mu        sync.Mutex
+	discovery int
+	commands  [][]string
+}
+
+func (s *smartServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Git-Protocol") != "version=2" {
+		http.Error(w, "protocol version 2 expected", http.StatusBadRequest)
+		return
+	}
+	if _, token, _ := r.BasicAuth(); token != "secret" {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var out bytes.Buffer
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/o/r.git/info/refs":
+		s.mu.Lock()
+		s.discovery++
+		s.mu.Unlock()
+		writePkt(&out, "# service=git-upload-pack\n")
+		writeFlush(&out)
+		writePkt(&out, "version 2\n")
+		writePkt(&out, "agent=git/2.45.0\n")
+		writePkt(&out, "ls-refs=unborn\n")
+		writePkt(&out, "fetch="+s.fetch+"\n")
+		writeFlush(&out)
+	case r.Method == http.MethodPost && r.URL.Path == "/o/r.git/git-upload-pack":
+		var command []string
+		pkts := newPktReader(r.Body)
+		for {
+			line, kind, err := pkts.nextLine()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if kind == pktFlush {
+				break
+			}
+			if kind == pktData && !strings.HasPrefix(line, "agent=") {
+				command = append(command, line)
+			}
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, command)
+		s.mu.Unlock()
+
+		switch command[0] {
+		case "command=ls-refs":
+			for _, ref := range s.refs {
+				writePkt(&out, ref+"\n")
+			}
+		case "command=fetch":
+			writePkt(&out, "packfile\n")
+			// Band 1 carries the pack, split as servers split it into pkt-lines
+			for data := s.pack; len(data) > 0; {
+				n := min(len(data), 64)
+				writePkt(&out, "\x01"+string(data[:n]))
+				data = data[n:]
+			}
+		}
+		writeFlush(&out)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Write(out.Bytes())
+}
+
+func newTestRemote(t *testing.T, server *smartServer) *Remote {
+	t.Helper()
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return &Remote{URL: httpServer.URL + "/o/r.git", Token: "secret"}
+}
+
+func TestRemoteResolveRef(t *testing.T) {
+	main := strings.Repeat("a", 40)
+	tag := strings.Repeat("b", 40)
+	peeled := strings.Repeat("c", 40)
+	server := &smartServer{
+		refs:  []string{main + " refs/heads/main", tag + " refs/tags/v1 peeled:" + peeled},
+		fetch: "shallow filter",
+	}
+	remote := newTestRemote(t, server)
+	ctx := context.Background()
+
+	for _, test := range []struct{ ref, expected string }{
+		{"main", main},
+		{"v1", peeled},
+		{"refs/heads/main", main},
+		{tag, tag},
+	} {
+		commit, err := remote.ResolveRef(ctx, test.ref)
+		if err != nil || commit != test.expected {
+			t.Errorf("expected %s to resolve to %s, got %s (%v)", test.ref, test.expected, commit, err)
+		}
+	}
+	if _, err := remote.ResolveRef(ctx, "missing"); err == nil {
+		t.Errorf("expected a missing ref to fail")
+	}
+
+	// Capabilities are discovered once, and object IDs never reach the server
+	if server.discovery != 1 {
+		t.Errorf("expected one capability discovery, got %d", server.discovery)
+	}
+	if len(server.commands) != 4 {
+		t.Fatalf("expected 4 ls-refs commands, got %d", len(server.commands))
+	}
+	expected := []string{"command=ls-refs", "peel", "ref-prefix v1", "ref-prefix refs/heads/v1", "ref-prefix refs/tags/v1"}
+	if !reflect.DeepEqual(server.commands[1], expected) {
+		t.Errorf("expected ls-refs arguments %q, got %q", expected, server.commands[1])
+	}
+}
+
+func TestRemoteFetch(t *testing.T) {
+	base := HashObject(TypeBlob, []byte("hello world\n"))
+	delta := []byte{12, 10, 0x90, 6, 4, 'g', 'i', 't', '\n'}
+	server := &smartServer{pack: buildPack(t, []byte("other\n"), base, delta), fetch: "shallow filter"}
+	remote := newTestRemote(t, server)
+
+	want := strings.Repeat("d", 40)
+	have := strings.Repeat("e", 40)
+	pack, err := remote.Fetch(context.Background(), FetchRequest{
+		Wants:  []string{want},
+		Haves:  []string{have},
+		Filter: "blob:none",
+		Depth:  1,
+		Thin:   true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"command=fetch", "ofs-delta", "no-progress", "thin-pack", "deepen 1", "filter blob:none",
+		"want " + want, "have " + have, "done"}
+	if len(server.commands) != 1 || !reflect.DeepEqual(server.commands[0], expected) {
+		t.Errorf("expected fetch arguments %q, got %q", expected, server.commands)
+	}
+	if _, ok := pack.Objects[HashObject(TypeBlob, []byte("other\n"))]; !ok {
+		t.Errorf("expected the blob in the pack, got %v", pack.Objects)
+	}
+	if missing := pack.MissingBases(); !reflect.DeepEqual(missing, []string{base}) {
+		t.Errorf("expected the thin delta's base to be missing, got %v", missing)
+	}
+}
+
+func TestRemoteFetchNeedsFilterSupport(t *testing.T) {
+	remote := newTestRemote(t, &smartServer{fetch: "shallow"})
+	_, err := remote.Fetch(context.Background(), FetchRequest{Wants: []string{strings.Repeat("d", 40)}, Filter: "blob:none"})
+	if err == nil || !strings.Contains(err.Error(), "filter") {
+		t.Errorf("expected a missing filter capability to fail, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package helpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FailedManifest is the archive entry listing files that couldn't be downloaded
+const FailedManifest = "FAILED.txt"
+
+// CheckArchiveName reports an error unless name has the extension of an archive WriteArchive
+// can write
+func CheckArchiveName(name string) error {
+	if strings.HasSuffix(name, ".zip") {
+		return nil
+	}
+	_, err := CompressWriter(name, io.Discard)
+	return err
+}
+
+// WriteArchive writes the files under dir to a zip or tar archive at name, following its
+// extension, with tar archives gzip-compressed when the extension asks for it. A non-empty failed list, one line per file, is added as FAILED.txt
+// at the root so partial downloads are visible inside the archive. The archive is written to
+// a temporary file first and renamed into place, so an interrupted run leaves no corrupt archive.
+func WriteArchive(name, dir string, failed []string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".repo-pack-archive-")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	write := writeArchive
+	if strings.HasSuffix(name, ".zip") {
+		write = writeZip
+	}
+	if err := write(tmp, name, dir, failed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing archive: %v", err)
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+func writeArchive(w io.Writer, name, dir string, failed []string) error {
+	cw, err := CompressWriter(name, w)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(cw)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if link != "" {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		manifest := failedManifest(failed)
+		header := &tar.Header{
+			Name:    FailedManifest,
+			Mode:    0o644,
+			Size:    int64(len(manifest)),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(manifest); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return cw.Close()
+}
+
+// writeZip is writeArchive for zip archives, whose entries are deflated
+func writeZip(w io.Writer, _, dir string, failed []string) error {
+	zw := zip.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		header.Method = zip.Deflate
+		entry, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		// Zip archives hold a link's target as its content
+		if info.Mode()&fs.ModeSymlink != 0 {
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(entry, link)
+			return err
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(entry, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		entry, err := zw.CreateHeader(&zip.FileHeader{Name: FailedManifest, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(failedManifest(failed)); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// failedManifest renders the FAILED.txt listing, one failed file per line
+func failedManifest(failed []string) []byte {
+	var manifest []byte
+	for _, line := range failed {
+		manifest = append(manifest, line...)
+		manifest = append(manifest, '\n')
+	}
+	return manifest
+}
@@ -0,0 +1,100 @@
+package helpers_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestWriteArchiveWithFailures(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "docs", "api", "index.md"), []byte("# API\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "docs.tar.gz")
+	if err := helpers.WriteArchive(name, dir, []string{"docs/guide.md: HTTP 404"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("expected a gzip archive: %v", err)
+	}
+
+	entries := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected a valid archive: %v", err)
+		}
+		content, _ := io.ReadAll(tr)
+		entries[header.Name] = string(content)
+	}
+
+	expected := map[string]string{
+		"docs/api/index.md": "# API\n",
+		"FAILED.txt":        "docs/guide.md: HTTP 404\n",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries: %v, got: %v", expected, entries)
+	}
+}
+
+func TestWriteArchiveZip(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(t.TempDir(), "src.zip")
+	if err := helpers.CheckArchiveName(name); err != nil {
+		t.Fatalf("expected .zip to be accepted: %v", err)
+	}
+	if err := helpers.WriteArchive(name, dir, []string{"util.go: HTTP 500"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		t.Fatalf("expected a zip archive: %v", err)
+	}
+	defer zr.Close()
+
+	entries := map[string]string{}
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(r)
+		r.Close()
+		entries[f.Name] = string(content)
+	}
+
+	expected := map[string]string{
+		"main.go":    "package main\n",
+		"FAILED.txt": "util.go: HTTP 500\n",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries: %v, got: %v", expected, entries)
+	}
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes, as git does
+const binarySniffLen = 8000
+
+// binaryExtensions are extensions whose files are binary in practice, so they can be
+// skipped without downloading them first
+var binaryExtensions = map[string]bool{
+	// Images
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".bmp": true, ".ico": true,
+	".webp": true, ".tif": true, ".tiff": true, ".psd": true, ".icns": true,
+	// Archives
+	".zip": true, ".gz": true, ".tgz": true, ".bz2": true, ".xz": true, ".7z": true,
+	".rar": true, ".tar": true, ".zst": true, ".jar": true, ".war": true,
+	// Compiled code
+	".exe": true, ".dll": true, ".so": true, ".dylib": true, ".a": true, ".o": true,
+	".class": true, ".pyc": true, ".wasm": true, ".bin": true,
+	// Media
+	".mp3": true, ".mp4": true, ".mov": true, ".avi": true, ".wav": true, ".flac": true,
+	".ogg": true, ".webm": true, ".mkv": true,
+	// Documents and fonts
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true,
+	".pptx": true, ".ttf": true, ".otf": true, ".woff": true, ".woff2": true, ".eot": true,
+	// Data and models
+	".sqlite": true, ".db": true, ".parquet": true, ".npy": true, ".pkl": true, ".h5": true,
+	".onnx": true, ".pt": true,
+}
+
+// IsBinaryPath reports whether a file's extension marks it as binary
+func IsBinaryPath(filePath string) bool {
+	return binaryExtensions[strings.ToLower(path.Ext(filePath))]
+}
+
+// IsBinaryContent reports whether content looks binary: a NUL byte early on, as git decides
+func IsBinaryContent(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0
+}
+
+// IsBinaryFile sniffs the start of a saved file for binary content
+func IsBinaryFile(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	head := make([]byte, binarySniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	return IsBinaryContent(head[:n]), nil
+}
+
+// FilterTextFiles splits files into those that may be text and those whose extension marks
+// them as binary. Files kept may still turn out binary once their content is sniffed.
+func FilterTextFiles(files []model.FileInfo) (text, binary []model.FileInfo) {
+	for _, file := range files {
+		if IsBinaryPath(file.Path) {
+			binary = append(binary, file)
+		} else {
+			text = append(text, file)
+		}
+	}
+	return text, binary
+}
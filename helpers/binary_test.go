@@ -0,0 +1,29 @@
+package helpers_test
+
+import (
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestFilterTextFiles(t *testing.T) {
+	files := []model.FileInfo{{Path: "docs/logo.PNG"}, {Path: "main.go"}, {Path: "dist/app.wasm"}, {Path: "Makefile"}}
+
+	text, binary := helpers.FilterTextFiles(files)
+	if expected := []model.FileInfo{{Path: "main.go"}, {Path: "Makefile"}}; !reflect.DeepEqual(text, expected) {
+		t.Errorf("expected text files: %+v, got: %+v", expected, text)
+	}
+	if expected := []model.FileInfo{{Path: "docs/logo.PNG"}, {Path: "dist/app.wasm"}}; !reflect.DeepEqual(binary, expected) {
+		t.Errorf("expected binary files: %+v, got: %+v", expected, binary)
+	}
+}
+
+func TestIsBinaryContent(t *testing.T) {
+	if helpers.IsBinaryContent([]byte("package main\n")) {
+		t.Errorf("expected source code to be text")
+	}
+	if !helpers.IsBinaryContent([]byte("\x7fELF\x02\x01\x01\x00")) {
+		t.Errorf("expected content with a NUL byte to be binary")
+	}
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import (
+	"io"
+	"sync"
+)
+
+const copyBufferSize = 32 * 1024
+
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+// writerOnly hides a destination's ReadFrom method, which for *os.File would otherwise
+// bypass the pooled buffer and allocate its own
+type writerOnly struct {
+	io.Writer
+}
+
+// CopyBuffered copies src to dst using a pooled buffer, avoiding a fresh allocation per file
+func CopyBuffered(dst io.Writer, src io.Reader) (int64, error) {
+	bufp := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bufp)
+	return io.CopyBuffer(writerOnly{dst}, src, *bufp)
+}
@@ -0,0 +1,41 @@
+package helpers_test
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestCopyBuffered(t *testing.T) {
+	// Larger than a pooled buffer, so the copy takes several rounds
+	content := make([]byte, 100<<10+7)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	for i := 0; i < 2; i++ {
+		var out bytes.Buffer
+		n, err := helpers.CopyBuffered(&out, bytes.NewReader(content))
+		if err != nil || n != int64(len(content)) {
+			t.Fatalf("expected %d bytes copied, got %d (%v)", len(content), n, err)
+		}
+		if !bytes.Equal(out.Bytes(), content) {
+			t.Errorf("copy %d doesn't match the source", i+1)
+		}
+	}
+
+	// Files have a ReadFrom method the copy must not take
+	name := filepath.Join(t.TempDir(), "out")
+	file, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helpers.CopyBuffered(file, bytes.NewReader(content)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	file.Close()
+	if data, _ := os.ReadFile(name); !bytes.Equal(data, content) {
+		t.Errorf("expected the file to hold the source, got %d bytes", len(data))
+	}
+}
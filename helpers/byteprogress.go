@@ -0,0 +1,174 @@
+package helpers
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"repo-pack/model"
+)
+
+// ByteProgress estimates overall byte progress when only some file sizes are known up front.
+// Files of unknown size are assumed to be of average known size until their response arrives.
+type ByteProgress struct {
+	mu         sync.Mutex
+	expected   map[string]int64
+	known      int64
+	knownCount int
+	unknown    int
+	done       int64
+	active     map[string]*activeFile
+}
+
+type activeFile struct {
+	done    int64
+	started time.Time
+}
+
+// ActiveFile is the progress of a file being downloaded
+type ActiveFile struct {
+	Path string
+	// Done is the bytes read so far and Size the expected total, -1 when unknown
+	Done, Size int64
+	Elapsed    time.Duration
+}
+
+// NewByteProgress creates an empty byte progress tracker
+func NewByteProgress() *ByteProgress {
+	return &ByteProgress{expected: map[string]int64{}, active: map[string]*activeFile{}}
+}
+
+// Expect registers a file to be downloaded, with size -1 when it is not known yet
+func (p *ByteProgress) Expect(path string, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.expected[path] = size
+	if size < 0 {
+		p.unknown++
+		return
+	}
+	p.known += size
+	p.knownCount++
+}
+
+// ExpectFiles registers every file of a listing using its listed size
+func (p *ByteProgress) ExpectFiles(files []model.FileInfo) {
+	for _, file := range files {
+		p.Expect(file.Path, file.Size)
+	}
+}
+
+// Resolve replaces the expected size of a file with the actual size reported by its response
+func (p *ByteProgress) Resolve(path string, size int64) {
+	if p == nil || size < 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expected, ok := p.expected[path]
+	if !ok || expected == size {
+		return
+	}
+	if expected < 0 {
+		p.unknown--
+		p.knownCount++
+		p.known += size
+	} else {
+		p.known += size - expected
+	}
+	p.expected[path] = size
+}
+
+// Add records n downloaded bytes
+func (p *ByteProgress) Add(n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.done += n
+	p.mu.Unlock()
+}
+
+// Begin marks a file as being downloaded, so its own progress is reported by Active until End
+func (p *ByteProgress) Begin(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.active[path] = &activeFile{started: time.Now()}
+	p.mu.Unlock()
+}
+
+// End marks a file begun with Begin as finished, whether or not it succeeded
+func (p *ByteProgress) End(path string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	delete(p.active, path)
+	p.mu.Unlock()
+}
+
+// Active returns the progress of the files being downloaded, longest running first
+func (p *ByteProgress) Active() []ActiveFile {
+	p.mu.Lock()
+	now := time.Now()
+	active := make([]ActiveFile, 0, len(p.active))
+	for path, file := range p.active {
+		size, ok := p.expected[path]
+		if !ok {
+			size = -1
+		}
+		active = append(active, ActiveFile{Path: path, Done: file.done, Size: size, Elapsed: now.Sub(file.started)})
+	}
+	p.mu.Unlock()
+
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].Elapsed != active[j].Elapsed {
+			return active[i].Elapsed > active[j].Elapsed
+		}
+		return active[i].Path < active[j].Path
+	})
+	return active
+}
+
+// Snapshot returns the bytes downloaded so far and the estimated total
+func (p *ByteProgress) Snapshot() (done, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total = p.known
+	if p.unknown > 0 && p.knownCount > 0 {
+		total += int64(p.unknown) * (p.known / int64(p.knownCount))
+	}
+	return p.done, max(total, p.done)
+}
+
+// Reader wraps r so that bytes read from it are recorded as downloaded, for path as well as
+// in total while path is active
+func (p *ByteProgress) Reader(path string, r io.ReadCloser) io.ReadCloser {
+	if p == nil {
+		return r
+	}
+	return &progressReader{ReadCloser: r, progress: p, path: path}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	progress *ByteProgress
+	path     string
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		p := r.progress
+		p.mu.Lock()
+		p.done += int64(n)
+		if file, ok := p.active[r.path]; ok {
+			file.done += int64(n)
+		}
+		p.mu.Unlock()
+	}
+	return n, err
+}
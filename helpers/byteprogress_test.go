@@ -0,0 +1,49 @@
+package helpers_test
+
+import (
+	"io"
+	"repo-pack/helpers"
+	"strings"
+	"testing"
+)
+
+func TestByteProgressEstimatesUnknownSizes(t *testing.T) {
+	progress := helpers.NewByteProgress()
+	progress.Expect("a", 100)
+	progress.Expect("b", 300)
+	progress.Expect("c", -1)
+
+	if _, total := progress.Snapshot(); total != 600 {
+		t.Errorf("expected estimated total: 600, got: %d", total)
+	}
+
+	progress.Resolve("c", 50)
+	progress.Resolve("a", 1000)
+	progress.Add(250)
+
+	done, total := progress.Snapshot()
+	if done != 250 || total != 1350 {
+		t.Errorf("expected 250/1350, got: %d/%d", done, total)
+	}
+}
+
+func TestByteProgressTracksActiveFiles(t *testing.T) {
+	progress := helpers.NewByteProgress()
+	progress.Expect("a", 10)
+	progress.Expect("b", -1)
+
+	progress.Begin("a")
+	progress.Begin("b")
+	if _, err := io.ReadAll(progress.Reader("a", io.NopCloser(strings.NewReader("12345")))); err != nil {
+		t.Fatal(err)
+	}
+	progress.End("b")
+
+	active := progress.Active()
+	if len(active) != 1 || active[0].Path != "a" || active[0].Done != 5 || active[0].Size != 10 {
+		t.Errorf("expected a alone to be active with 5 of 10 bytes, got %+v", active)
+	}
+	if done, _ := progress.Snapshot(); done != 5 {
+		t.Errorf("expected 5 bytes done in total, got %d", done)
+	}
+}
@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CASTreeFile is the file of a content-addressed layout mapping paths to object hashes
+const CASTreeFile = "tree.json"
+
+// WriteCASLayout lays the files under srcDir out by content in dstDir: each is stored once as
+// objects/<sha256>, and tree.json maps every path relative to srcDir to its hash. Files with
+// identical content share an object, and objects already present are kept. It returns the
+// number of files in the tree.
+func WriteCASLayout(srcDir, dstDir string) (int, error) {
+	objects := filepath.Join(dstDir, "objects")
+	if err := os.MkdirAll(objects, 0o755); err != nil {
+		return 0, fmt.Errorf("error creating object directory: %v", err)
+	}
+
+	tree := map[string]string{}
+	err := filepath.WalkDir(srcDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, p)
+		if err != nil {
+			return err
+		}
+		sum, err := storeObject(objects, p)
+		if err != nil {
+			return fmt.Errorf("error storing %s: %v", rel, err)
+		}
+		tree[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(filepath.Join(dstDir, CASTreeFile), append(data, '\n'), 0o644); err != nil {
+		return 0, fmt.Errorf("error writing %s: %v", CASTreeFile, err)
+	}
+	return len(tree), nil
+}
+
+// storeObject copies the file at src into objects under its SHA-256, which it returns. A
+// symbolic link is stored as git stores it, by its target.
+func storeObject(objects, src string) (string, error) {
+	var in io.Reader
+	if info, err := os.Lstat(src); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(src)
+		if err != nil {
+			return "", err
+		}
+		in = strings.NewReader(link)
+	} else {
+		file, err := os.Open(src)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	tmp, err := os.CreateTemp(objects, ".tmp-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	if _, err := CopyBuffered(io.MultiWriter(tmp, h), in); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	dst := filepath.Join(objects, sum)
+	if _, err := os.Stat(dst); err == nil {
+		return sum, nil
+	}
+	if err := os.Chmod(tmp.Name(), 0o444); err != nil {
+		return "", err
+	}
+	return sum, os.Rename(tmp.Name(), dst)
+}
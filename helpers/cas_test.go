@@ -0,0 +1,62 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestWriteCASLayout(t *testing.T) {
+	src := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "same\n", "sub/b.txt": "same\n", "c.txt": "other\n"} {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := t.TempDir()
+	count, err := helpers.WriteCASLayout(src, dst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 files, got %d", count)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "tree.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree map[string]string
+	if err := json.Unmarshal(data, &tree); err != nil {
+		t.Fatalf("invalid tree.json: %v", err)
+	}
+	// SHA-256 of "same\n"
+	same := "a6328afc76e9db71da297ebff4b0d3e7a7eb3b01d917c05a6573fef121b6ecb6"
+	if tree["a.txt"] != same || tree["sub/b.txt"] != same || tree["c.txt"] == same {
+		t.Errorf("expected identical content to share one hash, got: %v", tree)
+	}
+
+	objects, err := os.ReadDir(filepath.Join(dst, "objects"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, object := range objects {
+		names = append(names, object.Name())
+	}
+	expected := []string{tree["a.txt"], tree["c.txt"]}
+	if expected[0] > expected[1] {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected objects: %v, got: %v", expected, names)
+	}
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// IsChecksumFile reports whether a repository path names a SHA-256 checksum file: a
+// SHA256SUMS listing, possibly with a .txt extension, or a <file>.sha256 for a single file
+func IsChecksumFile(name string) bool {
+	base := path.Base(name)
+	return strings.EqualFold(base, "SHA256SUMS") || strings.EqualFold(base, "SHA256SUMS.txt") ||
+		strings.HasSuffix(strings.ToLower(base), ".sha256") && len(base) > len(".sha256")
+}
+
+var (
+	// gnuChecksumLine is a sha256sum line: the digest, a space, then a space or * and the name
+	gnuChecksumLine = regexp.MustCompile(`^([0-9a-fA-F]{64}) [ *](.+)$`)
+	// bsdChecksumLine is a line of shasum --tag or BSD sha256: SHA256 (name) = digest
+	bsdChecksumLine = regexp.MustCompile(`^SHA256 \((.+)\) = ([0-9a-fA-F]{64})$`)
+	bareChecksum    = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// ParseChecksums reads the digests a checksum file named name lists, by file name relative to
+// the checksum file's directory. Both sha256sum and BSD lines are understood; a <file>.sha256
+// holding just a digest stands for <file>. Blank lines and # comments are ignored.
+func ParseChecksums(name string, data []byte) (map[string]string, error) {
+	sums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if m := gnuChecksumLine.FindStringSubmatch(text); m != nil {
+			sums[path.Clean(m[2])] = strings.ToLower(m[1])
+			continue
+		}
+		if m := bsdChecksumLine.FindStringSubmatch(text); m != nil {
+			sums[path.Clean(m[1])] = strings.ToLower(m[2])
+			continue
+		}
+		if bareChecksum.MatchString(text) && strings.HasSuffix(strings.ToLower(name), ".sha256") {
+			base := path.Base(name)
+			sums[base[:len(base)-len(".sha256")]] = strings.ToLower(text)
+			continue
+		}
+		return nil, fmt.Errorf("%s line %d isn't a SHA-256 checksum line", name, line)
+	}
+	return sums, scanner.Err()
+}
+
+// ChecksumMismatch is a downloaded file whose SHA-256 differs from the one a checksum file lists
+type ChecksumMismatch struct {
+	Path, ChecksumFile, Expected, Actual string
+}
+
+func (m ChecksumMismatch) String() string {
+	return fmt.Sprintf("%s: SHA-256 %s, but %s lists %s", m.Path, m.Actual, m.ChecksumFile, m.Expected)
+}
+
+// VerifyUpstream checks the files saved under outputDir against the checksum files among
+// files. Listed files that weren't saved, such as ones filtered out, are passed over, and
+// entries naming paths outside the checksum file's directory are ignored. It returns how many
+// files matched and the mismatches, sorted by path.
+func VerifyUpstream(outputDir string, components model.RepoURLComponents, files []model.FileInfo) (int, []ChecksumMismatch, error) {
+	baseDir := filepath.Base(components.OutputRoot())
+	verified := 0
+	var mismatches []ChecksumMismatch
+	for _, file := range files {
+		if !IsChecksumFile(file.Path) {
+			continue
+		}
+		local, err := OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return verified, nil, err
+		}
+		data, err := os.ReadFile(local)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return verified, nil, err
+		}
+		sums, err := ParseChecksums(file.Path, data)
+		if err != nil {
+			return verified, nil, err
+		}
+
+		for name, expected := range sums {
+			if strings.HasPrefix(name, "../") || name == ".." || path.IsAbs(name) {
+				continue
+			}
+			sibling := path.Join(path.Dir(file.Path), name)
+			siblingLocal, err := OutputPath(outputDir, baseDir, sibling)
+			if err != nil {
+				continue
+			}
+			actual, err := fileSHA256(siblingLocal)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return verified, nil, err
+			}
+			if actual != expected {
+				mismatches = append(mismatches, ChecksumMismatch{Path: sibling, ChecksumFile: file.Path, Expected: expected, Actual: actual})
+				continue
+			}
+			verified++
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Path < mismatches[j].Path })
+	return verified, mismatches, nil
+}
+
+// fileSHA256 computes the hex SHA-256 of a file on disk
+func fileSHA256(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := CopyBuffered(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
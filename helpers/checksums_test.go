@@ -0,0 +1,97 @@
+package helpers_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestParseChecksums(t *testing.T) {
+	data := "# release 1.2\n" +
+		sha256Hex("a") + "  tool-linux.tar.gz\n" +
+		sha256Hex("b") + " *./tool-windows.zip\n" +
+		"SHA256 (tool-darwin.tar.gz) = " + sha256Hex("c") + "\n\n"
+	sums, err := helpers.ParseChecksums("dist/SHA256SUMS", []byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"tool-linux.tar.gz":  sha256Hex("a"),
+		"tool-windows.zip":   sha256Hex("b"),
+		"tool-darwin.tar.gz": sha256Hex("c"),
+	}
+	for name, sum := range expected {
+		if sums[name] != sum {
+			t.Errorf("expected %s for %s, got %q", sum, name, sums[name])
+		}
+	}
+
+	single, err := helpers.ParseChecksums("dist/tool.zip.sha256", []byte(sha256Hex("d")+"\n"))
+	if err != nil || single["tool.zip"] != sha256Hex("d") {
+		t.Errorf("expected a bare digest to stand for tool.zip, got %v (%v)", single, err)
+	}
+
+	if _, err := helpers.ParseChecksums("dist/SHA256SUMS", []byte("not a checksum\n")); err == nil {
+		t.Errorf("expected a malformed line to be rejected")
+	}
+}
+
+func TestVerifyUpstream(t *testing.T) {
+	out := t.TempDir()
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "v1", Dir: "dist"}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(out, name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(out, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("dist/good.tar.gz", "good")
+	write("dist/bad.zip", "tampered")
+	write("dist/SHA256SUMS", sha256Hex("good")+"  good.tar.gz\n"+
+		sha256Hex("original")+"  bad.zip\n"+
+		sha256Hex("filtered")+"  filtered.tar.gz\n"+
+		sha256Hex("outside")+"  ../outside\n")
+	write("dist/one.bin", "one")
+	write("dist/one.bin.sha256", sha256Hex("one")+"\n")
+
+	files := []model.FileInfo{
+		{Path: "dist/SHA256SUMS"}, {Path: "dist/good.tar.gz"}, {Path: "dist/bad.zip"},
+		{Path: "dist/one.bin"}, {Path: "dist/one.bin.sha256"},
+	}
+	verified, mismatches, err := helpers.VerifyUpstream(out, components, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if verified != 2 {
+		t.Errorf("expected 2 verified files, got %d", verified)
+	}
+	if len(mismatches) != 1 || mismatches[0].Path != "dist/bad.zip" || mismatches[0].Expected != sha256Hex("original") {
+		t.Errorf("expected only dist/bad.zip to mismatch, got %v", mismatches)
+	}
+}
+
+func TestIsChecksumFile(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"dist/SHA256SUMS":      true,
+		"sha256sums.txt":       true,
+		"dist/tool.sha256":     true,
+		"dist/.sha256":         false,
+		"dist/SHA512SUMS":      false,
+		"dist/tool.sha256.sig": false,
+	} {
+		if helpers.IsChecksumFile(name) != expected {
+			t.Errorf("expected IsChecksumFile(%q) to be %v", name, expected)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"path"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// hashSuffixLength is how many hex digits of a blob SHA HashSuffix inserts
+const hashSuffixLength = 8
+
+// HashSuffix inserts the first digits of sha before the extension of the file name at p, as
+// ci.yml becomes ci-1a2b3c4d.yml. Names without an extension, or only one, as .env, end with it.
+func HashSuffix(p, sha string) string {
+	short := sha[:min(len(sha), hashSuffixLength)]
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" {
+		stem, ext = name, ""
+	}
+	return dir + stem + "-" + short + ext
+}
+
+// SuffixCollisions renames the files that several downloads would save at the same local
+// path with different content, giving each a HashSuffix of its blob SHA so the names are the
+// same whichever order the downloads run in. local returns where a file of the download with
+// the given index is saved. Files with the same content at one path are left as they are, as
+// they'd only be written twice. A renamed file keeps its repository path as its Source. It
+// returns how many files were renamed.
+func SuffixCollisions(downloads [][]model.FileInfo, local func(download int, file model.FileInfo) (string, error)) (int, error) {
+	type ref struct{ download, file int }
+	byLocal := make(map[string][]ref)
+	for d, files := range downloads {
+		for f, file := range files {
+			key, err := local(d, file)
+			if err != nil {
+				return 0, err
+			}
+			byLocal[key] = append(byLocal[key], ref{d, f})
+		}
+	}
+
+	renamed := 0
+	for _, refs := range byLocal {
+		shas := make(map[string]bool, len(refs))
+		for _, r := range refs {
+			shas[downloads[r.download][r.file].SHA] = true
+		}
+		if len(shas) < 2 {
+			continue
+		}
+		for _, r := range refs {
+			file := &downloads[r.download][r.file]
+			if file.SHA == "" {
+				continue
+			}
+			file.Source = file.SourcePath()
+			file.Path = HashSuffix(file.Path, file.SHA)
+			renamed++
+		}
+	}
+	return renamed, nil
+}
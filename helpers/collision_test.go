@@ -0,0 +1,48 @@
+package helpers_test
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+func TestHashSuffix(t *testing.T) {
+	tests := map[string]string{
+		".github/workflows/ci.yml": ".github/workflows/ci-0123abcd.yml",
+		"Makefile":                 "Makefile-0123abcd",
+		"conf/.env":                "conf/.env-0123abcd",
+		"archive.tar.gz":           "archive.tar-0123abcd.gz",
+	}
+	for in, want := range tests {
+		if got := helpers.HashSuffix(in, "0123abcdef"); got != want {
+			t.Errorf("%s: expected %s, got %s", in, want, got)
+		}
+	}
+}
+
+func TestSuffixCollisions(t *testing.T) {
+	downloads := [][]model.FileInfo{
+		{{Path: "wf/ci.yml", SHA: "aaaaaaaaaa"}, {Path: "wf/lint.yml", SHA: "cccccccccc"}, {Path: "wf/own.yml", SHA: "dddddddddd"}},
+		{{Path: "wf/ci.yml", SHA: "bbbbbbbbbb"}, {Path: "wf/lint.yml", SHA: "cccccccccc"}},
+	}
+	// Both downloads save into the same directory
+	renamed, err := helpers.SuffixCollisions(downloads, func(_ int, file model.FileInfo) (string, error) {
+		return path.Join("out", file.Path), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if renamed != 2 {
+		t.Errorf("expected 2 files renamed, got %d", renamed)
+	}
+	expected := [][]model.FileInfo{
+		{{Path: "wf/ci-aaaaaaaa.yml", SHA: "aaaaaaaaaa", Source: "wf/ci.yml"}, {Path: "wf/lint.yml", SHA: "cccccccccc"}, {Path: "wf/own.yml", SHA: "dddddddddd"}},
+		{{Path: "wf/ci-bbbbbbbb.yml", SHA: "bbbbbbbbbb", Source: "wf/ci.yml"}, {Path: "wf/lint.yml", SHA: "cccccccccc"}},
+	}
+	if !reflect.DeepEqual(downloads, expected) {
+		t.Errorf("expected %+v, got %+v", expected, downloads)
+	}
+}
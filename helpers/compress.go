@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// nopWriteCloser adapts a writer that needs no finalisation
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// CompressWriter wraps w with the compression implied by name's extension: gzip for
+// .gz/.tgz, none for .tar. Closing the result flushes the compressor but not w.
+func CompressWriter(name string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(name, ".tar"):
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, unsupportedArchive(name)
+	}
+}
+
+// DecompressReader wraps r with the decompression implied by name's extension
+func DecompressReader(name string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"), strings.HasSuffix(name, ".tgz"):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(name, ".tar"):
+		return io.NopCloser(r), nil
+	default:
+		return nil, unsupportedArchive(name)
+	}
+}
+
+func unsupportedArchive(name string) error {
+	if strings.HasSuffix(name, ".zst") {
+		return fmt.Errorf("zstd compression is not supported, use .tar.gz or .tar instead of %s", name)
+	}
+	return fmt.Errorf("unsupported archive format %s, use .tar.gz or .tar", name)
+}
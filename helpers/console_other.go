@@ -0,0 +1,8 @@
+//go:build !windows
+
+package helpers
+
+// EnableVirtualTerminal is a no-op outside Windows, where terminals handle ANSI escapes natively
+func EnableVirtualTerminal() error {
+	return nil
+}
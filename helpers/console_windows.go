@@ -0,0 +1,27 @@
+package helpers
+
+import "syscall"
+
+// enableVirtualTerminalProcessing makes the console interpret ANSI escape sequences
+const enableVirtualTerminalProcessing = 0x0004
+
+var procSetConsoleMode = syscall.NewLazyDLL("kernel32.dll").NewProc("SetConsoleMode")
+
+// EnableVirtualTerminal turns on ANSI escape handling for stdout and stderr, so the progress
+// bar renders in plain cmd.exe and PowerShell consoles and not only in Windows Terminal.
+// Handles that aren't consoles, such as redirected output, are left alone.
+func EnableVirtualTerminal() error {
+	for _, handle := range []syscall.Handle{syscall.Stdout, syscall.Stderr} {
+		var mode uint32
+		if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+			continue
+		}
+		if mode&enableVirtualTerminalProcessing != 0 {
+			continue
+		}
+		if ok, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode|enableVirtualTerminalProcessing)); ok == 0 {
+			return err
+		}
+	}
+	return nil
+}
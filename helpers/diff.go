@@ -0,0 +1,192 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffEdits bounds the edit distance searched for by UnifiedDiff. Files differing by more
+// are shown as entirely replaced, which keeps the search's memory use within a few megabytes.
+const maxDiffEdits = 2000
+
+// diffOp is one line of an edit script: kept (' '), deleted ('-') or inserted ('+')
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns the differences between a and b as a unified diff with context lines of
+// context around each change, naming the sides aName and bName. Equal contents give "".
+func UnifiedDiff(aName, bName string, a, b []byte, context int) string {
+	ops := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+
+		// A hunk runs until a stretch of more than twice the context is left unchanged
+		start := max(i-context, 0)
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*context {
+				end = min(end+context, len(ops))
+				break
+			}
+			end = next
+		}
+		writeHunk(&out, ops, start, end)
+		i = end
+	}
+	return out.String()
+}
+
+// writeHunk writes ops[start:end] as one hunk, numbering lines from their position in ops
+func writeHunk(out *strings.Builder, ops []diffOp, start, end int) {
+	aLine, bLine := 0, 0
+	for _, op := range ops[:start] {
+		if op.kind != '+' {
+			aLine++
+		}
+		if op.kind != '-' {
+			bLine++
+		}
+	}
+	aLen, bLen := 0, 0
+	for _, op := range ops[start:end] {
+		if op.kind != '+' {
+			aLen++
+		}
+		if op.kind != '-' {
+			bLen++
+		}
+	}
+	// An empty side is numbered by the line before it, as diff -u does
+	if aLen > 0 {
+		aLine++
+	}
+	if bLen > 0 {
+		bLine++
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine, aLen), hunkRange(bLine, bLen))
+	for _, op := range ops[start:end] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.text)
+		if !strings.HasSuffix(op.text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats one side of a hunk header, leaving out a length of 1 as diff -u does
+func hunkRange(line, length int) string {
+	if length == 1 {
+		return fmt.Sprint(line)
+	}
+	return fmt.Sprintf("%d,%d", line, length)
+}
+
+// splitLines splits text into lines, each keeping its newline
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines finds a shortest edit script turning a into b with Myers' algorithm, giving up
+// after maxDiffEdits and replacing every line instead
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	v := make([]int, 2*limit+3)
+	offset := limit + 1
+
+	// trace[d] holds v[-d..d] as it was before step d, for walking the path back
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b)
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// backtrackDiff walks the furthest-reaching paths recorded in trace back from the end of both
+// inputs, returning the edit script in order
+func backtrackDiff(trace [][]int, a, b []string) []diffOp {
+	var ops []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		at := func(k int) int { return trace[d][k+d] }
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = at(prevK)
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,39 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n"
+	b := "one\n2\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\neleven"
+
+	expected := strings.Join([]string{
+		"--- a/f.txt",
+		"+++ b/f.txt",
+		"@@ -1,3 +1,3 @@",
+		" one",
+		"-two",
+		"+2",
+		" three",
+		"@@ -10 +10,2 @@",
+		" ten",
+		"+eleven",
+		`\ No newline at end of file`,
+		"",
+	}, "\n")
+	if diff := helpers.UnifiedDiff("a/f.txt", "b/f.txt", []byte(a), []byte(b), 1); diff != expected {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+
+	if diff := helpers.UnifiedDiff("a", "b", []byte(a), []byte(a), 3); diff != "" {
+		t.Errorf("expected no diff for equal content, got:\n%s", diff)
+	}
+
+	expected = "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+x\n+y\n"
+	if diff := helpers.UnifiedDiff("/dev/null", "b/new.txt", nil, []byte("x\ny\n"), 3); diff != expected {
+		t.Errorf("expected diff:\n%s\ngot:\n%s", expected, diff)
+	}
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"sync"
+)
+
+// FileEvent is the outcome of one file, written as a line of NDJSON
+type FileEvent struct {
+	Path string `json:"path"`
+	// Status is downloaded, cached, skipped or failed
+	Status string `json:"status"`
+	Bytes  int64  `json:"bytes"`
+	Error  string `json:"error,omitempty"`
+	// Category classifies a failure as rate_limit, not_found, auth, network, disk, lfs, blocked or other
+	Category string `json:"category,omitempty"`
+}
+
+// EventLog writes file events as newline-delimited JSON. It is safe for concurrent use by
+// download workers, and a nil EventLog discards every event.
+type EventLog struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+	// Keep holds on to every event for Events, such as for a report written at the end
+	Keep   bool
+	events []FileEvent
+}
+
+// NewEventLog creates an event log writing to w, or only keeping events when w is nil
+func NewEventLog(w io.Writer) *EventLog {
+	if w == nil {
+		return &EventLog{Keep: true}
+	}
+	return &EventLog{enc: json.NewEncoder(w)}
+}
+
+// Write appends an event to the log. The first write error is kept and reported by Err.
+func (l *EventLog) Write(event FileEvent) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Keep {
+		l.events = append(l.events, event)
+	}
+	if l.enc == nil {
+		return
+	}
+	if err := l.enc.Encode(event); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+// Events returns the events written so far when the log keeps them
+func (l *EventLog) Events() []FileEvent {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.events)
+}
+
+// Err returns the first error met writing events
+func (l *EventLog) Err() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
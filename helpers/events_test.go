@@ -0,0 +1,26 @@
+package helpers_test
+
+import (
+	"bytes"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestEventLogWritesNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	events := helpers.NewEventLog(&buf)
+	events.Write(helpers.FileEvent{Path: "dir/a.go", Status: "downloaded", Bytes: 12})
+	events.Write(helpers.FileEvent{Path: "dir/b.go", Status: "failed", Error: "HTTP 404", Category: "not_found"})
+
+	expected := `{"path":"dir/a.go","status":"downloaded","bytes":12}` + "\n" +
+		`{"path":"dir/b.go","status":"failed","bytes":0,"error":"HTTP 404","category":"not_found"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected events:\n%s\ngot:\n%s", expected, buf.String())
+	}
+	if err := events.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	var nilLog *helpers.EventLog
+	nilLog.Write(helpers.FileEvent{Path: "ignored"})
+}
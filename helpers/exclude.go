@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"strings"
+
+	"repo-pack/model"
+)
+
+// DefaultExcludes are directory and file names skipped unless --no-default-excludes is given:
+// VCS metadata, dependency and build output, and OS clutter that naive downloads drag in
+var DefaultExcludes = []string{".git", "node_modules", "dist", "__pycache__", ".DS_Store"}
+
+// ExcludeNames drops files with a path segment below root matching one of names, so a file
+// is excluded both when it has an excluded name and when it sits in an excluded directory.
+// Segments of root itself never match, so explicitly downloading e.g. a dist directory works.
+func ExcludeNames(files []model.FileInfo, root string, names []string) (kept []model.FileInfo, excluded int) {
+	if len(names) == 0 {
+		return files, 0
+	}
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+
+	prefix := strings.Trim(root, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	kept = make([]model.FileInfo, 0, len(files))
+	for _, file := range files {
+		if hasExcludedSegment(strings.TrimPrefix(file.Path, prefix), set) {
+			excluded++
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, excluded
+}
+
+func hasExcludedSegment(rel string, set map[string]bool) bool {
+	for _, segment := range strings.Split(rel, "/") {
+		if set[segment] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package helpers_test
+
+import (
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestExcludeNames(t *testing.T) {
+	files := []model.FileInfo{
+		{Path: "web/dist/index.js"},
+		{Path: "web/src/index.ts"},
+		{Path: "web/src/node_modules/left-pad/index.js"},
+		{Path: "web/.DS_Store"},
+		{Path: "web/distribution.md"},
+	}
+
+	kept, excluded := helpers.ExcludeNames(files, "web", helpers.DefaultExcludes)
+	expected := []model.FileInfo{{Path: "web/src/index.ts"}, {Path: "web/distribution.md"}}
+	if !reflect.DeepEqual(kept, expected) || excluded != 3 {
+		t.Errorf("expected %+v with 3 excluded, got %+v with %d excluded", expected, kept, excluded)
+	}
+
+	// The download root itself may be an excluded name
+	kept, excluded = helpers.ExcludeNames([]model.FileInfo{{Path: "web/dist/index.js"}}, "web/dist", helpers.DefaultExcludes)
+	if len(kept) != 1 || excluded != 0 {
+		t.Errorf("expected files under an explicitly requested dist directory to be kept, got %+v", kept)
+	}
+}
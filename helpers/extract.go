@@ -0,0 +1,169 @@
+package helpers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// extractSuffixes are the extensions of the archives ExtractArchive unpacks
+var extractSuffixes = []string{".tar.gz", ".tgz", ".zip"}
+
+// ExtractDir returns the directory an archive at name is extracted to, its name without the
+// archive extension, and reports whether name is an archive ExtractArchive unpacks
+func ExtractDir(name string) (string, bool) {
+	for _, suffix := range extractSuffixes {
+		// A file named just ".zip" has no name left for its directory
+		if dir, ok := strings.CutSuffix(name, suffix); ok && dir != "" && !os.IsPathSeparator(dir[len(dir)-1]) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// ExtractArchive unpacks the zip or gzipped tar archive at name into the directory
+// ExtractDir names for it, next to the archive, which is kept. It refuses to extract over
+// anything already there, such as a directory of the repository with the same name. Entries
+// are only written inside that directory; links are skipped, as they could point outside it.
+// It returns how many files it wrote.
+func ExtractArchive(name string) (int, error) {
+	dir, ok := ExtractDir(name)
+	if !ok {
+		return 0, fmt.Errorf("%s isn't a .zip, .tar.gz or .tgz archive", name)
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		return 0, fmt.Errorf("%s already exists", dir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+
+	var count int
+	var err error
+	if strings.HasSuffix(name, ".zip") {
+		count, err = extractZip(name, dir)
+	} else {
+		count, err = extractTarGz(name, dir)
+	}
+	if err != nil {
+		// A partly extracted directory would be mistaken for a complete one on the next run
+		os.RemoveAll(dir)
+		return 0, fmt.Errorf("error extracting %s: %v", name, err)
+	}
+	return count, nil
+}
+
+// entryPath returns where an archive entry named name is written under dir, or an error for
+// names that would land outside it
+func entryPath(dir, name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(slashed) || filepath.VolumeName(name) != "" || slices.Contains(strings.Split(slashed, "/"), "..") {
+		return "", fmt.Errorf("entry %q points outside the archive", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(path.Clean(slashed))), nil
+}
+
+// writeEntry saves one file of an archive, keeping its permission bits
+func writeEntry(target string, mode fs.FileMode, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func extractZip(name, dir string) (int, error) {
+	reader, err := zip.OpenReader(name)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+
+	count := 0
+	for _, entry := range reader.File {
+		target, err := entryPath(dir, entry.Name)
+		if err != nil {
+			return count, err
+		}
+		mode := entry.Mode()
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return count, err
+			}
+			continue
+		case !mode.IsRegular():
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return count, err
+		}
+		err = writeEntry(target, mode, content)
+		content.Close()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func extractTarGz(name, dir string) (int, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return 0, err
+	}
+	defer gz.Close()
+
+	count := 0
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		// GitHub's own tarballs start with a global header carrying the commit
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		target, err := entryPath(dir, header.Name)
+		if err != nil {
+			return count, err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return count, err
+			}
+		case tar.TypeReg:
+			if err := writeEntry(target, header.FileInfo().Mode(), tr); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+}
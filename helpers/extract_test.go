@@ -0,0 +1,96 @@
+package helpers_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestExtractArchive(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "bin", "tool"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "README"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	for _, name := range []string{"release.zip", "assets.tar.gz", "vendor.tgz"} {
+		archive := filepath.Join(out, name)
+		if err := helpers.WriteArchive(archive, src, nil); err != nil {
+			t.Fatal(err)
+		}
+		count, err := helpers.ExtractArchive(archive)
+		if err != nil {
+			t.Fatalf("unexpected error extracting %s: %v", name, err)
+		}
+		if count != 2 {
+			t.Errorf("expected 2 files from %s, got %d", name, count)
+		}
+		dir, _ := helpers.ExtractDir(archive)
+		if data, err := os.ReadFile(filepath.Join(dir, "README")); err != nil || string(data) != "hello\n" {
+			t.Errorf("expected README extracted from %s, got %q (%v)", name, data, err)
+		}
+		if info, err := os.Stat(filepath.Join(dir, "bin", "tool")); err != nil || info.Mode().Perm()&0o100 == 0 {
+			t.Errorf("expected bin/tool extracted executable from %s, got %v", name, err)
+		}
+		if _, err := os.Stat(archive); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+
+		if _, err := helpers.ExtractArchive(archive); err == nil {
+			t.Errorf("expected extracting %s over its earlier extraction to be refused", name)
+		}
+	}
+}
+
+func TestExtractArchiveRejectsEscapingEntries(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.zip")
+	file, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(file)
+	for _, name := range []string{"ok.txt", "../escaped.txt"} {
+		entry, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry.Write([]byte("x"))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if _, err := helpers.ExtractArchive(archive); err == nil {
+		t.Fatal("expected an entry outside the archive to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(archive), "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing written outside the archive's directory")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(archive), "evil")); !os.IsNotExist(err) {
+		t.Errorf("expected the partial extraction to be removed")
+	}
+}
+
+func TestExtractDir(t *testing.T) {
+	for name, expected := range map[string]string{
+		"a/b.zip":    "a/b",
+		"a/b.tar.gz": "a/b",
+		"a/b.tgz":    "a/b",
+		"a/b.tar":    "",
+		"a/.zip":     "",
+		"a/b.gz":     "",
+	} {
+		if dir, _ := helpers.ExtractDir(name); dir != expected {
+			t.Errorf("expected %q for %s, got %q", expected, name, dir)
+		}
+	}
+}
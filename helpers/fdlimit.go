@@ -0,0 +1,47 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+const (
+	// FDsPerDownload is the most descriptors one download holds at once: the raw
+	// connection, an LFS media connection and the destination file
+	FDsPerDownload = 3
+	// fdReserve covers stdio, DNS lookups and API connections used outside downloads
+	fdReserve = 16
+)
+
+// FitConcurrency caps concurrency so that downloads stay within budget file descriptors.
+// A budget of 0 uses the process open file limit.
+func FitConcurrency(concurrency int, budget uint64) (int, error) {
+	if budget == 0 {
+		limit, err := openFileLimit()
+		if err != nil || limit == 0 {
+			// The limit can't be determined on this platform, so trust the requested concurrency
+			return concurrency, nil
+		}
+		budget = limit
+	}
+
+	if budget < fdReserve+FDsPerDownload {
+		return 0, fmt.Errorf(
+			"open file limit of %d is too low, at least %d is needed; raise it with `ulimit -n`",
+			budget,
+			fdReserve+FDsPerDownload,
+		)
+	}
+
+	fit := int((budget - fdReserve) / FDsPerDownload)
+	return min(concurrency, fit), nil
+}
+
+// WithFDHint adds advice to errors caused by running out of file descriptors
+func WithFDHint(err error) error {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+		return fmt.Errorf("%w (too many open files: lower --concurrency or raise `ulimit -n`)", err)
+	}
+	return err
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package helpers
+
+// openFileLimit reports an unknown limit on platforms without RLIMIT_NOFILE
+func openFileLimit() (uint64, error) {
+	return 0, nil
+}
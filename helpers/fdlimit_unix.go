@@ -0,0 +1,15 @@
+//go:build unix
+
+package helpers
+
+import "syscall"
+
+// openFileLimit returns the soft RLIMIT_NOFILE. The Go runtime already raises it to the
+// hard limit at startup, so this is the most descriptors the process can use.
+func openFileLimit() (uint64, error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	return uint64(rlimit.Cur), nil
+}
@@ -4,41 +4,195 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
 
-// SaveFile saves file to a filepath and base directory
-func SaveFile(baseDir string, filePath string, reader io.ReadCloser) error {
-	defer reader.Close()
-	currentDir, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("error getting current working directory: %v", err)
+// SaveOptions describes what is known about a file before it is written
+type SaveOptions struct {
+	// Size is the expected length of the content, or -1 when unknown. On Linux, its blocks are
+	// reserved before writing.
+	Size int64
+	// Sparse skips writing all-zero blocks so they become holes on supporting filesystems
+	Sparse bool
+	// OutputDir is the directory files are saved under; the working directory when empty
+	OutputDir string
+}
+
+// SaveResult describes a file once it has been written
+type SaveResult struct {
+	Path    string
+	Written int64
+	SHA256  string
+	// BlobSHA is the git blob SHA-1 of the content
+	BlobSHA string
+	// Cached is set when the content was restored from the cache instead of downloaded
+	Cached bool
+	// Verified is set when the content was checked against the listing's blob SHA
+	Verified bool
+}
+
+// OutputPath returns where a repository file is saved: its path from the base directory
+// onwards, relative to outputDir or the current working directory when outputDir is empty.
+// An empty or "." base directory stands for the repository root, so the full path is kept,
+// and a base naming the file itself keeps just the file name.
+func OutputPath(outputDir string, baseDir string, filePath string) (string, error) {
+	currentDir := outputDir
+	if currentDir == "" {
+		var err error
+		if currentDir, err = os.Getwd(); err != nil {
+			return "", fmt.Errorf("error getting current working directory: %v", err)
+		}
+	}
+
+	if baseDir == "" || baseDir == "." {
+		return filepath.Join(currentDir, filePath), nil
 	}
 
 	baseDirIndex := strings.Index(filePath, baseDir+"/")
+	// A single-file download is rooted at the file itself, which is saved under its own name
+	if baseDirIndex == -1 && path.Base(filePath) == baseDir {
+		return filepath.Join(currentDir, baseDir), nil
+	}
 	if baseDirIndex == -1 {
-		return fmt.Errorf("base directory %s not found in file path %s", baseDir, filePath)
+		return "", fmt.Errorf("base directory %s not found in file path %s", baseDir, filePath)
 	}
 
 	adjustedFilePath := filePath[baseDirIndex:]
-	fullPath := filepath.Join(currentDir, adjustedFilePath)
+	return filepath.Join(currentDir, adjustedFilePath), nil
+}
 
+// SaveFile saves file to a filepath and base directory, hashing the content as it is written
+func SaveFile(baseDir string, filePath string, reader io.ReadCloser, opts SaveOptions) (SaveResult, error) {
+	defer reader.Close()
+	fullPath, err := OutputPath(opts.OutputDir, baseDir, filePath)
+	if err != nil {
+		return SaveResult{}, err
+	}
+
+	// A link saved by an earlier download is replaced rather than written through
+	if err := RemoveLinks(opts.OutputDir, fullPath); err != nil {
+		return SaveResult{}, err
+	}
 	dir := filepath.Dir(fullPath)
 	if makeDirErr := os.MkdirAll(dir, 0o755); makeDirErr != nil && !os.IsExist(makeDirErr) {
-		return fmt.Errorf("error creating output folder for %s: %w", fullPath, makeDirErr)
+		return SaveResult{}, fmt.Errorf("error creating output folder for %s: %w", fullPath, makeDirErr)
 	}
 
 	file, err := os.Create(fullPath)
 	if err != nil {
-		return fmt.Errorf("error creating file %s: %v", fullPath, err)
+		return SaveResult{}, fmt.Errorf("error creating file %s: %w", fullPath, WithFDHint(err))
 	}
 
-	_, err = io.Copy(file, reader)
+	defer file.Close()
+
+	hasher := newContentHasher(opts.Size)
+	written, err := writeContent(file, io.TeeReader(reader, hasher), opts)
 	if err != nil {
-		return fmt.Errorf("error copying content to file %s: %v", fullPath, err)
+		return SaveResult{}, fmt.Errorf("error writing file %s: %v", fullPath, err)
 	}
 
-	defer file.Close()
+	result := SaveResult{Path: fullPath, Written: written}
+	result.SHA256, result.BlobSHA = hasher.sums(written)
+	if result.BlobSHA == "" {
+		// The size wasn't known up front, so the blob header can only be computed now
+		if result.BlobSHA, err = ComputeBlobSHA(fullPath); err != nil {
+			return SaveResult{}, fmt.Errorf("error hashing file %s: %v", fullPath, err)
+		}
+	}
+
+	return result, nil
+}
+
+// RemoveLinks removes the symbolic links on the way from outputDir, or the working directory
+// when empty, down to fullPath, so that saving fullPath can't write through a link an earlier
+// download recreated. Paths outside outputDir are left alone.
+func RemoveLinks(outputDir, fullPath string) error {
+	root := outputDir
+	if root == "" {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			return fmt.Errorf("error getting current working directory: %v", err)
+		}
+	}
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+
+	current := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if err != nil {
+			// Nothing exists below a missing path, so there are no links left to find
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(current); err != nil {
+				return fmt.Errorf("error replacing symlink %s: %v", current, err)
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// ApplyMode gives a saved file the git file mode it has in the repository: "100755" makes it
+// executable and "120000" turns it, holding the link's target as raw downloads of links do,
+// into a symbolic link to that target. Other modes leave it as it is. Where links can't be
+// created, as on Windows without the privilege, the error is returned and the file is kept.
+func ApplyMode(name, mode string) error {
+	switch mode {
+	case "100755":
+		return os.Chmod(name, 0o755)
+	case "120000":
+		target, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		// The link is made beside the file and renamed over it, so a failure keeps the file
+		tmp := name + ".repo-pack-link"
+		os.Remove(tmp)
+		if err := os.Symlink(string(target), tmp); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, name); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
 	return nil
 }
+
+// writeContent copies reader into file, honouring the sparse and preallocation options
+func writeContent(file *os.File, reader io.Reader, opts SaveOptions) (int64, error) {
+	if opts.Sparse {
+		sparse := &sparseWriter{file: file}
+		written, err := CopyBuffered(sparse, reader)
+		if err != nil {
+			return written, err
+		}
+		return written, sparse.finish()
+	}
+
+	// Preallocating would allocate the blocks a sparse write leaves as holes, so only do it here
+	if opts.Size > 0 {
+		if err := preallocate(file, opts.Size); err != nil {
+			return 0, err
+		}
+	}
+
+	written, err := CopyBuffered(file, reader)
+	if err != nil {
+		return written, err
+	}
+
+	if opts.Size > 0 && written != opts.Size {
+		if err := file.Truncate(written); err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
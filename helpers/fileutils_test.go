@@ -0,0 +1,107 @@
+package helpers_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"strings"
+	"testing"
+)
+
+func TestOutputPath(t *testing.T) {
+	cases := []struct {
+		baseDir  string
+		filePath string
+		expected string
+	}{
+		{"lua", ".config/nvim/lua/init.lua", "lua/init.lua"},
+		{".", "docs/README.md", "docs/README.md"},
+		{"file.go", "path/file.go", "file.go"},
+		{"README.md", "README.md", "README.md"},
+	}
+
+	for _, c := range cases {
+		got, err := helpers.OutputPath("/out", c.baseDir, c.filePath)
+		if err != nil {
+			t.Errorf("%s in %s: unexpected error: %v", c.filePath, c.baseDir, err)
+			continue
+		}
+		if expected := filepath.Join("/out", c.expected); got != expected {
+			t.Errorf("%s in %s: expected %s, got %s", c.filePath, c.baseDir, expected, got)
+		}
+	}
+}
+
+func TestApplyMode(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	link := filepath.Join(dir, "readme")
+	for name, content := range map[string]string{script: "#!/bin/sh\n", link: "../README.md"} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := helpers.ApplyMode(script, "100755"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info, _ := os.Stat(script); info.Mode().Perm() != 0o755 {
+		t.Errorf("expected run.sh to be executable, got %s", info.Mode())
+	}
+
+	if err := helpers.ApplyMode(link, "120000"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if target, err := os.Readlink(link); err != nil || target != "../README.md" {
+		t.Errorf("expected readme to link to ../README.md, got %q (%v)", target, err)
+	}
+	// Git hashes a link by its target, so a recreated link still matches the listing
+	if sha, err := helpers.ComputeBlobSHA(link); err != nil || sha != "32d46ee883b58d6a383eed06eb98f33aa6530ded" {
+		t.Errorf("expected the blob SHA of the link's target, got %s (%v)", sha, err)
+	}
+}
+
+func TestSaveFileReplacesLinks(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "outside")
+	if err := os.WriteFile(outside, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Left by an earlier download, when docs/a.md was a link
+	if err := os.Symlink(outside, filepath.Join(dir, "docs", "a.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := helpers.SaveFile("docs", "docs/a.md", io.NopCloser(strings.NewReader("new")), helpers.SaveOptions{Size: 3, OutputDir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "keep" {
+		t.Errorf("expected the link's target to be left alone, got %q", data)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "docs", "a.md")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("expected docs/a.md to be a regular file again, got %v (%v)", info, err)
+	}
+}
+
+func TestSaveFileBlobSHA(t *testing.T) {
+	// git hash-object of "hello\n"
+	const blobSHA = "ce013625030ba8dba906f756967f9e9ca394464a"
+	dir := t.TempDir()
+	for _, size := range []int64{6, -1, 4} {
+		result, err := helpers.SaveFile("", "hello.txt", io.NopCloser(strings.NewReader("hello\n")), helpers.SaveOptions{Size: size, OutputDir: dir})
+		if err != nil {
+			t.Fatalf("size %d: unexpected error: %v", size, err)
+		}
+		if result.BlobSHA != blobSHA || result.Written != 6 {
+			t.Errorf("size %d: expected blob SHA %s of 6 bytes, got %s of %d", size, blobSHA, result.BlobSHA, result.Written)
+		}
+		if data, _ := os.ReadFile(result.Path); string(data) != "hello\n" {
+			t.Errorf("size %d: expected the content to be saved, got %q", size, data)
+		}
+	}
+}
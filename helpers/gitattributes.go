@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// LFSAttributes holds the filter rules of one .gitattributes file: which paths it routes
+// through Git LFS and which it takes back out
+type LFSAttributes struct {
+	dir   string
+	rules []lfsRule
+}
+
+type lfsRule struct {
+	glob Glob
+	lfs  bool
+}
+
+// ParseGitattributes reads the filter rules of the .gitattributes file in the repository
+// directory dir, empty for the root. Lines setting filter=lfs route their pattern through LFS;
+// lines setting another filter or unsetting it take it back out. Macros, quoted patterns and
+// patterns for directories, which git doesn't apply attributes to, are ignored.
+func ParseGitattributes(dir string, data []byte) LFSAttributes {
+	attrs := LFSAttributes{dir: strings.Trim(dir, "/")}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") ||
+			strings.HasPrefix(fields[0], `"`) || strings.HasSuffix(fields[0], "/") {
+			continue
+		}
+		var lfs, set bool
+		for _, attr := range fields[1:] {
+			switch {
+			case attr == "filter=lfs":
+				lfs, set = true, true
+			case strings.HasPrefix(attr, "filter="), attr == "-filter", attr == "!filter":
+				lfs, set = false, true
+			}
+		}
+		if !set {
+			continue
+		}
+		glob, err := ParseGlob(fields[0])
+		if err != nil {
+			continue
+		}
+		attrs.rules = append(attrs.rules, lfsRule{glob: glob, lfs: lfs})
+	}
+	return attrs
+}
+
+// match reports whether the last rule matching filePath routes it through LFS, and whether
+// any rule matched it at all
+func (a LFSAttributes) match(filePath string) (lfs, matched bool) {
+	rel := filePath
+	if a.dir != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(filePath, a.dir+"/"); !ok {
+			return false, false
+		}
+	}
+	// Unlike --include and --exclude patterns, attributes apply to files, not directories
+	segments := strings.Split(path.Clean(rel), "/")
+	for i := len(a.rules) - 1; i >= 0; i-- {
+		if matchSegments(a.rules[i].glob.segments, segments) {
+			return a.rules[i].lfs, true
+		}
+	}
+	return false, false
+}
+
+// LFSTracked reports whether .gitattributes files route the repository file filePath through
+// Git LFS. attrs are given from the root down, as rules in deeper directories take precedence.
+func LFSTracked(attrs []LFSAttributes, filePath string) bool {
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if lfs, matched := attrs[i].match(filePath); matched {
+			return lfs
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"repo-pack/helpers"
+)
+
+func TestLFSTracked(t *testing.T) {
+	root := helpers.ParseGitattributes("", []byte(`# large files
+*.psd filter=lfs diff=lfs merge=lfs -text
+assets/** filter=lfs diff=lfs merge=lfs -text
+assets/*.txt -filter
+"quoted name.bin" filter=lfs
+[attr]binary -diff -merge -text
+*.md text eol=lf
+`))
+	models := helpers.ParseGitattributes("models", []byte("*.onnx filter=lfs\n/small.onnx !filter\n"))
+	attrs := []helpers.LFSAttributes{root, models}
+
+	tests := []struct {
+		path string
+		lfs  bool
+	}{
+		{"art/logo.psd", true},
+		{"logo.psd", true},
+		{"assets/video/intro.mp4", true},
+		{"assets/notes.txt", false},
+		{"assets/sub/notes.txt", true},
+		{"README.md", false},
+		{"quoted name.bin", false},
+		{"models/net.onnx", true},
+		{"models/small.onnx", false},
+		{"models/sub/small.onnx", true},
+		{"net.onnx", false},
+		{"art.psd/readme.txt", false},
+	}
+	for _, tt := range tests {
+		if got := helpers.LFSTracked(attrs, tt.path); got != tt.lfs {
+			t.Errorf("%s: expected tracked %v, got %v", tt.path, tt.lfs, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// Glob is a gitignore-style pattern matched against paths relative to the downloaded directory.
+// A pattern without a slash matches a file or directory name at any depth, one containing a
+// slash is anchored to the directory, ** matches any number of directories and a trailing
+// slash only matches directories. Matching a directory matches every file beneath it.
+type Glob struct {
+	segments []string
+	dirOnly  bool
+}
+
+// ParseGlob parses a gitignore-style pattern such as *.go, /docs or testdata/**
+func ParseGlob(pattern string) (Glob, error) {
+	trimmed := strings.TrimSpace(pattern)
+	if strings.HasPrefix(trimmed, "!") {
+		return Glob{}, fmt.Errorf("negated pattern %q is not supported, use --include and --exclude instead", pattern)
+	}
+
+	var g Glob
+	trimmed, g.dirOnly = strings.CutSuffix(trimmed, "/")
+	anchored := strings.Contains(trimmed, "/")
+	trimmed = strings.TrimPrefix(trimmed, "/")
+	if trimmed == "" {
+		return Glob{}, fmt.Errorf("empty pattern %q", pattern)
+	}
+
+	g.segments = strings.Split(trimmed, "/")
+	if !anchored {
+		g.segments = append([]string{"**"}, g.segments...)
+	}
+	for _, segment := range g.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return Glob{}, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return g, nil
+}
+
+// ParseGlobs parses each pattern with ParseGlob
+func ParseGlobs(patterns []string) ([]Glob, error) {
+	globs := make([]Glob, 0, len(patterns))
+	for _, pattern := range patterns {
+		g, err := ParseGlob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
+}
+
+// Match reports whether the file at rel, relative to the downloaded directory, or one of
+// its parent directories matches the pattern
+func (g Glob) Match(rel string) bool {
+	segments := strings.Split(rel, "/")
+	last := len(segments)
+	if g.dirOnly {
+		last--
+	}
+	for n := 1; n <= last; n++ {
+		if matchSegments(g.segments, segments[:n]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], segments[0])
+	return ok && matchSegments(pattern[1:], segments[1:])
+}
+
+func matchAny(globs []Glob, rel string) bool {
+	for _, g := range globs {
+		if g.Match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterGlobs keeps the files below root matching at least one include pattern, or all of
+// them when there are none, and no exclude pattern
+func FilterGlobs(files []model.FileInfo, root string, includes, excludes []Glob) (kept []model.FileInfo, filtered int) {
+	if len(includes) == 0 && len(excludes) == 0 {
+		return files, 0
+	}
+
+	prefix := strings.Trim(root, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	kept = make([]model.FileInfo, 0, len(files))
+	for _, file := range files {
+		rel := strings.TrimPrefix(file.Path, prefix)
+		if (len(includes) > 0 && !matchAny(includes, rel)) || matchAny(excludes, rel) {
+			filtered++
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept, filtered
+}
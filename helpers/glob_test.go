@@ -0,0 +1,63 @@
+package helpers_test
+
+import (
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/tool/main.go", true},
+		{"*.go", "main.go.orig", false},
+		{"testdata", "pkg/testdata/input.txt", true},
+		{"testdata/**", "testdata/a/b.txt", true},
+		{"testdata/**", "pkg/testdata/b.txt", false},
+		{"/docs", "docs/index.md", true},
+		{"/docs", "api/docs/index.md", false},
+		{"**/fixtures/*.json", "a/b/fixtures/x.json", true},
+		{"build/", "build/out.bin", true},
+		{"build/", "build", false},
+		{"src/*.ts", "src/app/index.ts", false},
+	}
+
+	for _, test := range tests {
+		g, err := helpers.ParseGlob(test.pattern)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", test.pattern, err)
+		}
+		if got := g.Match(test.path); got != test.match {
+			t.Errorf("expected %q matching %q to be %v", test.pattern, test.path, test.match)
+		}
+	}
+
+	if _, err := helpers.ParseGlob("!*.go"); err == nil {
+		t.Errorf("expected negated patterns to be rejected")
+	}
+	if _, err := helpers.ParseGlob("[a-"); err == nil {
+		t.Errorf("expected malformed patterns to be rejected")
+	}
+}
+
+func TestFilterGlobs(t *testing.T) {
+	files := []model.FileInfo{
+		{Path: "repo/main.go"},
+		{Path: "repo/README.md"},
+		{Path: "repo/testdata/golden.go"},
+		{Path: "repo/pkg/util.go"},
+	}
+	includes, _ := helpers.ParseGlobs([]string{"*.go"})
+	excludes, _ := helpers.ParseGlobs([]string{"testdata/**"})
+
+	kept, filtered := helpers.FilterGlobs(files, "repo", includes, excludes)
+	expected := []model.FileInfo{{Path: "repo/main.go"}, {Path: "repo/pkg/util.go"}}
+	if !reflect.DeepEqual(kept, expected) || filtered != 2 {
+		t.Errorf("expected %+v with 2 filtered, got %+v with %d filtered", expected, kept, filtered)
+	}
+}
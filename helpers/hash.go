@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"os"
+)
+
+// contentHasher computes the SHA-256 and git blob SHA-1 of content as it is written
+type contentHasher struct {
+	sha256 hash.Hash
+	blob   hash.Hash
+	size   int64
+}
+
+// newContentHasher prepares hashers for content of the given size. The git blob hash
+// needs the length up front, so it is only computed when size is known.
+func newContentHasher(size int64) *contentHasher {
+	h := &contentHasher{sha256: sha256.New(), size: size}
+	if size >= 0 {
+		h.blob = sha1.New()
+		fmt.Fprintf(h.blob, "blob %d\x00", size)
+	}
+	return h
+}
+
+func (h *contentHasher) Write(p []byte) (int, error) {
+	h.sha256.Write(p)
+	if h.blob != nil {
+		h.blob.Write(p)
+	}
+	return len(p), nil
+}
+
+// sums returns the hex digests for content of the given written length. The blob SHA is
+// empty when the length differs from the size the header was computed for.
+func (h *contentHasher) sums(written int64) (sha256Sum, blobSHA string) {
+	sha256Sum = hex.EncodeToString(h.sha256.Sum(nil))
+	if h.blob != nil && written == h.size {
+		blobSHA = hex.EncodeToString(h.blob.Sum(nil))
+	}
+	return sha256Sum, blobSHA
+}
+
+// ComputeBlobSHA computes the git blob SHA-1 of a file already on disk. A symbolic link is
+// hashed as git stores it, by its target.
+func ComputeBlobSHA(path string) (string, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return "", err
+		}
+		h := sha1.New()
+		fmt.Fprintf(h, "blob %d\x00%s", len(target), target)
+		return hex.EncodeToString(h.Sum(nil)), nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", info.Size())
+	if _, err := CopyBuffered(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,25 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestComputeBlobSHA(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Matches `git hash-object hello.txt`
+	expected := "ce013625030ba8dba906f756967f9e9ca394464a"
+	sha, err := helpers.ComputeBlobSHA(path)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if sha != expected {
+		t.Errorf("expected blob SHA: %s, got: %s", expected, sha)
+	}
+}
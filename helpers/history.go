@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HistoryFile is the file past downloads are recorded in, one JSON object per line
+const HistoryFile = "history.jsonl"
+
+// HistoryEntry records one download, with what is needed to run it again
+type HistoryEntry struct {
+	Time time.Time `json:"time"`
+	// Command and Args are the subcommand and its arguments, with secrets such as tokens removed
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	// Dir is the working directory the download ran in
+	Dir    string `json:"dir"`
+	URL    string `json:"url"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	Output string `json:"output,omitempty"`
+	// Result is "ok", or the error the download ended with
+	Result string `json:"result"`
+}
+
+// AppendHistory adds an entry to the history file at path, creating it if needed. Each entry
+// is a single small write, so concurrent runs don't interleave their lines.
+func AppendHistory(path string, entry HistoryEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating history directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening history: %v", err)
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing history: %v", err)
+	}
+	return file.Close()
+}
+
+// ReadHistory returns the entries of the history file at path, oldest first. A missing file
+// is an empty history, and lines that don't parse, such as one cut short by a crash, are skipped.
+func ReadHistory(path string) ([]HistoryEntry, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening history: %v", err)
+	}
+	defer file.Close()
+
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,54 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"testing"
+	"time"
+)
+
+func TestHistoryAppendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", helpers.HistoryFile)
+
+	entries, err := helpers.ReadHistory(path)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected an empty history before the first run, got %v (%v)", entries, err)
+	}
+
+	first := helpers.HistoryEntry{
+		Time:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Command: "get",
+		Args:    []string{"--concurrency", "4", "https://github.com/o/r/tree/main/docs"},
+		Dir:     "/work",
+		URL:     "https://github.com/o/r/tree/main/docs",
+		Ref:     "main",
+		Commit:  "abc123",
+		Output:  ".",
+		Result:  "ok",
+	}
+	second := first
+	second.Result = "2 files failed"
+	for _, entry := range []helpers.HistoryEntry{first, second} {
+		if err := helpers.AppendHistory(path, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A line cut short by a crash is skipped rather than failing the whole history
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2024-05`)
+	file.Close()
+
+	entries, err = helpers.ReadHistory(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []helpers.HistoryEntry{first, second}; !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries %+v, got %+v", expected, entries)
+	}
+}
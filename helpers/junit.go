@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// ReportFormats are the formats --report writes
+var ReportFormats = []string{"junit"}
+
+// ParseReport splits a --report value such as junit:report.xml into its format and path
+func ParseReport(value string) (format, path string, err error) {
+	format, path, ok := strings.Cut(value, ":")
+	if !ok || path == "" {
+		return "", "", fmt.Errorf("%q should be format:path, e.g. junit:report.xml", value)
+	}
+	if format != "junit" {
+		return "", "", fmt.Errorf("unknown report format %q, expected one of %s", format, strings.Join(ReportFormats, ", "))
+	}
+	return format, path, nil
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+// WriteJUnit writes events as a JUnit XML report with one test case per file, named suite,
+// so CI systems show which files failed. Failed files are failures carrying their error and
+// category, and skipped files are skipped test cases. Test cases are in path order, whatever
+// order the downloads finished in.
+func WriteJUnit(w io.Writer, suite string, events []FileEvent) error {
+	events = slices.Clone(events)
+	slices.SortStableFunc(events, func(a, b FileEvent) int { return strings.Compare(a.Path, b.Path) })
+	s := junitSuite{Name: suite, Tests: len(events), Cases: make([]junitCase, len(events))}
+	for i, event := range events {
+		c := junitCase{ClassName: suite, Name: event.Path}
+		switch event.Status {
+		case "failed":
+			c.Failure = &junitFailure{Message: event.Error, Type: event.Category, Text: event.Error}
+			s.Failures++
+		case "skipped":
+			c.Skipped = &junitSkipped{Message: event.Error}
+			s.Skipped++
+		}
+		s.Cases[i] = c
+	}
+
+	report := junitSuites{Name: "repo-pack", Tests: s.Tests, Failures: s.Failures, Skipped: s.Skipped, Suites: []junitSuite{s}}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,47 @@
+package helpers_test
+
+import (
+	"bytes"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	events := helpers.NewEventLog(nil)
+	events.Write(helpers.FileEvent{Path: "dir/c.go", Status: "skipped", Error: "blocked by GitHub"})
+	events.Write(helpers.FileEvent{Path: "dir/b.go", Status: "failed", Error: "HTTP 404 for dir/b.go", Category: "not_found"})
+	events.Write(helpers.FileEvent{Path: "dir/a.go", Status: "downloaded", Bytes: 12})
+
+	var buf bytes.Buffer
+	if err := helpers.WriteJUnit(&buf, "o/r/dir", events.Events()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="repo-pack" tests="3" failures="1" skipped="1">
+  <testsuite name="o/r/dir" tests="3" failures="1" skipped="1">
+    <testcase classname="o/r/dir" name="dir/a.go"></testcase>
+    <testcase classname="o/r/dir" name="dir/b.go">
+      <failure message="HTTP 404 for dir/b.go" type="not_found">HTTP 404 for dir/b.go</failure>
+    </testcase>
+    <testcase classname="o/r/dir" name="dir/c.go">
+      <skipped message="blocked by GitHub"></skipped>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if buf.String() != expected {
+		t.Errorf("expected report:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestParseReport(t *testing.T) {
+	format, path, err := helpers.ParseReport("junit:out/report.xml")
+	if err != nil || format != "junit" || path != "out/report.xml" {
+		t.Errorf("expected junit and out/report.xml, got %q, %q, %v", format, path, err)
+	}
+	for _, value := range []string{"junit", "junit:", "sarif:out.sarif"} {
+		if _, _, err := helpers.ParseReport(value); err == nil {
+			t.Errorf("expected %q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"repo-pack/model"
+)
+
+// DownloadManifest is the file recording the blob SHA of every file downloaded into a directory
+const DownloadManifest = ".repo-pack-manifest.json"
+
+// Manifest records the blob SHA each downloaded file had, so a later download into the same
+// directory only fetches the files that changed since
+type Manifest struct {
+	// Repository is the owner/repo the files came from
+	Repository string `json:"repository"`
+	// Files maps repository paths to blob SHAs
+	Files map[string]string `json:"files"`
+}
+
+// manifestRepository names the repository of a download as manifests record it
+func manifestRepository(components model.RepoURLComponents) string {
+	return components.Owner + "/" + components.Repository
+}
+
+// LoadManifest reads the manifest in outputDir. A missing manifest, or one recorded for another
+// repository, is returned empty for the repository of components.
+func LoadManifest(outputDir string, components model.RepoURLComponents) (Manifest, error) {
+	m := Manifest{Repository: manifestRepository(components), Files: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(outputDir, DownloadManifest))
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return m, fmt.Errorf("error reading %s: %v", DownloadManifest, err)
+	}
+
+	var recorded Manifest
+	if err := json.Unmarshal(data, &recorded); err != nil {
+		return m, fmt.Errorf("invalid %s: %v", DownloadManifest, err)
+	}
+	if recorded.Repository != m.Repository || recorded.Files == nil {
+		return m, nil
+	}
+	return recorded, nil
+}
+
+// Changed returns the files whose listed blob SHA differs from the recorded one, or whose
+// local copy is gone, along with how many are unchanged. Files without a SHA always count as changed.
+func (m Manifest) Changed(outputDir string, components model.RepoURLComponents, files []model.FileInfo) ([]model.FileInfo, int) {
+	baseDir := filepath.Base(components.OutputRoot())
+	var changed []model.FileInfo
+	unchanged := 0
+	for _, file := range files {
+		if file.SHA != "" && m.Files[file.Path] == file.SHA {
+			if local, err := OutputPath(outputDir, baseDir, file.Path); err == nil {
+				if _, err := os.Stat(local); err == nil {
+					unchanged++
+					continue
+				}
+			}
+		}
+		changed = append(changed, file)
+	}
+	return changed, unchanged
+}
+
+// Record notes the blob SHA of downloaded files, forgetting those failed so they are retried
+func (m *Manifest) Record(downloaded, failed []model.FileInfo) {
+	for _, file := range downloaded {
+		if file.SHA != "" {
+			m.Files[file.Path] = file.SHA
+		}
+	}
+	for _, file := range failed {
+		delete(m.Files, file.Path)
+	}
+}
+
+// SaveManifest writes m to outputDir, replacing any manifest in one step. An empty manifest
+// removes the file instead, so single-file downloads, which have no blob SHA, don't leave one.
+func SaveManifest(outputDir string, m Manifest) error {
+	manifest := filepath.Join(outputDir, DownloadManifest)
+	if len(m.Files) == 0 {
+		if err := os.Remove(manifest); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := manifest + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", DownloadManifest, err)
+	}
+	return os.Rename(tmp, manifest)
+}
@@ -0,0 +1,59 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestManifestSkipsUnchangedFiles(t *testing.T) {
+	out := t.TempDir()
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "docs"}
+	files := []model.FileInfo{
+		{Path: "docs/a.md", SHA: "aaa"},
+		{Path: "docs/b.md", SHA: "bbb"},
+		{Path: "docs/c.md", SHA: "ccc"},
+	}
+	for _, name := range []string{"a.md", "b.md"} {
+		os.MkdirAll(filepath.Join(out, "docs"), 0o755)
+		if err := os.WriteFile(filepath.Join(out, "docs", name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := helpers.LoadManifest(out, components)
+	if err != nil {
+		t.Fatalf("unexpected error loading a missing manifest: %v", err)
+	}
+	m.Record(files[:2], files[2:])
+	if err := helpers.SaveManifest(out, m); err != nil {
+		t.Fatal(err)
+	}
+
+	// b changed upstream and c failed last time, so only a is skipped
+	files[1].SHA = "bbb2"
+	m, err = helpers.LoadManifest(out, components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, unchanged := m.Changed(out, components, files)
+	if expected := files[1:]; !reflect.DeepEqual(changed, expected) || unchanged != 1 {
+		t.Errorf("expected %v to have changed with 1 unchanged, got %v with %d", expected, changed, unchanged)
+	}
+
+	// A deleted local copy is fetched again
+	os.Remove(filepath.Join(out, "docs", "a.md"))
+	if changed, _ := m.Changed(out, components, files); len(changed) != 3 {
+		t.Errorf("expected the deleted file to count as changed, got %v", changed)
+	}
+
+	// Another repository's manifest doesn't apply
+	other := components
+	other.Repository = "fork"
+	if m, _ := helpers.LoadManifest(out, other); len(m.Files) != 0 {
+		t.Errorf("expected an empty manifest for another repository, got %v", m.Files)
+	}
+}
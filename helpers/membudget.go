@@ -0,0 +1,40 @@
+package helpers
+
+import "sync"
+
+// MemoryBudget tracks how many bytes of response bodies are currently held in memory
+type MemoryBudget struct {
+	mu    sync.Mutex
+	limit int64
+	used  int64
+}
+
+// NewMemoryBudget creates a budget allowing up to limit bytes of in-flight buffers
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit}
+}
+
+// TryAcquire reserves n bytes if they fit in the remaining budget and reports whether it did.
+// A nil budget never grants memory, so callers fall back to streaming.
+func (b *MemoryBudget) TryAcquire(n int64) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.used+n > b.limit {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+// Release returns n previously acquired bytes to the budget
+func (b *MemoryBudget) Release(n int64) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+}
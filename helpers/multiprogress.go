@@ -0,0 +1,122 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// multiBarRefreshInterval is how often the multi-line display is redrawn
+const multiBarRefreshInterval = 200 * time.Millisecond
+
+// multiBarMaxLines caps the downloads listed at once, so wide worker pools still fit a screen
+const multiBarMaxLines = 20
+
+// multiBarNameWidth caps the width of listed paths, so lines don't wrap and break redrawing
+const multiBarNameWidth = 50
+
+// MultiBar draws one line per active download, with its name, bytes and speed, above a line
+// with the overall progress. It redraws in place, so it is only meant for terminals.
+type MultiBar struct {
+	// Out receives the display; os.Stdout when nil
+	Out io.Writer
+
+	mu        sync.Mutex
+	bytes     *ByteProgress
+	cur       int64
+	total     int64
+	startTime time.Time
+	lines     int
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+// Config starts drawing the progress of total files, whose bytes and active downloads are
+// tracked by bytes
+func (m *MultiBar) Config(total int64, bytes *ByteProgress) {
+	if m.Out == nil {
+		m.Out = os.Stdout
+	}
+	m.total = total
+	m.bytes = bytes
+	m.startTime = time.Now()
+	m.stop = make(chan struct{})
+	m.stopped = make(chan struct{})
+	go m.refresh(m.stop, m.stopped)
+}
+
+// refresh redraws the display on a ticker until stop is closed
+func (m *MultiBar) refresh(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(multiBarRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			m.draw()
+			m.mu.Unlock()
+		}
+	}
+}
+
+// Increment marks one more file as completed
+func (m *MultiBar) Increment() {
+	m.mu.Lock()
+	m.cur++
+	m.mu.Unlock()
+}
+
+// draw replaces the lines drawn last time with the current progress
+func (m *MultiBar) draw() {
+	var b strings.Builder
+	active := m.bytes.Active()
+	for i, file := range active {
+		if i == multiBarMaxLines {
+			fmt.Fprintf(&b, "    … and %d more\n", len(active)-i)
+			break
+		}
+		size := "?"
+		if file.Size >= 0 {
+			size = FormatByteSize(file.Size)
+		}
+		fmt.Fprintf(&b, "    %-*s %10s / %-10s %10s/s\n", multiBarNameWidth, shortenPath(file.Path, multiBarNameWidth),
+			FormatByteSize(file.Done), size, FormatByteSize(averageRate(file.Done, file.Elapsed)))
+	}
+
+	done, total := m.bytes.Snapshot()
+	percent := 0
+	if total > 0 {
+		percent = int(done * 100 / total)
+	}
+	elapsed := time.Since(m.startTime)
+	fmt.Fprintf(&b, "[-] Total: %3d%% %s/%s %s/s %d/%d files %s", percent, FormatByteSize(done), FormatByteSize(total),
+		FormatByteSize(averageRate(done, elapsed)), m.cur, m.total, formatClock(elapsed))
+
+	ClearLines(m.Out, m.lines)
+	fmt.Fprint(m.Out, b.String())
+	m.lines = strings.Count(b.String(), "\n") + 1
+}
+
+// Finish stops redrawing and replaces the display with a final total line
+func (m *MultiBar) Finish() {
+	if m.stop != nil {
+		close(m.stop)
+		<-m.stopped
+		m.stop = nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ClearLines(m.Out, m.lines)
+	m.lines = 0
+	done, _ := m.bytes.Snapshot()
+	elapsed := time.Since(m.startTime)
+	fmt.Fprintf(m.Out, "[-] Total: 100%% %s %d/%d files  Time: %s  Avg: %s/s\n", FormatByteSize(done), m.cur, m.total,
+		elapsed.String(), FormatByteSize(averageRate(done, elapsed)))
+}
@@ -0,0 +1,76 @@
+package helpers
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// ParsePatternList splits a comma-separated list of glob patterns, dropping empty entries
+func ParsePatternList(list string) []string {
+	patterns := []string{}
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// matchRank returns the index of the first pattern matching the file's path or base name,
+// or len(patterns) when none match
+func matchRank(file string, patterns []string) int {
+	base := path.Base(file)
+	for i, pattern := range patterns {
+		if ok, _ := path.Match(pattern, base); ok {
+			return i
+		}
+		if ok, _ := path.Match(pattern, file); ok {
+			return i
+		}
+	}
+	return len(patterns)
+}
+
+// PrioritizeFiles reorders files so that those matching the priority patterns come first,
+// in pattern order. Files matching no pattern keep their original relative order.
+func PrioritizeFiles(files []model.FileInfo, patterns []string) []model.FileInfo {
+	if len(patterns) == 0 {
+		return files
+	}
+
+	ranks := make(map[string]int, len(files))
+	for _, file := range files {
+		ranks[file.Path] = matchRank(file.Path, patterns)
+	}
+
+	sorted := make([]model.FileInfo, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return ranks[sorted[i].Path] < ranks[sorted[j].Path]
+	})
+	return sorted
+}
+
+// GroupByDirectory reorders files so that files sharing a directory are scheduled together.
+// Directories keep the order in which they first appear, as do files within each directory,
+// so an interrupted run leaves whole subdirectories complete rather than scattered files.
+func GroupByDirectory(files []model.FileInfo) []model.FileInfo {
+	dirOrder := map[string]int{}
+	for _, file := range files {
+		dir := path.Dir(file.Path)
+		if _, ok := dirOrder[dir]; !ok {
+			dirOrder[dir] = len(dirOrder)
+		}
+	}
+
+	grouped := make([]model.FileInfo, len(files))
+	copy(grouped, files)
+	sort.SliceStable(grouped, func(i, j int) bool {
+		return dirOrder[path.Dir(grouped[i].Path)] < dirOrder[path.Dir(grouped[j].Path)]
+	})
+	return grouped
+}
@@ -0,0 +1,37 @@
+package helpers_test
+
+import (
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func fileInfos(paths ...string) []model.FileInfo {
+	files := make([]model.FileInfo, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, model.FileInfo{Path: path})
+	}
+	return files
+}
+
+func TestPrioritizeFiles(t *testing.T) {
+	files := fileInfos("dir/a.go", "dir/go.mod", "dir/sub/README.md", "dir/b.go", "dir/README")
+	patterns := helpers.ParsePatternList(" README* , go.mod,")
+	expected := fileInfos("dir/sub/README.md", "dir/README", "dir/go.mod", "dir/a.go", "dir/b.go")
+
+	prioritized := helpers.PrioritizeFiles(files, patterns)
+	if !reflect.DeepEqual(prioritized, expected) {
+		t.Errorf("expected order: %v, got: %v", expected, prioritized)
+	}
+}
+
+func TestGroupByDirectory(t *testing.T) {
+	files := fileInfos("dir/a/1", "dir/b/1", "dir/a/2", "dir/c", "dir/b/2")
+	expected := fileInfos("dir/a/1", "dir/a/2", "dir/b/1", "dir/b/2", "dir/c")
+
+	grouped := helpers.GroupByDirectory(files)
+	if !reflect.DeepEqual(grouped, expected) {
+		t.Errorf("expected order: %v, got: %v", expected, grouped)
+	}
+}
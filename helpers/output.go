@@ -0,0 +1,48 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// outputPlaceholder matches the {name} placeholders of an output directory template
+var outputPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// ExpandOutputDir fills in an output directory template such as ~/packs/{owner}/{repo}/{dir}
+// for a download. The placeholders are {owner}, {repo}, {ref} and {dir}, the directory's path
+// in the repository; a leading ~ stands for the home directory.
+func ExpandOutputDir(template string, components model.RepoURLComponents) (string, error) {
+	values := map[string]string{
+		"owner": components.Owner,
+		"repo":  components.Repository,
+		"ref":   strings.ReplaceAll(components.Ref, "/", "-"),
+		"dir":   strings.Trim(components.Dir, "/"),
+	}
+
+	var unknown []string
+	expanded := outputPlaceholder.ReplaceAllStringFunc(template, func(match string) string {
+		name := match[1 : len(match)-1]
+		value, ok := values[name]
+		if !ok {
+			unknown = append(unknown, match)
+		}
+		return value
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown placeholder %s in %q, expected {owner}, {repo}, {ref} or {dir}", unknown[0], template)
+	}
+
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error expanding ~ in %q: %v", template, err)
+		}
+		expanded = filepath.Join(home, expanded[1:])
+	}
+	return filepath.Clean(filepath.FromSlash(expanded)), nil
+}
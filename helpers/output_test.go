@@ -0,0 +1,39 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestExpandOutputDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skip("no home directory")
+	}
+	components := model.RepoURLComponents{Owner: "JazzyGrim", Repository: "dotfiles", Ref: "release/1.0", Dir: ".config/nvim"}
+
+	tests := []struct {
+		template, expected string
+	}{
+		{"~/packs/{owner}/{repo}/{dir}", filepath.Join(home, "packs", "JazzyGrim", "dotfiles", ".config", "nvim")},
+		{"out/{repo}@{ref}", filepath.Join("out", "dotfiles@release-1.0")},
+		{"vendor", "vendor"},
+	}
+	for _, tt := range tests {
+		got, err := helpers.ExpandOutputDir(tt.template, components)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.template, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.template, tt.expected, got)
+		}
+	}
+
+	if _, err := helpers.ExpandOutputDir("out/{branch}", components); err == nil {
+		t.Errorf("expected an unknown placeholder to be rejected")
+	}
+}
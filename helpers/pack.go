@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// PackEntry is one file of a concatenated pack
+type PackEntry struct {
+	Path    string
+	Content []byte
+	// Tokens is the estimated token count of Content
+	Tokens int
+	// Truncated is set when Content was cut short to fit a token budget
+	Truncated bool
+}
+
+// PackTokens totals the estimated tokens of entries
+func PackTokens(entries []PackEntry) int {
+	total := 0
+	for _, entry := range entries {
+		total += entry.Tokens
+	}
+	return total
+}
+
+// WritePack concatenates files into a single Markdown document, each under a header with its
+// path, size and estimated tokens and fenced as a code block, ready to paste into an LLM prompt
+func WritePack(w io.Writer, title string, entries []PackEntry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n\n~%d tokens in %d files\n", title, PackTokens(entries), len(entries))
+	for _, entry := range entries {
+		fence := codeFence(entry.Content)
+		details := fmt.Sprintf("%s, ~%d tokens", FormatByteSize(int64(len(entry.Content))), entry.Tokens)
+		if entry.Truncated {
+			details += ", truncated"
+		}
+		fmt.Fprintf(bw, "\n## %s (%s)\n\n%s%s\n", entry.Path, details,
+			fence, strings.TrimPrefix(path.Ext(entry.Path), "."))
+		bw.Write(entry.Content)
+		if len(entry.Content) > 0 && entry.Content[len(entry.Content)-1] != '\n' {
+			bw.WriteByte('\n')
+		}
+		fmt.Fprintf(bw, "%s\n", fence)
+	}
+	return bw.Flush()
+}
+
+// codeFence returns a backtick fence longer than any backtick run in content, so content
+// that itself contains fenced blocks (e.g. Markdown) can't close it early
+func codeFence(content []byte) string {
+	longest, run := 0, 0
+	for _, b := range content {
+		if b == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -0,0 +1,51 @@
+package helpers_test
+
+import (
+	"bytes"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestWritePack(t *testing.T) {
+	var out bytes.Buffer
+	err := helpers.WritePack(&out, "owner/repo/docs", []helpers.PackEntry{
+		{Path: "docs/main.go", Content: []byte("package main\n"), Tokens: 4},
+		{Path: "docs/README.md", Content: []byte("```sh\nmake\n```"), Tokens: 4, Truncated: true},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "# owner/repo/docs\n\n~8 tokens in 2 files\n" +
+		"\n## docs/main.go (13 B, ~4 tokens)\n\n```go\npackage main\n```\n" +
+		"\n## docs/README.md (14 B, ~4 tokens, truncated)\n\n````md\n```sh\nmake\n```\n````\n"
+	if out.String() != expected {
+		t.Errorf("expected pack:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestFitTokenBudget(t *testing.T) {
+	entries := []helpers.PackEntry{
+		{Path: "a", Content: []byte("12345678")},
+		{Path: "b", Content: []byte("1234567890ab")},
+		{Path: "c", Content: []byte("1234")},
+	}
+
+	kept, dropped := helpers.FitTokenBudget(entries, 0, 4)
+	if len(kept) != 3 || dropped != 0 || helpers.PackTokens(kept) != 6 {
+		t.Errorf("expected every entry kept without a budget, got %d kept (%d tokens), %d dropped", len(kept), helpers.PackTokens(kept), dropped)
+	}
+
+	kept, dropped = helpers.FitTokenBudget(entries, 4, 4)
+	if len(kept) != 2 || dropped != 1 {
+		t.Fatalf("expected 2 kept and 1 dropped, got %d kept and %d dropped", len(kept), dropped)
+	}
+	if kept[0].Truncated || !kept[1].Truncated || string(kept[1].Content) != "12345678" || kept[1].Tokens != 2 {
+		t.Errorf("expected the second entry truncated to the remaining 2 tokens, got %+v", kept[1])
+	}
+
+	kept, dropped = helpers.FitTokenBudget(entries, 2, 4)
+	if len(kept) != 1 || dropped != 2 {
+		t.Errorf("expected 1 kept and 2 dropped, got %d kept and %d dropped", len(kept), dropped)
+	}
+}
@@ -3,12 +3,16 @@ package helpers
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"repo-pack/model"
 )
 
-// ParseRepoURL validates that URL is valid and then extracts user, repository, ref, and directory
+// ParseRepoURL validates that URL is valid and then extracts user, repository, ref, and directory.
+// A /blob/ URL names a single file, which is returned as FilePath with its parent as directory.
 func ParseRepoURL(urlStr string) (urlComponents model.RepoURLComponents, err error) {
 	parsedURL, err := url.Parse(urlStr)
 	if err != nil {
@@ -17,18 +21,18 @@ func ParseRepoURL(urlStr string) (urlComponents model.RepoURLComponents, err err
 	}
 
 	urlPath := parsedURL.Path
-	urlParserRegex := regexp.MustCompile(`^/([^/]+)/([^/]+)/tree/([^/]+)/(.*)`)
+	urlParserRegex := regexp.MustCompile(`^/([^/]+)/([^/]+)/(tree|blob)/([^/]+)/(.*)`)
 	match := urlParserRegex.FindStringSubmatch(urlPath)
 
-	if len(match) != 5 {
+	if len(match) != 6 || (match[3] == "blob" && strings.Trim(match[5], "/") == "") {
 		err = fmt.Errorf("invalid URL format: %s", urlStr)
 		return
 	}
 
 	owner := match[1]
 	repository := match[2]
-	ref := match[3]
-	dir := match[4]
+	ref := match[4]
+	dir := match[5]
 
 	urlComponents = model.RepoURLComponents{
 		Owner:      owner,
@@ -36,5 +40,51 @@ func ParseRepoURL(urlStr string) (urlComponents model.RepoURLComponents, err err
 		Ref:        ref,
 		Dir:        dir,
 	}
+	if match[3] == "blob" {
+		urlComponents.FilePath = strings.Trim(dir, "/")
+		urlComponents.Dir = strings.TrimSuffix(path.Dir(urlComponents.FilePath), ".")
+		urlComponents.IsFile = true
+	}
 	return urlComponents, nil
 }
+
+// ParseRepoRootURL extracts the user and repository from a bare repository URL such as
+// https://github.com/owner/repo. The ref is HEAD, which raw downloads resolve to the default branch.
+func ParseRepoRootURL(urlStr string) (model.RepoURLComponents, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return model.RepoURLComponents{}, fmt.Errorf("invalid URL: %s", urlStr)
+	}
+
+	match := regexp.MustCompile(`^/([^/]+)/([^/]+?)(?:\.git)?/?$`).FindStringSubmatch(parsedURL.Path)
+	if match == nil {
+		return model.RepoURLComponents{}, fmt.Errorf("invalid URL format: %s", urlStr)
+	}
+
+	return model.RepoURLComponents{
+		Owner:      match[1],
+		Repository: match[2],
+		Ref:        "HEAD",
+	}, nil
+}
+
+// ParsePullRequestURL extracts the user, repository and pull request number from a URL such as
+// https://github.com/owner/repo/pull/123. The ref is left empty for the caller to resolve.
+func ParsePullRequestURL(urlStr string) (model.RepoURLComponents, int, error) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return model.RepoURLComponents{}, 0, fmt.Errorf("invalid URL: %s", urlStr)
+	}
+
+	match := regexp.MustCompile(`^/([^/]+)/([^/]+)/pull/([0-9]+)(?:/.*)?$`).FindStringSubmatch(parsedURL.Path)
+	if match == nil {
+		return model.RepoURLComponents{}, 0, fmt.Errorf("invalid pull request URL format: %s", urlStr)
+	}
+
+	number, err := strconv.Atoi(match[3])
+	if err != nil {
+		return model.RepoURLComponents{}, 0, fmt.Errorf("invalid pull request number in %s", urlStr)
+	}
+
+	return model.RepoURLComponents{Owner: match[1], Repository: match[2]}, number, nil
+}
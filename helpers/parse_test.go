@@ -43,9 +43,9 @@ func TestParseRepoInvalidURL(t *testing.T) {
 }
 
 func TestParseRepoInvalidURLFormat(t *testing.T) {
-	url := "https://github.com/owner/repo/blob/main/file.txt"
+	url := "https://github.com/owner/repo/commits/main/file.txt"
 	expected := model.RepoURLComponents{}
-	expectedErr := "invalid URL format: https://github.com/owner/repo/blob/main/file.txt"
+	expectedErr := "invalid URL format: https://github.com/owner/repo/commits/main/file.txt"
 
 	components, err := helpers.ParseRepoURL(url)
 	if err == nil {
@@ -58,3 +58,64 @@ func TestParseRepoInvalidURLFormat(t *testing.T) {
 		t.Errorf("expected components: %+v, got: %+v", expected, components)
 	}
 }
+
+func TestParseRepoBlobURL(t *testing.T) {
+	cases := map[string]model.RepoURLComponents{
+		"https://github.com/owner/repo/blob/main/path/file.go": {
+			Owner: "owner", Repository: "repo", Ref: "main", Dir: "path", FilePath: "path/file.go", IsFile: true,
+		},
+		"https://github.com/owner/repo/blob/main/README.md": {
+			Owner: "owner", Repository: "repo", Ref: "main", Dir: "", FilePath: "README.md", IsFile: true,
+		},
+	}
+	for url, expected := range cases {
+		components, err := helpers.ParseRepoURL(url)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", url, err)
+		}
+		if components != expected {
+			t.Errorf("%s: expected components: %+v, got: %+v", url, expected, components)
+		}
+	}
+}
+
+func TestParseRepoRootURL(t *testing.T) {
+	expected := model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "HEAD"}
+	for _, url := range []string{
+		"https://github.com/owner/repo",
+		"https://github.com/owner/repo/",
+		"https://github.com/owner/repo.git",
+	} {
+		components, err := helpers.ParseRepoRootURL(url)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", url, err)
+		}
+		if components != expected {
+			t.Errorf("%s: expected components: %+v, got: %+v", url, expected, components)
+		}
+	}
+
+	if _, err := helpers.ParseRepoRootURL("https://github.com/owner/repo/tree/main/dir"); err == nil {
+		t.Errorf("expected error for a tree URL, got nil")
+	}
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	expected := model.RepoURLComponents{Owner: "owner", Repository: "repo"}
+	for _, url := range []string{
+		"https://github.com/owner/repo/pull/123",
+		"https://github.com/owner/repo/pull/123/files",
+	} {
+		components, number, err := helpers.ParsePullRequestURL(url)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", url, err)
+		}
+		if components != expected || number != 123 {
+			t.Errorf("%s: expected %+v #123, got: %+v #%d", url, expected, components, number)
+		}
+	}
+
+	if _, _, err := helpers.ParsePullRequestURL("https://github.com/owner/repo/pull/abc"); err == nil {
+		t.Errorf("expected error for a non-numeric pull request, got nil")
+	}
+}
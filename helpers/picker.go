@@ -0,0 +1,237 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"repo-pack/model"
+)
+
+// pickerHeight is how many files the picker lists at once
+const pickerHeight = 15
+
+// pickerNameWidth caps the width of listed paths, so long ones don't wrap and break redrawing
+const pickerNameWidth = 70
+
+// ErrPickCancelled is returned by PickFiles when the picker is closed without confirming
+var ErrPickCancelled = errors.New("file selection cancelled")
+
+// Picker holds the state of the interactive file picker: the filter typed so far, the files
+// matching it, the highlighted one and those selected
+type Picker struct {
+	files    []model.FileInfo
+	names    []string
+	filter   string
+	visible  []int
+	cursor   int
+	offset   int
+	selected map[int]bool
+}
+
+// NewPicker creates a picker over files, showing their paths relative to dir with nothing selected
+func NewPicker(files []model.FileInfo, dir string) *Picker {
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	p := &Picker{files: files, names: make([]string, len(files)), selected: map[int]bool{}}
+	for i, file := range files {
+		p.names[i] = strings.TrimPrefix(file.Path, prefix)
+	}
+	p.SetFilter("")
+	return p
+}
+
+// FuzzyMatch reports whether the characters of pattern appear in s in order, ignoring case,
+// so "hdlr" matches "api/handler.go"
+func FuzzyMatch(pattern, s string) bool {
+	s = strings.ToLower(s)
+	for _, r := range strings.ToLower(pattern) {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+// SetFilter lists only the files whose path fuzzily matches filter, highlighting the first.
+// Selections are kept for files the filter hides.
+func (p *Picker) SetFilter(filter string) {
+	p.filter = filter
+	p.visible = p.visible[:0]
+	for i, name := range p.names {
+		if FuzzyMatch(filter, name) {
+			p.visible = append(p.visible, i)
+		}
+	}
+	p.cursor, p.offset = 0, 0
+}
+
+// Move moves the highlight by delta files, stopping at the first and last
+func (p *Picker) Move(delta int) {
+	p.cursor = max(0, min(p.cursor+delta, len(p.visible)-1))
+}
+
+// Toggle selects the highlighted file, or deselects it when it is selected
+func (p *Picker) Toggle() {
+	if len(p.visible) == 0 {
+		return
+	}
+	i := p.visible[p.cursor]
+	p.selected[i] = !p.selected[i]
+}
+
+// ToggleAll selects every file matching the filter, or deselects them all when they already are
+func (p *Picker) ToggleAll() {
+	all := true
+	for _, i := range p.visible {
+		all = all && p.selected[i]
+	}
+	for _, i := range p.visible {
+		p.selected[i] = !all
+	}
+}
+
+// Selected returns the selected files in the order they were given
+func (p *Picker) Selected() []model.FileInfo {
+	var selected []model.FileInfo
+	for i, file := range p.files {
+		if p.selected[i] {
+			selected = append(selected, file)
+		}
+	}
+	return selected
+}
+
+// Render draws the picker, listing at most height files around the highlight, and returns the
+// number of lines drawn. The last line isn't ended, so ClearLines can erase the picker.
+func (p *Picker) Render(w io.Writer, height int) int {
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	} else if p.cursor >= p.offset+height {
+		p.offset = p.cursor - height + 1
+	}
+
+	var count int
+	var size int64
+	for _, file := range p.Selected() {
+		count++
+		size += max(file.Size, 0)
+	}
+	lines := []string{fmt.Sprintf("Select files: %s_  (%d of %d selected, %s)", p.filter, count, len(p.files), FormatByteSize(size))}
+
+	shown := p.visible[p.offset:min(p.offset+height, len(p.visible))]
+	width := 0
+	for _, i := range shown {
+		width = max(width, utf8.RuneCountInString(shortenPath(p.names[i], pickerNameWidth)))
+	}
+	for row, i := range shown {
+		cursor, check := " ", "[ ]"
+		if p.offset+row == p.cursor {
+			cursor = ">"
+		}
+		if p.selected[i] {
+			check = "[x]"
+		}
+		name := shortenPath(p.names[i], pickerNameWidth)
+		padding := strings.Repeat(" ", width-utf8.RuneCountInString(name))
+		lines = append(lines, fmt.Sprintf("%s %s %s%s  %10s", cursor, check, name, padding, FormatByteSize(max(p.files[i].Size, 0))))
+	}
+	switch {
+	case len(p.visible) == 0:
+		lines = append(lines, "  no files match")
+	case len(p.visible) > len(shown):
+		lines = append(lines, fmt.Sprintf("  %d of %d matching files shown", len(shown), len(p.visible)))
+	}
+	lines = append(lines, "↑/↓ move  space select  ctrl-a select all matching  enter download  esc cancel")
+
+	fmt.Fprint(w, strings.Join(lines, "\n"))
+	return len(lines)
+}
+
+// pickerAction is what a key press asks of the picker loop
+type pickerAction int
+
+const (
+	pickContinue pickerAction = iota
+	pickDone
+	pickCancel
+)
+
+// handleKey applies the keys read in one go from the terminal to the picker
+func (p *Picker) handleKey(key []byte) pickerAction {
+	switch k := string(key); k {
+	case "\r", "\n":
+		return pickDone
+	case "\x03", "\x1b":
+		return pickCancel
+	case "\x1b[A", "\x1bOA", "\x10":
+		p.Move(-1)
+	case "\x1b[B", "\x1bOB", "\x0e":
+		p.Move(1)
+	case "\x1b[5~":
+		p.Move(-pickerHeight)
+	case "\x1b[6~":
+		p.Move(pickerHeight)
+	case " ", "\t":
+		p.Toggle()
+	case "\x01":
+		p.ToggleAll()
+	case "\x7f", "\x08":
+		if _, size := utf8.DecodeLastRuneInString(p.filter); size > 0 {
+			p.SetFilter(p.filter[:len(p.filter)-size])
+		}
+	default:
+		// Other escape sequences, such as function keys, are ignored
+		if strings.HasPrefix(k, "\x1b") {
+			break
+		}
+		filter := p.filter
+		for _, r := range k {
+			if unicode.IsPrint(r) {
+				filter += string(r)
+			}
+		}
+		p.SetFilter(filter)
+	}
+	return pickContinue
+}
+
+// PickFiles lets the user choose among files in a terminal UI on in and out, with fuzzy
+// filtering by typing, and returns those selected. The picker is erased once closed.
+func PickFiles(in, out *os.File, files []model.FileInfo, dir string) ([]model.FileInfo, error) {
+	restore, err := MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+	HideCursor(out)
+	defer ShowCursor(out)
+
+	p := NewPicker(files, dir)
+	lines := p.Render(out, pickerHeight)
+	buf := make([]byte, 256)
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			ClearLines(out, lines)
+			return nil, err
+		}
+		action := p.handleKey(buf[:n])
+		ClearLines(out, lines)
+		switch action {
+		case pickDone:
+			return p.Selected(), nil
+		case pickCancel:
+			return nil, ErrPickCancelled
+		}
+		lines = p.Render(out, pickerHeight)
+	}
+}
@@ -0,0 +1,64 @@
+package helpers_test
+
+import (
+	"bytes"
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"strings"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		expected   bool
+	}{
+		{"", "api/handler.go", true},
+		{"hdlr", "api/handler.go", true},
+		{"API/H", "api/handler.go", true},
+		{"gohandler", "api/handler.go", false},
+		{"hh", "api/handler.go", false},
+	}
+	for _, tt := range tests {
+		if got := helpers.FuzzyMatch(tt.pattern, tt.s); got != tt.expected {
+			t.Errorf("FuzzyMatch(%q, %q) = %v, expected %v", tt.pattern, tt.s, got, tt.expected)
+		}
+	}
+}
+
+func TestPickerSelection(t *testing.T) {
+	files := []model.FileInfo{
+		{Path: "docs/a.md", Size: 10},
+		{Path: "docs/api/handler.go", Size: 2048},
+		{Path: "docs/api/handler_test.go", Size: 100},
+		{Path: "docs/b.md", Size: 20},
+	}
+	p := helpers.NewPicker(files, "docs")
+
+	p.Move(1)
+	p.Toggle()
+	p.SetFilter("md")
+	p.ToggleAll()
+	if expected := []model.FileInfo{files[0], files[1], files[3]}; !reflect.DeepEqual(p.Selected(), expected) {
+		t.Errorf("expected selections to survive filtering, got %v", p.Selected())
+	}
+
+	// Toggling all when every match is selected clears them instead
+	p.ToggleAll()
+	if expected := []model.FileInfo{files[1]}; !reflect.DeepEqual(p.Selected(), expected) {
+		t.Errorf("expected only the file hidden by the filter to stay selected, got %v", p.Selected())
+	}
+
+	var out bytes.Buffer
+	p.SetFilter("hdlr")
+	lines := p.Render(&out, 1)
+	if lines != 4 {
+		t.Errorf("expected a header, one file, a count and the key help, got %d lines:\n%s", lines, out.String())
+	}
+	for _, expected := range []string{"(1 of 4 selected, 2.00 KB)", "> [x] api/handler.go", "1 of 2 matching files shown"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected %q in the picker, got:\n%s", expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// PlaceholderManifest is the file recording what placeholders stand for, written next to them
+const PlaceholderManifest = ".repo-pack-placeholders.json"
+
+// Placeholders describes a lazily materialized download: the repository it came from and the
+// files whose content hasn't been fetched yet
+type Placeholders struct {
+	Components model.RepoURLComponents `json:"components"`
+	Files      []model.FileInfo        `json:"files"`
+}
+
+// WritePlaceholders creates an empty file in dir for each file not already present, where a
+// download would save it, and records them in the manifest for FindPlaceholders to pick up
+func WritePlaceholders(dir string, p Placeholders) error {
+	baseDir := filepath.Base(p.Components.OutputRoot())
+	for _, file := range p.Files {
+		dst, err := OutputPath(dir, baseDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return fmt.Errorf("error creating output folder for %s: %v", dst, err)
+		}
+		placeholder, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error creating placeholder %s: %v", dst, err)
+		}
+		placeholder.Close()
+	}
+	return SavePlaceholders(dir, p)
+}
+
+// SavePlaceholders rewrites the manifest in dir, removing it once no placeholders are left
+func SavePlaceholders(dir string, p Placeholders) error {
+	manifest := filepath.Join(dir, PlaceholderManifest)
+	if len(p.Files) == 0 {
+		if err := os.Remove(manifest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := manifest + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", PlaceholderManifest, err)
+	}
+	return os.Rename(tmp, manifest)
+}
+
+// FindPlaceholders looks for a manifest in the directory of path and each of its parents,
+// returning the directory holding it
+func FindPlaceholders(path string) (string, Placeholders, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", Placeholders{}, err
+	}
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		data, err := os.ReadFile(filepath.Join(dir, PlaceholderManifest))
+		if err == nil {
+			var p Placeholders
+			if err := json.Unmarshal(data, &p); err != nil {
+				return "", Placeholders{}, fmt.Errorf("invalid %s in %s: %v", PlaceholderManifest, dir, err)
+			}
+			return dir, p, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", Placeholders{}, err
+		}
+		if filepath.Dir(dir) == dir {
+			return "", Placeholders{}, fmt.Errorf("no %s found above %s", PlaceholderManifest, path)
+		}
+	}
+}
+
+// Select splits the placeholders into those saved at target, a file or directory below dir,
+// and the rest
+func (p Placeholders) Select(dir, target string) (selected, rest []model.FileInfo, err error) {
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	baseDir := filepath.Base(p.Components.OutputRoot())
+	for _, file := range p.Files {
+		dst, err := OutputPath(dir, baseDir, file.Path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dst == abs || strings.HasPrefix(dst, abs+string(filepath.Separator)) {
+			selected = append(selected, file)
+		} else {
+			rest = append(rest, file)
+		}
+	}
+	return selected, rest, nil
+}
@@ -0,0 +1,56 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestPlaceholdersRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	files := []model.FileInfo{
+		{Path: "data/raw/a.csv", Size: 10, SHA: "aaa"},
+		{Path: "data/raw/b.csv", Size: 20, SHA: "bbb"},
+		{Path: "data/README.md", Size: 5, SHA: "ccc"},
+	}
+	p := helpers.Placeholders{
+		Components: model.RepoURLComponents{Owner: "owner", Repository: "repo", Ref: "main", Dir: "data"},
+		Files:      files,
+	}
+	if err := helpers.WritePlaceholders(dir, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "data", "raw", "a.csv"))
+	if err != nil || info.Size() != 0 {
+		t.Fatalf("expected an empty placeholder, got %v, %v", info, err)
+	}
+
+	found, loaded, err := helpers.FindPlaceholders(filepath.Join(dir, "data", "raw"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if found != dir || !reflect.DeepEqual(loaded, p) {
+		t.Errorf("expected manifest in %s with %+v, got %s with %+v", dir, p, found, loaded)
+	}
+
+	selected, rest, err := loaded.Select(found, filepath.Join(dir, "data", "raw"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(selected, files[:2]) || !reflect.DeepEqual(rest, files[2:]) {
+		t.Errorf("expected the raw directory's files to be selected, got %+v and %+v", selected, rest)
+	}
+
+	// The manifest goes away with the last placeholder
+	loaded.Files = nil
+	if err := helpers.SavePlaceholders(found, loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, helpers.PlaceholderManifest)); !os.IsNotExist(err) {
+		t.Errorf("expected the manifest to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// preallocate reserves size bytes for file with fallocate, falling back to Truncate on
+// filesystems that don't support it
+func preallocate(file *os.File, size int64) error {
+	err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return file.Truncate(size)
+	}
+	return err
+}
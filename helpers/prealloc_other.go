@@ -0,0 +1,11 @@
+//go:build !linux
+
+package helpers
+
+import "os"
+
+// preallocate does nothing, as only Linux reserves blocks for a file ahead of writing. Extending
+// the file with Truncate would leave it sparse, reserving nothing either.
+func preallocate(file *os.File, size int64) error {
+	return nil
+}
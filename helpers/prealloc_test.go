@@ -0,0 +1,31 @@
+package helpers_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestSaveFilePreallocates(t *testing.T) {
+	content := bytes.Repeat([]byte("preallocated\n"), 5000)
+	length := int64(len(content))
+	dir := t.TempDir()
+
+	// The file is reserved at the expected size and cut back or grown to what arrived
+	for _, size := range []int64{length, length + 4096, length - 1000} {
+		result, err := helpers.SaveFile("", "data.txt", io.NopCloser(bytes.NewReader(content)), helpers.SaveOptions{Size: size, OutputDir: dir})
+		if err != nil {
+			t.Fatalf("size %d: unexpected error: %v", size, err)
+		}
+		info, err := os.Stat(result.Path)
+		if err != nil || info.Size() != length {
+			t.Errorf("size %d: expected a file of %d bytes, got %v (%v)", size, length, info, err)
+			continue
+		}
+		if data, _ := os.ReadFile(result.Path); !bytes.Equal(data, content) {
+			t.Errorf("size %d: content doesn't round trip", size)
+		}
+	}
+}
@@ -2,11 +2,27 @@ package helpers
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
+// barRefreshInterval is how often the bar repaints on its own, so elapsed time, speed and
+// ETA keep moving while downloads stall
+const barRefreshInterval = 500 * time.Millisecond
+
 type Bar struct {
+	// Out receives the bar; os.Stdout when nil. Writers other than a terminal get one line per
+	// refresh instead of a line redrawn in place.
+	Out io.Writer
+
+	mu          sync.Mutex
+	interactive bool
+	lastDraw    time.Time
+	stop        chan struct{}
+	stopped     chan struct{}
 	startTime   time.Time
 	rate        string
 	graph       string
@@ -15,6 +31,7 @@ type Bar struct {
 	Cur         int64
 	total       int64
 	width       int
+	bytes       *ByteProgress
 }
 
 func (bar *Bar) Config(start, total int64, description string) {
@@ -25,22 +42,79 @@ func (bar *Bar) Config(start, total int64, description string) {
 	bar.description = description
 	bar.startTime = time.Now()
 	bar.updateRate()
+	if bar.Out == nil {
+		bar.Out = os.Stdout
+	}
+	bar.interactive = isTerminal(bar.Out)
+
+	bar.stop = make(chan struct{})
+	bar.stopped = make(chan struct{})
+	go bar.refresh(bar.stop, bar.stopped)
+}
+
+// refresh repaints the bar on a ticker until stop is closed
+func (bar *Bar) refresh(stop <-chan struct{}, stopped chan<- struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(barRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			bar.mu.Lock()
+			bar.lastDraw = time.Time{}
+			bar.Play(bar.Cur)
+			bar.mu.Unlock()
+		}
+	}
+}
+
+// TrackBytes makes the bar report progress in bytes rather than completed files
+func (bar *Bar) TrackBytes(bytes *ByteProgress) {
+	bar.mu.Lock()
+	defer bar.mu.Unlock()
+	bar.bytes = bytes
+	bar.percent = bar.getPercent()
+	bar.updateRate()
+}
+
+func (bar *Bar) fraction() float64 {
+	if bar.bytes != nil {
+		done, total := bar.bytes.Snapshot()
+		if total == 0 {
+			return 0
+		}
+		return float64(done) / float64(total)
+	}
+	if bar.total == 0 {
+		return 0
+	}
+	return float64(bar.Cur) / float64(bar.total)
 }
 
 func (bar *Bar) getPercent() int64 {
-	return int64((float64(bar.Cur) / float64(bar.total)) * 100)
+	return int64(bar.fraction() * 100)
 }
 
 func (bar *Bar) updateRate() {
-	completedWidth := int((float64(bar.Cur) / float64(bar.total)) * float64(bar.width))
+	completedWidth := min(int(bar.fraction()*float64(bar.width)), bar.width)
 	bar.rate = strings.Repeat(bar.graph, completedWidth) + strings.Repeat(" ", bar.width-completedWidth)
 }
 
 func (bar *Bar) Update(cur int64) {
-	bar.Cur = cur
+	bar.mu.Lock()
+	defer bar.mu.Unlock()
 	bar.Play(cur)
 }
 
+// Increment marks one more file as completed and redraws the bar
+func (bar *Bar) Increment() {
+	bar.mu.Lock()
+	defer bar.mu.Unlock()
+	bar.Play(bar.Cur + 1)
+}
+
 func (bar *Bar) Play(cur int64) {
 	bar.Cur = cur
 	lastPercent := bar.percent
@@ -48,13 +122,98 @@ func (bar *Bar) Play(cur int64) {
 	if bar.percent != lastPercent {
 		bar.updateRate()
 	}
+	// Log files would otherwise get a line per completed file
+	if !bar.interactive && time.Since(bar.lastDraw) < barRefreshInterval {
+		return
+	}
+	bar.lastDraw = time.Now()
+
 	elapsedTime := time.Since(bar.startTime)
+	timing := fmt.Sprintf("%s ETA %s", formatClock(elapsedTime), bar.eta(elapsedTime))
+	if bar.bytes != nil {
+		done, total := bar.bytes.Snapshot()
+		bytesPerSec := averageRate(done, elapsedTime)
+		bar.draw("%s |%-50s| %3d%% %s/%s %s/s %d/%d files %s ", bar.description, bar.rate, bar.percent,
+			FormatByteSize(done), FormatByteSize(total), FormatByteSize(bytesPerSec), bar.Cur, bar.total, timing)
+		return
+	}
 	itemsPerSec := float64(bar.Cur) / elapsedTime.Seconds()
-	fmt.Printf("\r%s |%-50s| %3d%% %3d/%d %.2f it/s", bar.description, bar.rate, bar.percent, bar.Cur, bar.total, itemsPerSec)
+	bar.draw("%s |%-50s| %3d%% %3d/%d %.2f it/s %s ", bar.description, bar.rate, bar.percent, bar.Cur, bar.total, itemsPerSec, timing)
 }
 
+// averageRate returns the bytes per second of n bytes transferred over elapsed
+func averageRate(n int64, elapsed time.Duration) int64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return int64(float64(n) / elapsed.Seconds())
+}
+
+// draw writes a progress line, redrawing it in place on a terminal
+func (bar *Bar) draw(format string, args ...any) {
+	if bar.interactive {
+		fmt.Fprintf(bar.Out, "\r"+format, args...)
+		return
+	}
+	fmt.Fprintf(bar.Out, strings.TrimRight(format, " ")+"\n", args...)
+}
+
+// drawFinal writes the last progress line, ending it with a newline
+func (bar *Bar) drawFinal(format string, args ...any) {
+	if bar.interactive {
+		format = "\r" + format
+	}
+	fmt.Fprintf(bar.Out, format+"\n", args...)
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// eta extrapolates the time left from the progress made so far, or "--:--" before there is any
+func (bar *Bar) eta(elapsed time.Duration) string {
+	fraction := bar.fraction()
+	if fraction <= 0 {
+		return "--:--"
+	}
+	if fraction >= 1 {
+		return formatClock(0)
+	}
+	return formatClock(time.Duration(float64(elapsed) * (1 - fraction) / fraction))
+}
+
+// formatClock formats a duration as mm:ss, or h:mm:ss from an hour up
+func formatClock(d time.Duration) string {
+	seconds := int64(d.Round(time.Second) / time.Second)
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+// Finish stops the background refresh and prints the final line
 func (bar *Bar) Finish() {
+	if bar.stop != nil {
+		close(bar.stop)
+		<-bar.stopped
+		bar.stop = nil
+	}
+
+	bar.mu.Lock()
+	defer bar.mu.Unlock()
 	bar.updateRate()
 	elapsedTime := time.Since(bar.startTime)
-	fmt.Printf("\r%s |%-20s| 100%% %3d/%d  Time: %s\n", bar.description, bar.rate, bar.total, bar.total, elapsedTime.String())
+	if bar.bytes != nil {
+		done, _ := bar.bytes.Snapshot()
+		bar.drawFinal("%s |%-50s| 100%% %s %d/%d files  Time: %s  Avg: %s/s", bar.description, bar.rate,
+			FormatByteSize(done), bar.Cur, bar.total, elapsedTime.String(), FormatByteSize(averageRate(done, elapsedTime)))
+		return
+	}
+	bar.drawFinal("%s |%-20s| 100%% %3d/%d  Time: %s", bar.description, bar.rate, bar.total, bar.total, elapsedTime.String())
 }
@@ -0,0 +1,52 @@
+package helpers_test
+
+import (
+	"bytes"
+	"repo-pack/helpers"
+	"strings"
+	"testing"
+)
+
+func TestBarWritesLinesToNonTerminal(t *testing.T) {
+	var out bytes.Buffer
+	bar := &helpers.Bar{Out: &out}
+	bar.Config(0, 3, "[-] Progress: ")
+	for i := 0; i < 3; i++ {
+		bar.Increment()
+	}
+	bar.Finish()
+
+	if strings.Contains(out.String(), "\r") {
+		t.Errorf("expected no carriage returns outside a terminal, got %q", out.String())
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	// Increments within one refresh interval are coalesced into a single line
+	if len(lines) != 2 {
+		t.Fatalf("expected a progress line and a final line, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], "[-] Progress: ") || !strings.Contains(lines[1], "100%") {
+		t.Errorf("unexpected final line: %q", lines[1])
+	}
+}
+
+func TestBarReportsBytesAndThroughput(t *testing.T) {
+	progress := helpers.NewByteProgress()
+	progress.Expect("a.bin", 2048)
+
+	var out bytes.Buffer
+	bar := &helpers.Bar{Out: &out}
+	bar.Config(0, 1, "[-] Progress: ")
+	bar.TrackBytes(progress)
+	progress.Add(2048)
+	bar.Increment()
+	bar.Finish()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	first, last := lines[0], lines[len(lines)-1]
+	if !strings.Contains(first, "2.00 KB/2.00 KB") || !strings.Contains(first, "/s") {
+		t.Errorf("expected bytes done, total and throughput, got %q", first)
+	}
+	if !strings.Contains(last, "1/1 files") || !strings.Contains(last, "Avg: ") {
+		t.Errorf("expected file count and average throughput on the final line, got %q", last)
+	}
+}
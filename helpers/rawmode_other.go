@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package helpers
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// makeRaw isn't implemented for this platform's consoles, so interactive features are unavailable
+func makeRaw(int) (func() error, error) {
+	return nil, fmt.Errorf("interactive terminals aren't supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,37 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package helpers
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func makeRaw(fd int) (func() error, error) {
+	var saved syscall.Termios
+	if err := termios(fd, ioctlGetTermios, &saved); err != nil {
+		return nil, err
+	}
+
+	// As cfmakeraw, but keeping output processing so "\n" still returns the carriage
+	raw := saved
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := termios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() error {
+		return termios(fd, ioctlSetTermios, &saved)
+	}, nil
+}
+
+func termios(fd int, request uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
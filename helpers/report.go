@@ -0,0 +1,100 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"repo-pack/model"
+)
+
+// Outcome is what happened to one file of a download
+type Outcome int
+
+const (
+	// Downloaded files were fetched over the network
+	Downloaded Outcome = iota
+	// Skipped files weren't fetched, e.g. because the cache already held them
+	Skipped
+	// Failed files couldn't be saved
+	Failed
+)
+
+// DirReport tallies the outcomes of the files under one top-level subdirectory
+type DirReport struct {
+	Dir        string
+	Downloaded int
+	Skipped    int
+	Failed     int
+	// Bytes is the size of the files saved, whether downloaded or skipped
+	Bytes int64
+}
+
+// Report collects per-file outcomes grouped by top-level subdirectory. It is safe for
+// concurrent use by download workers.
+type Report struct {
+	mu   sync.Mutex
+	key  func(file model.FileInfo) string
+	dirs map[string]*DirReport
+}
+
+// NewReport creates an empty report for a download of root
+func NewReport(root string) *Report {
+	return &Report{key: ByTopLevelDir(root), dirs: map[string]*DirReport{}}
+}
+
+// Record adds the outcome of a file, with bytes the size saved
+func (r *Report) Record(file model.FileInfo, outcome Outcome, bytes int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := r.key(file)
+	dir, ok := r.dirs[name]
+	if !ok {
+		dir = &DirReport{Dir: name}
+		r.dirs[name] = dir
+	}
+	switch outcome {
+	case Downloaded:
+		dir.Downloaded++
+	case Skipped:
+		dir.Skipped++
+	case Failed:
+		dir.Failed++
+	}
+	dir.Bytes += max(bytes, 0)
+}
+
+// Dirs returns the tally of every subdirectory, sorted by name
+func (r *Report) Dirs() []DirReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	dirs := make([]DirReport, 0, len(r.dirs))
+	for _, dir := range r.dirs {
+		dirs = append(dirs, *dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].Dir < dirs[j].Dir
+	})
+	return dirs
+}
+
+// RenderReport writes a table of per-subdirectory outcomes followed by their total
+func RenderReport(w io.Writer, dirs []DirReport) {
+	total := DirReport{Dir: "TOTAL"}
+	width := len(total.Dir)
+	for _, dir := range dirs {
+		total.Downloaded += dir.Downloaded
+		total.Skipped += dir.Skipped
+		total.Failed += dir.Failed
+		total.Bytes += dir.Bytes
+		width = max(width, len(dir.Dir))
+	}
+
+	fmt.Fprintf(w, "%-*s %10s %8s %7s %12s\n", width, "DIRECTORY", "DOWNLOADED", "SKIPPED", "FAILED", "SIZE")
+	for _, dir := range append(dirs, total) {
+		fmt.Fprintf(w, "%-*s %10d %8d %7d %12s\n", width, dir.Dir, dir.Downloaded, dir.Skipped, dir.Failed, FormatByteSize(dir.Bytes))
+	}
+}
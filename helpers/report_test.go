@@ -0,0 +1,34 @@
+package helpers_test
+
+import (
+	"bytes"
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"strings"
+	"testing"
+)
+
+func TestReportGroupsByTopLevelDir(t *testing.T) {
+	report := helpers.NewReport("lua")
+	report.Record(model.FileInfo{Path: "lua/init.lua"}, helpers.Downloaded, 10)
+	report.Record(model.FileInfo{Path: "lua/plugins/a.lua"}, helpers.Downloaded, 5)
+	report.Record(model.FileInfo{Path: "lua/plugins/b.lua"}, helpers.Skipped, 7)
+	report.Record(model.FileInfo{Path: "lua/plugins/deep/c.lua"}, helpers.Failed, 0)
+
+	expected := []helpers.DirReport{
+		{Dir: ".", Downloaded: 1, Bytes: 10},
+		{Dir: "plugins/", Downloaded: 1, Skipped: 1, Failed: 1, Bytes: 12},
+	}
+	dirs := report.Dirs()
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("expected dirs: %+v, got: %+v", expected, dirs)
+	}
+
+	var out bytes.Buffer
+	helpers.RenderReport(&out, dirs)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[3], "TOTAL") || !strings.Contains(lines[3], "22 B") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
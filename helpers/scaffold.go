@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ScaffoldFile is the file in a template directory describing its variables
+const ScaffoldFile = "repopack.scaffold.yaml"
+
+// ScaffoldVar is a template variable asked for when scaffolding
+type ScaffoldVar struct {
+	Name    string
+	Prompt  string
+	Default string
+}
+
+// ScaffoldSpec describes the variables of a scaffold template
+type ScaffoldSpec struct {
+	Variables []ScaffoldVar
+}
+
+// ParseScaffold reads a scaffold spec. Only the subset of YAML the spec needs is understood:
+//
+//	variables:
+//	  - name: project
+//	    prompt: Project name
+//	    default: my-app
+func ParseScaffold(data []byte) (ScaffoldSpec, error) {
+	var spec ScaffoldSpec
+	inVariables := false
+	for i, line := range strings.Split(string(data), "\n") {
+		lineNo := i + 1
+		if trimmed := strings.TrimSpace(line); trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "-") {
+			key, value, _ := strings.Cut(line, ":")
+			if strings.TrimSpace(key) != "variables" || strings.TrimSpace(value) != "" {
+				return ScaffoldSpec{}, fmt.Errorf("line %d: unknown key %q, expected variables", lineNo, strings.TrimSpace(key))
+			}
+			inVariables = true
+			continue
+		}
+		if !inVariables {
+			return ScaffoldSpec{}, fmt.Errorf("line %d: expected variables:", lineNo)
+		}
+
+		entry := strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(entry, "-"); ok {
+			spec.Variables = append(spec.Variables, ScaffoldVar{})
+			entry = strings.TrimSpace(rest)
+		}
+		if len(spec.Variables) == 0 {
+			return ScaffoldSpec{}, fmt.Errorf("line %d: expected a list item starting with -", lineNo)
+		}
+
+		key, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			return ScaffoldSpec{}, fmt.Errorf("line %d: expected key: value", lineNo)
+		}
+		value, err := unquoteScalar(strings.TrimSpace(value))
+		if err != nil {
+			return ScaffoldSpec{}, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+
+		variable := &spec.Variables[len(spec.Variables)-1]
+		switch strings.TrimSpace(key) {
+		case "name":
+			variable.Name = value
+		case "prompt":
+			variable.Prompt = value
+		case "default":
+			variable.Default = value
+		default:
+			return ScaffoldSpec{}, fmt.Errorf("line %d: unknown variable key %q, expected name, prompt or default", lineNo, strings.TrimSpace(key))
+		}
+	}
+
+	for i, variable := range spec.Variables {
+		if variable.Name == "" {
+			return ScaffoldSpec{}, fmt.Errorf("variable %d has no name", i+1)
+		}
+	}
+	return spec, nil
+}
+
+// unquoteScalar strips YAML quotes from a scalar, and a trailing comment from a plain one
+func unquoteScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated quoted value %s", value)
+		}
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+// PromptVars asks for every variable not already in preset, offering its default. An empty
+// answer takes the default; a variable without one must be answered.
+func PromptVars(in io.Reader, out io.Writer, spec ScaffoldSpec, preset map[string]string) (map[string]string, error) {
+	vars := make(map[string]string, len(spec.Variables)+len(preset))
+	for key, value := range preset {
+		vars[key] = value
+	}
+
+	scanner := bufio.NewScanner(in)
+	for _, variable := range spec.Variables {
+		if _, ok := vars[variable.Name]; ok {
+			continue
+		}
+
+		prompt := variable.Prompt
+		if prompt == "" {
+			prompt = variable.Name
+		}
+		for {
+			if variable.Default != "" {
+				fmt.Fprintf(out, "%s [%s]: ", prompt, variable.Default)
+			} else {
+				fmt.Fprintf(out, "%s: ", prompt)
+			}
+
+			if !scanner.Scan() {
+				if variable.Default == "" {
+					return nil, fmt.Errorf("no value given for %s", variable.Name)
+				}
+				fmt.Fprintln(out)
+				vars[variable.Name] = variable.Default
+				break
+			}
+			if answer := strings.TrimSpace(scanner.Text()); answer != "" {
+				vars[variable.Name] = answer
+				break
+			}
+			if variable.Default != "" {
+				vars[variable.Name] = variable.Default
+				break
+			}
+		}
+	}
+	return vars, scanner.Err()
+}
@@ -0,0 +1,70 @@
+package helpers_test
+
+import (
+	"io"
+	"reflect"
+	"repo-pack/helpers"
+	"strings"
+	"testing"
+)
+
+func TestParseScaffold(t *testing.T) {
+	data := `# scaffold for a service
+variables:
+  - name: project
+    prompt: Project name
+    default: my-app # used for the module path
+  - name: port
+    default: "8080"
+  - name: owner
+    prompt: 'Owner''s team'
+`
+	spec, err := helpers.ParseScaffold([]byte(data))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := helpers.ScaffoldSpec{Variables: []helpers.ScaffoldVar{
+		{Name: "project", Prompt: "Project name", Default: "my-app"},
+		{Name: "port", Default: "8080"},
+		{Name: "owner", Prompt: "Owner's team"},
+	}}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Errorf("expected spec: %+v, got: %+v", expected, spec)
+	}
+}
+
+func TestParseScaffoldInvalid(t *testing.T) {
+	for _, data := range []string{
+		"vars:\n  - name: a\n",
+		"variables:\n  - nme: a\n",
+		"variables:\n  - prompt: no name\n",
+	} {
+		if _, err := helpers.ParseScaffold([]byte(data)); err == nil {
+			t.Errorf("%q: expected error, got nil", data)
+		}
+	}
+}
+
+func TestPromptVars(t *testing.T) {
+	spec := helpers.ScaffoldSpec{Variables: []helpers.ScaffoldVar{
+		{Name: "project", Prompt: "Project name", Default: "my-app"},
+		{Name: "owner"},
+		{Name: "port", Default: "8080"},
+	}}
+
+	vars, err := helpers.PromptVars(strings.NewReader("\n\nplatform\n"), io.Discard, spec, map[string]string{"port": "9000"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The blank answer for owner, which has no default, is asked again
+	expected := map[string]string{"project": "my-app", "owner": "platform", "port": "9000"}
+	if !reflect.DeepEqual(vars, expected) {
+		t.Errorf("expected vars: %v, got: %v", expected, vars)
+	}
+
+	if _, err := helpers.PromptVars(strings.NewReader(""), io.Discard, spec, nil); err == nil {
+		t.Errorf("expected error when input ends before a required answer, got nil")
+	}
+}
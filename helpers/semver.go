@@ -0,0 +1,212 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version tag such as v1.2.3 or 2.0.0-rc.1
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+// ParseVersion parses a tag as a semantic version, allowing a leading "v" and omitted
+// minor or patch numbers
+func ParseVersion(tag string) (Version, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(tag), "v")
+	var v Version
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		if s[i] == '-' {
+			v.Pre = strings.SplitN(s[i+1:], "+", 2)[0]
+		}
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return Version{}, fmt.Errorf("invalid version %q", tag)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid version %q", tag)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 as v sorts before, equal to or after o. Pre-releases sort
+// before their release; pre-release labels are compared as plain strings.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return sign(strings.Compare(v.Pre, o.Pre))
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// versionBound is a single comparison such as >=1.2.0
+type versionBound struct {
+	op      string
+	version Version
+}
+
+func (b versionBound) allows(v Version) bool {
+	c := v.Compare(b.version)
+	switch b.op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+// VersionRange selects versions satisfying every one of its bounds
+type VersionRange struct {
+	bounds []versionBound
+}
+
+// IsVersionRange reports whether a ref names a range to resolve against tags rather than a
+// literal branch, tag or commit: "latest", or an expression such as ^1.2, ~1.2.3, 1.x or >=2
+func IsVersionRange(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return ref == "latest" ||
+		strings.ContainsAny(ref[:min(1, len(ref))], "^~<>=") ||
+		strings.Contains(ref, ".x") || strings.Contains(ref, ".*") ||
+		ref == "*" || ref == "x"
+}
+
+// ParseVersionRange parses "latest" or space-separated bounds, each a caret (^1.2), tilde (~1.2),
+// wildcard (1.x) or comparison (>=1.0, <2) expression
+func ParseVersionRange(expr string) (VersionRange, error) {
+	var r VersionRange
+	expr = strings.TrimSpace(expr)
+	if expr == "latest" || expr == "*" || expr == "x" {
+		return r, nil
+	}
+
+	for _, term := range strings.Fields(expr) {
+		bounds, err := parseRangeTerm(term)
+		if err != nil {
+			return VersionRange{}, err
+		}
+		r.bounds = append(r.bounds, bounds...)
+	}
+	return r, nil
+}
+
+func parseRangeTerm(term string) ([]versionBound, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(term, op) {
+			v, err := ParseVersion(term[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			return []versionBound{{op, v}}, nil
+		}
+	}
+
+	switch term[0] {
+	case '^':
+		v, err := ParseVersion(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		// ^1.2.3 allows minor and patch updates; below 1.0 only patch updates are compatible
+		upper := Version{Major: v.Major + 1}
+		if v.Major == 0 {
+			upper = Version{Minor: v.Minor + 1}
+		}
+		return []versionBound{{">=", v}, {"<", upper}}, nil
+	case '~':
+		v, err := ParseVersion(term[1:])
+		if err != nil {
+			return nil, err
+		}
+		return []versionBound{{">=", v}, {"<", Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	}
+
+	// Wildcards and partial versions match everything sharing the given prefix
+	parts := strings.Split(strings.TrimPrefix(term, "v"), ".")
+	for len(parts) > 0 && (parts[len(parts)-1] == "x" || parts[len(parts)-1] == "*") {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 3 {
+		v, err := ParseVersion(term)
+		if err != nil {
+			return nil, err
+		}
+		return []versionBound{{"=", v}}, nil
+	}
+	if len(parts) == 0 {
+		return nil, nil
+	}
+	v, err := ParseVersion(strings.Join(parts, "."))
+	if err != nil {
+		return nil, fmt.Errorf("invalid version range %q", term)
+	}
+	upper := Version{Major: v.Major + 1}
+	if len(parts) == 2 {
+		upper = Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return []versionBound{{">=", v}, {"<", upper}}, nil
+}
+
+// Allows reports whether v satisfies the range. Pre-releases are never selected, so that
+// "latest" and open ranges only track published releases.
+func (r VersionRange) Allows(v Version) bool {
+	if v.Pre != "" {
+		return false
+	}
+	for _, bound := range r.bounds {
+		if !bound.allows(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// HighestMatchingTag returns the tag with the highest version allowed by the range, ignoring
+// tags that aren't semantic versions
+func HighestMatchingTag(tags []string, r VersionRange) (string, bool) {
+	var best string
+	var bestVersion Version
+	for _, tag := range tags {
+		v, err := ParseVersion(tag)
+		if err != nil || !r.Allows(v) {
+			continue
+		}
+		if best == "" || v.Compare(bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best, best != ""
+}
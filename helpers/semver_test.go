@@ -0,0 +1,53 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestHighestMatchingTag(t *testing.T) {
+	tags := []string{"v0.9.0", "v1.2.0", "v1.2.7", "v1.10.1", "v2.0.0-rc.1", "v2.0.0", "nightly"}
+
+	cases := []struct {
+		expr     string
+		expected string
+	}{
+		{"latest", "v2.0.0"},
+		{"^1.2", "v1.10.1"},
+		{"~1.2", "v1.2.7"},
+		{"1.x", "v1.10.1"},
+		{"1.2.x", "v1.2.7"},
+		{">=1.0 <1.10", "v1.2.7"},
+		{"^0.9", "v0.9.0"},
+		{"^3", ""},
+	}
+
+	for _, c := range cases {
+		r, err := helpers.ParseVersionRange(c.expr)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.expr, err)
+			continue
+		}
+		tag, _ := helpers.HighestMatchingTag(tags, r)
+		if tag != c.expected {
+			t.Errorf("%s: expected tag: %q, got: %q", c.expr, c.expected, tag)
+		}
+	}
+}
+
+func TestIsVersionRange(t *testing.T) {
+	for ref, expected := range map[string]bool{
+		"latest": true,
+		"^1.2":   true,
+		"~1.2.3": true,
+		"1.x":    true,
+		">=2":    true,
+		"main":   false,
+		"v1.2.3": false,
+		"feat/x": false,
+	} {
+		if got := helpers.IsVersionRange(ref); got != expected {
+			t.Errorf("%s: expected %v, got %v", ref, expected, got)
+		}
+	}
+}
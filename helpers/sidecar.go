@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// SidecarSuffix is appended to a downloaded file's name to name its provenance sidecar
+const SidecarSuffix = ".repopack.json"
+
+// Sidecar records where one downloaded file came from, for pipelines requiring per-file provenance
+type Sidecar struct {
+	// Source is the file's page on GitHub at the commit it was downloaded from
+	Source     string `json:"source"`
+	Repository string `json:"repository"`
+	Path       string `json:"path"`
+	Ref        string `json:"ref"`
+	Commit     string `json:"commit,omitempty"`
+	BlobSHA    string `json:"blob_sha,omitempty"`
+	Size       int64  `json:"size"`
+}
+
+// WriteSidecars writes a sidecar next to each of files saved under outputDir, where webBase is
+// the root of the repository's web pages, such as https://github.com. Files missing locally,
+// such as binary files --text-only removed again, are skipped. It returns how many it wrote.
+func WriteSidecars(outputDir, webBase string, components model.RepoURLComponents, files []model.FileInfo) (int, error) {
+	baseDir := filepath.Base(components.OutputRoot())
+	written := 0
+	for _, file := range files {
+		local, err := OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return written, err
+		}
+		info, err := os.Lstat(local)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return written, err
+		}
+
+		sidecar := Sidecar{
+			Source: fmt.Sprintf("%s/%s/%s/blob/%s/%s", strings.TrimSuffix(webBase, "/"),
+				components.Owner, components.Repository, components.ContentRef(), file.Path),
+			Repository: components.Owner + "/" + components.Repository,
+			Path:       file.Path,
+			Ref:        components.Ref,
+			Commit:     components.Commit,
+			BlobSHA:    file.SHA,
+			Size:       file.Size,
+		}
+		// Single-file downloads aren't listed, so only the saved copy tells their size
+		if sidecar.Size < 0 {
+			sidecar.Size = info.Size()
+		}
+		data, err := json.MarshalIndent(sidecar, "", "  ")
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(local+SidecarSuffix, append(data, '\n'), 0o644); err != nil {
+			return written, fmt.Errorf("error writing sidecar for %s: %v", file.Path, err)
+		}
+		written++
+	}
+	return written, nil
+}
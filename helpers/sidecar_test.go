@@ -0,0 +1,56 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestWriteSidecars(t *testing.T) {
+	out := t.TempDir()
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Commit: "0123456789abcdef0123456789abcdef01234567", Dir: "docs"}
+	os.MkdirAll(filepath.Join(out, "docs"), 0o755)
+	if err := os.WriteFile(filepath.Join(out, "docs", "a.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files := []model.FileInfo{
+		{Path: "docs/a.md", SHA: "ce013625030ba8dba906f756967f9e9ca394464a", Size: -1},
+		// Removed again after downloading, as --text-only does for binary files
+		{Path: "docs/image.png", SHA: "bbb", Size: 10},
+	}
+
+	count, err := helpers.WriteSidecars(out, "https://github.com/", components, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 sidecar, wrote %d", count)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "docs", "a.md"+helpers.SidecarSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sidecar helpers.Sidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		t.Fatal(err)
+	}
+	expected := helpers.Sidecar{
+		Source:     "https://github.com/o/r/blob/0123456789abcdef0123456789abcdef01234567/docs/a.md",
+		Repository: "o/r",
+		Path:       "docs/a.md",
+		Ref:        "main",
+		Commit:     "0123456789abcdef0123456789abcdef01234567",
+		BlobSHA:    "ce013625030ba8dba906f756967f9e9ca394464a",
+		Size:       6,
+	}
+	if sidecar != expected {
+		t.Errorf("expected %+v, got %+v", expected, sidecar)
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "image.png"+helpers.SidecarSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected no sidecar for a file missing locally, got: %v", err)
+	}
+}
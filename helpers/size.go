@@ -0,0 +1,53 @@
+package helpers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var byteUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+// ParseByteSize parses a human readable size such as "512", "64KB" or "1.5MB" into bytes
+func ParseByteSize(size string) (int64, error) {
+	trimmed := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(trimmed, unit.suffix) {
+			trimmed = strings.TrimSpace(strings.TrimSuffix(trimmed, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	value, err := strconv.ParseFloat(trimmed, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// FormatByteSize renders a byte count using the largest fitting binary unit
+func FormatByteSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.2f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.2f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.2f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
@@ -0,0 +1,27 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{
+		"512":   512,
+		"64KB":  64 << 10,
+		"1.5mb": 3 << 19,
+		"2 G":   2 << 30,
+	}
+	for input, expected := range cases {
+		size, err := helpers.ParseByteSize(input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %v", input, err)
+		} else if size != expected {
+			t.Errorf("expected %d for %q, got: %d", expected, input, size)
+		}
+	}
+
+	if _, err := helpers.ParseByteSize("lots"); err == nil {
+		t.Errorf("expected error for invalid size, got: nil")
+	}
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// SizeGroup totals the files sharing an extension or top-level subdirectory
+type SizeGroup struct {
+	Name  string
+	Files int
+	Bytes int64
+}
+
+// GroupSizes aggregates files by key, largest group first
+func GroupSizes(files []model.FileInfo, key func(file model.FileInfo) string) []SizeGroup {
+	groups := map[string]*SizeGroup{}
+	for _, file := range files {
+		name := key(file)
+		group, ok := groups[name]
+		if !ok {
+			group = &SizeGroup{Name: name}
+			groups[name] = group
+		}
+		group.Files++
+		group.Bytes += file.Size
+	}
+
+	sorted := make([]SizeGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, *group)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Bytes != sorted[j].Bytes {
+			return sorted[i].Bytes > sorted[j].Bytes
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// ByExtension keys files by lower-cased extension, or "(none)" when they have none
+func ByExtension(file model.FileInfo) string {
+	ext := strings.ToLower(path.Ext(file.Path))
+	if ext == "" {
+		return "(none)"
+	}
+	return ext
+}
+
+// ByTopLevelDir returns a key func grouping files by their first directory under root,
+// with files directly in root grouped as "."
+func ByTopLevelDir(root string) func(file model.FileInfo) string {
+	prefix := strings.Trim(root, "/") + "/"
+	return func(file model.FileInfo) string {
+		rel := strings.TrimPrefix(file.Path, prefix)
+		if dir, _, found := strings.Cut(rel, "/"); found {
+			return dir + "/"
+		}
+		return "."
+	}
+}
+
+// RenderSizes writes a size breakdown table with each group's share of the total
+func RenderSizes(w io.Writer, title string, groups []SizeGroup) {
+	var total int64
+	width := len(title)
+	for _, group := range groups {
+		total += group.Bytes
+		width = max(width, len(group.Name))
+	}
+
+	fmt.Fprintf(w, "%-*s %8s %12s %7s\n", width, title, "FILES", "SIZE", "SHARE")
+	for _, group := range groups {
+		share := 0.0
+		if total > 0 {
+			share = float64(group.Bytes) / float64(total) * 100
+		}
+		fmt.Fprintf(w, "%-*s %8d %12s %6.1f%%\n", width, group.Name, group.Files, FormatByteSize(group.Bytes), share)
+	}
+}
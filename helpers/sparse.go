@@ -0,0 +1,43 @@
+package helpers
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+const sparseBlockSize = 4096
+
+var zeroBlock = make([]byte, sparseBlockSize)
+
+// sparseWriter writes to a file, seeking over all-zero blocks so that filesystems
+// supporting sparse files leave holes instead of allocating them
+type sparseWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *sparseWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// Keep blocks aligned to the file offset so holes line up with filesystem blocks
+		n := min(len(p), sparseBlockSize-int(w.offset%sparseBlockSize))
+		block := p[:n]
+		if bytes.Equal(block, zeroBlock[:n]) {
+			if _, err := w.file.Seek(int64(n), io.SeekCurrent); err != nil {
+				return written, err
+			}
+		} else if _, err := w.file.Write(block); err != nil {
+			return written, err
+		}
+		w.offset += int64(n)
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// finish sets the file length, materializing any trailing hole skipped by Write
+func (w *sparseWriter) finish() error {
+	return w.file.Truncate(w.offset)
+}
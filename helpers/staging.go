@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// PromoteStaged moves every top-level entry of stagingDir into outputDir with a rename. An
+// existing entry of the same name is set aside first and removed only once its replacement
+// is in place, so readers never see a half-written pack.
+func PromoteStaged(stagingDir, outputDir string) error {
+	entries, err := os.ReadDir(stagingDir)
+	if err != nil {
+		return fmt.Errorf("error reading staging directory: %v", err)
+	}
+
+	for _, entry := range entries {
+		src := filepath.Join(stagingDir, entry.Name())
+		dst := filepath.Join(outputDir, entry.Name())
+		if err := replaceEntry(src, dst); err != nil {
+			if errors.Is(err, syscall.EXDEV) {
+				return fmt.Errorf("cannot move %s into place: the staging directory must be on the same filesystem as the output", entry.Name())
+			}
+			return fmt.Errorf("error moving %s into place: %v", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// replaceEntry renames src to dst, swapping out whatever dst held before
+func replaceEntry(src, dst string) error {
+	if _, err := os.Lstat(dst); os.IsNotExist(err) {
+		return os.Rename(src, dst)
+	}
+
+	old := dst + ".repo-pack-old"
+	if err := os.RemoveAll(old); err != nil {
+		return err
+	}
+	if err := os.Rename(dst, old); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		// Put the previous content back rather than leave nothing in place
+		os.Rename(old, dst)
+		return err
+	}
+	return os.RemoveAll(old)
+}
@@ -0,0 +1,41 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestPromoteStagedReplacesExisting(t *testing.T) {
+	staging := t.TempDir()
+	output := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(staging, "lua", "plugins"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, "lua", "plugins", "init.lua"), []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(output, "lua"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(output, "lua", "stale.lua"), []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := helpers.PromoteStaged(staging, output); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(output, "lua", "plugins", "init.lua"))
+	if err != nil || string(data) != "new" {
+		t.Errorf("expected promoted content %q, got %q (%v)", "new", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "lua", "stale.lua")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous directory to be replaced, stat err: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(output, "lua.repo-pack-old")); !os.IsNotExist(err) {
+		t.Errorf("expected the previous directory to be removed, stat err: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// ChecksumsFile is the name of the checksum listing written at the root of a download
+const ChecksumsFile = "SHA256SUMS"
+
+// FileChecksum is the SHA-256 of a downloaded file, by its path relative to the download's root
+type FileChecksum struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// DownloadRoot returns the local directory a download of components is saved under: the
+// directory's own for directories, and outputDir for whole repositories and single files
+func DownloadRoot(outputDir string, components model.RepoURLComponents) string {
+	if components.IsFile {
+		return outputDir
+	}
+	return syncRoot(outputDir, components)
+}
+
+// ComputeChecksums hashes the files saved under outputDir, sorted by path. Files that aren't
+// there, such as templates rendered under another name, are passed over.
+func ComputeChecksums(outputDir string, components model.RepoURLComponents, files []model.FileInfo) ([]FileChecksum, error) {
+	baseDir := filepath.Base(components.OutputRoot())
+	root, err := filepath.Abs(DownloadRoot(outputDir, components))
+	if err != nil {
+		return nil, err
+	}
+	sums := make([]FileChecksum, 0, len(files))
+	for _, file := range files {
+		local, err := OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Lstat(local)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		// sha256sum would check whatever a link points to, which is listed on its own if downloaded
+		if info.Mode()&fs.ModeSymlink != 0 {
+			continue
+		}
+		sum, err := fileSHA256(local)
+		if err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(local)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, abs)
+		if err != nil {
+			return nil, err
+		}
+		sums = append(sums, FileChecksum{Path: filepath.ToSlash(rel), Size: info.Size(), SHA256: sum})
+	}
+	sort.Slice(sums, func(i, j int) bool { return sums[i].Path < sums[j].Path })
+	return sums, nil
+}
+
+// WriteSHA256Sums writes checksums to name in the format of sha256sum, so that sha256sum -c
+// checks them from the download's root. Names with a backslash or newline are escaped the way
+// sha256sum escapes them.
+func WriteSHA256Sums(name string, sums []FileChecksum) error {
+	var b strings.Builder
+	for _, sum := range sums {
+		if strings.ContainsAny(sum.Path, "\\\n") {
+			escaped := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(sum.Path)
+			fmt.Fprintf(&b, "\\%s  %s\n", sum.SHA256, escaped)
+			continue
+		}
+		fmt.Fprintf(&b, "%s  %s\n", sum.SHA256, sum.Path)
+	}
+	return writeReplacing(name, []byte(b.String()))
+}
+
+// checksumManifest is the JSON form of a download's checksums
+type checksumManifest struct {
+	Repository string         `json:"repository"`
+	Ref        string         `json:"ref"`
+	Commit     string         `json:"commit,omitempty"`
+	Dir        string         `json:"dir,omitempty"`
+	Files      []FileChecksum `json:"files"`
+}
+
+// WriteChecksumManifest writes checksums to name as JSON, along with the repository, ref and
+// commit they were downloaded from
+func WriteChecksumManifest(name string, components model.RepoURLComponents, sums []FileChecksum) error {
+	data, err := json.MarshalIndent(checksumManifest{
+		Repository: components.Owner + "/" + components.Repository,
+		Ref:        components.Ref,
+		Commit:     components.Commit,
+		Dir:        components.Dir,
+		Files:      sums,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeReplacing(name, append(data, '\n'))
+}
+
+// writeReplacing writes data to name through a temporary file, replacing it in one step
+func writeReplacing(name string, data []byte) error {
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	return os.Rename(tmp, name)
+}
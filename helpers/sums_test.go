@@ -0,0 +1,80 @@
+package helpers_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+func TestComputeChecksums(t *testing.T) {
+	outputDir := t.TempDir()
+	for name, content := range map[string]string{"docs/b.md": "b\n", "docs/sub/a.md": "a\n"} {
+		local := filepath.Join(outputDir, name)
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(local, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("b.md", filepath.Join(outputDir, "docs", "link.md")); err != nil {
+		t.Fatal(err)
+	}
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "project/docs"}
+	files := []model.FileInfo{{Path: "project/docs/sub/a.md"}, {Path: "project/docs/b.md"}, {Path: "project/docs/link.md"}, {Path: "project/docs/missing.md"}}
+	sums, err := helpers.ComputeChecksums(outputDir, components, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []helpers.FileChecksum{
+		{Path: "b.md", Size: 2, SHA256: "0263829989b6fd954f72baaf2fc64bc2e2f01d692d4de72986ea808f6e99813f"},
+		{Path: "sub/a.md", Size: 2, SHA256: "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7"},
+	}
+	if !reflect.DeepEqual(sums, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, sums)
+	}
+
+	sumsFile := filepath.Join(helpers.DownloadRoot(outputDir, components), helpers.ChecksumsFile)
+	if err := helpers.WriteSHA256Sums(sumsFile, sums); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(sumsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "0263829989b6fd954f72baaf2fc64bc2e2f01d692d4de72986ea808f6e99813f  b.md\n" +
+		"87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7  sub/a.md\n"
+	if string(data) != want {
+		t.Errorf("expected %q, got %q", want, data)
+	}
+	// The written listing reads back the way --verify-upstream reads upstream ones
+	parsed, err := helpers.ParseChecksums(helpers.ChecksumsFile, data)
+	if err != nil || len(parsed) != 2 || parsed["sub/a.md"] != expected[1].SHA256 {
+		t.Errorf("expected the listing to parse back, got %v (%v)", parsed, err)
+	}
+
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	components.Commit = "abc"
+	if err := helpers.WriteChecksumManifest(manifest, components, sums); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var decoded struct {
+		Repository, Ref, Commit, Dir string
+		Files                        []helpers.FileChecksum
+	}
+	if data, err = os.ReadFile(manifest); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Repository != "o/r" || decoded.Commit != "abc" || !reflect.DeepEqual(decoded.Files, expected) {
+		t.Errorf("unexpected manifest: %s", data)
+	}
+}
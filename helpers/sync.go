@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"repo-pack/model"
+)
+
+// SyncPlan is what it takes to make a local directory mirror a remote one
+type SyncPlan struct {
+	// Download holds the files missing locally or whose local content differs
+	Download []model.FileInfo
+	// Unchanged counts the files whose local copy already matches the listing
+	Unchanged int
+	// Remove holds the local files the remote directory doesn't have, by repository path
+	Remove []model.FileInfo
+}
+
+// syncRoot returns the local directory a download of components is saved under
+func syncRoot(outputDir string, components model.RepoURLComponents) string {
+	if components.Dir == "" {
+		return outputDir
+	}
+	return filepath.Join(outputDir, path.Base(components.Dir))
+}
+
+// PlanSync compares the local copy of components.Dir in outputDir with the remote listing.
+// files, which may be filtered, are downloaded unless their local copy has the listed blob SHA;
+// local files absent from listed, the unfiltered listing, are to be removed. Sidecars go with the
+// files they describe. A repository root is saved straight into outputDir, which may hold files
+// of its own, so there only files the download manifest records as downloaded are removed.
+func PlanSync(outputDir string, components model.RepoURLComponents, listed, files []model.FileInfo) (SyncPlan, error) {
+	var plan SyncPlan
+	baseDir := filepath.Base(components.OutputRoot())
+	for _, file := range files {
+		local, err := OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return SyncPlan{}, err
+		}
+		if file.SHA != "" {
+			if sha, err := ComputeBlobSHA(local); err == nil && sha == file.SHA {
+				plan.Unchanged++
+				continue
+			}
+		}
+		plan.Download = append(plan.Download, file)
+	}
+
+	remote := make(map[string]bool, len(listed))
+	for _, file := range listed {
+		remote[file.Path] = true
+	}
+	var downloaded map[string]string
+	if components.Dir == "" {
+		manifest, err := LoadManifest(outputDir, components)
+		if err != nil {
+			return SyncPlan{}, err
+		}
+		downloaded = manifest.Files
+	}
+	planned := make(map[string]bool)
+	root := syncRoot(outputDir, components)
+	err := filepath.WalkDir(root, func(local string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && local == root {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() || entry.Name() == PlaceholderManifest || entry.Name() == DownloadManifest {
+			return err
+		}
+		rel, err := filepath.Rel(root, local)
+		if err != nil {
+			return err
+		}
+		repoPath := path.Join(components.Dir, filepath.ToSlash(rel))
+		if remote[repoPath] {
+			return nil
+		}
+		// A sidecar is rewritten along with its file, and removed with it when the file goes
+		if described, ok := strings.CutSuffix(repoPath, SidecarSuffix); ok {
+			if remote[described] {
+				return nil
+			}
+			repoPath = described
+		}
+		if _, ok := downloaded[repoPath]; components.Dir == "" && !ok {
+			return nil
+		}
+		if !planned[repoPath] {
+			planned[repoPath] = true
+			plan.Remove = append(plan.Remove, model.FileInfo{Path: repoPath})
+		}
+		return nil
+	})
+	if err != nil {
+		return SyncPlan{}, fmt.Errorf("error scanning %s: %v", root, err)
+	}
+	return plan, nil
+}
+
+// RemoveSynced deletes the local copies of files planned for removal and their sidecars, along
+// with directories left empty by it
+func RemoveSynced(outputDir string, components model.RepoURLComponents, files []model.FileInfo) error {
+	root := syncRoot(outputDir, components)
+	baseDir := filepath.Base(components.OutputRoot())
+	for _, file := range files {
+		local, err := OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(local); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing %s: %v", local, err)
+		}
+		os.Remove(local + SidecarSuffix)
+		// Removing a non-empty directory fails, which ends the climb
+		for dir := filepath.Dir(local); dir != root && dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+			if os.Remove(dir) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
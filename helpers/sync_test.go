@@ -0,0 +1,105 @@
+package helpers_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"testing"
+)
+
+func TestPlanAndRemoveSync(t *testing.T) {
+	out := t.TempDir()
+	for path, content := range map[string]string{
+		"docs/same.md":                     "same\n",
+		"docs/changed.md":                  "old\n",
+		"docs/gone/stale.md":               "stale\n",
+		"docs/kept.log":                    "filtered out, but still listed\n",
+		"docs/same.md.repopack.json":       "{}\n",
+		"docs/gone/stale.md.repopack.json": "{}\n",
+		"docs/orphan.md.repopack.json":     "{}\n",
+	} {
+		full := filepath.Join(out, filepath.FromSlash(path))
+		os.MkdirAll(filepath.Dir(full), 0o755)
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sameSHA, err := helpers.ComputeBlobSHA(filepath.Join(out, "docs", "same.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main", Dir: "docs"}
+	files := []model.FileInfo{
+		{Path: "docs/same.md", SHA: sameSHA},
+		{Path: "docs/changed.md", SHA: "0000000000000000000000000000000000000000"},
+		{Path: "docs/new.md", SHA: "1111111111111111111111111111111111111111"},
+	}
+	listed := append(files, model.FileInfo{Path: "docs/kept.log"})
+
+	plan, err := helpers.PlanSync(out, components, listed, files)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := files[1:]; !reflect.DeepEqual(plan.Download, expected) || plan.Unchanged != 1 {
+		t.Errorf("expected to download %v with 1 unchanged, got %v with %d", expected, plan.Download, plan.Unchanged)
+	}
+	// Sidecars of listed files stay; those of removed files, or of files already gone, go
+	if expected := []model.FileInfo{{Path: "docs/gone/stale.md"}, {Path: "docs/orphan.md"}}; !reflect.DeepEqual(plan.Remove, expected) {
+		t.Errorf("expected to remove %v, got %v", expected, plan.Remove)
+	}
+
+	if err := helpers.RemoveSynced(out, components, plan.Remove); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "gone")); !os.IsNotExist(err) {
+		t.Errorf("expected the emptied directory to be removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "kept.log")); err != nil {
+		t.Errorf("expected listed files to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "same.md.repopack.json")); err != nil {
+		t.Errorf("expected the sidecars of listed files to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "docs", "orphan.md.repopack.json")); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned sidecars to be removed, got %v", err)
+	}
+
+	// Nothing is local before the first sync
+	plan, err = helpers.PlanSync(filepath.Join(out, "empty"), components, listed, files)
+	if err != nil || len(plan.Download) != 3 || len(plan.Remove) != 0 {
+		t.Errorf("expected every file to be downloaded into a missing directory, got %+v (%v)", plan, err)
+	}
+}
+
+func TestPlanSyncOfRepositoryRoot(t *testing.T) {
+	out := t.TempDir()
+	for _, name := range []string{"kept.go", "stale.go", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(out, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	components := model.RepoURLComponents{Owner: "o", Repository: "r", Ref: "main"}
+	manifest := helpers.Manifest{Repository: "o/r", Files: map[string]string{"kept.go": "aaa", "stale.go": "bbb"}}
+	if err := helpers.SaveManifest(out, manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	// The root is saved into the output directory itself, so files repo-pack didn't put there stay
+	listed := []model.FileInfo{{Path: "kept.go", SHA: "aaa"}}
+	plan, err := helpers.PlanSync(out, components, listed, listed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []model.FileInfo{{Path: "stale.go"}}; !reflect.DeepEqual(plan.Remove, expected) {
+		t.Errorf("expected to remove %v, got %v", expected, plan.Remove)
+	}
+
+	// Without a manifest, nothing is known to have been downloaded
+	os.Remove(filepath.Join(out, helpers.DownloadManifest))
+	if plan, err = helpers.PlanSync(out, components, listed, listed); err != nil || len(plan.Remove) != 0 {
+		t.Errorf("expected nothing to be removed without a manifest, got %v (%v)", plan.Remove, err)
+	}
+}
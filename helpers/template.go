@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Templates renders downloaded files carrying a template extension, turning a directory of
+// boilerplate into a scaffold
+type Templates struct {
+	// Ext marks files to render, e.g. ".tmpl"; the extension is dropped from the saved name
+	Ext string
+	// Vars are available to templates as {{.name}}
+	Vars map[string]string
+}
+
+// ParseVars parses key=value pairs into template variables
+func ParseVars(pairs []string) (map[string]string, error) {
+	vars := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid variable %q, expected key=value", pair)
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// Matches reports whether the file at path is a template
+func (t *Templates) Matches(path string) bool {
+	return t != nil && t.Ext != "" && strings.HasSuffix(path, t.Ext) && len(path) > len(t.Ext)
+}
+
+// Render executes content as a Go template. Referencing a variable that wasn't given is an
+// error rather than silently rendering "<no value>".
+func (t *Templates) Render(name string, content []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, t.Vars); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// RenderFile renders the saved template at savedPath into the same path without the template
+// extension, removes the template and returns the rendered file's path
+func (t *Templates) RenderFile(savedPath string) (string, error) {
+	content, err := os.ReadFile(savedPath)
+	if err != nil {
+		return "", err
+	}
+	out, err := t.Render(savedPath, content)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := os.Stat(savedPath)
+	if err != nil {
+		return "", err
+	}
+	rendered := strings.TrimSuffix(savedPath, t.Ext)
+	if err := os.WriteFile(rendered, out, info.Mode()); err != nil {
+		return "", err
+	}
+	return rendered, os.Remove(savedPath)
+}
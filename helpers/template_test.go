@@ -0,0 +1,38 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestTemplatesRender(t *testing.T) {
+	vars, err := helpers.ParseVars([]string{"name=demo", "port=8080"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	templates := &helpers.Templates{Ext: ".tmpl", Vars: vars}
+
+	if !templates.Matches("config/app.yaml.tmpl") || templates.Matches("config/app.yaml") {
+		t.Errorf("expected only .tmpl files to match")
+	}
+
+	out, err := templates.Render("app.yaml.tmpl", []byte("name: {{.name}}\nport: {{.port}}\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "name: demo\nport: 8080\n"; string(out) != expected {
+		t.Errorf("expected %q, got %q", expected, out)
+	}
+
+	if _, err := templates.Render("app.yaml.tmpl", []byte("{{.missing}}")); err == nil {
+		t.Errorf("expected error for an undefined variable, got nil")
+	}
+}
+
+func TestParseVarsInvalid(t *testing.T) {
+	for _, pair := range []string{"novalue", "=value"} {
+		if _, err := helpers.ParseVars([]string{pair}); err == nil {
+			t.Errorf("%s: expected error, got nil", pair)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// MoveCursorUp moves the terminal cursor up n lines, staying in the same column
+func MoveCursorUp(w io.Writer, n int) {
+	if n > 0 {
+		fmt.Fprintf(w, "\x1b[%dA", n)
+	}
+}
+
+// ClearLine erases the line the cursor is on and returns the cursor to its start
+func ClearLine(w io.Writer) {
+	fmt.Fprint(w, "\r\x1b[2K")
+}
+
+// ClearLines erases the n lines ending at the cursor's line, leaving the cursor at the start
+// of the first of them, so that a block of n lines can be redrawn in place
+func ClearLines(w io.Writer, n int) {
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			MoveCursorUp(w, 1)
+		}
+		ClearLine(w)
+	}
+}
+
+// HideCursor stops the terminal drawing its cursor, until ShowCursor
+func HideCursor(w io.Writer) {
+	fmt.Fprint(w, "\x1b[?25l")
+}
+
+// ShowCursor draws the terminal cursor again after HideCursor
+func ShowCursor(w io.Writer) {
+	fmt.Fprint(w, "\x1b[?25h")
+}
+
+// shortenPath cuts a path to width characters for display, keeping its end where the file name is
+func shortenPath(path string, width int) string {
+	runes := []rune(path)
+	if len(runes) <= width {
+		return path
+	}
+	return "…" + string(runes[len(runes)-width+1:])
+}
+
+// IsTerminal reports whether f is a terminal rather than a file or pipe
+func IsTerminal(f *os.File) bool {
+	return isTerminal(f)
+}
+
+// MakeRaw puts the terminal f into raw mode, delivering every key press as it is typed without
+// echoing it, and returns a function restoring the previous mode
+func MakeRaw(f *os.File) (restore func() error, err error) {
+	if !isTerminal(f) {
+		return nil, fmt.Errorf("%s is not a terminal", f.Name())
+	}
+	return makeRaw(int(f.Fd()))
+}
@@ -0,0 +1,99 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleChunk caps how much a single read takes, so the waits between reads stay short
+// and workers sharing a throttle take turns rather than stalling each other for long
+const throttleChunk = 32 << 10
+
+// Throttle is a token bucket capping the combined throughput of every reader it wraps.
+// Tokens are bytes, refilled at the rate per second up to one second's worth.
+type Throttle struct {
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewThrottle creates a throttle allowing bytesPerSecond across all its readers
+func NewThrottle(bytesPerSecond int64) *Throttle {
+	return &Throttle{rate: float64(bytesPerSecond), tokens: float64(bytesPerSecond)}
+}
+
+// ParseThrottle parses a rate such as "2MB/s" or "500KB", per second either way
+func ParseThrottle(rate string) (*Throttle, error) {
+	trimmed := strings.TrimSuffix(strings.TrimSpace(rate), "/s")
+	n, err := ParseByteSize(trimmed)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid rate %q, expected a size per second such as 2MB/s", rate)
+	}
+	return NewThrottle(n), nil
+}
+
+// Take accounts for n bytes transferred, sleeping for as long as the bucket is in debt
+// afterwards. Debt is shared, so concurrent readers each wait their share and together stay
+// within the rate. The wait ends early with ctx's error once ctx is done.
+func (t *Throttle) Take(ctx context.Context, n int) error {
+	t.mu.Lock()
+	now := time.Now()
+	if !t.last.IsZero() {
+		t.tokens = min(t.rate, t.tokens+now.Sub(t.last).Seconds()*t.rate)
+	}
+	t.last = now
+	t.tokens -= float64(n)
+	var wait time.Duration
+	if t.tokens < 0 {
+		wait = time.Duration(-t.tokens / t.rate * float64(time.Second))
+	}
+	t.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader wraps r so that reading from it is throttled, until ctx is done. A nil throttle
+// returns r as is.
+func (t *Throttle) Reader(ctx context.Context, r io.ReadCloser) io.ReadCloser {
+	if t == nil {
+		return r
+	}
+	return &throttledReader{ReadCloser: r, ctx: ctx, throttle: t}
+}
+
+func (t *Throttle) String() string {
+	return FormatByteSize(int64(t.rate)) + "/s"
+}
+
+type throttledReader struct {
+	io.ReadCloser
+	ctx      context.Context
+	throttle *Throttle
+}
+
+func (r *throttledReader) Read(b []byte) (int, error) {
+	if len(b) > throttleChunk {
+		b = b[:throttleChunk]
+	}
+	n, err := r.ReadCloser.Read(b)
+	if n > 0 {
+		if takeErr := r.throttle.Take(r.ctx, n); takeErr != nil {
+			return n, takeErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,79 @@
+package helpers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"repo-pack/helpers"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleCapsCombinedRate(t *testing.T) {
+	throttle, err := helpers.ParseThrottle("1MB/s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The bucket starts with one second's worth, so 1.5MB over three readers owes half a second
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := throttle.Reader(context.Background(), io.NopCloser(bytes.NewReader(make([]byte, 512<<10))))
+			if n, err := io.Copy(io.Discard, r); err != nil || n != 512<<10 {
+				t.Errorf("expected all 512KB read, got %d (%v)", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("expected about half a second for 1.5MB at 1MB/s, took %s", elapsed)
+	}
+}
+
+func TestThrottleStopsWaitingOnCancel(t *testing.T) {
+	throttle, err := helpers.ParseThrottle("1KB/s")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 64KB at 1KB/s would wait about a minute
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	r := throttle.Reader(ctx, io.NopCloser(bytes.NewReader(make([]byte, 64<<10))))
+	if _, err := io.Copy(io.Discard, r); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to end the read, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the read to stop at the deadline, took %s", elapsed)
+	}
+}
+
+func TestParseThrottle(t *testing.T) {
+	for rate, expected := range map[string]string{"2MB/s": "2.00 MB/s", "500KB": "500.00 KB/s"} {
+		throttle, err := helpers.ParseThrottle(rate)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", rate, err)
+		}
+		if throttle.String() != expected {
+			t.Errorf("expected %s to parse as %s, got %s", rate, expected, throttle)
+		}
+	}
+	for _, invalid := range []string{"", "0", "fast", "-1MB/s"} {
+		if _, err := helpers.ParseThrottle(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+
+	var none *helpers.Throttle
+	r := io.NopCloser(bytes.NewReader(nil))
+	if none.Reader(context.Background(), r) != r {
+		t.Errorf("expected a nil throttle to leave readers unwrapped")
+	}
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// DefaultCharsPerToken approximates common LLM tokenizers on source code and English prose
+const DefaultCharsPerToken = 4.0
+
+// EstimateTokens approximates how many tokens content takes, counting one token per
+// charsPerToken characters
+func EstimateTokens(content []byte, charsPerToken float64) int {
+	if charsPerToken <= 0 {
+		charsPerToken = DefaultCharsPerToken
+	}
+	return int(math.Ceil(float64(utf8.RuneCount(content)) / charsPerToken))
+}
+
+// FitTokenBudget estimates the tokens of each entry and keeps entries in order while they fit
+// within maxTokens. The first entry that doesn't fit is truncated to the remaining budget and
+// every later, lower-priority entry is dropped. A maxTokens of 0 keeps everything.
+func FitTokenBudget(entries []PackEntry, maxTokens int, charsPerToken float64) (kept []PackEntry, dropped int) {
+	if charsPerToken <= 0 {
+		charsPerToken = DefaultCharsPerToken
+	}
+
+	used := 0
+	for i, entry := range entries {
+		entry.Tokens = EstimateTokens(entry.Content, charsPerToken)
+		if maxTokens <= 0 || used+entry.Tokens <= maxTokens {
+			kept = append(kept, entry)
+			used += entry.Tokens
+			continue
+		}
+
+		if remaining := maxTokens - used; remaining > 0 {
+			entry.Content = truncateRunes(entry.Content, int(float64(remaining)*charsPerToken))
+			entry.Tokens = EstimateTokens(entry.Content, charsPerToken)
+			entry.Truncated = true
+			kept = append(kept, entry)
+			i++
+		}
+		return kept, len(entries) - i
+	}
+	return kept, 0
+}
+
+// truncateRunes cuts content after n characters without splitting a multi-byte rune
+func truncateRunes(content []byte, n int) []byte {
+	for i := range string(content) {
+		if n == 0 {
+			return content[:i]
+		}
+		n--
+	}
+	return content
+}
@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransferBudget bounds a download by bytes transferred or time spent. It only decides whether
+// another file may start, so files already in flight when it runs out still finish.
+type TransferBudget struct {
+	bytes    int64
+	duration time.Duration
+	deadline time.Time
+}
+
+// ParseTransferBudget parses a budget given as a duration such as "5m" or a size such as "500MB"
+func ParseTransferBudget(budget string) (*TransferBudget, error) {
+	trimmed := strings.TrimSpace(budget)
+	if d, err := time.ParseDuration(trimmed); err == nil {
+		if d <= 0 {
+			return nil, fmt.Errorf("budget must be positive, got %s", budget)
+		}
+		return &TransferBudget{duration: d}, nil
+	}
+	n, err := ParseByteSize(trimmed)
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid budget %q, expected a duration such as 5m or a size such as 500MB", budget)
+	}
+	return &TransferBudget{bytes: n}, nil
+}
+
+// Start begins the clock of a time budget
+func (b *TransferBudget) Start(now time.Time) {
+	if b != nil && b.duration > 0 {
+		b.deadline = now.Add(b.duration)
+	}
+}
+
+// Exhausted reports whether no further file should be started, given the bytes transferred
+// so far. A nil budget is never exhausted.
+func (b *TransferBudget) Exhausted(transferred int64, now time.Time) bool {
+	switch {
+	case b == nil:
+		return false
+	case b.bytes > 0:
+		return transferred >= b.bytes
+	default:
+		return !b.deadline.IsZero() && !now.Before(b.deadline)
+	}
+}
+
+func (b *TransferBudget) String() string {
+	if b.bytes > 0 {
+		return FormatByteSize(b.bytes)
+	}
+	return b.duration.String()
+}
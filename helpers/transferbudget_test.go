@@ -0,0 +1,39 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"testing"
+	"time"
+)
+
+func TestTransferBudget(t *testing.T) {
+	size, err := helpers.ParseTransferBudget("500MB")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now()
+	size.Start(now)
+	if size.Exhausted(499<<20, now) || !size.Exhausted(500<<20, now) {
+		t.Errorf("expected a 500MB budget to run out at 500MB")
+	}
+
+	clock, err := helpers.ParseTransferBudget("5m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Start(now)
+	if clock.Exhausted(1<<40, now.Add(4*time.Minute)) || !clock.Exhausted(0, now.Add(5*time.Minute)) {
+		t.Errorf("expected a 5m budget to run out after 5 minutes regardless of bytes")
+	}
+
+	for _, invalid := range []string{"", "0", "-5m", "soon"} {
+		if _, err := helpers.ParseTransferBudget(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+
+	var none *helpers.TransferBudget
+	if none.Exhausted(1<<40, now) {
+		t.Errorf("expected a nil budget to never run out")
+	}
+}
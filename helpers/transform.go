@@ -0,0 +1,188 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Transform rewrites the content of the repository file at path
+type Transform func(path string, content []byte) ([]byte, error)
+
+// ParseTransforms builds a single transform applying each spec in order. Specs are dos2unix,
+// unix2dos, sed:s/pattern/replacement/[gi] with a Go regular expression, or exec:command args
+// to pipe content through an external program as a plugin hook.
+func ParseTransforms(specs []string) (Transform, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	transforms := make([]Transform, 0, len(specs))
+	for _, spec := range specs {
+		t, err := parseTransform(spec)
+		if err != nil {
+			return nil, err
+		}
+		transforms = append(transforms, t)
+	}
+
+	return func(path string, content []byte) ([]byte, error) {
+		if IsBinaryContent(content) {
+			return content, nil
+		}
+		for _, t := range transforms {
+			var err error
+			if content, err = t(path, content); err != nil {
+				return nil, err
+			}
+		}
+		return content, nil
+	}, nil
+}
+
+func parseTransform(spec string) (Transform, error) {
+	name, arg, _ := strings.Cut(spec, ":")
+	switch name {
+	case "dos2unix":
+		return func(_ string, content []byte) ([]byte, error) {
+			return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+		}, nil
+	case "unix2dos":
+		return func(_ string, content []byte) ([]byte, error) {
+			content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+			return bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n")), nil
+		}, nil
+	case "sed":
+		return parseSubstitution(arg)
+	case "exec":
+		args := strings.Fields(arg)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("transform %q needs a command", spec)
+		}
+		return execTransform(args), nil
+	}
+	return nil, fmt.Errorf("unknown transform %q, expected dos2unix, unix2dos, sed:s/a/b/ or exec:command", spec)
+}
+
+// sedBackref matches sed-style \1 back-references, rewritten to Go's ${1}
+var sedBackref = regexp.MustCompile(`\\([0-9])`)
+
+// parseSubstitution parses a sed s command such as s/foo/bar/g. Any character may follow
+// the s as delimiter; flags are g (replace all matches) and i (ignore case).
+func parseSubstitution(expr string) (Transform, error) {
+	if len(expr) < 2 || expr[0] != 's' {
+		return nil, fmt.Errorf("invalid sed expression %q, expected s/pattern/replacement/", expr)
+	}
+	delim := string(expr[1])
+	parts := splitUnescaped(expr[2:], delim)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid sed expression %q, expected s/pattern/replacement/", expr)
+	}
+
+	pattern, flags := parts[0], parts[2]
+	global := strings.Contains(flags, "g")
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	if strings.Trim(flags, "gi") != "" {
+		return nil, fmt.Errorf("unsupported sed flags %q in %q", flags, expr)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sed pattern in %q: %v", expr, err)
+	}
+	replacement := strings.ReplaceAll(parts[1], "$", "$$")
+	replacement = strings.ReplaceAll(replacement, "&", "${0}")
+	template := []byte(sedBackref.ReplaceAllString(replacement, "$${$1}"))
+
+	return func(_ string, content []byte) ([]byte, error) {
+		if global {
+			return re.ReplaceAll(content, template), nil
+		}
+		match := re.FindSubmatchIndex(content)
+		if match == nil {
+			return content, nil
+		}
+		out := append([]byte{}, content[:match[0]]...)
+		out = re.Expand(out, template, content, match)
+		return append(out, content[match[1]:]...), nil
+	}, nil
+}
+
+// splitUnescaped splits s on delim, treating a backslash-escaped delimiter as literal
+func splitUnescaped(s, delim string) []string {
+	var parts []string
+	var current strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && string(s[i+1]) == delim:
+			current.WriteString(delim)
+			i++
+		case string(s[i]) == delim:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+	return append(parts, current.String())
+}
+
+// execTransform pipes content through a command, which reads the file on stdin, writes the
+// result to stdout and finds the repository path in REPO_PACK_PATH
+func execTransform(args []string) Transform {
+	return func(path string, content []byte) ([]byte, error) {
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), "REPO_PACK_PATH="+path)
+		cmd.Stdin = bytes.NewReader(content)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("transform %s failed for %s: %v %s", args[0], path, err, strings.TrimSpace(stderr.String()))
+		}
+		return out, nil
+	}
+}
+
+// TransformFile rewrites the saved file at savedPath in place. The new content goes to a
+// temporary file that replaces the original, so a failed transform leaves it untouched.
+func TransformFile(savedPath, path string, t Transform) error {
+	content, err := os.ReadFile(savedPath)
+	if err != nil {
+		return err
+	}
+	out, err := t(path, content)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(out, content) {
+		return nil
+	}
+
+	info, err := os.Stat(savedPath)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(savedPath), ".repo-pack-transform-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), savedPath)
+}
@@ -0,0 +1,48 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"testing"
+)
+
+func TestParseTransforms(t *testing.T) {
+	cases := []struct {
+		specs    []string
+		input    string
+		expected string
+	}{
+		{[]string{"dos2unix"}, "a\r\nb\r\n", "a\nb\n"},
+		{[]string{"unix2dos"}, "a\nb\r\n", "a\r\nb\r\n"},
+		{[]string{"sed:s/foo/bar/"}, "foo foo", "bar foo"},
+		{[]string{"sed:s/foo/bar/g"}, "foo foo", "bar bar"},
+		{[]string{"sed:s|(\\w+)@old|\\1@new|g"}, "a@old b@old", "a@new b@new"},
+		{[]string{"sed:s/FOO/[&]/i"}, "foo", "[foo]"},
+		{[]string{"sed:s/x/$1/"}, "x", "$1"},
+		{[]string{"dos2unix", "sed:s/a/b/g"}, "a\r\na", "b\nb"},
+		{[]string{"dos2unix"}, "bin\x00\r\n", "bin\x00\r\n"},
+	}
+
+	for _, c := range cases {
+		transform, err := helpers.ParseTransforms(c.specs)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.specs, err)
+			continue
+		}
+		out, err := transform("file.txt", []byte(c.input))
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.specs, err)
+			continue
+		}
+		if string(out) != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.specs, c.expected, out)
+		}
+	}
+}
+
+func TestParseTransformsInvalid(t *testing.T) {
+	for _, spec := range []string{"rot13", "sed:s/a/b", "sed:s/a/b/x", "sed:s/(/b/", "exec:"} {
+		if _, err := helpers.ParseTransforms([]string{spec}); err == nil {
+			t.Errorf("%s: expected error, got nil", spec)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package helpers
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"repo-pack/model"
+)
+
+// treeNode is a directory or file in a rendered tree
+type treeNode struct {
+	name     string
+	size     int64
+	modified time.Time
+	children map[string]*treeNode
+}
+
+func (n *treeNode) isDir() bool {
+	return n.children != nil
+}
+
+// sortedChildren lists directories first, then files, each alphabetically
+func (n *treeNode) sortedChildren() []*treeNode {
+	children := make([]*treeNode, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].isDir() != children[j].isDir() {
+			return children[i].isDir()
+		}
+		return children[i].name < children[j].name
+	})
+	return children
+}
+
+// buildTree arranges files under root into nested nodes, totalling sizes and keeping the
+// latest modification time of each directory
+func buildTree(root string, files []model.FileInfo, modified map[string]time.Time) *treeNode {
+	rootNode := &treeNode{name: path.Base(root), children: map[string]*treeNode{}}
+	prefix := strings.Trim(root, "/") + "/"
+	for _, file := range files {
+		rel := strings.TrimPrefix(file.Path, prefix)
+		parts := strings.Split(rel, "/")
+		node := rootNode
+		ancestors := []*treeNode{rootNode}
+		for _, dir := range parts[:len(parts)-1] {
+			child, ok := node.children[dir]
+			if !ok {
+				child = &treeNode{name: dir, children: map[string]*treeNode{}}
+				node.children[dir] = child
+			}
+			node = child
+			ancestors = append(ancestors, node)
+		}
+
+		leaf := &treeNode{name: parts[len(parts)-1], size: file.Size, modified: modified[file.Path]}
+		node.children[leaf.name] = leaf
+		for _, ancestor := range ancestors {
+			ancestor.size += file.Size
+			if leaf.modified.After(ancestor.modified) {
+				ancestor.modified = leaf.modified
+			}
+		}
+	}
+	return rootNode
+}
+
+// RenderTree writes files under root as an indented tree with sizes and, when known,
+// last-modified dates
+func RenderTree(w io.Writer, root string, files []model.FileInfo, modified map[string]time.Time) {
+	rootNode := buildTree(root, files, modified)
+	fmt.Fprintf(w, "%s/%s\n", rootNode.name, treeDetails(rootNode))
+	renderChildren(w, rootNode, "")
+}
+
+func renderChildren(w io.Writer, node *treeNode, indent string) {
+	children := node.sortedChildren()
+	for i, child := range children {
+		branch, nextIndent := "├── ", indent+"│   "
+		if i == len(children)-1 {
+			branch, nextIndent = "└── ", indent+"    "
+		}
+
+		name := child.name
+		if child.isDir() {
+			name += "/"
+		}
+		fmt.Fprintf(w, "%s%s%s%s\n", indent, branch, name, treeDetails(child))
+		if child.isDir() {
+			renderChildren(w, child, nextIndent)
+		}
+	}
+}
+
+// treeDetails formats a node's size and last-modified date
+func treeDetails(node *treeNode) string {
+	details := "  " + FormatByteSize(node.size)
+	if !node.modified.IsZero() {
+		details += "  " + node.modified.Format("2006-01-02")
+	}
+	return details
+}
@@ -0,0 +1,34 @@
+package helpers_test
+
+import (
+	"repo-pack/helpers"
+	"repo-pack/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTree(t *testing.T) {
+	files := []model.FileInfo{
+		{Path: "docs/guide/b.md", Size: 100},
+		{Path: "docs/a.md", Size: 2048},
+		{Path: "docs/guide/c.md", Size: 50},
+	}
+	modified := map[string]time.Time{
+		"docs/a.md":       time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+		"docs/guide/c.md": time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC),
+	}
+
+	var out strings.Builder
+	helpers.RenderTree(&out, "docs", files, modified)
+
+	expected := `docs/  2.15 KB  2024-03-04
+├── guide/  150 B  2024-03-04
+│   ├── b.md  100 B
+│   └── c.md  50 B  2024-03-04
+└── a.md  2.00 KB  2024-01-02
+`
+	if out.String() != expected {
+		t.Errorf("expected tree:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
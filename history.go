@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+)
+
+// historyPath returns where past downloads are recorded, next to the default blob cache
+func historyPath() (string, error) {
+	dir, err := gh.DefaultCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating history: %v", err)
+	}
+	return filepath.Join(dir, helpers.HistoryFile), nil
+}
+
+// recordHistory appends a finished download to the history. Runs that failed before naming
+// a URL, such as usage errors, aren't worth repeating and are left out. Failing to record
+// only costs the entry, so it is reported as a warning.
+func recordHistory(entry helpers.HistoryEntry, err error) {
+	if entry.URL == "" {
+		return
+	}
+	entry.Time = time.Now()
+	entry.Dir, _ = os.Getwd()
+	entry.Result = "ok"
+	if err != nil {
+		entry.Result = err.Error()
+	}
+
+	path, pathErr := historyPath()
+	if pathErr == nil {
+		pathErr = helpers.AppendHistory(path, entry)
+	}
+	if pathErr != nil {
+		log.Printf("warning: download not recorded in history: %v", pathErr)
+	}
+}
+
+// redactArgs drops the token from arguments recorded in the history, which a redo takes from
+// the config or command line instead
+func redactArgs(args []string) []string {
+	return dropFlags(args, "token")
+}
+
+// runHistory handles `repo-pack history [-n count]`, listing recent downloads numbered for redo
+func runHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ExitOnError)
+	count := flags.Int("n", 20, "Number of most recent downloads to list (0 for all)")
+	flags.Parse(args)
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: repo-pack history [-n count]")
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	entries, err := helpers.ReadHistory(path)
+	if err != nil {
+		return err
+	}
+
+	first := 0
+	if *count > 0 {
+		first = max(len(entries)-*count, 0)
+	}
+	for i := first; i < len(entries); i++ {
+		entry := entries[i]
+		commit := entry.Ref
+		if entry.Commit != "" {
+			commit = fmt.Sprintf("%s@%.7s", entry.Ref, entry.Commit)
+		}
+		fmt.Printf("%4d  %s  %s  %s -> %s  [%s]\n", i+1, entry.Time.Local().Format("2006-01-02 15:04"),
+			entry.URL, commit, entry.Output, entry.Result)
+	}
+	return nil
+}
+
+// runRedo handles `repo-pack redo <n>`, repeating download n of the history with the same
+// arguments from the directory it ran in
+func runRedo(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: repo-pack redo <n>, with n from repo-pack history")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("usage: repo-pack redo <n>, with n from repo-pack history")
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	entries, err := helpers.ReadHistory(path)
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > len(entries) {
+		return fmt.Errorf("no download %d in history, which holds %d", n, len(entries))
+	}
+
+	entry := entries[n-1]
+	if entry.Dir != "" {
+		if err := os.Chdir(entry.Dir); err != nil {
+			return fmt.Errorf("error entering %s: %v", entry.Dir, err)
+		}
+	}
+	fmt.Printf("[-] Redoing in %s: repo-pack %s %s\n", entry.Dir, entry.Command, strings.Join(entry.Args, " "))
+	return run(entry.Command, entry.Args)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// listEntry is one file of `repo-pack list --format json`
+type listEntry struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	SHA  string `json:"sha"`
+	Mode string `json:"mode"`
+}
+
+// runList handles `repo-pack list [flags] <url>`, printing the path and size in bytes of every
+// file a download would fetch, one per line, without downloading anything. With --format csv
+// or json, the blob SHA and mode are included for inventory tooling.
+func runList(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	format := flags.String("format", "text", "Output format: text, csv or json")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: repo-pack list [--token token] [--format text|csv|json] <url>")
+	}
+	if *format != "text" && *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q, expected text, csv or json", *format)
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository URL: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+
+	// Each page of the listing is printed and flushed as its response arrives, so a huge
+	// monorepo directory's first files show up long before its last subtree has been requested.
+	// A failed write, such as to a pipe whose reader has gone, stops the listing.
+	pages, listErr := client.ListPages(ctx, components)
+	w := bufio.NewWriter(os.Stdout)
+	var writeErr error
+	switch *format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"path", "size", "sha", "mode"})
+		pages(func(page []model.FileInfo) bool {
+			for _, file := range page {
+				cw.Write([]string{file.Path, strconv.FormatInt(file.Size, 10), file.SHA, file.Mode})
+			}
+			cw.Flush()
+			if writeErr = cw.Error(); writeErr == nil {
+				writeErr = w.Flush()
+			}
+			return writeErr == nil
+		})
+		if writeErr == nil {
+			cw.Flush()
+			writeErr = cw.Error()
+		}
+	case "json":
+		entries := []listEntry{}
+		pages(func(page []model.FileInfo) bool {
+			for _, file := range page {
+				entries = append(entries, listEntry{Path: file.Path, Size: file.Size, SHA: file.SHA, Mode: file.Mode})
+			}
+			return true
+		})
+		if err := listErr(); err != nil {
+			return fmt.Errorf("failed to list files: %v", err)
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			return err
+		}
+	default:
+		pages(func(page []model.FileInfo) bool {
+			for _, file := range page {
+				fmt.Fprintf(w, "%s\t%d\n", file.Path, file.Size)
+			}
+			writeErr = w.Flush()
+			return writeErr == nil
+		})
+	}
+	if writeErr == nil {
+		writeErr = w.Flush()
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := listErr(); err != nil {
+		return fmt.Errorf("failed to list files: %v", err)
+	}
+	return nil
+}
@@ -2,78 +2,1260 @@ package main
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"repo-pack/gh"
+	"repo-pack/gitproto"
 	"repo-pack/helpers"
+	"repo-pack/model"
 )
 
+// version is set at build time by goreleaser
+var version = "dev"
+
 func main() {
-	if err := run(); err != nil {
+	// Consoles predating Windows 10 reject VT mode and only lose escape sequences, so errors are ignored
+	_ = helpers.EnableVirtualTerminal()
+
+	var err error
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		err = runCommand(os.Args[1], os.Args[2:])
+	} else {
+		err = run("repo-pack", os.Args[1:])
+	}
+	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func run() error {
-	repoURL := flag.String("url", "", "GitHub repository URL")
-	token := flag.String("token", "", "GitHub personal access token")
-	flag.Parse()
+// commands maps each subcommand to its handler. Running repo-pack with flags and no command
+// keeps the flat --url interface working.
+var commands = map[string]func(args []string) error{
+	"get":        func(args []string) error { return run("get", args) },
+	"pack":       func(args []string) error { return run("pack", args) },
+	"list":       runList,
+	"tree":       runTree,
+	"sizes":      runSizes,
+	"search-get": runSearchGet,
+	"new":        runNew,
+	"fetch":      runFetch,
+	"cache":      runCache,
+	"config":     runConfig,
+	"alias":      runAlias,
+	"org":        runOrg,
+	"compare":    runCompare,
+	"history":    runHistory,
+	"mount":      runMount,
+	"redo":       runRedo,
+}
+
+// runCommand dispatches to the handler of a subcommand
+func runCommand(name string, args []string) error {
+	command, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for name := range commands {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		return fmt.Errorf("unknown command %q, expected one of %s", name, strings.Join(names, ", "))
+	}
+	return command(args)
+}
 
-	if *repoURL == "" {
-		err := fmt.Errorf("missing argument for repoURL")
+// run handles the download commands: `repo-pack get [flags] <url>`, `repo-pack pack [flags]
+// -o <archive> <url>` and the flat `repo-pack --url <url> [flags]` predating subcommands
+func run(name string, args []string) (err error) {
+	opts, err := parseGetOptions(name, args)
+	if err != nil {
 		return err
 	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	history := helpers.HistoryEntry{Command: name, Args: redactArgs(args), URL: opts.repoURL}
 
-	components, err := helpers.ParseRepoURL(*repoURL)
+	stopProfiling, err := startProfiling(opts.pprofAddr, opts.cpuProfile, opts.memProfile)
 	if err != nil {
-		return fmt.Errorf("failed to parse repository URL: %v", err)
+		return err
+	}
+	defer stopProfiling()
+
+	d := newDownload(opts)
+	defer d.close()
+	if err := d.setUp(); err != nil {
+		return err
+	}
+	prNumber, err := d.parseURL()
+	if err != nil {
+		return err
 	}
 
 	ctx := context.Background()
-	gh.FetchRepoIsPrivate(ctx, &components, *token)
+	if err := d.connect(); err != nil {
+		return err
+	}
+	defer func() {
+		history.Ref = d.components.Ref
+		if err == nil {
+			history.Commit = resolveCommit(ctx, d.client, d.components)
+			warnRefDrift(ctx, d.client, d.components)
+		}
+		recordHistory(history, err)
+	}()
+	if err := d.resolveRepository(ctx); err != nil {
+		return err
+	}
+	if opts.allRefs != "" {
+		if prNumber != 0 {
+			return fmt.Errorf("--all-refs can't be combined with --ref, a pull request URL, --pack-file or --archive")
+		}
+		// Each ref's download is recorded in the history on its own
+		history.URL = ""
+		return runAllRefs(ctx, d.status, d.client, d.components, name, args, opts.allRefs, opts.output)
+	}
+	if err := d.resolveRef(ctx, prNumber); err != nil {
+		return err
+	}
+	if history.Output, err = d.prepareOutput(); err != nil {
+		return err
+	}
+	if opts.gitStrategy() {
+		return d.fetchViaGit(ctx)
+	}
 
-	files, _, err := gh.RepoListingSlashBranchSupport(ctx, &components, *token)
+	listing, err := d.list(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get files via contents API: %v", err)
+		return err
+	}
+	fmt.Fprintf(d.status, "[-] Repository: %s/%s\n", d.components.Owner, d.components.Repository)
+	fmt.Fprintf(d.status, "[-] GitHub Directory: %s\n", d.components.Dir)
+	if opts.toStdout {
+		return streamToStdout(ctx, d.client, &d.components, listing.files, os.Stdout, d.status, opts.stdoutFormat == "tar")
+	}
+	if opts.placeholders {
+		return writePlaceholders(d.outputDir, d.components, listing.files)
+	}
+	var syncPlan helpers.SyncPlan
+	if opts.sync {
+		if syncPlan, err = d.planSync(listing); err != nil || opts.syncDryRun {
+			return err
+		}
 	}
 
-	fmt.Printf("[-] Repository: %s/%s\n", components.Owner, components.Repository)
-	fmt.Printf("[-] GitHub Directory: %s\n", components.Dir)
-	fmt.Printf("[-] Fetching %d files\n", len(files))
+	result, err := d.fetch(ctx, listing, syncPlan)
+	if err != nil {
+		return err
+	}
+	return d.finish(listing, result)
+}
 
-	bar := &helpers.Bar{}
-	bar.Config(0, int64(len(files)), "[-] Progress: ")
+// download is one run of the download commands, holding what its stages share
+type download struct {
+	opts       *getOptions
+	client     *gh.Client
+	components model.RepoURLComponents
+	fetchOpts  gh.FetchOptions
+	workers    int
+	// status receives the messages reporting progress, which go to stderr when the content
+	// owns stdout
+	status *os.File
 
-	var wg sync.WaitGroup
-	errorsCh := make(chan error, len(files))
+	localCache  *gh.FileCache
+	remoteCache *gh.RemoteCache
+
+	progressOut    io.Writer
+	multiProgress  bool
+	transferBudget *helpers.TransferBudget
+	events         *helpers.EventLog
+	reportPath     string
+
+	// outputDir is where files end up. They are gathered in staged first with --staging-dir,
+	// and in scratch when only a pack, archive or objects are kept.
+	outputDir, staged, scratch string
+	// closers release the logs the download writes once it is done
+	closers []func() error
+}
+
+func newDownload(opts *getOptions) *download {
+	d := &download{opts: opts, status: os.Stdout, outputDir: "."}
+	if opts.toStdout {
+		d.status = os.Stderr
+	}
+	return d
+}
+
+// close releases the logs and removes the scratch directory
+func (d *download) close() {
+	if d.scratch != "" {
+		os.RemoveAll(d.scratch)
+	}
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		d.closers[i]()
+	}
+}
+
+// setUp turns the options into the fetch options, caches, logs and directories the download uses
+func (d *download) setUp() (err error) {
+	o := d.opts
+	if d.workers, err = helpers.FitConcurrency(o.concurrency, o.maxOpenFiles); err != nil {
+		return err
+	}
+	if d.fetchOpts, err = o.fetchOptions(); err != nil {
+		return err
+	}
+	if o.budget != "" {
+		if d.transferBudget, err = helpers.ParseTransferBudget(o.budget); err != nil {
+			return fmt.Errorf("invalid --budget: %v", err)
+		}
+	}
+	if o.report != "" {
+		if _, d.reportPath, err = helpers.ParseReport(o.report); err != nil {
+			return fmt.Errorf("invalid --report: %v", err)
+		}
+	}
+
+	// The local cache sits in front of the remote one, so shared blobs are fetched once per machine
+	var caches gh.LayeredCache
+	if !o.noCache {
+		if d.localCache, err = openCache(o.cacheDir); err == nil {
+			caches = append(caches, d.localCache)
+		} else if o.strategy == "delta" {
+			return err
+		} else {
+			log.Printf("warning: %v, downloading without the local cache", err)
+		}
+	}
+	if o.remoteCache != "" {
+		if d.remoteCache, err = gh.NewRemoteCache(o.remoteCache); err != nil {
+			return err
+		}
+		caches = append(caches, d.remoteCache)
+	}
+	switch len(caches) {
+	case 0:
+	case 1:
+		d.fetchOpts.Cache = caches[0]
+	default:
+		d.fetchOpts.Cache = caches
+	}
+
+	// Lines can only be redrawn in place on a terminal, elsewhere the bar logs a line per update
+	d.multiProgress = o.progressStyle == "multi" && o.progressLog == "" && helpers.IsTerminal(d.status)
+	if o.progressLog != "" {
+		logFile, err := os.OpenFile(o.progressLog, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("error opening progress log: %v", err)
+		}
+		d.closers = append(d.closers, logFile.Close)
+		d.progressOut = logFile
+	}
+	if o.jsonLog != "" {
+		jsonFile, err := os.Create(o.jsonLog)
+		if err != nil {
+			return fmt.Errorf("error creating JSON log: %v", err)
+		}
+		d.closers = append(d.closers, jsonFile.Close)
+		d.events = helpers.NewEventLog(jsonFile)
+	}
+	if d.reportPath != "" {
+		if d.events == nil {
+			d.events = helpers.NewEventLog(nil)
+		}
+		d.events.Keep = true
+	}
+
+	if o.packFile != "" || o.archive != "" || o.layout == "cas" {
+		// Files are gathered in a scratch directory and only the pack, archive or objects are kept
+		if d.scratch, err = os.MkdirTemp("", "repo-pack-"); err != nil {
+			return fmt.Errorf("error creating scratch directory: %v", err)
+		}
+		d.fetchOpts.OutputDir = d.scratch
+	} else if o.stagingDir != "" {
+		if err := os.MkdirAll(o.stagingDir, 0o755); err != nil {
+			return fmt.Errorf("error creating staging directory: %v", err)
+		}
+		if d.staged, err = os.MkdirTemp(o.stagingDir, "repo-pack-"); err != nil {
+			return fmt.Errorf("error creating staging directory: %v", err)
+		}
+		d.fetchOpts.OutputDir = d.staged
+	}
+	return nil
+}
+
+// parseURL parses the URL to download, returning the number of the pull request it names, if any
+func (d *download) parseURL() (prNumber int, err error) {
+	o := d.opts
+	components, err := helpers.ParseRepoURL(o.repoURL)
+	if err != nil {
+		// Pull request URLs name no ref; it is resolved to the head commit once a client exists.
+		// With --ref naming the ref, a bare repository URL downloads its root.
+		var prErr error
+		if components, prNumber, prErr = helpers.ParsePullRequestURL(o.repoURL); prErr == nil {
+			components.Dir = strings.Trim(o.dir, "/")
+		} else if rootComponents, rootErr := helpers.ParseRepoRootURL(o.repoURL); rootErr == nil && o.ref != "" && o.dir == "" {
+			components = rootComponents
+		} else {
+			return 0, fmt.Errorf("failed to parse repository URL: %v", err)
+		}
+	} else if o.dir != "" {
+		return 0, fmt.Errorf("--dir only applies to pull request URLs")
+	}
+	if o.ref != "" && prNumber != 0 {
+		return 0, fmt.Errorf("--ref can't be combined with a pull request URL")
+	}
+	d.components = components
+	return prNumber, nil
+}
+
+// connect creates the client, recording, replaying or disrupting its requests as the flags ask
+func (d *download) connect() error {
+	var chaosTransport *gh.ChaosTransport
+	if d.opts.chaos != "" {
+		var err error
+		if chaosTransport, err = gh.ParseChaos(d.opts.chaos); err != nil {
+			return fmt.Errorf("invalid --chaos: %v", err)
+		}
+	}
+
+	client, err := d.opts.global.newClient(d.opts.repoURL)
+	if err != nil {
+		return err
+	}
+	client.RateLimiter.Reserve = max(client.RateLimiter.Reserve, d.workers)
+	if d.remoteCache != nil {
+		// The cache shares the pool and timeouts, but isn't recorded or subjected to chaos
+		d.remoteCache.HTTPClient = client.HTTPClient
+	}
+	if d.opts.record != "" {
+		client.HTTPClient = &http.Client{Transport: &gh.RecordingTransport{Dir: d.opts.record, Next: client.HTTPClient.Transport}}
+	} else if d.opts.replay != "" {
+		client.HTTPClient = &http.Client{Transport: &gh.ReplayTransport{Dir: d.opts.replay}}
+	}
+	if chaosTransport != nil {
+		chaosTransport.Next = client.HTTPClient.Transport
+		client.HTTPClient = &http.Client{Transport: chaosTransport}
+		log.Printf("warning: chaos mode injects failures into %.0f%% of requests", chaosTransport.FailureRate*100)
+	}
+	d.client = client
+	return nil
+}
 
-	// Use semaphores to manage the goroutines, this current implementation can affect performance if file number is too large
+// resolveRepository checks the repository can be downloaded and settles where the URL's ref ends
+func (d *download) resolveRepository(ctx context.Context) error {
+	if err := detectPrivate(ctx, d.client, &d.components); err != nil {
+		return err
+	}
+	// Where a branch with slashes ends in the URL is settled before --ref can replace it
+	if err := d.client.ResolveURLRef(ctx, &d.components); err != nil {
+		return err
+	}
+	if d.opts.toStdout && !d.components.IsFile && d.opts.stdoutFormat != "tar" {
+		return fmt.Errorf("--stdout writes a single file; pass --format tar to stream a directory")
+	}
+	return nil
+}
+
+// resolveRef settles the ref to download, from --ref, a pull request or the URL, and pins the
+// download to its commit
+func (d *download) resolveRef(ctx context.Context, prNumber int) error {
+	o, client := d.opts, d.client
+	if helpers.IsVersionRange(o.ref) {
+		tag, err := client.ResolveVersionRange(ctx, d.components, o.ref)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.status, "[-] Resolved %s to %s\n", o.ref, tag)
+		d.components.Ref = tag
+	} else if o.ref != "" {
+		resolved, err := client.ResolveRef(ctx, d.components, o.ref)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.status, "[-] Using %s %s at %s\n", resolved.Kind, resolved.Name, resolved.SHA)
+		d.components.Ref, d.components.Commit = o.ref, resolved.SHA
+	}
+
+	if o.prFiles != 0 {
+		if prNumber != 0 && prNumber != o.prFiles {
+			return fmt.Errorf("--pr-files %d doesn't match pull request #%d in --url", o.prFiles, prNumber)
+		}
+		prNumber = o.prFiles
+	}
+	if prNumber != 0 {
+		headRef, headSHA, err := client.PullRequestHead(ctx, d.components, prNumber)
+		if err != nil {
+			return err
+		}
+		d.components.Ref = headSHA
+		fmt.Fprintf(d.status, "[-] Pull request #%d: %s at %s\n", prNumber, headRef, headSHA)
+	}
+
+	if o.requireSigned {
+		verification, err := client.VerifyCommit(ctx, d.components)
+		if err != nil {
+			return err
+		}
+		if !verification.Verified {
+			return fmt.Errorf("commit %s is not verified (%s), refusing to download", verification.SHA, verification.Reason)
+		}
+		// Pin the download to the checked commit so the ref can't move to an unverified one mid-run
+		fmt.Fprintf(d.status, "[-] Commit %s is verified\n", verification.SHA)
+		d.components.Commit = verification.SHA
+	}
+	// Files are fetched by commit rather than by branch name, so the download is one snapshot
+	// even if the branch moves while it runs
+	if d.components.Commit == "" && !gitproto.IsObjectID(d.components.Ref) {
+		if sha, err := client.ResolveCommit(ctx, d.components); err != nil {
+			log.Printf("warning: couldn't pin %s to a commit, downloading by name: %v", d.components.Ref, err)
+		} else {
+			d.components.Commit = sha
+		}
+	}
+	return nil
+}
+
+// prepareOutput creates the --output directory, returning where the download goes for the history
+func (d *download) prepareOutput() (string, error) {
+	o := d.opts
+	// Packs and archives are single files named by their own flags, so only other downloads
+	// land in the output directory
+	switch {
+	case o.packFile != "":
+		return o.packFile, nil
+	case o.archive != "":
+		return o.archive, nil
+	case o.output == "":
+		return d.outputDir, nil
+	}
+
+	outputDir, err := helpers.ExpandOutputDir(o.output, d.components)
+	if err != nil {
+		return "", fmt.Errorf("invalid --output: %v", err)
+	}
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return "", fmt.Errorf("error creating output directory: %v", err)
+	}
+	d.outputDir = outputDir
+	if d.fetchOpts.OutputDir == "" {
+		d.fetchOpts.OutputDir = outputDir
+	}
+	fmt.Fprintf(d.status, "[-] Output directory: %s\n", outputDir)
+	return outputDir, nil
+}
+
+// fetchViaGit downloads the directory with the git or delta strategy and lays it out as asked
+func (d *download) fetchViaGit(ctx context.Context) error {
+	var deltaCache *gh.FileCache
+	if d.opts.strategy == "delta" {
+		deltaCache = d.localCache
+	}
+	if err := runGitStrategy(ctx, d.client, &d.components, d.fetchOpts, deltaCache, d.status); err != nil {
+		return err
+	}
+	if d.opts.archive != "" {
+		return writeArchive(d.status, d.opts.archive, d.fetchOpts.OutputDir, nil)
+	}
+	if d.opts.layout == "cas" {
+		return writeCASLayout(d.status, d.fetchOpts.OutputDir, d.outputDir)
+	}
+	return promoteStaged(d.staged, d.outputDir, 0)
+}
+
+// downloadListing is the files a download selected, in the order they are fetched
+type downloadListing struct {
+	files []model.FileInfo
+	// listed is every file of the directory, before filters, which --sync keeps local files of
+	listed     []model.FileInfo
+	submodules []model.FileInfo
+}
+
+// list lists the files to download, leaving out those the excludes, filters, --text-only and
+// the --interactive picker don't select, and orders them
+func (d *download) list(ctx context.Context) (downloadListing, error) {
+	o, client, components := d.opts, d.client, &d.components
+	if o.strategy == "tarball" && components.IsFile {
+		return downloadListing{}, fmt.Errorf("the tarball strategy downloads directories, not single files")
+	}
+
+	var files, submoduleEntries []model.FileInfo
+	var err error
+	if components.IsFile {
+		// The size isn't known without an API call; the response's Content-Length supplies it
+		files = []model.FileInfo{{Path: components.FilePath, Size: -1}}
+	} else if o.prFiles != 0 {
+		if files, err = client.PullRequestFiles(ctx, *components, o.prFiles); err != nil {
+			return downloadListing{}, err
+		}
+	} else if files, submoduleEntries, _, err = client.RepoListing(ctx, components); err != nil {
+		return downloadListing{}, fmt.Errorf("failed to get files via contents API: %v", err)
+	}
+
+	if o.dereferenceSymlinks && !components.IsFile {
+		if files, err = client.DereferenceSymlinks(ctx, *components, files, d.fetchOpts.Warn); err != nil {
+			return downloadListing{}, err
+		}
+	} else if o.followSymlinks && !components.IsFile {
+		if files, err = client.FollowSymlinks(ctx, *components, files, d.fetchOpts.Warn); err != nil {
+			return downloadListing{}, err
+		}
+	}
+	if o.sync && components.IsFile {
+		return downloadListing{}, fmt.Errorf("--sync mirrors directories, not single files")
+	}
+	// Sync only deletes files missing from the whole listing, not those filtered out below
+	listed := files
+	if o.sha256sums {
+		// The checksum file isn't in the repository, but isn't to be deleted as if it had been
+		listed = append(slices.Clone(listed), model.FileInfo{Path: path.Join(components.Dir, helpers.ChecksumsFile)})
+	}
+
+	if !components.IsFile {
+		var excluded int
+		if files, excluded = helpers.ExcludeNames(files, components.Dir, d.fetchOpts.Excludes); excluded > 0 {
+			fmt.Fprintf(d.status, "[-] Skipping %d files matched by default excludes (--no-default-excludes to keep them)\n", excluded)
+		}
+		if files, excluded = helpers.FilterGlobs(files, components.Dir, d.fetchOpts.Include, d.fetchOpts.Exclude); excluded > 0 {
+			fmt.Fprintf(d.status, "[-] Skipping %d files filtered by --include/--exclude\n", excluded)
+		}
+		submoduleEntries, _ = helpers.ExcludeNames(submoduleEntries, components.Dir, d.fetchOpts.Excludes)
+		// --include selects files, so only --exclude can leave a submodule out
+		submoduleEntries, _ = helpers.FilterGlobs(submoduleEntries, components.Dir, nil, d.fetchOpts.Exclude)
+	}
+	if len(submoduleEntries) > 0 {
+		switch o.submodules {
+		case "skip":
+			log.Printf("warning: skipping %d submodules (--submodules=clone downloads them): %s", len(submoduleEntries), submodulePaths(submoduleEntries))
+		case "error":
+			return downloadListing{}, fmt.Errorf("%d submodules found, which --submodules=error rejects: %s", len(submoduleEntries), submodulePaths(submoduleEntries))
+		}
+	}
+
+	if o.textOnly {
+		var binary []model.FileInfo
+		files, binary = helpers.FilterTextFiles(files)
+		if len(binary) > 0 {
+			fmt.Fprintf(d.status, "[-] Skipping %d binary files\n", len(binary))
+		}
+	}
+
+	if o.interactive && !components.IsFile {
+		if files, err = helpers.PickFiles(os.Stdin, d.status, files, components.Dir); err != nil {
+			return downloadListing{}, fmt.Errorf("--interactive: %w", err)
+		}
+		if len(files) == 0 {
+			return downloadListing{}, fmt.Errorf("no files selected")
+		}
+	}
+
+	files = helpers.GroupByDirectory(files)
+	files = helpers.PrioritizeFiles(files, helpers.ParsePatternList(o.priority))
+	return downloadListing{files: files, listed: listed, submodules: submoduleEntries}, nil
+}
+
+// planSync works out what --sync downloads and deletes, printing the plan in full for --sync-dry-run
+func (d *download) planSync(listing downloadListing) (helpers.SyncPlan, error) {
+	plan, err := helpers.PlanSync(d.outputDir, d.components, listing.listed, listing.files)
+	if err != nil {
+		return plan, err
+	}
+	// Local files the filters keep out of the download, such as .git, are left alone
+	plan.Remove, _ = helpers.ExcludeNames(plan.Remove, d.components.Dir, d.fetchOpts.Excludes)
+	plan.Remove, _ = helpers.FilterGlobs(plan.Remove, d.components.Dir, d.fetchOpts.Include, d.fetchOpts.Exclude)
+	fmt.Fprintf(d.status, "[-] Sync: %d files to download, %d unchanged, %d to delete\n", len(plan.Download), plan.Unchanged, len(plan.Remove))
+	if d.opts.syncDryRun {
+		for _, file := range plan.Download {
+			fmt.Fprintf(d.status, "  + %s\n", file.Path)
+		}
+		for _, file := range plan.Remove {
+			fmt.Fprintf(d.status, "  - %s\n", file.Path)
+		}
+	}
+	return plan, nil
+}
+
+// fetchResult is what the fetch stage did
+type fetchResult struct {
+	// files are the files it set out to fetch, of which failed failed and unstarted were left
+	// for the --budget that ran out
+	files     []model.FileInfo
+	failed    []downloadFailure
+	unstarted []model.FileInfo
+	manifest  helpers.Manifest
+	syncPlan  helpers.SyncPlan
+}
+
+// fetch downloads the listed files, or those --sync has to, leaving out files the manifest
+// records as unchanged. Files come from the repository tarball when it pays off and one by one
+// otherwise; those the budget leaves unstarted get placeholders.
+func (d *download) fetch(ctx context.Context, listing downloadListing, syncPlan helpers.SyncPlan) (fetchResult, error) {
+	o, client, components := d.opts, d.client, &d.components
+	result := fetchResult{files: listing.files, syncPlan: syncPlan}
+	if o.sync {
+		result.files = syncPlan.Download
+	}
+
+	if o.useManifest() {
+		var err error
+		if result.manifest, err = helpers.LoadManifest(d.outputDir, *components); err != nil {
+			log.Printf("warning: %v, downloading every file", err)
+		}
+		// Transformed and rendered files depend on more than their blob, so they are always fetched
+		if !o.force && !o.sync && d.fetchOpts.Transform == nil && d.fetchOpts.Templates == nil {
+			var unchanged int
+			if result.files, unchanged = result.manifest.Changed(d.outputDir, *components, result.files); unchanged > 0 {
+				fmt.Fprintf(d.status, "[-] Skipping %d files unchanged since the last download (--force to fetch them)\n", unchanged)
+			}
+		}
+	}
+	files := result.files
+	fmt.Fprintf(d.status, "[-] Fetching %d files\n", len(files))
+	if d.workers < o.concurrency {
+		fmt.Fprintf(d.status, "[-] Limiting concurrency to %d to stay within the open file limit\n", d.workers)
+	}
+
+	// Auto only picks the tarball when nothing needs files handled one by one
+	tarballable := o.strategy == "auto" && d.transferBudget == nil && o.prFiles == 0 && !o.followSymlinks && !o.dereferenceSymlinks && !components.IsFile
+	// Without a token the API allows 60 requests an hour, so auto sticks to raw downloads, which
+	// don't count against it, rather than spend one on the tarball
+	useTarball := len(files) > 0 && (o.strategy == "tarball" ||
+		(tarballable && client.Token != "" && (len(files) >= autoTarballFiles || (components.Dir == "" && len(files) > 1))))
+	// Private files are each downloaded through the contents API, which --max-api-calls must cover
+	if left := client.CallLimit.Remaining(); left >= 0 && components.Private && !useTarball && len(files) > left {
+		if !tarballable {
+			return result, fmt.Errorf("downloading %d files of a private repository takes more API calls than the %d --max-api-calls leaves", len(files), left)
+		}
+		fmt.Fprintf(d.status, "[-] Using the tarball strategy: %d files would take more API calls than the %d --max-api-calls leaves\n", len(files), left)
+		useTarball = true
+	}
+	// Besides the tarball, the ref is looked up again once the download is done
+	needed := 1
+	if useTarball {
+		needed++
+	}
+	anonymousQuota(client, needed)
+	remaining := files
+	if useTarball {
+		var err error
+		if remaining, err = runTarballStrategy(ctx, client, components, files, d.fetchOpts, d.status); err != nil {
+			return result, err
+		}
+	}
+
+	if o.warmUp && len(remaining) > 0 {
+		if components.Private {
+			fmt.Fprintf(d.status, "[-] Skipping the warm-up, as probing private files costs an API request each\n")
+		} else {
+			var stats gh.WarmUpStats
+			remaining, stats = client.WarmUp(ctx, *components, remaining, d.workers)
+			fmt.Fprintf(d.status, "[-] Warm-up probed %d files: %d sizes filled in, %d Git LFS files found\n", stats.Probed, stats.Sized, stats.LFS)
+		}
+	}
+
+	if len(remaining) > 0 || (!useTarball && len(files) > 0) {
+		result.failed, result.unstarted = downloadFiles(ctx, client, components, remaining, d.workers, d.fetchOpts,
+			d.status, d.progressOut, d.multiProgress, d.transferBudget, d.events)
+	}
+	if o.submodules == "clone" && len(listing.submodules) > 0 {
+		if err := downloadSubmodules(ctx, client, *components, listing.submodules, d.workers, d.fetchOpts, 0); err != nil {
+			return result, err
+		}
+	}
+	if err := d.events.Err(); err != nil {
+		log.Printf("warning: error writing JSON log: %v", err)
+	}
+	if d.reportPath != "" {
+		reported := d.events.Events()
+		for _, file := range result.unstarted {
+			reported = append(reported, helpers.FileEvent{Path: file.Path, Status: "skipped", Error: "not started before the --budget ran out"})
+		}
+		if err := writeJUnitReport(d.reportPath, path.Join(components.Owner, components.Repository, components.Dir), reported); err != nil {
+			return result, err
+		}
+	}
+	if len(result.unstarted) > 0 {
+		fmt.Fprintf(d.status, "[-] Budget of %s exhausted with %d files left\n", d.transferBudget, len(result.unstarted))
+		if err := writePlaceholders(d.outputDir, *components, result.unstarted); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// finish post-processes the fetched files: verifying, annotating, extracting and checksumming
+// them, then packing, archiving or laying them out as asked, or recording them in the manifest,
+// deleting what --sync removes and moving staged files into place
+func (d *download) finish(listing downloadListing, result fetchResult) error {
+	o, components := d.opts, d.components
+	failed, unstarted := result.failed, result.unstarted
+	if o.verifyUpstream || o.sidecars || o.autoExtract {
+		failedFiles := slices.Clone(unstarted)
+		for _, failure := range failed {
+			failedFiles = append(failedFiles, failure.File)
+		}
+		saved := succeededFiles(result.files, failedFiles)
+		if o.verifyUpstream {
+			if err := verifyUpstreamChecksums(d.status, d.fetchOpts.OutputDir, components, saved); err != nil {
+				return err
+			}
+		}
+		if o.sidecars {
+			count, err := helpers.WriteSidecars(d.fetchOpts.OutputDir, d.client.GitBaseURL, components, saved)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(d.status, "[-] Wrote %d provenance sidecars\n", count)
+		}
+		if o.autoExtract {
+			extractArchives(d.status, d.fetchOpts.OutputDir, components, saved)
+		}
+	}
+	if o.sha256sums || o.checksumManifest != "" {
+		// Checksums cover files left alone as unchanged as well as those downloaded again
+		if len(failed) > 0 || len(unstarted) > 0 {
+			fmt.Fprintf(d.status, "[-] Not writing checksums, as not every file was downloaded\n")
+		} else if err := writeChecksums(d.status, d.fetchOpts.OutputDir, components, listing.files, o.sha256sums, o.checksumManifest); err != nil {
+			return err
+		}
+	}
+	if o.packFile != "" {
+		title := fmt.Sprintf("%s @ %s", path.Join(components.Owner, components.Repository, components.Dir), components.Ref)
+		entries, dropped, err := writePackFile(o.packFile, title, d.fetchOpts.OutputDir, components, result.files, o.maxTokens, o.charsPerToken)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(d.status, "[-] Packed %d files into %s (~%d tokens)\n", len(entries), o.packFile, helpers.PackTokens(entries))
+		if dropped > 0 {
+			fmt.Fprintf(d.status, "[-] Dropped %d lowest-priority files to stay within %d tokens\n", dropped, o.maxTokens)
+		}
+		return nil
+	}
+	if o.archive != "" {
+		return writeArchive(d.status, o.archive, d.fetchOpts.OutputDir, failed)
+	}
+	if o.layout == "cas" {
+		return writeCASLayout(d.status, d.fetchOpts.OutputDir, d.outputDir)
+	}
+	if o.useManifest() {
+		failedFiles := append(slices.Clone(unstarted), result.syncPlan.Remove...)
+		for _, failure := range failed {
+			failedFiles = append(failedFiles, failure.File)
+		}
+		result.manifest.Record(succeededFiles(result.files, failedFiles), failedFiles)
+		if err := helpers.SaveManifest(d.outputDir, result.manifest); err != nil {
+			log.Printf("warning: %v", err)
+		}
+	}
+	if o.sync && len(result.syncPlan.Remove) > 0 {
+		if len(failed) > 0 {
+			fmt.Fprintf(d.status, "[-] Keeping %d files the repository no longer has since downloads failed\n", len(result.syncPlan.Remove))
+		} else if err := helpers.RemoveSynced(d.outputDir, components, result.syncPlan.Remove); err != nil {
+			return err
+		} else {
+			fmt.Fprintf(d.status, "[-] Deleted %d files the repository no longer has\n", len(result.syncPlan.Remove))
+		}
+	}
+	return promoteStaged(d.staged, d.outputDir, len(failed))
+}
+
+// succeededFiles returns the files not among failed
+func succeededFiles(files, failed []model.FileInfo) []model.FileInfo {
+	failedPaths := make(map[string]bool, len(failed))
+	for _, file := range failed {
+		failedPaths[file.Path] = true
+	}
+	var succeeded []model.FileInfo
 	for _, file := range files {
+		if !failedPaths[file.Path] {
+			succeeded = append(succeeded, file)
+		}
+	}
+	return succeeded
+}
+
+// verifyUpstreamChecksums checks the saved files against the checksum files saved with them,
+// failing the run when any differ. With --staging-dir, mismatched files are never moved into place.
+func verifyUpstreamChecksums(status io.Writer, outputDir string, components model.RepoURLComponents, saved []model.FileInfo) error {
+	verified, mismatches, err := helpers.VerifyUpstream(outputDir, components, saved)
+	if err != nil {
+		return fmt.Errorf("error verifying upstream checksums: %v", err)
+	}
+	for _, mismatch := range mismatches {
+		log.Printf("checksum mismatch: %s", mismatch)
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%d files don't match their upstream checksums", len(mismatches))
+	}
+	fmt.Fprintf(status, "[-] Verified %d files against upstream checksum files\n", verified)
+	return nil
+}
+
+// writeChecksums writes the SHA-256 of each selected file saved under outputDir to a
+// SHA256SUMS file at the root of the download and, when manifest is set, to that JSON file
+func writeChecksums(status io.Writer, outputDir string, components model.RepoURLComponents, selected []model.FileInfo, sha256sums bool, manifest string) error {
+	sums, err := helpers.ComputeChecksums(outputDir, components, selected)
+	if err != nil {
+		return fmt.Errorf("error computing checksums: %v", err)
+	}
+	if sha256sums {
+		if slices.ContainsFunc(sums, func(sum helpers.FileChecksum) bool { return sum.Path == helpers.ChecksumsFile }) {
+			return fmt.Errorf("the repository has its own %s, which --sha256sums would overwrite; use --manifest instead", helpers.ChecksumsFile)
+		}
+		if err := helpers.WriteSHA256Sums(filepath.Join(helpers.DownloadRoot(outputDir, components), helpers.ChecksumsFile), sums); err != nil {
+			return err
+		}
+	}
+	if manifest != "" {
+		if err := helpers.WriteChecksumManifest(manifest, components, sums); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(status, "[-] Wrote SHA-256 checksums of %d files\n", len(sums))
+	return nil
+}
+
+// extractArchives extracts the downloaded archives among files saved under outputDir. An
+// archive that can't be extracted is reported but fails nothing, as it was downloaded fine.
+func extractArchives(status io.Writer, outputDir string, components model.RepoURLComponents, files []model.FileInfo) {
+	baseDir := filepath.Base(components.OutputRoot())
+	archives, extracted := 0, 0
+	for _, file := range files {
+		if _, ok := helpers.ExtractDir(file.Path); !ok {
+			continue
+		}
+		local, err := helpers.OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			log.Printf("warning: not extracting %s: %v", file.Path, err)
+			continue
+		}
+		// Binary files --text-only removed again aren't there to extract
+		if _, err := os.Stat(local); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		count, err := helpers.ExtractArchive(local)
+		if err != nil {
+			log.Printf("warning: not extracting %s: %v", file.Path, err)
+			continue
+		}
+		archives++
+		extracted += count
+	}
+	if archives > 0 {
+		fmt.Fprintf(status, "[-] Extracted %d files from %d archives\n", extracted, archives)
+	}
+}
+
+// writeJUnitReport writes the outcome of every file to a JUnit XML report at name
+func writeJUnitReport(name, suite string, events []helpers.FileEvent) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("error creating report: %v", err)
+	}
+	defer f.Close()
+	if err := helpers.WriteJUnit(f, suite, events); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	return f.Close()
+}
+
+// writeCASLayout lays a download gathered under dir out by content in outputDir
+func writeCASLayout(status io.Writer, dir, outputDir string) error {
+	count, err := helpers.WriteCASLayout(dir, outputDir)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(status, "[-] Stored %d files under objects/, indexed by %s\n", count, helpers.CASTreeFile)
+	return nil
+}
+
+// writeArchive archives a download gathered under dir. Failed files don't prevent the archive,
+// which lists them in its FAILED.txt, but are still reported as an error.
+func writeArchive(status io.Writer, name, dir string, failed []downloadFailure) error {
+	lines := make([]string, len(failed))
+	for i, failure := range failed {
+		lines[i] = failure.String()
+	}
+	if err := helpers.WriteArchive(name, dir, lines); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed; %s holds the rest and lists them in %s", len(failed), name, helpers.FailedManifest)
+	}
+	fmt.Fprintf(status, "[-] Wrote %s\n", name)
+	return nil
+}
+
+// warnRefDrift looks the download's branch up again once it's done and warns when the branch
+// moved on in the meantime. Files were all fetched at the pinned commit, so the download is one
+// snapshot, but no longer the latest.
+func warnRefDrift(ctx context.Context, client *gh.Client, components model.RepoURLComponents) {
+	// The check is worth less than the requests left to a client without a token
+	if components.Commit == "" || gitproto.IsObjectID(components.Ref) || !quotaFits(client, 1) {
+		return
+	}
+	pinned := components.Commit
+	components.Commit = ""
+	sha, err := client.ResolveCommit(ctx, components)
+	if err != nil || sha == pinned {
+		return
+	}
+	log.Printf("warning: %s moved from %.7s to %.7s during the download, which holds the files at %.7s; run again to update",
+		components.Ref, pinned, sha, pinned)
+}
+
+// resolveCommit returns the commit a download's ref pointed to, or "" when it can't be looked up
+func resolveCommit(ctx context.Context, client *gh.Client, components model.RepoURLComponents) string {
+	if components.Commit != "" {
+		return components.Commit
+	}
+	if gitproto.IsObjectID(components.Ref) {
+		return components.Ref
+	}
+	sha, err := client.ResolveCommit(ctx, components)
+	if err != nil {
+		return ""
+	}
+	return sha
+}
+
+// runAllRefs downloads the directory at every branch and tag matching pattern by running the
+// command again for each, with --ref set and {ref} added to the output directory unless the
+// --output template already has it
+func runAllRefs(
+	ctx context.Context,
+	status io.Writer,
+	client *gh.Client,
+	components model.RepoURLComponents,
+	name string,
+	args []string,
+	pattern, output string,
+) error {
+	refs, err := client.MatchingRefs(ctx, components, pattern)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return fmt.Errorf("no branch or tag of %s/%s matches %q", components.Owner, components.Repository, pattern)
+	}
+	if !strings.Contains(output, "{ref}") {
+		output = filepath.Join(output, "{ref}")
+	}
+	fmt.Fprintf(status, "[-] Downloading %s from %d refs matching %s\n", components.Dir, len(refs), pattern)
+
+	// Profiling already covers the whole run, and a second pprof server couldn't bind its address
+	base := dropFlags(args, "all-refs", "ref", "output", "pprof", "cpuprofile", "memprofile")
+	var failedRefs []string
+	for _, ref := range refs {
+		fmt.Fprintf(status, "[-] Ref %s\n", ref)
+		if err := run(name, append([]string{"--ref", ref, "--output", output}, base...)); err != nil {
+			log.Printf("error downloading %s: %v", ref, err)
+			failedRefs = append(failedRefs, ref)
+		}
+	}
+	if len(failedRefs) > 0 {
+		return fmt.Errorf("%d of %d refs failed: %s", len(failedRefs), len(refs), strings.Join(failedRefs, ", "))
+	}
+	return nil
+}
+
+// detectPrivate marks private repositories so their files are downloaded with the token.
+// Only a missing repository is fatal; when the check fails otherwise, downloads proceed as public.
+func detectPrivate(ctx context.Context, client *gh.Client, components *model.RepoURLComponents) error {
+	private, err := client.FetchRepoIsPrivate(ctx, components)
+	switch {
+	case errors.Is(err, gh.ErrRepositoryNotFound) && client.Token == "":
+		return fmt.Errorf("%v (private repositories need a token from --token, GITHUB_TOKEN, GH_TOKEN or gh auth login)", err)
+	case errors.Is(err, gh.ErrRepositoryNotFound), errors.Is(err, gh.ErrBlocked):
+		return err
+	case err != nil:
+		log.Printf("warning: couldn't tell whether the repository is private, downloading it as public: %v", err)
+	}
+	components.Private = private
+	return nil
+}
+
+// promoteStaged moves a staged download into outputDir once no file failed, leaving
+// everything in staging otherwise. It does nothing when no staging directory is used.
+func promoteStaged(staged, outputDir string, failed int) error {
+	if staged == "" {
+		return nil
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d files failed, so nothing was moved into place; completed files are kept in %s", failed, staged)
+	}
+
+	dest, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("error resolving output directory: %v", err)
+	}
+	if err := helpers.PromoteStaged(staged, dest); err != nil {
+		return err
+	}
+	return os.Remove(staged)
+}
+
+// downloadFailure is a file that couldn't be downloaded
+type downloadFailure struct {
+	File model.FileInfo
+	Err  error
+}
+
+func (f downloadFailure) String() string {
+	return fmt.Sprintf("%s: %v", f.File.Path, f.Err)
+}
+
+// progressDisplay draws the progress of downloadFiles, either as a single bar or a line per download
+type progressDisplay interface {
+	Increment()
+	Finish()
+}
+
+// downloadFiles fetches files with a pool of workers in the given order, showing progress on
+// progressOut (status when nil) and logging each failed file, then prints a per-subdirectory
+// summary to status. Once budget runs out no further file is started. Every file's outcome is also
+// written to events. It returns the files that failed and those never started.
+func downloadFiles(
+	ctx context.Context,
+	client *gh.Client,
+	components *model.RepoURLComponents,
+	files []model.FileInfo,
+	workers int,
+	fetchOpts gh.FetchOptions,
+	status, progressOut io.Writer,
+	multiProgress bool,
+	budget *helpers.TransferBudget,
+	events *helpers.EventLog,
+) (failed []downloadFailure, unstarted []model.FileInfo) {
+	// Tree sizes seed the byte progress; responses correct them where they differ (e.g. LFS)
+	progress := helpers.NewByteProgress()
+	progress.ExpectFiles(files)
+	fetchOpts.Progress = progress
+	if progressOut == nil {
+		progressOut = status
+	}
+
+	report := helpers.NewReport(components.Dir)
+	var bar progressDisplay
+	if multiProgress {
+		multi := &helpers.MultiBar{Out: progressOut}
+		multi.Config(int64(len(files)), progress)
+		bar = multi
+	} else {
+		single := &helpers.Bar{Out: progressOut}
+		single.Config(0, int64(len(files)), "[-] Progress: ")
+		single.TrackBytes(progress)
+		bar = single
+	}
+
+	var wg sync.WaitGroup
+	var verified atomic.Int64
+	failures := make(chan downloadFailure, len(files))
+	download := func(file model.FileInfo) {
+		progress.Begin(file.Path)
+		result, err := client.FetchPublicFile(ctx, file, components, fetchOpts)
+		progress.End(file.Path)
+		if errors.Is(err, gh.ErrBinarySkipped) {
+			report.Record(file, helpers.Skipped, 0)
+			events.Write(helpers.FileEvent{Path: file.Path, Status: "skipped"})
+			bar.Increment()
+			return
+		}
+		// A file withheld on its own, such as after a takedown notice, can't be had by retrying
+		if errors.Is(err, gh.ErrBlocked) {
+			log.Printf("warning: skipping %s, %v", file.Path, err)
+			report.Record(file, helpers.Skipped, 0)
+			events.Write(helpers.FileEvent{Path: file.Path, Status: "skipped", Error: err.Error(), Category: gh.CategoryBlocked})
+			bar.Increment()
+			return
+		}
+		if err != nil {
+			report.Record(file, helpers.Failed, 0)
+			events.Write(helpers.FileEvent{Path: file.Path, Status: "failed", Error: err.Error(), Category: gh.ErrorCategory(err)})
+			failures <- downloadFailure{File: file, Err: err}
+			return
+		}
+		if result.Verified {
+			verified.Add(1)
+		}
+		if result.Cached {
+			report.Record(file, helpers.Skipped, result.Written)
+			events.Write(helpers.FileEvent{Path: file.Path, Status: "cached", Bytes: result.Written})
+		} else {
+			report.Record(file, helpers.Downloaded, result.Written)
+			events.Write(helpers.FileEvent{Path: file.Path, Status: "downloaded", Bytes: result.Written})
+		}
+		bar.Increment()
+	}
+
+	// Small and large files are downloaded by pools of their own, so a few large files can't
+	// occupy every worker while thousands of small ones wait. Each pool takes its files in
+	// order, so prioritized files are scheduled before the rest, and helps the other pool once
+	// its own files have all been started.
+	smallWorkers, largeWorkers := sizeTiers(workers)
+	small, large := make(chan model.FileInfo), make(chan model.FileInfo)
+	for i := 0; i < smallWorkers+largeWorkers; i++ {
+		own, other := small, large
+		if i >= smallWorkers {
+			own, other = large, small
+		}
 		wg.Add(1)
-		go func(file string) {
+		go func() {
 			defer wg.Done()
+			for file := range own {
+				download(file)
+			}
+			for file := range other {
+				download(file)
+			}
+		}()
+	}
 
-			err := gh.FetchPublicFile(ctx, file, &components)
-			if err != nil {
-				errorsCh <- fmt.Errorf("error fetching %s: %v", file, err)
+	budget.Start(time.Now())
+	var feeders sync.WaitGroup
+	var unstartedMu sync.Mutex
+	unstartedAt := map[int]bool{}
+	// Without a pool for large files, every file goes to the one for small files
+	inLargePool := func(file model.FileInfo) bool { return largeWorkers > 0 && isLargeFile(file) }
+	feed := func(jobs chan<- model.FileInfo, isLarge bool) {
+		defer feeders.Done()
+		defer close(jobs)
+		for i, file := range files {
+			if inLargePool(file) != isLarge {
+				continue
+			}
+			if done, _ := progress.Snapshot(); budget.Exhausted(done, time.Now()) {
+				unstartedMu.Lock()
+				for j := i; j < len(files); j++ {
+					if inLargePool(files[j]) == isLarge {
+						unstartedAt[j] = true
+					}
+				}
+				unstartedMu.Unlock()
 				return
 			}
-			bar.Update(bar.Cur + 1)
-		}(file)
+			jobs <- file
+		}
+	}
+	feeders.Add(1)
+	go feed(small, false)
+	if largeWorkers > 0 {
+		feeders.Add(1)
+		go feed(large, true)
+	} else {
+		close(large)
 	}
 
 	go func() {
 		wg.Wait()
-		close(errorsCh)
+		close(failures)
 		bar.Finish()
 	}()
 
-	for err := range errorsCh {
-		log.Println(err)
+	for failure := range failures {
+		log.Printf("error fetching %v", failure)
+		failed = append(failed, failure)
+	}
+	feeders.Wait()
+	for i, file := range files {
+		if unstartedAt[i] {
+			unstarted = append(unstarted, file)
+		}
+	}
+
+	fmt.Fprintln(status)
+	helpers.RenderReport(status, report.Dirs())
+	if fetchOpts.Verify {
+		mismatched := 0
+		for _, failure := range failed {
+			if errors.Is(failure.Err, gh.ErrChecksumMismatch) {
+				mismatched++
+			}
+		}
+		fmt.Fprintf(status, "[-] Verified %d files against their git blob SHAs, %d mismatched\n", verified.Load(), mismatched)
+	}
+	return failed, unstarted
+}
+
+// smallFileSize is the size below which files are downloaded by the pool for small files
+const smallFileSize = 64 << 10
+
+// isLargeFile reports whether a file is downloaded by the pool for large files. Files of
+// unknown size are, as they may be anything.
+func isLargeFile(file model.FileInfo) bool {
+	return file.Size < 0 || file.Size >= smallFileSize
+}
+
+// sizeTiers splits workers between the pools for small and large files, giving a quarter to
+// large files, which are bound by bandwidth rather than by the number of requests in flight.
+// A single worker isn't split, leaving large files to the one pool.
+func sizeTiers(workers int) (small, large int) {
+	if workers < 2 {
+		return workers, 0
+	}
+	large = max(workers/4, 1)
+	return workers - large, large
+}
+
+// autoTarballFiles is how many files make --strategy auto download the repository tarball
+// instead of each file
+const autoTarballFiles = 200
+
+// runTarballStrategy extracts files from the repository tarball, returning those left to
+// download individually
+func runTarballStrategy(
+	ctx context.Context,
+	client *gh.Client,
+	components *model.RepoURLComponents,
+	files []model.FileInfo,
+	fetchOpts gh.FetchOptions,
+	status io.Writer,
+) ([]model.FileInfo, error) {
+	fmt.Fprintf(status, "[-] Streaming the repository tarball at %s\n", components.Ref)
+	stats, remaining, err := client.FetchViaTarball(ctx, components, files, fetchOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch via tarball: %v", err)
+	}
+
+	fmt.Fprintf(status, "[-] Extracted %d files (%s) from the tarball\n", stats.Files, helpers.FormatByteSize(stats.Bytes))
+	if stats.Skipped > 0 {
+		fmt.Fprintf(status, "[-] Skipped %d binary files\n", stats.Skipped)
+	}
+	if len(remaining) > 0 {
+		fmt.Fprintf(status, "[-] Downloading %d Git LFS or changed files individually\n", len(remaining))
+	}
+	return remaining, nil
+}
+
+// runGitStrategy downloads the directory over git's smart HTTP protocol. With a cache,
+// unchanged blobs are restored from it and the rest fetched as deltas.
+func runGitStrategy(
+	ctx context.Context,
+	client *gh.Client,
+	components *model.RepoURLComponents,
+	fetchOpts gh.FetchOptions,
+	cache *gh.FileCache,
+	status io.Writer,
+) error {
+	fmt.Fprintf(status, "[-] Repository: %s/%s\n", components.Owner, components.Repository)
+	fmt.Fprintf(status, "[-] Negotiating packfile for %s\n", components.Dir)
+
+	stats, err := client.FetchViaGit(ctx, components, cache, fetchOpts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch via git protocol: %v", err)
 	}
 
+	fmt.Fprintf(status, "[-] %d files: %d restored from cache, %d fetched\n", stats.Files, stats.Restored, stats.Fetched)
 	return nil
 }
@@ -5,4 +5,33 @@ type RepoURLComponents struct {
 	Repository string
 	Ref        string
 	Dir        string
+	// FilePath is the file to download when the URL names a single file, with Dir its parent
+	FilePath string
+	// IsFile is set for single-file URLs such as /blob/ URLs
+	IsFile bool
+	// Private is set for private repositories, whose files are downloaded with the token
+	Private bool
+	// RefResolved is set once Ref is known not to continue into Dir. A URL such as
+	// /tree/feat/new-feature/docs is parsed with Ref "feat" until the branches are checked.
+	RefResolved bool
+	// Commit is the SHA Ref pointed to when the download started. Content is fetched by it when
+	// set, so a branch moving mid-download can't mix two snapshots.
+	Commit string
+}
+
+// ContentRef returns the ref content is fetched at: the pinned commit, or else Ref
+func (c RepoURLComponents) ContentRef() string {
+	if c.Commit != "" {
+		return c.Commit
+	}
+	return c.Ref
+}
+
+// OutputRoot returns the path whose last element starts every output path: the directory
+// being downloaded, or the file itself for single-file downloads
+func (c RepoURLComponents) OutputRoot() string {
+	if c.IsFile {
+		return c.FilePath
+	}
+	return c.Dir
 }
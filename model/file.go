@@ -0,0 +1,26 @@
+package model
+
+// FileInfo describes a file in a repository listing
+type FileInfo struct {
+	Path string
+	// Size is the content length in bytes, or -1 when the listing doesn't report it
+	Size int64
+	// SHA is the git blob SHA-1 of the file content
+	SHA string
+	// Mode is the git file mode, e.g. "100644", "100755" or "120000"; empty when unknown
+	Mode string
+	// Source is the repository path content is read from when it differs from Path, as for
+	// files reached through a directory symlink; empty otherwise
+	Source string
+	// LFS is set once the file is known to be stored in Git LFS, before downloading it; Size
+	// is then the content's rather than the pointer's
+	LFS bool
+}
+
+// SourcePath returns the repository path the file's content is read from
+func (f FileInfo) SourcePath() string {
+	if f.Source != "" {
+		return f.Source
+	}
+	return f.Path
+}
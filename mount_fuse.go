@@ -0,0 +1,199 @@
+//go:build linux && fuse
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"repo-pack/fuse"
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// runMount handles `repo-pack mount [flags] <url> <mountpoint>`, serving the remote directory
+// read-only at mountpoint until it is unmounted or repo-pack is interrupted
+func runMount(args []string) error {
+	flags := flag.NewFlagSet("mount", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	cacheDir := flags.String("cache-dir", "", "Local blob cache directory (defaults to the user cache directory)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("usage: repo-pack mount [--token token] [--cache-dir dir] <url> <mountpoint>")
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		if components, err = helpers.ParseRepoRootURL(repoURL); err != nil {
+			return fmt.Errorf("failed to parse repository URL: %v", err)
+		}
+	}
+	if components.IsFile {
+		return fmt.Errorf("mount takes a directory URL, not a file's")
+	}
+	mountpoint := flags.Arg(1)
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+	if err := detectPrivate(ctx, client, &components); err != nil {
+		return err
+	}
+
+	files, _, err := client.RepoListingSlashBranchSupport(ctx, &components)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %v", err)
+	}
+	tree, byPath, err := mountTree(components, files)
+	if err != nil {
+		return err
+	}
+
+	cache, err := openCache(*cacheDir)
+	if err != nil {
+		return err
+	}
+	staging, err := os.MkdirTemp("", "repo-pack-mount-")
+	if err != nil {
+		return fmt.Errorf("error creating staging directory: %v", err)
+	}
+	defer os.RemoveAll(staging)
+
+	fs := &remoteFS{
+		ctx:        ctx,
+		client:     client,
+		components: components,
+		files:      byPath,
+		opts: gh.FetchOptions{
+			OutputDir: staging,
+			Cache:     cache,
+			Warn:      func(err error) { log.Printf("[!] %v", err) },
+		},
+		fetched: make(map[string]*fetchedFile),
+	}
+
+	dev, err := fuse.Mount(mountpoint, components.Owner+"/"+components.Repository)
+	if err != nil {
+		return err
+	}
+	defer dev.Close()
+
+	// An interrupt unmounts, which ends Serve; the filesystem can't outlive the process serving it
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupts)
+	go func() {
+		if _, ok := <-interrupts; ok {
+			if err := fuse.Unmount(mountpoint); err != nil {
+				log.Printf("[!] %v", err)
+			}
+		}
+	}()
+
+	fmt.Printf("[+] Mounted %d files of %s/%s at %s; unmount it or press Ctrl-C to stop\n",
+		len(files), components.Owner, components.Repository, mountpoint)
+	err = fuse.Serve(dev, tree, fs, func(err error) { log.Printf("[!] %v", err) })
+	close(interrupts)
+	if err != nil {
+		fuse.Unmount(mountpoint)
+		return err
+	}
+	fmt.Printf("[-] Unmounted %s\n", mountpoint)
+	return nil
+}
+
+// mountTree arranges the listed files by their path below the requested directory, returning
+// them by that path as well
+func mountTree(components model.RepoURLComponents, files []model.FileInfo) (*fuse.Tree, map[string]model.FileInfo, error) {
+	tree := fuse.NewTree()
+	byPath := make(map[string]model.FileInfo, len(files))
+	prefix := strings.Trim(components.Dir, "/") + "/"
+	for _, file := range files {
+		rel := strings.TrimPrefix(file.Path, prefix)
+		var mode os.FileMode = 0o444
+		switch file.Mode {
+		case "100755":
+			mode = 0o555
+		case "120000":
+			mode = os.ModeSymlink | 0o777
+		}
+		// An unknown size is corrected when the file is opened
+		if err := tree.Add(rel, mode, max(file.Size, 0)); err != nil {
+			return nil, nil, fmt.Errorf("error mounting %s: %v", file.Path, err)
+		}
+		byPath[rel] = file
+	}
+	return tree, byPath, nil
+}
+
+// remoteFS fetches the files of a mount the first time they are opened, through the blob cache,
+// into a staging directory removed on unmount
+type remoteFS struct {
+	ctx        context.Context
+	client     *gh.Client
+	components model.RepoURLComponents
+	files      map[string]model.FileInfo
+	opts       gh.FetchOptions
+
+	mu      sync.Mutex
+	fetched map[string]*fetchedFile
+}
+
+// fetchedFile is a file fetched once however many times it is opened
+type fetchedFile struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+func (fs *remoteFS) Open(p string) (*os.File, error) {
+	file, ok := fs.files[p]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	fs.mu.Lock()
+	fetched, ok := fs.fetched[p]
+	if !ok {
+		fetched = &fetchedFile{}
+		fs.fetched[p] = fetched
+	}
+	fs.mu.Unlock()
+
+	fetched.once.Do(func() {
+		// A symlink is served as a file holding its target, which the kernel reads as the link
+		file.Mode = ""
+		components := fs.components
+		result, err := fs.client.FetchPublicFile(fs.ctx, file, &components, fs.opts)
+		fetched.path = result.Path
+		if err != nil {
+			fetched.err = fmt.Errorf("error fetching %s: %v", file.Path, err)
+		}
+	})
+	if fetched.err != nil {
+		// The next open tries again, as a failed request may not fail twice
+		fs.mu.Lock()
+		if fs.fetched[p] == fetched {
+			delete(fs.fetched, p)
+		}
+		fs.mu.Unlock()
+		return nil, fetched.err
+	}
+	return os.Open(fetched.path)
+}
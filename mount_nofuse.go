@@ -0,0 +1,10 @@
+//go:build !linux || !fuse
+
+package main
+
+import "fmt"
+
+// runMount reports that this build has no mount command, which needs Linux and the fuse tag
+func runMount(args []string) error {
+	return fmt.Errorf("mount is experimental and only built on Linux with `go build -tags fuse`")
+}
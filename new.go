@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// runNew handles `repo-pack new [flags] <url> [dest]`, bootstrapping a project from a template
+// directory. Variables described by the directory's scaffold file are prompted for, and its
+// templates rendered, before the result is moved into dest in one step.
+func runNew(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	var vars listFlag
+	flags.Var(&vars, "vars", "Template variable as key=value, skipping its prompt (repeatable)")
+	templateExt := flags.String("template-ext", ".tmpl", "Extension of the files rendered as Go templates")
+	concurrency := flags.Int("concurrency", 10, "Maximum number of files to download at once")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return fmt.Errorf("usage: repo-pack new [--token token] [--vars key=value] [--template-ext .tmpl] <url> [dest]")
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", *concurrency)
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		if components, err = helpers.ParseRepoRootURL(repoURL); err != nil {
+			return fmt.Errorf("failed to parse repository URL: %v", err)
+		}
+	}
+
+	preset, err := helpers.ParseVars(vars)
+	if err != nil {
+		return fmt.Errorf("invalid --vars: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+	if err := detectPrivate(ctx, client, &components); err != nil {
+		return err
+	}
+
+	files, _, err := client.RepoListingSlashBranchSupport(ctx, &components)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %v", err)
+	}
+
+	spec, files, err := scaffoldSpec(ctx, client, components, files)
+	if err != nil {
+		return err
+	}
+	templateVars, err := helpers.PromptVars(os.Stdin, os.Stdout, spec, preset)
+	if err != nil {
+		return err
+	}
+
+	baseDir := filepath.Base(components.Dir)
+	dest := flags.Arg(1)
+	if dest == "" {
+		dest = baseDir
+		if dest == "." {
+			dest = components.Repository
+		}
+	}
+	if _, err := os.Lstat(dest); err == nil {
+		return fmt.Errorf("%s already exists", dest)
+	}
+
+	// Files land in a hidden sibling of dest, so the finished project appears with one rename
+	destAbs, err := filepath.Abs(dest)
+	if err != nil {
+		return err
+	}
+	staged, err := os.MkdirTemp(filepath.Dir(destAbs), ".repo-pack-new-")
+	if err != nil {
+		return fmt.Errorf("error creating staging directory: %v", err)
+	}
+	defer os.RemoveAll(staged)
+
+	workers, err := helpers.FitConcurrency(*concurrency, 0)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("[-] Scaffolding %s from %s/%s\n", dest, components.Owner, components.Repository)
+	failed, _ := downloadFiles(ctx, client, &components, files, workers, gh.FetchOptions{
+		StreamThreshold: 1 << 20,
+		Budget:          helpers.NewMemoryBudget(64 << 20),
+		Warn: func(err error) {
+			log.Printf("warning: %v", err)
+		},
+		OutputDir: staged,
+		Templates: &helpers.Templates{Ext: *templateExt, Vars: templateVars},
+	}, os.Stdout, nil, false, nil, nil)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed, %s was not created", len(failed), dest)
+	}
+
+	src := staged
+	if baseDir != "." {
+		src = filepath.Join(staged, baseDir)
+	}
+	if err := os.Rename(src, destAbs); err != nil {
+		return fmt.Errorf("error creating %s: %v", dest, err)
+	}
+
+	fmt.Printf("[-] Created %s\n", dest)
+	return nil
+}
+
+// scaffoldSpec reads the template directory's scaffold file, if it has one, and returns it
+// along with the files to download, which leave the scaffold file out
+func scaffoldSpec(
+	ctx context.Context,
+	client *gh.Client,
+	components model.RepoURLComponents,
+	files []model.FileInfo,
+) (helpers.ScaffoldSpec, []model.FileInfo, error) {
+	specPath := path.Join(components.Dir, helpers.ScaffoldFile)
+	rest := make([]model.FileInfo, 0, len(files))
+	found := false
+	for _, file := range files {
+		if file.Path == specPath {
+			found = true
+			continue
+		}
+		rest = append(rest, file)
+	}
+	if !found {
+		return helpers.ScaffoldSpec{}, rest, nil
+	}
+
+	data, err := client.RawFile(ctx, components, specPath)
+	if err != nil {
+		return helpers.ScaffoldSpec{}, nil, fmt.Errorf("failed to read %s: %v", helpers.ScaffoldFile, err)
+	}
+	spec, err := helpers.ParseScaffold(data)
+	if err != nil {
+		return helpers.ScaffoldSpec{}, nil, fmt.Errorf("invalid %s: %v", helpers.ScaffoldFile, err)
+	}
+	return spec, rest, nil
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+)
+
+// getOptions are the flags of the download commands
+type getOptions struct {
+	global globalFlags
+
+	repoURL, dir, ref, allRefs string
+	requireSigned              bool
+	prFiles                    int
+
+	priority                      string
+	concurrency                   int
+	streamThreshold, memoryBudget string
+	sparse                        bool
+	maxOpenFiles                  uint64
+
+	pprofAddr, cpuProfile, memProfile string
+	record, replay, chaos             string
+
+	noCache                bool
+	cacheDir, remoteCache  string
+	strategy               string
+	packFile               string
+	maxTokens              int
+	charsPerToken          float64
+	followSymlinks         bool
+	dereferenceSymlinks    bool
+	submodules             string
+	noDefaultExcludes      bool
+	verifyUpstream, verify bool
+	textOnly, interactive  bool
+	includes, excludes     listFlag
+	transforms, vars       listFlag
+	templateExt            string
+
+	jsonLog, report, progressLog, progressStyle string
+	budget                                      string
+	placeholders                                bool
+	layout, output, archive, stagingDir         string
+	sync, syncDryRun, force                     bool
+	sidecars, sha256sums                        bool
+	checksumManifest                            string
+	autoExtract, warmUp                         bool
+	toStdout                                    bool
+	stdoutFormat                                string
+}
+
+// parseGetOptions parses the flags of the download command name, taking the URL from --url
+// or the only argument. Flags implied by others are set along the way.
+func parseGetOptions(name string, args []string) (*getOptions, error) {
+	o := &getOptions{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&o.repoURL, "url", "", "GitHub directory (/tree/) or file (/blob/) URL, or a pull request URL to download its head commit")
+	flags.StringVar(&o.dir, "dir", "", "Directory to download when --url is a pull request URL (default: the whole repository)")
+	flags.StringVar(&o.ref, "ref", "", "Ref to download instead of the URL's: a branch, tag or commit, \"latest\", or a semver range such as ^1.2 resolved against tags")
+	flags.StringVar(&o.allRefs, "all-refs", "", "Download the directory once per branch and tag matching this glob (e.g. \"release/*\" or \"v*\"), each into a {ref} subdirectory")
+	flags.BoolVar(&o.requireSigned, "require-signed", false, "Abort unless GitHub reports the resolved commit's signature as verified")
+	flags.IntVar(&o.prFiles, "pr-files", 0, "Download only the files this pull request adds or modifies, at its head commit")
+	o.global = addGlobalFlags(flags)
+	flags.StringVar(&o.priority, "priority", "", "Comma-separated glob patterns of files to download first (e.g. \"README*,go.mod\")")
+	flags.IntVar(&o.concurrency, "concurrency", 10, "Maximum number of files to download at once")
+	flags.StringVar(&o.streamThreshold, "stream-threshold", "1MB", "Size above which files are always streamed to disk instead of buffered")
+	flags.StringVar(&o.memoryBudget, "memory-budget", "64MB", "Maximum memory used for buffered downloads across all workers")
+	flags.BoolVar(&o.sparse, "sparse", false, "Write all-zero blocks as holes to save disk space on large mostly-empty files")
+	flags.Uint64Var(&o.maxOpenFiles, "max-open-files", 0, "Maximum file descriptors downloads may use at once (0 uses the OS limit)")
+	flags.StringVar(&o.pprofAddr, "pprof", "", "Serve live pprof endpoints on this address (e.g. :6060)")
+	flags.StringVar(&o.cpuProfile, "cpuprofile", "", "Write a CPU profile to this file")
+	flags.StringVar(&o.memProfile, "memprofile", "", "Write a heap profile to this file on exit")
+	flags.StringVar(&o.record, "record", "", "Record every HTTP response into this fixture directory")
+	flags.StringVar(&o.replay, "replay", "", "Answer HTTP requests from fixtures recorded with --record instead of the network")
+	flags.StringVar(&o.chaos, "chaos", "", "Randomly fail and delay requests, e.g. p=0.1,delay=500ms,seed=1, to test retries and resumes")
+	flags.BoolVar(&o.noCache, "no-cache", false, "Don't restore files from or add them to the local blob cache")
+	flags.StringVar(&o.cacheDir, "cache-dir", "", "Local blob cache directory (defaults to the user cache directory)")
+	flags.StringVar(&o.remoteCache, "remote-cache", "", "Shared blob cache consulted before GitHub (http(s)://host/path or s3://bucket/prefix)")
+	flags.StringVar(&o.packFile, "pack-file", "", "Concatenate the downloaded text files into this single Markdown document instead of writing them individually")
+	flags.IntVar(&o.maxTokens, "max-tokens", 0, "With --pack-file, truncate or drop the lowest-priority files so the pack fits this many estimated tokens (0 for no limit)")
+	flags.Float64Var(&o.charsPerToken, "chars-per-token", helpers.DefaultCharsPerToken, "Characters per token assumed when estimating pack token counts")
+	flags.BoolVar(&o.followSymlinks, "follow-symlinks", false, "Download the contents of symlinked directories inside the repository under the link's path")
+	flags.BoolVar(&o.dereferenceSymlinks, "dereference-symlinks", false, "Save the files symlinks point to instead of recreating the links, following symlinked directories as --follow-symlinks does")
+	flags.StringVar(&o.submodules, "submodules", "skip", "What to do with submodules: skip them with a warning, clone them (download each at its pinned commit into its path) or error")
+	flags.BoolVar(&o.noDefaultExcludes, "no-default-excludes", false, "Also download .git, node_modules, dist, __pycache__ and .DS_Store entries, which are skipped by default")
+	flags.BoolVar(&o.verifyUpstream, "verify-upstream", false, "Check downloaded files against the SHA256SUMS and *.sha256 files downloaded alongside them, failing on a mismatch")
+	flags.BoolVar(&o.verify, "verify", false, "Check every file against the git blob SHA from the listing, failing files that don't match")
+	flags.BoolVar(&o.textOnly, "text-only", false, "Skip binary files, judged by extension before downloading and by content after")
+	flags.BoolVar(&o.interactive, "interactive", false, "Choose the files to download in a terminal picker with fuzzy filtering")
+	flags.StringVar(&o.jsonLog, "json", "", "Write an NDJSON line per file to this file, with its status and, for failures, a stable error category")
+	flags.StringVar(&o.report, "report", "", "Write every file's outcome as a CI report, given as format:path, e.g. junit:report.xml")
+	flags.StringVar(&o.progressLog, "progress-log", "", "Append progress lines to this file instead of drawing the bar on stdout")
+	flags.StringVar(&o.progressStyle, "progress", "bar", "Progress display: bar (one aggregate bar) or multi (a line per active download plus a total)")
+	flags.StringVar(&o.budget, "budget", "", "Stop starting downloads once this much time (e.g. 5m) or data (e.g. 500MB) is spent, leaving the rest as placeholders for repo-pack fetch")
+	flags.BoolVar(&o.placeholders, "placeholders", false, "Write empty placeholder files instead of downloading, to be filled later with repo-pack fetch <path>")
+	flags.StringVar(&o.layout, "layout", "tree", "Output layout: tree (the repository's directory structure) or cas (objects/<sha256> plus a tree.json mapping paths to hashes)")
+	flags.StringVar(&o.output, "output", "", "Directory to download into, where {owner}, {repo}, {ref} and {dir} are filled in (default: the working directory)")
+	flags.StringVar(&o.archive, "archive", "", "Write the download to this .zip, .tar.gz or .tar archive instead of the working directory")
+	flags.StringVar(&o.stagingDir, "staging-dir", "", "Write files under this directory first, moving them into place only once every file succeeded")
+	flags.BoolVar(&o.sync, "sync", false, "Mirror the remote directory: download only new and changed files and delete local files the repository no longer has")
+	flags.BoolVar(&o.sidecars, "sidecars", false, "Write <file>"+helpers.SidecarSuffix+" next to each downloaded file with its source URL, blob SHA, size and ref")
+	flags.BoolVar(&o.sha256sums, "sha256sums", false, "Once every file is downloaded, write a "+helpers.ChecksumsFile+" file at the root of the download covering them, for sha256sum -c")
+	flags.StringVar(&o.checksumManifest, "manifest", "", "Once every file is downloaded, write their SHA-256 checksums to this JSON file")
+	flags.BoolVar(&o.autoExtract, "auto-extract", false, "Extract downloaded .zip, .tar.gz and .tgz files into a directory of the same name next to them")
+	flags.BoolVar(&o.warmUp, "warm-up", false, "Probe files with HEAD requests before downloading, to learn sizes the listing lacks and find Git LFS files up front")
+	flags.BoolVar(&o.force, "force", false, "Download every file, even those the output directory's manifest records as unchanged since the last download")
+	flags.BoolVar(&o.syncDryRun, "sync-dry-run", false, "Print what --sync would download and delete without changing anything")
+	flags.BoolVar(&o.toStdout, "stdout", false, "Write a single file's content to stdout instead of saving it, or a directory as a tar stream with --format tar")
+	flags.StringVar(&o.stdoutFormat, "format", "", "With --stdout, tar streams a directory as an uncompressed tar archive")
+	flags.Var(&o.includes, "include", "Only download files matching this gitignore-style pattern, relative to the directory (repeatable)")
+	flags.Var(&o.excludes, "exclude", "Skip files matching this gitignore-style pattern, relative to the directory (repeatable)")
+	flags.Var(&o.transforms, "transform", "Rewrite text files as they are saved: dos2unix, unix2dos, sed:s/pattern/replacement/[gi] or exec:command (repeatable, applied in order)")
+	flags.Var(&o.vars, "vars", "Template variable as key=value, available as {{.key}} in rendered templates (repeatable)")
+	flags.StringVar(&o.templateExt, "template-ext", "", "Render files with this extension as Go templates and drop it from their names (default .tmpl when --vars is given)")
+	flags.StringVar(&o.strategy, "strategy", "files", "Download strategy: files (per-file raw downloads), tarball (one repository tarball, extracting the directory), auto (tarball for large directories, files otherwise), git (shallow sparse fetch over the git protocol) or delta (git, reusing the local cache)")
+	var packOutput *string
+	if name == "pack" {
+		packOutput = flags.String("o", "", "Archive to write: .zip, .tar.gz or .tar")
+	}
+	hideFlags(flags, "chaos")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+
+	if o.repoURL == "" && flags.NArg() == 1 {
+		o.repoURL = flags.Arg(0)
+	} else if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(flags.Args(), " "))
+	}
+	if o.repoURL == "" {
+		return nil, fmt.Errorf("missing argument for repoURL")
+	}
+	var err error
+	if o.repoURL, err = resolveAlias(o.repoURL); err != nil {
+		return nil, err
+	}
+	if packOutput != nil {
+		if *packOutput == "" {
+			return nil, fmt.Errorf("usage: repo-pack pack [flags] -o <archive.zip|archive.tar.gz> <url>")
+		}
+		o.archive = *packOutput
+	}
+
+	if o.syncDryRun {
+		o.sync = true
+	}
+	// Packs are for text pipelines, so binary files never make it in
+	if o.packFile != "" {
+		o.textOnly = true
+	}
+	if len(o.vars) > 0 && o.templateExt == "" {
+		o.templateExt = ".tmpl"
+	}
+	return o, nil
+}
+
+// perFileStrategy reports whether files are listed one by one, as the tarball strategy lists
+// them like the files strategy and auto falls back to it
+func (o *getOptions) perFileStrategy() bool {
+	return o.strategy == "files" || o.strategy == "auto"
+}
+
+// gitStrategy reports whether the directory is fetched over git's protocol
+func (o *getOptions) gitStrategy() bool {
+	return o.strategy == "git" || o.strategy == "delta"
+}
+
+// useManifest reports whether files are saved as they are in the output directory, where a
+// manifest can tell unchanged ones
+func (o *getOptions) useManifest() bool {
+	return o.layout == "tree" && o.packFile == "" && o.archive == "" && o.stagingDir == ""
+}
+
+// validate rejects flag values out of range and combinations of flags that can't work together.
+// Combinations depending on the URL are checked once it is parsed.
+func (o *getOptions) validate() error {
+	switch o.strategy {
+	case "files", "auto", "tarball", "git", "delta":
+	default:
+		return fmt.Errorf("unknown strategy %q, expected files, tarball, auto, git or delta", o.strategy)
+	}
+	switch o.layout {
+	case "tree", "cas":
+	default:
+		return fmt.Errorf("unknown layout %q, expected tree or cas", o.layout)
+	}
+	switch o.submodules {
+	case "skip", "clone", "error":
+	default:
+		return fmt.Errorf("unknown --submodules %q, expected skip, clone or error", o.submodules)
+	}
+	switch o.progressStyle {
+	case "bar", "multi":
+	default:
+		return fmt.Errorf("unknown progress display %q, expected bar or multi", o.progressStyle)
+	}
+	if o.concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", o.concurrency)
+	}
+	if o.maxTokens < 0 {
+		return fmt.Errorf("--max-tokens must not be negative, got %d", o.maxTokens)
+	}
+	if o.charsPerToken <= 0 {
+		return fmt.Errorf("--chars-per-token must be positive, got %v", o.charsPerToken)
+	}
+
+	if o.record != "" && o.replay != "" {
+		return fmt.Errorf("--record and --replay cannot be used together")
+	}
+	if o.maxTokens > 0 && o.packFile == "" {
+		return fmt.Errorf("--max-tokens only applies to --pack-file output")
+	}
+	if o.budget != "" && (o.placeholders || o.packFile != "" || o.archive != "" || o.layout != "tree" || o.stagingDir != "" || !o.perFileStrategy()) {
+		return fmt.Errorf("--budget only works when downloading files into the working directory with the files strategy")
+	}
+	if o.placeholders && (o.packFile != "" || o.archive != "" || o.layout != "tree" || o.stagingDir != "") {
+		return fmt.Errorf("--placeholders cannot be combined with --pack-file, --archive, --layout or --staging-dir")
+	}
+	if o.layout == "cas" && (o.packFile != "" || o.archive != "" || o.stagingDir != "") {
+		return fmt.Errorf("--layout cas cannot be combined with --pack-file, --archive or --staging-dir")
+	}
+	if o.archive != "" {
+		if o.packFile != "" || o.stagingDir != "" {
+			return fmt.Errorf("--archive cannot be combined with --pack-file or --staging-dir")
+		}
+		if err := helpers.CheckArchiveName(o.archive); err != nil {
+			return err
+		}
+	}
+	if o.packFile != "" && o.stagingDir != "" {
+		return fmt.Errorf("--pack-file and --staging-dir cannot be used together")
+	}
+	if o.packFile != "" && o.gitStrategy() {
+		return fmt.Errorf("--pack-file only works with the files strategy")
+	}
+
+	templates := o.templateExt != ""
+	// Rendered templates are saved under other names than the listing's, so they'd look extraneous
+	if o.sync && (o.packFile != "" || o.archive != "" || o.layout != "tree" || o.stagingDir != "" || o.placeholders ||
+		o.budget != "" || o.interactive || templates) {
+		return fmt.Errorf("--sync only works when downloading files into a directory, without --pack-file, --archive, --layout cas, --staging-dir, --placeholders, --budget, --interactive or templates")
+	}
+	if o.sync && o.gitStrategy() {
+		return fmt.Errorf("--sync only works with the files, tarball and auto strategies")
+	}
+	if o.sidecars && (o.packFile != "" || o.layout != "tree" || o.placeholders || o.toStdout || o.gitStrategy()) {
+		return fmt.Errorf("--sidecars only works when saving files as they are with the files, tarball and auto strategies, not with --pack-file, --layout cas, --placeholders or --stdout")
+	}
+	// Rewritten or rendered content can't match checksums of the original
+	if o.verifyUpstream && (o.packFile != "" || o.layout != "tree" || o.placeholders || o.toStdout ||
+		len(o.transforms) > 0 || templates) {
+		return fmt.Errorf("--verify-upstream only works when saving files as they are, not with --pack-file, --layout cas, --placeholders, --stdout, transforms or templates")
+	}
+	// Extracted files aren't in the listing, so --sync would take them for deleted ones
+	if o.autoExtract && (o.packFile != "" || o.layout != "tree" || o.placeholders || o.toStdout || o.sync) {
+		return fmt.Errorf("--auto-extract only works when saving files as they are, not with --pack-file, --layout cas, --placeholders, --stdout or --sync")
+	}
+	if (o.sha256sums || o.checksumManifest != "") && (o.packFile != "" || o.layout != "tree" || o.placeholders || o.toStdout || o.gitStrategy()) {
+		return fmt.Errorf("--sha256sums and --manifest only work when saving files into a directory with the files, tarball and auto strategies, not with --pack-file, --layout cas, --placeholders or --stdout")
+	}
+	// Submodule files aren't in the listing, so --sync would take them for deleted ones
+	if o.submodules == "clone" && (o.packFile != "" || o.layout != "tree" || o.placeholders || o.toStdout || o.sync || o.gitStrategy()) {
+		return fmt.Errorf("--submodules=clone only works when saving files into a directory with the files, tarball and auto strategies, not with --pack-file, --layout cas, --placeholders, --stdout or --sync")
+	}
+
+	if o.stdoutFormat != "" && (o.stdoutFormat != "tar" || !o.toStdout) {
+		return fmt.Errorf("--format only takes tar, together with --stdout")
+	}
+	if o.toStdout {
+		if o.packFile != "" || o.archive != "" || o.output != "" || o.layout != "tree" || o.stagingDir != "" || o.sync ||
+			o.placeholders || o.budget != "" || o.allRefs != "" || len(o.transforms) > 0 || templates {
+			return fmt.Errorf("--stdout cannot be combined with --pack-file, --archive, --output, --layout cas, --staging-dir, --sync, --placeholders, --budget, --all-refs, --transform or templates")
+		}
+		if !o.perFileStrategy() {
+			return fmt.Errorf("--stdout only works with the files and auto strategies")
+		}
+	}
+	if o.noCache && o.strategy == "delta" {
+		return fmt.Errorf("the delta strategy needs the local cache, so it can't be used with --no-cache")
+	}
+
+	if o.allRefs != "" && (o.ref != "" || o.packFile != "" || o.archive != "") {
+		return fmt.Errorf("--all-refs can't be combined with --ref, a pull request URL, --pack-file or --archive")
+	}
+	if o.prFiles != 0 && !o.perFileStrategy() {
+		return fmt.Errorf("--pr-files only works with the files strategy")
+	}
+	if o.followSymlinks && !o.perFileStrategy() {
+		return fmt.Errorf("--follow-symlinks only works with the files strategy")
+	}
+	if o.dereferenceSymlinks && !o.perFileStrategy() {
+		return fmt.Errorf("--dereference-symlinks only works with the files strategy")
+	}
+	if o.placeholders && !o.perFileStrategy() {
+		return fmt.Errorf("--placeholders only works with the files strategy")
+	}
+	if o.interactive && o.gitStrategy() {
+		return fmt.Errorf("--interactive only works with the files, tarball and auto strategies")
+	}
+	if o.interactive && !helpers.IsTerminal(os.Stdin) {
+		return fmt.Errorf("--interactive needs a terminal on stdin")
+	}
+	return nil
+}
+
+// fetchOptions builds the options every file is fetched with
+func (o *getOptions) fetchOptions() (gh.FetchOptions, error) {
+	threshold, err := helpers.ParseByteSize(o.streamThreshold)
+	if err != nil {
+		return gh.FetchOptions{}, fmt.Errorf("invalid --stream-threshold: %v", err)
+	}
+	budget, err := helpers.ParseByteSize(o.memoryBudget)
+	if err != nil {
+		return gh.FetchOptions{}, fmt.Errorf("invalid --memory-budget: %v", err)
+	}
+	fetchOpts := gh.FetchOptions{
+		StreamThreshold: threshold,
+		Budget:          helpers.NewMemoryBudget(budget),
+		Sparse:          o.sparse,
+		TextOnly:        o.textOnly,
+		Verify:          o.verify,
+		Warn: func(err error) {
+			log.Printf("warning: %v", err)
+		},
+	}
+	if !o.noDefaultExcludes {
+		fetchOpts.Excludes = helpers.DefaultExcludes
+	}
+	if fetchOpts.Include, err = helpers.ParseGlobs(o.includes); err != nil {
+		return fetchOpts, fmt.Errorf("invalid --include: %v", err)
+	}
+	if fetchOpts.Exclude, err = helpers.ParseGlobs(o.excludes); err != nil {
+		return fetchOpts, fmt.Errorf("invalid --exclude: %v", err)
+	}
+	if fetchOpts.Transform, err = helpers.ParseTransforms(o.transforms); err != nil {
+		return fetchOpts, fmt.Errorf("invalid --transform: %v", err)
+	}
+	if o.templateExt != "" {
+		vars, err := helpers.ParseVars(o.vars)
+		if err != nil {
+			return fetchOpts, fmt.Errorf("invalid --vars: %v", err)
+		}
+		fetchOpts.Templates = &helpers.Templates{Ext: o.templateExt, Vars: vars}
+	}
+	return fetchOpts, nil
+}
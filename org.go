@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// runOrg handles `repo-pack org <org> --dir <dir>`, downloading the same directory from every
+// repository of an organization, e.g. to audit workflows or policies across it
+func runOrg(args []string) error {
+	flags := flag.NewFlagSet("org", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	dir := flags.String("dir", "", "Directory to download from each repository, e.g. .github/workflows")
+	name := flags.String("name", "", "Only repositories whose name matches this glob pattern, e.g. \"svc-*\"")
+	var topics listFlag
+	flags.Var(&topics, "topic", "Only repositories with this topic (repeatable, all must match)")
+	skipForks := flags.Bool("skip-forks", false, "Leave out forked repositories")
+	skipArchived := flags.Bool("skip-archived", false, "Leave out archived repositories")
+	output := flags.String("output", "{repo}", "Directory each repository's files go to, where {owner}, {repo}, {ref} and {dir} are filled in")
+	concurrency := flags.Int("concurrency", 10, "Maximum number of files to download at once")
+	collision := flags.String("collision", "overwrite", "When repositories' files land on the same path, as with an --output without {repo}: overwrite (the last one wins) or hash-suffix (append a short blob hash to each differing file's name)")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	// Flags may also follow the organization, as in `repo-pack org acme --dir docs`
+	if flags.NArg() > 1 {
+		org := flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+		args = append([]string{org}, flags.Args()...)
+	} else {
+		args = flags.Args()
+	}
+
+	if len(args) != 1 || *dir == "" {
+		return fmt.Errorf("usage: repo-pack org [--token token] [--name glob] [--topic topic] [--output template] <org> --dir <dir>")
+	}
+	if _, err := path.Match(*name, ""); err != nil {
+		return fmt.Errorf("invalid --name pattern %q: %v", *name, err)
+	}
+	if *collision != "overwrite" && *collision != "hash-suffix" {
+		return fmt.Errorf("unknown --collision %q, expected overwrite or hash-suffix", *collision)
+	}
+	workers, err := helpers.FitConcurrency(*concurrency, 0)
+	if err != nil {
+		return err
+	}
+
+	org := args[0]
+	ctx := context.Background()
+	client, err := global.newClient("")
+	if err != nil {
+		return err
+	}
+	repos, err := client.OrgRepositories(ctx, org)
+	if err != nil {
+		return err
+	}
+
+	var selected []gh.Repository
+	for _, repo := range repos {
+		if matchesOrgFilters(repo, *name, topics, *skipForks, *skipArchived) {
+			selected = append(selected, repo)
+		}
+	}
+	fmt.Printf("[-] Downloading %s from %d of %d repositories in %s\n", *dir, len(selected), len(repos), org)
+
+	// Every repository is listed before any is downloaded, so files landing on the same path
+	// can be told apart whichever repository comes first
+	var missing, failedRepos []string
+	var downloads []orgDownload
+	for _, repo := range selected {
+		components := model.RepoURLComponents{
+			Owner:      org,
+			Repository: repo.Name,
+			Ref:        repo.DefaultBranch,
+			Dir:        strings.Trim(*dir, "/"),
+			Private:    repo.Private,
+			// The default branch is named by the API, so the directory never holds part of it
+			RefResolved: true,
+		}
+		download, err := listOrgRepo(ctx, client, components, *output)
+		switch {
+		case err != nil:
+			log.Printf("error listing %s/%s: %v", org, repo.Name, err)
+			failedRepos = append(failedRepos, repo.Name)
+		case len(download.files) == 0:
+			missing = append(missing, repo.Name)
+		default:
+			downloads = append(downloads, download)
+		}
+	}
+
+	if *collision == "hash-suffix" {
+		listings := make([][]model.FileInfo, len(downloads))
+		for i := range downloads {
+			listings[i] = downloads[i].files
+		}
+		renamed, err := helpers.SuffixCollisions(listings, func(i int, file model.FileInfo) (string, error) {
+			return helpers.OutputPath(downloads[i].outputDir, filepath.Base(downloads[i].components.Dir), file.Path)
+		})
+		if err != nil {
+			return err
+		}
+		if renamed > 0 {
+			fmt.Printf("[-] Suffixing %d files whose paths collide across repositories with their blob hash\n", renamed)
+		}
+	}
+
+	for _, download := range downloads {
+		if err := downloadOrgRepo(ctx, client, download, workers); err != nil {
+			log.Printf("error downloading %s/%s: %v", org, download.components.Repository, err)
+			failedRepos = append(failedRepos, download.components.Repository)
+		}
+	}
+
+	if len(missing) > 0 {
+		fmt.Printf("[-] %d repositories have no %s: %s\n", len(missing), *dir, strings.Join(missing, ", "))
+	}
+	if len(failedRepos) > 0 {
+		return fmt.Errorf("%d repositories failed: %s", len(failedRepos), strings.Join(failedRepos, ", "))
+	}
+	return nil
+}
+
+// matchesOrgFilters reports whether a repository passes the org command's filters
+func matchesOrgFilters(repo gh.Repository, name string, topics []string, skipForks, skipArchived bool) bool {
+	if (skipForks && repo.Fork) || (skipArchived && repo.Archived) {
+		return false
+	}
+	if name != "" {
+		if ok, _ := path.Match(name, repo.Name); !ok {
+			return false
+		}
+	}
+	for _, topic := range topics {
+		if !slices.Contains(repo.Topics, topic) {
+			return false
+		}
+	}
+	return true
+}
+
+// orgDownload is the listing of one repository's directory and where its files go
+type orgDownload struct {
+	components model.RepoURLComponents
+	files      []model.FileInfo
+	outputDir  string
+}
+
+// listOrgRepo lists components.Dir in one repository and expands the output template for it.
+// A repository without that directory has no files.
+func listOrgRepo(ctx context.Context, client *gh.Client, components model.RepoURLComponents, output string) (orgDownload, error) {
+	files, _, err := client.RepoListingSlashBranchSupport(ctx, &components)
+	if err != nil {
+		return orgDownload{}, err
+	}
+	outputDir, err := helpers.ExpandOutputDir(output, components)
+	if err != nil {
+		return orgDownload{}, fmt.Errorf("invalid --output: %v", err)
+	}
+	return orgDownload{components: components, files: files, outputDir: outputDir}, nil
+}
+
+// downloadOrgRepo downloads a listed repository directory into its output directory
+func downloadOrgRepo(ctx context.Context, client *gh.Client, download orgDownload, workers int) error {
+	components, files, outputDir := download.components, download.files, download.outputDir
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+
+	fmt.Printf("[-] %s/%s: fetching %d files into %s\n", components.Owner, components.Repository, len(files), outputDir)
+	failed, _ := downloadFiles(ctx, client, &components, helpers.GroupByDirectory(files), workers, gh.FetchOptions{
+		StreamThreshold: 1 << 20,
+		Budget:          helpers.NewMemoryBudget(64 << 20),
+		Warn: func(err error) {
+			log.Printf("warning: %v", err)
+		},
+		OutputDir: outputDir,
+	}, os.Stdout, nil, false, nil, nil)
+	if len(failed) > 0 {
+		return fmt.Errorf("%d files failed", len(failed))
+	}
+	return nil
+}
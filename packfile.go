@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// writePackFile concatenates the files downloaded under outputDir into one document at name,
+// in download order. Files that were skipped or failed are left out, and with maxTokens above
+// zero the lowest-priority files are truncated or dropped to fit. It returns the entries
+// written and how many files were dropped.
+func writePackFile(
+	name, title, outputDir string,
+	components model.RepoURLComponents,
+	files []model.FileInfo,
+	maxTokens int,
+	charsPerToken float64,
+) ([]helpers.PackEntry, int, error) {
+	baseDir := filepath.Base(components.OutputRoot())
+	entries := make([]helpers.PackEntry, 0, len(files))
+	for _, file := range files {
+		saved, err := helpers.OutputPath(outputDir, baseDir, file.Path)
+		if err != nil {
+			return nil, 0, err
+		}
+		content, err := os.ReadFile(saved)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, helpers.PackEntry{Path: file.Path, Content: content})
+	}
+
+	entries, dropped := helpers.FitTokenBudget(entries, maxTokens, charsPerToken)
+
+	out, err := os.Create(name)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error creating pack file: %v", err)
+	}
+	if err := helpers.WritePack(out, title, entries); err != nil {
+		out.Close()
+		return nil, 0, fmt.Errorf("error writing pack file: %v", err)
+	}
+	return entries, dropped, out.Close()
+}
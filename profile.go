@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	_ "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiling serves live pprof endpoints on pprofAddr and starts a CPU profile when
+// requested. The returned stop func finishes the CPU profile and writes the heap profile.
+func startProfiling(pprofAddr, cpuProfile, memProfile string) (func(), error) {
+	if pprofAddr != "" {
+		go func() {
+			if err := http.ListenAndServe(pprofAddr, nil); err != nil {
+				log.Printf("pprof server stopped: %v", err)
+			}
+		}()
+		fmt.Printf("[-] Serving pprof on http://%s/debug/pprof/\n", pprofAddr)
+	}
+
+	var cpuFile *os.File
+	if cpuProfile != "" {
+		file, err := os.Create(cpuProfile)
+		if err != nil {
+			return nil, fmt.Errorf("error creating CPU profile %s: %v", cpuProfile, err)
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error starting CPU profile: %v", err)
+		}
+		cpuFile = file
+	}
+
+	stop := func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if memProfile != "" {
+			if err := writeHeapProfile(memProfile); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	return stop, nil
+}
+
+// writeHeapProfile writes an up-to-date heap profile to path
+func writeHeapProfile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating memory profile %s: %v", path, err)
+	}
+	defer file.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		return fmt.Errorf("error writing memory profile: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+
+	"repo-pack/gh"
+)
+
+// anonymousQuota prints, for a client without a token, how many of GitHub's anonymous API
+// requests are left against the needed more a run is about to make, and reports whether they
+// fit as quotaFits does
+func anonymousQuota(client *gh.Client, needed int) bool {
+	remaining, limit, reset, ok := client.RateLimiter.Quota()
+	if client.Token != "" || !ok {
+		return true
+	}
+	of := ""
+	if limit > 0 {
+		of = fmt.Sprintf(" of %d", limit)
+	}
+	fmt.Printf("[-] Without a token: %d%s API requests left until %s, about %d needed (--token raises the limit)\n",
+		remaining, of, reset.Format("15:04"), needed)
+	return quotaFits(client, needed)
+}
+
+// quotaFits reports whether needed more API requests of a client without a token fit in what
+// is left of the anonymous limit, without pausing for it to reset. Clients with a token, and
+// those no API response has reported the limit to yet, always fit.
+func quotaFits(client *gh.Client, needed int) bool {
+	remaining, _, _, ok := client.RateLimiter.Quota()
+	return client.Token != "" || !ok || remaining-needed >= client.RateLimiter.Reserve
+}
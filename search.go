@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+)
+
+// runSearchGet handles `repo-pack search-get --query <query> <url>`, downloading the files
+// that a code search query matches instead of a whole directory
+func runSearchGet(args []string) error {
+	flags := flag.NewFlagSet("search-get", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	query := flags.String("query", "", "Code search query selecting the files (e.g. \"filename:Dockerfile path:deploy\")")
+	concurrency := flags.Int("concurrency", 10, "Maximum number of files to download at once")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 || *query == "" {
+		return fmt.Errorf("usage: repo-pack search-get --query <query> [--token token] [--concurrency n] <url>")
+	}
+	if *concurrency < 1 {
+		return fmt.Errorf("concurrency must be at least 1, got %d", *concurrency)
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	// A tree URL limits matches to its directory; a bare repository URL searches everything
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		if components, err = helpers.ParseRepoRootURL(repoURL); err != nil {
+			return fmt.Errorf("failed to parse repository URL: %v", err)
+		}
+	}
+
+	workers, err := helpers.FitConcurrency(*concurrency, 0)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+	if client.Token == "" {
+		return fmt.Errorf("search-get needs a token, the code search API rejects anonymous requests")
+	}
+	if err := detectPrivate(ctx, client, &components); err != nil {
+		return err
+	}
+	if err := client.ResolveURLRef(ctx, &components); err != nil {
+		return err
+	}
+
+	files, err := client.SearchCode(ctx, components, *query)
+	if err != nil {
+		return err
+	}
+	files = helpers.GroupByDirectory(files)
+
+	fmt.Printf("[-] Repository: %s/%s\n", components.Owner, components.Repository)
+	fmt.Printf("[-] Query: %s\n", *query)
+	fmt.Printf("[-] Fetching %d matching files\n", len(files))
+	if len(files) == 0 {
+		return nil
+	}
+
+	downloadFiles(ctx, client, &components, files, workers, gh.FetchOptions{
+		StreamThreshold: 1 << 20,
+		Budget:          helpers.NewMemoryBudget(64 << 20),
+		Warn: func(err error) {
+			log.Printf("warning: %v", err)
+		},
+	}, os.Stdout, nil, false, nil, nil)
+	return nil
+}
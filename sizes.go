@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"repo-pack/helpers"
+)
+
+// runSizes handles `repo-pack sizes [flags] <url>`, printing how the remote directory's
+// size breaks down by extension and top-level subdirectory
+func runSizes(args []string) error {
+	flags := flag.NewFlagSet("sizes", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	by := flags.String("by", "all", "Breakdown to print: ext, dir or all")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: repo-pack sizes [--token token] [--by ext|dir|all] <url>")
+	}
+	if *by != "ext" && *by != "dir" && *by != "all" {
+		return fmt.Errorf("unknown breakdown %q, expected ext, dir or all", *by)
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository URL: %v", err)
+	}
+
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+
+	files, _, err := client.RepoListingSlashBranchSupport(context.Background(), &components)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %v", err)
+	}
+
+	if *by == "ext" || *by == "all" {
+		helpers.RenderSizes(os.Stdout, "EXTENSION", helpers.GroupSizes(files, helpers.ByExtension))
+	}
+	if *by == "all" {
+		fmt.Println()
+	}
+	if *by == "dir" || *by == "all" {
+		helpers.RenderSizes(os.Stdout, "DIRECTORY", helpers.GroupSizes(files, helpers.ByTopLevelDir(components.Dir)))
+	}
+	return nil
+}
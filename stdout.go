@@ -0,0 +1,90 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// streamToStdout writes the downloaded content to w instead of the output directory: a single
+// file as it is, or with asTar, each file as an entry of an uncompressed tar stream named as it
+// would be saved, ready to pipe into `tar -x` or `docker build -`. Files are fetched one at a
+// time, in order, so nothing is buffered on disk. The closing summary goes to status.
+func streamToStdout(ctx context.Context, client *gh.Client, components *model.RepoURLComponents, files []model.FileInfo, w, status io.Writer, asTar bool) error {
+	if !asTar {
+		content, _, err := client.OpenFile(ctx, files[0], components)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		if _, err := io.Copy(w, content); err != nil {
+			return fmt.Errorf("error writing %s to stdout: %v", files[0].Path, err)
+		}
+		return nil
+	}
+
+	baseDir := filepath.Base(components.OutputRoot())
+	tw := tar.NewWriter(w)
+	for _, file := range files {
+		name, err := helpers.OutputPath(".", baseDir, file.Path)
+		if err != nil {
+			return err
+		}
+		if err := writeTarEntry(ctx, client, components, file, tw, filepath.ToSlash(name)); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error writing tar stream: %v", err)
+	}
+	fmt.Fprintf(status, "[-] Streamed %d files\n", len(files))
+	return nil
+}
+
+// writeTarEntry downloads a file into the next entry of tw. Symlinks become link entries and
+// the executable bit is kept. Content of unknown length is read into memory first, as tar
+// headers carry the size.
+func writeTarEntry(ctx context.Context, client *gh.Client, components *model.RepoURLComponents, file model.FileInfo, tw *tar.Writer, name string) error {
+	content, size, err := client.OpenFile(ctx, file, components)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	var body io.Reader = content
+	if size < 0 || file.Mode == "120000" {
+		data, err := io.ReadAll(content)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", file.Path, err)
+		}
+		body, size = bytes.NewReader(data), int64(len(data))
+	}
+
+	header := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if mode, err := strconv.ParseInt(file.Mode, 8, 64); err == nil && mode&0o111 != 0 {
+		header.Mode = 0o755
+	}
+	if file.Mode == "120000" {
+		// Raw downloads of a link hold its target
+		target, _ := io.ReadAll(body)
+		header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, string(target), 0
+		body = bytes.NewReader(nil)
+	}
+
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing tar stream: %v", err)
+	}
+	if _, err := io.Copy(tw, body); err != nil {
+		return fmt.Errorf("error writing %s to the tar stream: %v", file.Path, err)
+	}
+	return nil
+}
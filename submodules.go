@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// maxSubmoduleDepth bounds how deeply --submodules=clone follows submodules of submodules
+const maxSubmoduleDepth = 8
+
+// submodulePaths lists the paths of submodule entries for messages
+func submodulePaths(entries []model.FileInfo) string {
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = entry.Path
+	}
+	return strings.Join(paths, ", ")
+}
+
+// downloadSubmodules downloads each submodule among entries, listed for components, at its
+// pinned commit into its path under fetchOpts.OutputDir, followed by their own submodules.
+// Submodules that can't be fetched from this host, such as ones on another server, are
+// reported and passed over; submodules whose listing or files fail fail the run.
+func downloadSubmodules(
+	ctx context.Context,
+	client *gh.Client,
+	components model.RepoURLComponents,
+	entries []model.FileInfo,
+	workers int,
+	fetchOpts gh.FetchOptions,
+	depth int,
+) error {
+	submodules, err := client.Submodules(ctx, components, entries)
+	if err != nil {
+		return fmt.Errorf("error reading .gitmodules of %s/%s: %v", components.Owner, components.Repository, err)
+	}
+	baseDir := filepath.Base(components.OutputRoot())
+
+	var failedSubmodules []string
+	for _, submodule := range submodules {
+		subComponents, err := client.SubmoduleRepository(components, submodule)
+		if err != nil {
+			log.Printf("warning: skipping submodule: %v", err)
+			continue
+		}
+		if err := detectPrivate(ctx, client, &subComponents); err != nil {
+			log.Printf("submodule %s: %v", submodule.Path, err)
+			failedSubmodules = append(failedSubmodules, submodule.Path)
+			continue
+		}
+		files, nested, _, err := client.RepoListing(ctx, &subComponents)
+		if err != nil {
+			log.Printf("submodule %s: failed to list %s/%s: %v", submodule.Path, subComponents.Owner, subComponents.Repository, err)
+			failedSubmodules = append(failedSubmodules, submodule.Path)
+			continue
+		}
+		files, _ = helpers.ExcludeNames(files, "", fetchOpts.Excludes)
+
+		subOpts := fetchOpts
+		if subOpts.OutputDir, err = helpers.OutputPath(fetchOpts.OutputDir, baseDir, submodule.Path); err != nil {
+			return err
+		}
+		fmt.Printf("[-] Submodule %s: fetching %d files of %s/%s at %s\n", submodule.Path, len(files), subComponents.Owner, subComponents.Repository, submodule.Commit)
+		if len(files) > 0 {
+			if failed, _ := downloadFiles(ctx, client, &subComponents, helpers.GroupByDirectory(files), workers, subOpts, os.Stdout, nil, false, nil, nil); len(failed) > 0 {
+				failedSubmodules = append(failedSubmodules, submodule.Path)
+			}
+		}
+
+		if len(nested) == 0 {
+			continue
+		}
+		if depth+1 >= maxSubmoduleDepth {
+			log.Printf("warning: skipping submodules of %s nested more than %d levels deep: %s", submodule.Path, maxSubmoduleDepth, submodulePaths(nested))
+			continue
+		}
+		if err := downloadSubmodules(ctx, client, subComponents, nested, workers, subOpts, depth+1); err != nil {
+			log.Printf("submodule %s: %v", submodule.Path, err)
+			failedSubmodules = append(failedSubmodules, submodule.Path)
+		}
+	}
+	if len(failedSubmodules) > 0 {
+		return fmt.Errorf("%d submodules failed: %s", len(failedSubmodules), strings.Join(failedSubmodules, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"repo-pack/gh"
+	"repo-pack/helpers"
+	"repo-pack/model"
+)
+
+// lastModifiedConcurrency bounds the commits API requests made to date files
+const lastModifiedConcurrency = 8
+
+// runTree handles `repo-pack tree [flags] <url>`, printing the remote directory without
+// downloading anything
+func runTree(args []string) error {
+	flags := flag.NewFlagSet("tree", flag.ExitOnError)
+	global := addGlobalFlags(flags)
+	noDates := flags.Bool("no-dates", false, "Skip last-modified dates, which cost one API request per file")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: repo-pack tree [--token token] [--no-dates] <url>")
+	}
+
+	repoURL, err := resolveAlias(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	components, err := helpers.ParseRepoURL(repoURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse repository URL: %v", err)
+	}
+
+	ctx := context.Background()
+	client, err := global.newClient(repoURL)
+	if err != nil {
+		return err
+	}
+
+	files, _, err := client.RepoListingSlashBranchSupport(ctx, &components)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %v", err)
+	}
+
+	modified := map[string]time.Time{}
+	if !*noDates {
+		// Dates would exhaust the anonymous limit partway through, leaving most files undated
+		if anonymousQuota(client, len(files)) {
+			modified = lastModified(ctx, client, components, files)
+		} else {
+			fmt.Println("[-] Leaving out dates, which take an API request per file; pass --token to include them")
+		}
+	}
+
+	helpers.RenderTree(os.Stdout, components.Dir, files, modified)
+	return nil
+}
+
+// lastModified looks up the last commit date of each file, leaving out files whose lookup fails
+func lastModified(ctx context.Context, client *gh.Client, components model.RepoURLComponents, files []model.FileInfo) map[string]time.Time {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	modified := make(map[string]time.Time, len(files))
+	sem := make(chan struct{}, lastModifiedConcurrency)
+
+	for _, file := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			date, err := client.LastModified(ctx, components, path)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			modified[path] = date
+			mu.Unlock()
+		}(file.Path)
+	}
+
+	wg.Wait()
+	return modified
+}